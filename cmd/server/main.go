@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"time"
@@ -14,11 +15,22 @@ import (
 	"github.com/go-ozzo/ozzo-routing/v2/content"
 	"github.com/go-ozzo/ozzo-routing/v2/cors"
 	_ "github.com/lib/pq"
+	"google.golang.org/grpc"
 	"users-balance-microservice/internal/config"
 	"users-balance-microservice/internal/deposit"
 	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/idempotency"
+	"users-balance-microservice/internal/ledger"
+	"users-balance-microservice/internal/openapi"
+	"users-balance-microservice/internal/outbox"
 	"users-balance-microservice/internal/rates"
+	"users-balance-microservice/internal/reconciliation"
+	"users-balance-microservice/internal/report"
+	"users-balance-microservice/internal/reservation"
+	"users-balance-microservice/internal/rpc"
+	"users-balance-microservice/internal/snapshot"
 	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/internal/webhook"
 	"users-balance-microservice/pkg/accesslog"
 	"users-balance-microservice/pkg/dbcontext"
 	"users-balance-microservice/pkg/log"
@@ -53,11 +65,49 @@ func main() {
 		}
 	}()
 
+	// run the reconciliation instead of the server if asked to
+	if flag.Arg(0) == "reconcile" {
+		os.Exit(runReconcile(logger, dbcontext.New(db), flag.Args()[1:]))
+	}
+
+	// create the exchange rates service shared by the HTTP and gRPC servers
+	ratesService, err := buildRatesService(logger, dbcontext.New(db), cfg)
+	if err != nil {
+		logger.Errorf("failed to create exchange rates providers: %s", err)
+		os.Exit(-1)
+	}
+
+	// start refreshing exchange rates, delivering outbox events and webhooks, taking balance snapshots and deleting
+	// expired idempotency keys in background
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ratesService.Run(ctx)
+	go buildDispatcher(logger, dbcontext.New(db), cfg).Run(ctx, cfg.OutboxInterval)
+	go buildWorker(logger, dbcontext.New(db), cfg).Run(ctx, cfg.OutboxInterval)
+	go buildSnapshotWorker(logger, dbcontext.New(db)).Run(ctx, cfg.SnapshotInterval)
+	go idempotency.NewCleaner(idempotency.NewRepository(dbcontext.New(db), logger), cfg.IdempotencyTTL, logger).Run(ctx, time.Hour)
+
+	// start the gRPC server
+	grpcServer := buildGRPCServer(logger, dbcontext.New(db), ratesService, cfg)
+	grpcAddress := fmt.Sprintf(":%v", cfg.GRPCPort)
+	listener, err := net.Listen("tcp", grpcAddress)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(-1)
+	}
+	go func() {
+		logger.Infof("gRPC server %v is running at %v", Version, grpcAddress)
+		if err := grpcServer.Serve(listener); err != nil {
+			logger.Error(err)
+		}
+	}()
+	defer grpcServer.GracefulStop()
+
 	// build HTTP server
 	address := fmt.Sprintf(":%v", cfg.ServerPort)
 	hs := &http.Server{
 		Addr:    address,
-		Handler: buildHandler(logger, dbcontext.New(db), cfg),
+		Handler: buildHandler(logger, dbcontext.New(db), ratesService, cfg),
 	}
 
 	// start the HTTP server with graceful shutdown
@@ -70,7 +120,7 @@ func main() {
 }
 
 // buildHandler sets up the HTTP routing and builds an HTTP handler.
-func buildHandler(logger log.Logger, db *dbcontext.DB, cfg *config.Config) http.Handler {
+func buildHandler(logger log.Logger, db *dbcontext.DB, ratesService *rates.Service, cfg *config.Config) http.Handler {
 	router := routing.New()
 
 	router.Use(
@@ -82,17 +132,126 @@ func buildHandler(logger log.Logger, db *dbcontext.DB, cfg *config.Config) http.
 
 	rg := router.Group("/v1")
 
+	depositService := deposit.NewService(deposit.NewRepository(db, logger), ratesService, logger)
+	ledgerService := ledger.NewService(ledger.NewRepository(db, logger), logger)
+	transactionService := transaction.NewService(
+		transaction.NewRepository(db, logger),
+		logger,
+		ledgerService,
+		outbox.NewRecorder(outbox.NewRepository(db, logger), logger),
+	)
+
 	deposit.RegisterHandlers(
 		rg.Group(""),
-		deposit.NewService(deposit.NewRepository(db, logger), rates.NewService(cfg.RatesExpiration, logger), logger),
-		transaction.NewService(transaction.NewRepository(db, logger), logger),
+		depositService,
+		transactionService,
+		idempotency.NewService(idempotency.NewRepository(db, logger), cfg.IdempotencyTTL, logger),
+		logger,
+		db.TransactionHandler(),
+		db.Transactional,
+	)
+
+	deposit.RegisterHandlersV2(router.Group("/v2"), depositService, logger)
+
+	openapi.RegisterHandlers(rg.Group(""))
+
+	reservation.RegisterHandlers(
+		rg.Group(""),
+		reservation.NewService(reservation.NewRepository(db, logger), depositService, transactionService, logger),
+		logger,
+		db.TransactionHandler(),
+	)
+
+	ledger.RegisterHandlers(rg.Group(""), ledgerService, logger)
+
+	report.RegisterHandlers(rg.Group(""), report.NewService(report.NewRepository(db, logger), logger), logger)
+
+	rates.RegisterHandlers(rg.Group(""), ratesService, logger)
+
+	webhook.RegisterHandlers(
+		rg.Group(""),
+		webhook.NewService(webhook.NewRepository(db, logger), logger),
 		logger,
 		db.TransactionHandler(),
 	)
 
+	reconciliation.RegisterHandlers(
+		rg.Group(""),
+		reconciliation.NewService(deposit.NewRepository(db, logger), transaction.NewRepository(db, logger), transactionService, db.Transactional, db.RepeatableRead, logger),
+		logger,
+	)
+
 	return router
 }
 
+// buildGRPCServer creates the gRPC server which mirrors the HTTP endpoints of deposits.
+func buildGRPCServer(logger log.Logger, db *dbcontext.DB, ratesService rates.ExchangeRatesService, cfg *config.Config) *grpc.Server {
+	ledgerService := ledger.NewService(ledger.NewRepository(db, logger), logger)
+	transactionService := transaction.NewService(
+		transaction.NewRepository(db, logger),
+		logger,
+		ledgerService,
+		outbox.NewRecorder(outbox.NewRepository(db, logger), logger),
+	)
+
+	return rpc.NewServer(
+		deposit.NewService(deposit.NewRepository(db, logger), ratesService, logger),
+		transactionService,
+		idempotency.NewService(idempotency.NewRepository(db, logger), cfg.IdempotencyTTL, logger),
+		db.Transactional,
+		logger,
+	)
+}
+
+// buildRatesService creates the exchange rates service which falls back through the providers in the configured order
+// and saves every fetched set of rates to the database.
+func buildRatesService(logger log.Logger, db *dbcontext.DB, cfg *config.Config) (*rates.Service, error) {
+	providers := make([]rates.Provider, 0, len(cfg.RatesProviders))
+	for _, providerConfig := range cfg.RatesProviders {
+		provider, err := rates.NewProvider(providerConfig)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return rates.NewService(rates.NewRepository(db, logger), cfg.RatesExpiration, cfg.RatesMaxStaleness, logger, providers...), nil
+}
+
+// buildDispatcher creates the dispatcher of outbox events. Events are always delivered to webhook subscriptions
+// and also to the webhook and the file enabled in the configuration.
+func buildDispatcher(logger log.Logger, db *dbcontext.DB, cfg *config.Config) *outbox.Dispatcher {
+	sinks := []outbox.Sink{webhook.NewSink(webhook.NewRepository(db, logger), logger)}
+	if cfg.OutboxWebhookURL != "" {
+		sinks = append(sinks, outbox.NewHTTPSink(cfg.OutboxWebhookURL, 10*time.Second))
+	}
+	if cfg.OutboxFile != "" {
+		sink, err := outbox.NewFileSink(cfg.OutboxFile)
+		if err != nil {
+			logger.Errorf("failed to open outbox file: %s", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	return outbox.NewDispatcher(outbox.NewRepository(db, logger), db.Transactional, logger, sinks...)
+}
+
+// buildWorker creates the worker which delivers events to webhook subscriptions.
+func buildWorker(logger log.Logger, db *dbcontext.DB, cfg *config.Config) *webhook.Worker {
+	return webhook.NewWorker(
+		webhook.NewRepository(db, logger),
+		db.Transactional,
+		&http.Client{Timeout: 10 * time.Second},
+		cfg.WebhookMaxAttempts,
+		cfg.WebhookBackoff,
+		logger,
+	)
+}
+
+// buildSnapshotWorker creates the worker which takes snapshots of balances.
+func buildSnapshotWorker(logger log.Logger, db *dbcontext.DB) *snapshot.Worker {
+	return snapshot.NewWorker(snapshot.NewRepository(db, logger), db.Transactional, logger)
+}
+
 // logDBQuery returns a logging function that can be used to log SQL queries.
 func logDBQuery(logger log.Logger) dbx.QueryLogFunc {
 	return func(ctx context.Context, t time.Duration, sql string, rows *sql.Rows, err error) {
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/ledger"
+	"users-balance-microservice/internal/outbox"
+	"users-balance-microservice/internal/reconciliation"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// runReconcile runs the "reconcile" subcommand and returns the exit code.
+// The report is printed to stdout as JSON. The exit code is 1 if mismatches were found and not fixed.
+func runReconcile(logger log.Logger, db *dbcontext.DB, args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "write correction transactions for the found mismatches")
+	_ = fs.Parse(args)
+
+	ledgerService := ledger.NewService(ledger.NewRepository(db, logger), logger)
+	transactionRepo := transaction.NewRepository(db, logger)
+	service := reconciliation.NewService(
+		deposit.NewRepository(db, logger),
+		transactionRepo,
+		transaction.NewService(transactionRepo, logger, ledgerService, outbox.NewRecorder(outbox.NewRepository(db, logger), logger)),
+		db.Transactional,
+		db.RepeatableRead,
+		logger,
+	)
+
+	report, err := service.Reconcile(context.Background(), *fix)
+	if err != nil {
+		logger.Errorf("reconciliation failed: %s", err)
+		return -1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.Error(err)
+		return -1
+	}
+
+	if len(report.Mismatches) > 0 && !report.Fixed {
+		return 1
+	}
+	return 0
+}
@@ -9,24 +9,66 @@ import (
 	"users-balance-microservice/pkg/log"
 )
 
-const defaultServerPort = 8080
+const (
+	defaultServerPort = 8080
+	defaultGRPCPort   = 9090
+)
 
 // Config represents an application configuration.
 type Config struct {
 	// the server port. Defaults to 8080.
 	ServerPort int `yaml:"server_port" env:"SERVER_PORT"`
+	// the gRPC server port. Defaults to 9090.
+	GRPCPort int `yaml:"grpc_port" env:"GRPC_PORT"`
 	// the expiration time of currency rates. Defaults to 10 minutes.
 	RatesExpiration time.Duration `yaml:"rates_expiration"`
+	// the maximum age of currency rates which are still used while the providers are unavailable. Defaults to 1 hour.
+	RatesMaxStaleness time.Duration `yaml:"rates_max_staleness"`
+	// the providers of currency rates in the order of fallback. Defaults to exchangerate.host, then the CBR daily feed.
+	RatesProviders []RatesProvider `yaml:"rates_providers"`
 	// the data source name (DSN) for connecting to the database. Required.
 	DSN string `yaml:"dsn"`
+	// the interval of delivering outbox events. Defaults to 1 second.
+	OutboxInterval time.Duration `yaml:"outbox_interval"`
+	// the URL of the webhook outbox events are posted to. Optional.
+	OutboxWebhookURL string `yaml:"outbox_webhook_url" env:"OUTBOX_WEBHOOK_URL"`
+	// the path to the file outbox events are appended to as NDJSON. Optional.
+	OutboxFile string `yaml:"outbox_file" env:"OUTBOX_FILE"`
+	// the number of attempts to deliver an event to a webhook subscription. Defaults to 6.
+	WebhookMaxAttempts int `yaml:"webhook_max_attempts"`
+	// the delay before the first retry of a failed webhook delivery, doubled for every next retry. Defaults to 10 seconds.
+	WebhookBackoff time.Duration `yaml:"webhook_backoff"`
+	// the interval of taking balance snapshots. Defaults to 1 hour.
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+	// the time idempotency keys are kept for, a key used again later is treated as a new one. Defaults to 24 hours.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+}
+
+// RatesProvider describes a provider of currency rates: its Kind (exchangerate_host, cbr or file) and the URL
+// or the file path rates are read from.
+type RatesProvider struct {
+	Kind string `yaml:"kind"`
+	URL  string `yaml:"url"`
+	Path string `yaml:"path"`
 }
 
 // Load returns an application configuration which is populated from the given configuration file and environment variables.
 func Load(file string, logger log.Logger) (*Config, error) {
 	// default config
 	c := Config{
-		ServerPort:      defaultServerPort,
-		RatesExpiration: 10 * time.Minute,
+		ServerPort:        defaultServerPort,
+		GRPCPort:          defaultGRPCPort,
+		RatesExpiration:   10 * time.Minute,
+		RatesMaxStaleness: time.Hour,
+		RatesProviders: []RatesProvider{
+			{Kind: "exchangerate_host"},
+			{Kind: "cbr"},
+		},
+		OutboxInterval:     time.Second,
+		WebhookMaxAttempts: 6,
+		WebhookBackoff:     10 * time.Second,
+		SnapshotInterval:   time.Hour,
+		IdempotencyTTL:     24 * time.Hour,
 	}
 
 	// load from YAML config file
@@ -1,32 +1,58 @@
 package deposit
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/idempotency"
 	"users-balance-microservice/internal/requests"
 	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/dbcontext"
 	"users-balance-microservice/pkg/log"
 )
 
 // RegisterHandlers sets up the routing of the HTTP handlers.
+// A batch of operations runs within a single DB transaction started by transactional.
 func RegisterHandlers(
 	r *routing.RouteGroup,
 	depositService Service,
 	transactionService transaction.Service,
+	idempotencyService idempotency.Service,
 	logger log.Logger,
 	transactionHandler routing.Handler,
+	transactional dbcontext.TransactionFunc,
 ) {
-	res := resource{depositService, transactionService, logger}
+	res := resource{depositService, transactionService, idempotencyService, transactional, logger}
 
 	r.Post("/deposits/balance", res.getBalance)
+	r.Post("/deposits/balances", res.getBulkBalances)
 	r.Post("/deposits/update", transactionHandler, res.updateBalance)
 	r.Post("/deposits/transfer", transactionHandler, res.transfer)
+	r.Post("/deposits/exchange", transactionHandler, res.exchange)
+	r.Post("/deposits/refund", transactionHandler, res.refund)
+	r.Post("/deposits/batch", res.batch)
 	r.Post("/deposits/history", res.history)
+	r.Post("/deposits/history/export", res.export)
+	r.Post("/admin/deposits/status", transactionHandler, res.setStatus)
+	r.Post("/admin/deposits/status/history", res.statusHistory)
+}
+
+// RegisterHandlersV2 sets up the routing of the HTTP handlers which differ in the second version of the API.
+// The second version returns amounts of money as exact decimal strings together with their currency.
+func RegisterHandlersV2(r *routing.RouteGroup, depositService Service, logger log.Logger) {
+	res := resource{depositService: depositService, logger: logger}
+
+	r.Post("/deposits/balance", res.getBalanceV2)
 }
 
 type resource struct {
 	depositService     Service
 	transactionService transaction.Service
+	idempotencyService idempotency.Service
+	transactional      dbcontext.TransactionFunc
 	logger             log.Logger
 }
 
@@ -37,6 +63,64 @@ func (r resource) getBalance(c *routing.Context) error {
 		return errors.BadRequest("")
 	}
 
+	if len(input.Currencies) > 0 {
+		balances, err := r.depositService.GetBalances(c.Request.Context(), input)
+		if err != nil {
+			return err
+		}
+		// the first version returns bare JSON numbers as amounts
+		result := make(map[string]interface{}, len(balances))
+		for _, balance := range balances {
+			if balance.Error != "" {
+				result[balance.Currency] = map[string]string{"error": balance.Error}
+			} else {
+				result[balance.Currency] = map[string]interface{}{"amount": balance.Balance.Number()}
+			}
+		}
+		return c.Write(result)
+	}
+
+	balance, err := r.depositService.GetBalance(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	// the first version returns a bare JSON number, which is kept exact
+	return c.Write(balance.Number())
+}
+
+func (r resource) getBulkBalances(c *routing.Context) error {
+	var input requests.GetBalancesRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	balances, err := r.depositService.GetBulkBalances(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(balances)
+}
+
+func (r resource) getBalanceV2(c *routing.Context) error {
+	var input requests.GetBalanceRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	if len(input.Currencies) > 0 {
+		balances, err := r.depositService.GetBalances(c.Request.Context(), input)
+		if err != nil {
+			return err
+		}
+		result := make(map[string]ConvertedBalance, len(balances))
+		for _, balance := range balances {
+			result[balance.Currency] = balance
+		}
+		return c.Write(result)
+	}
+
 	balance, err := r.depositService.GetBalance(c.Request.Context(), input)
 	if err != nil {
 		return err
@@ -51,6 +135,14 @@ func (r resource) updateBalance(c *routing.Context) error {
 		return errors.BadRequest("")
 	}
 
+	key := idempotencyKey(c, &input.IdempotencyKey)
+	if tx, ok, err := r.replay(c, key, input); err != nil || ok {
+		if err != nil {
+			return err
+		}
+		return c.Write(tx)
+	}
+
 	err := r.depositService.Update(c.Request.Context(), input)
 	if err != nil {
 		return err
@@ -59,6 +151,9 @@ func (r resource) updateBalance(c *routing.Context) error {
 	if err != nil {
 		return err
 	}
+	if err = r.complete(c, key, tx.Id); err != nil {
+		return err
+	}
 	return c.Write(tx)
 }
 
@@ -69,6 +164,14 @@ func (r resource) transfer(c *routing.Context) error {
 		return errors.BadRequest("")
 	}
 
+	key := idempotencyKey(c, &input.IdempotencyKey)
+	if tx, ok, err := r.replay(c, key, input); err != nil || ok {
+		if err != nil {
+			return err
+		}
+		return c.Write(tx)
+	}
+
 	err := r.depositService.Transfer(c.Request.Context(), input)
 	if err != nil {
 		return err
@@ -77,9 +180,121 @@ func (r resource) transfer(c *routing.Context) error {
 	if err != nil {
 		return err
 	}
+	if err = r.complete(c, key, tx.Id); err != nil {
+		return err
+	}
 	return c.Write(tx)
 }
 
+func (r resource) exchange(c *routing.Context) error {
+	var input requests.ExchangeRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	result, err := r.depositService.Exchange(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	txs, err := r.transactionService.CreateExchangeTransactions(c.Request.Context(), input, result.Credited, result.Rate, result.RateId)
+	if err != nil {
+		return err
+	}
+	return c.Write(txs)
+}
+
+func (r resource) refund(c *routing.Context) error {
+	var input requests.RefundRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	key := idempotencyKey(c, &input.IdempotencyKey)
+	if tx, ok, err := r.replay(c, key, input); err != nil || ok {
+		if err != nil {
+			return err
+		}
+		return c.Write(tx)
+	}
+
+	original, refundable, err := r.transactionService.GetRefundable(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	amount, err := r.depositService.Refund(c.Request.Context(), input, original.Transaction, refundable)
+	if err != nil {
+		return err
+	}
+	tx, err := r.transactionService.CreateRefundTransaction(c.Request.Context(), input, original.Transaction, amount)
+	if err != nil {
+		return err
+	}
+	if err = r.complete(c, key, tx.Id); err != nil {
+		return err
+	}
+	return c.Write(tx)
+}
+
+func (r resource) batch(c *routing.Context) error {
+	var input requests.BatchRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	// either every operation is done or the whole batch is rolled back
+	txs := make([]transaction.Transaction, 0, len(input.Operations))
+	err := r.transactional(c.Request.Context(), func(ctx context.Context) error {
+		for i, op := range input.Operations {
+			tx, err := r.runOperation(ctx, op)
+			if err != nil {
+				return batchError(i, err)
+			}
+			txs = append(txs, tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.Write(txs)
+}
+
+// runOperation performs a single operation of a batch and creates its Transaction.
+func (r resource) runOperation(ctx context.Context, op requests.BatchOperation) (transaction.Transaction, error) {
+	if op.Transfer != nil {
+		if err := r.depositService.Transfer(ctx, *op.Transfer); err != nil {
+			return transaction.Transaction{}, err
+		}
+		return r.transactionService.CreateTransferTransaction(ctx, *op.Transfer)
+	}
+	if err := r.depositService.Update(ctx, *op.Update); err != nil {
+		return transaction.Transaction{}, err
+	}
+	return r.transactionService.CreateUpdateTransaction(ctx, *op.Update)
+}
+
+// batchError names the index of the failed operation of a batch in the error. Internal errors are only wrapped,
+// so that they are logged with the index but not shown to the client.
+func batchError(index int, err error) error {
+	var res errors.ErrorResponse
+	switch e := err.(type) {
+	case errors.ErrorResponse:
+		res = e
+	case validation.Errors:
+		res = errors.InvalidInput(e)
+	default:
+		return fmt.Errorf("operation %d: %w", index, err)
+	}
+	res.Message = fmt.Sprintf("Operation %d failed: %s", index, res.Message)
+	return res
+}
+
 func (r resource) history(c *routing.Context) error {
 	var input requests.GetHistoryRequest
 	if err := c.Read(&input); err != nil {
@@ -87,9 +302,110 @@ func (r resource) history(c *routing.Context) error {
 		return errors.BadRequest("")
 	}
 
+	if input.Cursor != nil {
+		page, err := r.transactionService.GetHistoryPage(c.Request.Context(), input)
+		if err != nil {
+			return err
+		}
+		return c.Write(page)
+	}
+
 	transactions, err := r.transactionService.GetHistory(c.Request.Context(), input)
 	if err != nil {
 		return err
 	}
 	return c.Write(transactions)
 }
+
+func (r resource) export(c *routing.Context) error {
+	var input requests.GetHistoryRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	format, ok := exportFormat(c.Request)
+	if !ok {
+		return errors.NotAcceptable("The history can be exported as text/csv or application/x-ndjson.")
+	}
+
+	w := newExportWriter(c.Response, format, "history-"+input.OwnerId)
+	err := r.transactionService.ExportHistory(c.Request.Context(), input, w.Write)
+	if err != nil {
+		if !w.started {
+			return err
+		}
+		// the response is already being sent, so the error can only be logged
+		r.logger.With(c.Request.Context()).Errorf("failed streaming history export: %v", err)
+		return nil
+	}
+	return w.Flush()
+}
+
+func (r resource) setStatus(c *routing.Context) error {
+	var input requests.SetStatusRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	change, err := r.depositService.SetStatus(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(change)
+}
+
+func (r resource) statusHistory(c *routing.Context) error {
+	var input requests.StatusHistoryRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	changes, err := r.depositService.GetStatusHistory(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(changes)
+}
+
+// replay registers the idempotency key of the request. In case the request is a retry of an already processed one,
+// replay returns the Transaction created by the original request.
+func (r resource) replay(c *routing.Context, key string, req interface{}) (transaction.Transaction, bool, error) {
+	if key == "" {
+		return transaction.Transaction{}, false, nil
+	}
+
+	ctx := c.Request.Context()
+	txId, replay, err := r.idempotencyService.Begin(ctx, key, idempotency.Fingerprint(c.Request.URL.Path, req))
+	if err != nil || !replay {
+		return transaction.Transaction{}, false, err
+	}
+
+	tx, err := r.transactionService.Get(ctx, txId)
+	if err != nil {
+		return transaction.Transaction{}, false, err
+	}
+	c.Response.Header().Set("Idempotent-Replayed", "true")
+	return tx, true, nil
+}
+
+// complete saves the Transaction created by the request with the given idempotency key.
+func (r resource) complete(c *routing.Context, key string, transactionId int64) error {
+	if key == "" {
+		return nil
+	}
+	return r.idempotencyService.Complete(c.Request.Context(), key, transactionId)
+}
+
+// idempotencyKey returns the idempotency key of the request and removes it from the request's field,
+// so that it does not affect the request's fingerprint. The header takes precedence over the field.
+func idempotencyKey(c *routing.Context, field *string) string {
+	key := *field
+	*field = ""
+	if header := c.Request.Header.Get(idempotency.Header); header != "" {
+		key = header
+	}
+	return key
+}
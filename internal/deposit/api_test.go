@@ -2,12 +2,18 @@ package deposit
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	routing "github.com/go-ozzo/ozzo-routing/v2"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
 	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/idempotency"
 	"users-balance-microservice/internal/test"
 	"users-balance-microservice/internal/transaction"
 	"users-balance-microservice/pkg/log"
@@ -19,9 +25,16 @@ const badRequestResponse = `{"status":400,"message":"Your request is in a bad fo
 func TestAPI(t *testing.T) {
 	logger, _ := log.NewForTest()
 	router := test.MockRouter(logger)
-	depositRepo := &mockDepositRepository{
-		items: []entity.Deposit{
-			{uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), 1000},
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), Balance: 100000},
+		},
+		History: []entity.BalanceSnapshot{
+			{
+				OwnerId:         uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"),
+				TransactionDate: time.Date(2021, 11, 10, 11, 0, 0, 0, time.UTC),
+				Balance:         40000,
+			},
 		},
 	}
 	transactionRepo := mockTransactionRepository{
@@ -29,15 +42,20 @@ func TestAPI(t *testing.T) {
 	}
 	exchangeService := mockExchangeRatesService{}
 	transactionHandler := func(c *routing.Context) error { return c.Next() }
+	transactional := func(ctx context.Context, f func(ctx context.Context) error) error { return f(ctx) }
 
 	RegisterHandlers(
 		router.Group(""),
 		NewService(depositRepo, exchangeService, logger),
 		transaction.NewService(&transactionRepo, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
 		logger,
 		transactionHandler,
+		transactional,
 	)
 
+	RegisterHandlersV2(router.Group("/v2"), NewService(depositRepo, exchangeService, logger), logger)
+
 	tests := []test.APITestCase{
 		{
 			"get balance success existing Deposit",
@@ -55,6 +73,113 @@ func TestAPI(t *testing.T) {
 			http.StatusOK,
 			`0`,
 		},
+		{
+			"get balance success in another currency",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "currency": "USD"}`,
+			http.StatusOK,
+			`100`,
+		},
+		{
+			"get balance v2 success",
+			"POST",
+			"/v2/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`{"amount":"1000.00","currency":"RUB"}`,
+		},
+		{
+			"get balance v2 success in another currency",
+			"POST",
+			"/v2/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "currency": "USD", "rounding": "half_even"}`,
+			http.StatusOK,
+			`{"amount":"100.00","currency":"USD","rate_timestamp":"2021-11-10T09:00:00Z","rate_id":42}`,
+		},
+		{
+			"get balance success in several currencies",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "currencies": ["RUB", "USD", "KWD"]}`,
+			http.StatusOK,
+			`{"RUB":{"amount":1000},"USD":{"amount":100},"KWD":{"error":"Requested currency is not available at the moment."}}`,
+		},
+		{
+			"get balance v2 success in several currencies",
+			"POST",
+			"/v2/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "currencies": ["RUB", "USD", "KWD"]}`,
+			http.StatusOK,
+			`{"RUB":{"amount":"1000.00","currency":"RUB"},` +
+				`"USD":{"amount":"100.00","currency":"USD","rate_timestamp":"2021-11-10T09:00:00Z","rate_id":42},` +
+				`"KWD":{"currency":"KWD","error":"Requested currency is not available at the moment."}}`,
+		},
+		{
+			"get balance failure both currency and currencies",
+			"POST",
+			"/v2/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "currency": "USD", "currencies": ["EUR"]}`,
+			http.StatusBadRequest,
+			`*"field":"currency"*`,
+		},
+		{
+			"get bulk balances success",
+			"POST",
+			"/deposits/balances",
+			`{"owner_ids": ["615f3e76-37d3-11ec-8d3d-0242ac130003", "8c5593a0-37d3-11ec-8d3d-0242ac130003"]}`,
+			http.StatusOK,
+			`{"balances":{"615f3e76-37d3-11ec-8d3d-0242ac130003":1000,"8c5593a0-37d3-11ec-8d3d-0242ac130003":0}}`,
+		},
+		{
+			"get bulk balances success in another currency reporting missing",
+			"POST",
+			"/deposits/balances",
+			`{"owner_ids": ["615f3e76-37d3-11ec-8d3d-0242ac130003", "8c5593a0-37d3-11ec-8d3d-0242ac130003"], "currency": "USD", "report_missing": true}`,
+			http.StatusOK,
+			`{"balances":{"615f3e76-37d3-11ec-8d3d-0242ac130003":100},"missing":["8c5593a0-37d3-11ec-8d3d-0242ac130003"],` +
+				`"rate_timestamp":"2021-11-10T09:00:00Z","rate_id":42}`,
+		},
+		{
+			"get bulk balances failure invalid owner_ids",
+			"POST",
+			"/deposits/balances",
+			`{"owner_ids": ["615f3e76-37d3-11ec-8d3d-0242ac130003", "0123"]}`,
+			http.StatusBadRequest,
+			`*"field":"owner_ids"*`,
+		},
+		{
+			"get bulk balances failure missing owner_ids",
+			"POST",
+			"/deposits/balances",
+			`{}`,
+			http.StatusBadRequest,
+			`*"field":"owner_ids"*`,
+		},
+		{
+			"get balance v2 failure invalid rounding",
+			"POST",
+			"/v2/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "rounding": "ceil"}`,
+			http.StatusBadRequest,
+			`*"field":"rounding"*`,
+		},
+		{
+			"get balance success at a point in time",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "at": "2021-11-10T12:00:00Z"}`,
+			http.StatusOK,
+			`400`,
+		},
+		{
+			"get balance failure at in the future",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003", "at": "2999-01-01T00:00:00Z"}`,
+			http.StatusBadRequest,
+			`*"field":"at","error":"must not be in the future."*`,
+		},
 		{
 			"get balance failure invalid owner_id",
 			"POST",
@@ -95,6 +220,30 @@ func TestAPI(t *testing.T) {
 			http.StatusOK,
 			"",
 		},
+		{
+			"update balance success amount with kopecks",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":10.05}`,
+			http.StatusOK,
+			`*"amount":10.05}*`,
+		},
+		{
+			"update balance success negative amount with kopecks",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":-10.05}`,
+			http.StatusOK,
+			`*"sender_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"*`,
+		},
+		{
+			"update balance failure amount smaller than a kopeck",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":0.001}`,
+			http.StatusBadRequest,
+			"",
+		},
 		{
 			"update balance failure not enough funds",
 			"POST",
@@ -151,6 +300,46 @@ func TestAPI(t *testing.T) {
 			http.StatusOK,
 			"",
 		},
+		{
+			"getHistory by cursor success first page",
+			"POST",
+			"/deposits/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","limit":1,"cursor":""}`,
+			http.StatusOK,
+			`*"next_cursor":"*`,
+		},
+		{
+			"getHistory by cursor fail invalid cursor",
+			"POST",
+			"/deposits/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","cursor":"123"}`,
+			http.StatusBadRequest,
+			`{"status":400,"message":"There is some problem with the data you submitted.","details":[{"field":"cursor","error":"must be a cursor returned with the previous page."}]}`,
+		},
+		{
+			"getHistory by cursor fail with offset",
+			"POST",
+			"/deposits/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","offset":1,"cursor":""}`,
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"getHistory with filters success",
+			"POST",
+			"/deposits/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","from":"2021-03-01T00:00:00+03:00","to":"2021-04-01T00:00:00+03:00","operation":"transfer_out","min_amount":1000}`,
+			http.StatusOK,
+			"",
+		},
+		{
+			"getHistory fail invalid filters",
+			"POST",
+			"/deposits/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","operation":"chargeback","min_amount":1000,"max_amount":500}`,
+			http.StatusBadRequest,
+			`{"status":400,"message":"There is some problem with the data you submitted.","details":[{"field":"max_amount","error":"must be no less than min_amount."},{"field":"operation","error":"must be a valid value"}]}`,
+		},
 		{
 			"getHistory fail invalid owner_id",
 			"POST",
@@ -167,18 +356,675 @@ func TestAPI(t *testing.T) {
 			http.StatusBadRequest,
 			"",
 		},
+		{
+			"update balance with idempotency key success",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":200,"idempotency_key":"key-1"}`,
+			http.StatusOK,
+			`*"amount":200*`,
+		},
+		{
+			"update balance with idempotency key replay",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":200,"idempotency_key":"key-1"}`,
+			http.StatusOK,
+			`*"amount":200*`,
+		},
+		{
+			"get balance after replay is changed only once",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id": "615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`1100`,
+		},
+		{
+			"update balance with idempotency key failure different payload",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":300,"idempotency_key":"key-1"}`,
+			http.StatusConflict,
+			`{"status":409,"message":"Idempotency key was already used with a different request."}`,
+		},
+		{
+			"transfer with idempotency key failure key used by another endpoint",
+			"POST",
+			"/deposits/transfer",
+			`{"sender_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","recipient_id":"11111111-37d3-11ec-8d3d-0242ac130003","amount":200,"idempotency_key":"key-1"}`,
+			http.StatusConflict,
+			"",
+		},
+	}
+
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
 	}
 
+	t.Run("transfer with idempotency key header replay", func(t *testing.T) {
+		body := `{"sender_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","recipient_id":"11111111-37d3-11ec-8d3d-0242ac130003","amount":100}`
+		var responses []string
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("POST", "/deposits/transfer", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(idempotency.Header, "key-2")
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+			assert.Equal(t, http.StatusOK, res.Code)
+			responses = append(responses, res.Body.String())
+		}
+		assert.Equal(t, responses[0], responses[1])
+		assert.Equal(t, 100000, int(depositRepo.Items[0].Balance))
+	})
+}
+
+func TestAPI_Exchange(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := test.MockRouter(logger)
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), Currency: "RUB", Balance: 100000},
+		},
+	}
+	RegisterHandlers(
+		router.Group(""),
+		NewService(depositRepo, mockExchangeRatesService{}, logger),
+		transaction.NewService(&mockTransactionRepository{}, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
+		logger,
+		func(c *routing.Context) error { return c.Next() },
+		func(ctx context.Context, f func(ctx context.Context) error) error { return f(ctx) },
+	)
+
+	tests := []test.APITestCase{
+		{
+			"exchange success",
+			"POST",
+			"/deposits/exchange",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","from":"RUB","to":"USD","amount":100}`,
+			http.StatusOK,
+			`*"exchange_rate":"0.1","rate_id":42,"amount":10}]*`,
+		},
+		{
+			"get balance of the wallet in another currency",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","wallet":"USD"}`,
+			http.StatusOK,
+			`10`,
+		},
+		{
+			"get balance of the wallet converted to the base currency",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","wallet":"USD","currency":"RUB"}`,
+			http.StatusOK,
+			`100`,
+		},
+		{
+			"get balance of the base wallet after exchange",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`900`,
+		},
+		{
+			"exchange failure insufficient funds",
+			"POST",
+			"/deposits/exchange",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","from":"USD","to":"RUB","amount":11}`,
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"exchange failure amount too small",
+			"POST",
+			"/deposits/exchange",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","from":"RUB","to":"USD","amount":0.09}`,
+			http.StatusBadRequest,
+			`{"status":400,"message":"The amount is too small to be exchanged."}`,
+		},
+		{
+			"exchange failure same currency",
+			"POST",
+			"/deposits/exchange",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","from":"USD","to":"USD","amount":1}`,
+			http.StatusBadRequest,
+			`*"field":"to"*`,
+		},
+		{
+			"exchange failure invalid request",
+			"POST",
+			"/deposits/exchange",
+			`{"owner_id":`,
+			http.StatusBadRequest,
+			badRequestResponse,
+		},
+	}
 	for _, tc := range tests {
 		test.Endpoint(t, router, tc)
 	}
 }
 
+func TestAPI_Refund(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := test.MockRouter(logger)
+	id1 := uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003")
+	serviceId, reservationId := int64(5), int64(1)
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: id1, Balance: 100000},
+		},
+	}
+	transactionRepo := mockTransactionRepository{
+		items: []entity.Transaction{
+			{Id: 1, SenderId: id1, Amount: 30000, Currency: "RUB", Description: "subscription", ServiceId: &serviceId},
+			{Id: 2, SenderId: id1, Amount: 10000, Currency: "RUB", Type: entity.TransactionTypeHold, ReservationId: &reservationId},
+		},
+		lastInsertedId: 3,
+	}
+	RegisterHandlers(
+		router.Group(""),
+		NewService(depositRepo, mockExchangeRatesService{}, logger),
+		transaction.NewService(&transactionRepo, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
+		logger,
+		func(c *routing.Context) error { return c.Next() },
+		func(ctx context.Context, f func(ctx context.Context) error) error { return f(ctx) },
+	)
+
+	tests := []test.APITestCase{
+		{
+			"refund partial success",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":1,"amount":100,"description":"order cancelled","idempotency_key":"refund-1"}`,
+			http.StatusOK,
+			`*"refund_of":1,"amount":100}*`,
+		},
+		{
+			"refund with idempotency key replay",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":1,"amount":100,"description":"order cancelled","idempotency_key":"refund-1"}`,
+			http.StatusOK,
+			`*"type":"refund","service_id":5,"refund_of":1,"amount":100}*`,
+		},
+		{
+			"get balance after refund is changed only once",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`1100`,
+		},
+		{
+			"refund failure more than the remaining amount",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":1,"amount":201}`,
+			http.StatusConflict,
+			`{"status":409,"message":"Only 200.00 of the transaction can be refunded."}`,
+		},
+		{
+			"refund failure amount smaller than a kopeck",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":1,"amount":0.001}`,
+			http.StatusBadRequest,
+			`*"field":"amount","error":"must have at most 2 fractional digits."*`,
+		},
+		{
+			"refund of the remaining amount success",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":1}`,
+			http.StatusOK,
+			`*"amount":200*`,
+		},
+		{
+			"refund failure already refunded",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":1}`,
+			http.StatusConflict,
+			`{"status":409,"message":"Transaction is already refunded."}`,
+		},
+		{
+			"get balance after full refund",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`1300`,
+		},
+		{
+			"refund failure hold",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":2}`,
+			http.StatusConflict,
+			`{"status":409,"message":"Only top-ups, withdrawals, transfers and captures can be refunded."}`,
+		},
+		{
+			"refund failure missing transaction",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":100}`,
+			http.StatusNotFound,
+			`{"status":404,"message":"Transaction not found."}`,
+		},
+		{
+			"refund failure missing transaction_id",
+			"POST",
+			"/deposits/refund",
+			`{"amount":100}`,
+			http.StatusBadRequest,
+			`*"field":"transaction_id"*`,
+		},
+		{
+			"refund failure invalid request",
+			"POST",
+			"/deposits/refund",
+			`{"transaction_id":`,
+			http.StatusBadRequest,
+			badRequestResponse,
+		},
+	}
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+}
+
+func TestAPI_Batch(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := test.MockRouter(logger)
+	id1 := uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003")
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: id1, Balance: 100000},
+		},
+	}
+	transactionRepo := mockTransactionRepository{
+		items:          []entity.Transaction{},
+		lastInsertedId: 1,
+	}
+	// transactional restores the state of the mock repositories if the batch fails, as a rollback does
+	var rollbacks int
+	transactional := func(ctx context.Context, f func(ctx context.Context) error) error {
+		deposits := append([]entity.Deposit(nil), depositRepo.Items...)
+		transactions, lastInsertedId := append([]entity.Transaction(nil), transactionRepo.items...), transactionRepo.lastInsertedId
+		err := f(ctx)
+		if err != nil {
+			rollbacks++
+			depositRepo.Items = deposits
+			transactionRepo.items, transactionRepo.lastInsertedId = transactions, lastInsertedId
+		}
+		return err
+	}
+	RegisterHandlers(
+		router.Group(""),
+		NewService(depositRepo, mockExchangeRatesService{}, logger),
+		transaction.NewService(&transactionRepo, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
+		logger,
+		func(c *routing.Context) error { return c.Next() },
+		transactional,
+	)
+
+	tests := []test.APITestCase{
+		{
+			"batch success",
+			"POST",
+			"/deposits/batch",
+			`{"operations":[{"update":{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":-300}},{"transfer":{"sender_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","recipient_id":"8c5593a0-37d3-11ec-8d3d-0242ac130003","amount":200,"description":"dinner"}}]}`,
+			http.StatusOK,
+			`*"description":"dinner"*`,
+		},
+		{
+			"get balance after batch",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`500`,
+		},
+		{
+			"batch failure insufficient funds",
+			"POST",
+			"/deposits/batch",
+			`{"operations":[{"update":{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":-400}},{"update":{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":-400}}]}`,
+			http.StatusForbidden,
+			`{"status":403,"message":"Operation 1 failed: Insufficient funds to perform operation."}`,
+		},
+		{
+			"get balance after failed batch is not changed",
+			"POST",
+			"/deposits/balance",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`500`,
+		},
+		{
+			"batch failure database error",
+			"POST",
+			"/deposits/batch",
+			`{"operations":[{"update":{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":100}},{"update":{"owner_id":"11111111-1111-1111-1111-111111111111","amount":100}}]}`,
+			http.StatusInternalServerError,
+			`*"status":500*`,
+		},
+		{
+			"batch failure invalid operation",
+			"POST",
+			"/deposits/batch",
+			`{"operations":[{"update":{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":100}},{"transfer":{"sender_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":100}}]}`,
+			http.StatusBadRequest,
+			`*"field":"operations","error":"1: (transfer: (recipient_id: cannot be blank.).)."*`,
+		},
+		{
+			"batch failure no operations",
+			"POST",
+			"/deposits/batch",
+			`{"operations":[]}`,
+			http.StatusBadRequest,
+			`*"field":"operations","error":"cannot be blank"*`,
+		},
+		{
+			"batch failure invalid request",
+			"POST",
+			"/deposits/batch",
+			`{"operations":`,
+			http.StatusBadRequest,
+			badRequestResponse,
+		},
+	}
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+	assert.Equal(t, 2, rollbacks)
+	if assert.Len(t, transactionRepo.items, 2) {
+		assert.Equal(t, int64(30000), transactionRepo.items[0].Amount)
+		assert.Equal(t, int64(20000), transactionRepo.items[1].Amount)
+	}
+}
+
+func TestAPI_Status(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := test.MockRouter(logger)
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), Balance: 100000},
+		},
+	}
+	RegisterHandlers(
+		router.Group(""),
+		NewService(depositRepo, mockExchangeRatesService{}, logger),
+		transaction.NewService(&mockTransactionRepository{}, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
+		logger,
+		func(c *routing.Context) error { return c.Next() },
+		func(ctx context.Context, f func(ctx context.Context) error) error { return f(ctx) },
+	)
+
+	tests := []test.APITestCase{
+		{
+			"freeze deposit success",
+			"POST",
+			"/admin/deposits/status",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","status":"frozen","reason":"fraud case #12"}`,
+			http.StatusOK,
+			`{"id":1,"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","currency":"RUB","previous_status":"active","status":"frozen","reason":"fraud case #12",*`,
+		},
+		{
+			"top-up of frozen deposit success",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":100}`,
+			http.StatusOK,
+			`*"amount":100*`,
+		},
+		{
+			"withdrawal from frozen deposit failure",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":-100}`,
+			http.StatusForbidden,
+			`{"status":403,"message":"Deposit is frozen, money cannot be debited from it."}`,
+		},
+		{
+			"block deposit success",
+			"POST",
+			"/admin/deposits/status",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","status":"blocked","reason":"court order"}`,
+			http.StatusOK,
+			`*"previous_status":"frozen","status":"blocked"*`,
+		},
+		{
+			"top-up of blocked deposit failure",
+			"POST",
+			"/deposits/update",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":100}`,
+			http.StatusForbidden,
+			`{"status":403,"message":"Deposit is blocked, its balance cannot be changed."}`,
+		},
+		{
+			"close deposit with money failure",
+			"POST",
+			"/admin/deposits/status",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","status":"closed","reason":"user request"}`,
+			http.StatusConflict,
+			`{"status":409,"message":"Only a deposit with zero balance can be closed."}`,
+		},
+		{
+			"set status failure missing reason",
+			"POST",
+			"/admin/deposits/status",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","status":"active"}`,
+			http.StatusBadRequest,
+			`{"status":400,"message":"There is some problem with the data you submitted.","details":[{"field":"reason","error":"cannot be blank"}]}`,
+		},
+		{
+			"set status failure invalid request",
+			"POST",
+			"/admin/deposits/status",
+			`{"owner_id":`,
+			http.StatusBadRequest,
+			badRequestResponse,
+		},
+		{
+			"status history success",
+			"POST",
+			"/admin/deposits/status/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`,
+			http.StatusOK,
+			`[{"id":1,*`,
+		},
+		{
+			"status history of another currency is empty",
+			"POST",
+			"/admin/deposits/status/history",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","currency":"USD"}`,
+			http.StatusOK,
+			`[]`,
+		},
+		{
+			"status history failure invalid owner_id",
+			"POST",
+			"/admin/deposits/status/history",
+			`{"owner_id":"615f3e76"}`,
+			http.StatusBadRequest,
+			invalidIdResponse,
+		},
+	}
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+	if assert.Len(t, depositRepo.StatusChanges, 2) {
+		assert.Equal(t, "court order", depositRepo.StatusChanges[1].Reason)
+	}
+}
+
+func TestAPI_Export(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := test.MockRouter(logger)
+	id1, id2 := uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), uuid.MustParse("8c5593a0-37d3-11ec-8d3d-0242ac130003")
+	serviceId := int64(5)
+	date := time.Date(2021, 11, 10, 14, 23, 11, 0, time.UTC)
+	transactionRepo := mockTransactionRepository{
+		items: []entity.Transaction{
+			{Id: 1, RecipientId: id1, Amount: 500000, Currency: "RUB", Description: "VISA top-up", TransactionDate: date},
+			{Id: 2, SenderId: id1, RecipientId: id2, Amount: 30000, Currency: "RUB", Description: `"happy", birthday!`, TransactionDate: date.Add(time.Minute)},
+			{Id: 3, SenderId: id1, Amount: 10000, Currency: "RUB", Description: "subscription", TransactionDate: date.Add(time.Hour), ServiceId: &serviceId},
+			{Id: 4, SenderId: id1, Amount: 1000, Currency: "USD", TransactionDate: date.Add(2 * time.Hour), Type: entity.TransactionTypeExchange, ExchangeRate: "0.013", RateId: &rateId},
+		},
+	}
+	RegisterHandlers(
+		router.Group(""),
+		NewService(&test.DepositRepository{}, mockExchangeRatesService{}, logger),
+		transaction.NewService(&transactionRepo, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
+		logger,
+		func(c *routing.Context) error { return c.Next() },
+		func(ctx context.Context, f func(ctx context.Context) error) error { return f(ctx) },
+	)
+
+	export := func(body, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/deposits/history/export", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("export csv by default", func(t *testing.T) {
+		res := export(`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`, "*/*")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="history-615f3e76-37d3-11ec-8d3d-0242ac130003.csv"`, res.Header().Get("Content-Disposition"))
+		assert.Equal(t, "id,sender_id,recipient_id,amount,currency,description,transaction_date,type,reservation_id,service_id,order_id,exchange_rate,rate_id,refund_of\n"+
+			"1,,615f3e76-37d3-11ec-8d3d-0242ac130003,5000,RUB,VISA top-up,2021-11-10T14:23:11Z,,,,,,,\n"+
+			"2,615f3e76-37d3-11ec-8d3d-0242ac130003,8c5593a0-37d3-11ec-8d3d-0242ac130003,300,RUB,\"\"\"happy\"\", birthday!\",2021-11-10T14:24:11Z,,,,,,,\n"+
+			"3,615f3e76-37d3-11ec-8d3d-0242ac130003,,100,RUB,subscription,2021-11-10T15:23:11Z,,,5,,,,\n", res.Body.String())
+	})
+
+	t.Run("export csv in another currency", func(t *testing.T) {
+		res := export(`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","currency":"USD"}`, "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, strings.Join(exportHeader, ",")+"\n"+
+			"4,615f3e76-37d3-11ec-8d3d-0242ac130003,,10,USD,,2021-11-10T16:23:11Z,exchange,,,,0.013,42,\n", res.Body.String())
+	})
+
+	t.Run("export ndjson", func(t *testing.T) {
+		res := export(`{"owner_id":"8c5593a0-37d3-11ec-8d3d-0242ac130003"}`, "application/x-ndjson")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "application/x-ndjson", res.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="history-8c5593a0-37d3-11ec-8d3d-0242ac130003.ndjson"`, res.Header().Get("Content-Disposition"))
+		assert.Equal(t, `{"id":2,"sender_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","recipient_id":"8c5593a0-37d3-11ec-8d3d-0242ac130003","currency":"RUB","description":"\"happy\", birthday!","transaction_date":"2021-11-10T14:24:11Z","amount":300}`+"\n", res.Body.String())
+	})
+
+	t.Run("export empty history has csv header", func(t *testing.T) {
+		res := export(`{"owner_id":"`+uuid.NewString()+`"}`, "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, strings.Join(exportHeader, ",")+"\n", res.Body.String())
+	})
+
+	t.Run("export fail unsupported format", func(t *testing.T) {
+		res := export(`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003"}`, "application/json")
+		assert.Equal(t, http.StatusNotAcceptable, res.Code)
+	})
+
+	t.Run("export fail invalid request", func(t *testing.T) {
+		res := export(`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","limit":10}`, "text/csv")
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Empty(t, res.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("export database error after streaming started", func(t *testing.T) {
+		failing := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+		transactionRepo.items = append(transactionRepo.items, entity.Transaction{Id: 5, RecipientId: failing, Amount: 1000, TransactionDate: date})
+		res := export(`{"owner_id":"11111111-1111-1111-1111-111111111111"}`, "application/x-ndjson")
+		// the response is already started, so the error is only logged
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "application/x-ndjson", res.Header().Get("Content-Type"))
+		assert.NotContains(t, res.Body.String(), `"status":500`)
+	})
+}
+
+type mockIdempotencyRepository struct {
+	items []entity.IdempotencyKey
+}
+
+func (m *mockIdempotencyRepository) Get(ctx context.Context, key string) (entity.IdempotencyKey, error) {
+	for _, item := range m.items {
+		if item.Key == key {
+			return item, nil
+		}
+	}
+	return entity.IdempotencyKey{}, sql.ErrNoRows
+}
+
+func (m *mockIdempotencyRepository) Create(ctx context.Context, key entity.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	for i, item := range m.items {
+		if item.Key == key.Key {
+			if !item.CreatedAt.Before(expiredBefore) {
+				return false, nil
+			}
+			m.items[i] = key
+			return true, nil
+		}
+	}
+	m.items = append(m.items, key)
+	return true, nil
+}
+
+func (m *mockIdempotencyRepository) Update(ctx context.Context, key entity.IdempotencyKey) error {
+	for i, item := range m.items {
+		if item.Key == key.Key {
+			m.items[i] = key
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
 type mockTransactionRepository struct {
 	items          []entity.Transaction
 	lastInsertedId int64
 }
 
+func (m *mockTransactionRepository) Get(ctx context.Context, id int64) (entity.Transaction, error) {
+	for _, tx := range m.items {
+		if tx.Id == id {
+			return tx, nil
+		}
+	}
+	return entity.Transaction{}, sql.ErrNoRows
+}
+
+// Lock does not lock anything, as the mock is not used concurrently
+func (m *mockTransactionRepository) Lock(ctx context.Context, id int64) (entity.Transaction, error) {
+	return m.Get(ctx, id)
+}
+
+func (m *mockTransactionRepository) RefundedAmount(ctx context.Context, id int64) (int64, error) {
+	var refunded int64
+	for _, tx := range m.items {
+		if tx.RefundOf != nil && *tx.RefundOf == id {
+			refunded += tx.Amount
+		}
+	}
+	return refunded, nil
+}
+
 func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Transaction) error {
 	if tx.Amount < 0 {
 		return databaseError
@@ -191,7 +1037,7 @@ func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Trans
 }
 
 // Offset, limit and order are ignored for simplicity
-func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
+func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
 	var result []entity.Transaction
 
 	for _, tx := range m.items {
@@ -203,6 +1049,41 @@ func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid
 	return result, nil
 }
 
+// Order is ignored for simplicity, transactions are paged by id
+func (m *mockTransactionRepository) GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error) {
+	var result []entity.Transaction
+	for _, tx := range m.items {
+		if (tx.SenderId == ownerId || tx.RecipientId == ownerId) && (after == nil || tx.Id > after.Id) && len(result) < limit {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+// Order is ignored for simplicity, the database error is simulated after all transactions are passed to fn
+func (m *mockTransactionRepository) ExportForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error {
+	for _, tx := range m.items {
+		if (tx.SenderId == ownerId || tx.RecipientId == ownerId) && (filter.Currency == "" || entity.CurrencyOrBase(tx.Currency) == filter.Currency) {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+	}
+	// simulate database error
+	if ownerId.String() == "11111111-1111-1111-1111-111111111111" {
+		return sql.ErrConnDone
+	}
+	return nil
+}
+
+func (m *mockTransactionRepository) Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockTransactionRepository) BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error) {
+	return map[int64]int64{}, nil
+}
+
 func (m *mockTransactionRepository) Count(ctx context.Context) (int64, error) {
 	return int64(len(m.items)), nil
 }
@@ -0,0 +1,146 @@
+package deposit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-ozzo/ozzo-routing/v2/content"
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+)
+
+// Formats of the history export.
+const (
+	formatCSV    = "text/csv"
+	formatNDJSON = "application/x-ndjson"
+)
+
+var exportFormats = []string{formatCSV, formatNDJSON}
+
+// exportHeader is the header of the history export in CSV.
+var exportHeader = []string{
+	"id", "sender_id", "recipient_id", "amount", "currency", "description", "transaction_date",
+	"type", "reservation_id", "service_id", "order_id", "exchange_rate", "rate_id",
+	"refund_of",
+}
+
+// exportFormat negotiates the format of the history export by the Accept header. CSV is used if the header
+// is missing or accepts any type. ok is false if neither format is acceptable.
+func exportFormat(r *http.Request) (format string, ok bool) {
+	if r.Header.Get("Accept") == "" {
+		return formatCSV, true
+	}
+	if format = content.NegotiateContentType(r, exportFormats, ""); format != "" {
+		return format, true
+	}
+	// NegotiateContentType does not match */* to any offer, so it is checked separately
+	for _, accept := range content.AcceptMediaTypes(r) {
+		if accept.Type == "*" && accept.Weight > 0 {
+			return formatCSV, true
+		}
+	}
+	return "", false
+}
+
+// exportWriter streams transactions to the response as a file download in CSV or NDJSON.
+// The response headers are sent with the first transaction, so that errors which happen before it
+// can still be reported as usual.
+type exportWriter struct {
+	response http.ResponseWriter
+	format   string
+	filename string
+	started  bool
+	buffer   *bufio.Writer
+	csv      *csv.Writer
+	json     *json.Encoder
+}
+
+func newExportWriter(response http.ResponseWriter, format, name string) *exportWriter {
+	extension := ".csv"
+	if format == formatNDJSON {
+		extension = ".ndjson"
+	}
+	return &exportWriter{response: response, format: format, filename: name + extension}
+}
+
+// Write writes a single transaction.
+func (w *exportWriter) Write(tx entity.Transaction) error {
+	if !w.started {
+		w.start()
+	}
+	if w.format == formatNDJSON {
+		return w.json.Encode(tx)
+	}
+	return w.csv.Write(csvRecord(tx))
+}
+
+// Flush sends all written transactions to the client. The CSV header is sent even if there were no transactions.
+func (w *exportWriter) Flush() error {
+	if !w.started {
+		w.start()
+	}
+	if w.format == formatNDJSON {
+		return w.buffer.Flush()
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *exportWriter) start() {
+	w.started = true
+	contentType := w.format
+	if w.format == formatCSV {
+		contentType += "; charset=utf-8"
+	}
+	w.response.Header().Set("Content-Type", contentType)
+	w.response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, w.filename))
+	w.response.WriteHeader(http.StatusOK)
+
+	if w.format == formatNDJSON {
+		w.buffer = bufio.NewWriter(w.response)
+		w.json = json.NewEncoder(w.buffer)
+		return
+	}
+	w.csv = csv.NewWriter(w.response)
+	_ = w.csv.Write(exportHeader)
+}
+
+// csvRecord converts the Transaction to a CSV record. The amount is in major units of the currency, like in JSON.
+// Missing participants and ids are represented by empty strings.
+func csvRecord(tx entity.Transaction) []string {
+	return []string{
+		strconv.FormatInt(tx.Id, 10),
+		uuidString(tx.SenderId),
+		uuidString(tx.RecipientId),
+		tx.Money().Number().String(),
+		tx.Currency,
+		tx.Description,
+		tx.TransactionDate.UTC().Format(time.RFC3339Nano),
+		tx.Type,
+		idString(tx.ReservationId),
+		idString(tx.ServiceId),
+		idString(tx.OrderId),
+		tx.ExchangeRate,
+		idString(tx.RateId),
+		idString(tx.RefundOf),
+	}
+}
+
+func uuidString(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+func idString(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
@@ -2,23 +2,48 @@ package deposit
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 
+	dbx "github.com/go-ozzo/ozzo-dbx"
 	"github.com/google/uuid"
 	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/transaction"
 	"users-balance-microservice/pkg/dbcontext"
 	"users-balance-microservice/pkg/log"
 )
 
 // Repository encapsulates the logic to access deposits from the database.
 type Repository interface {
-	// Get returns the Deposit with the specified owner's UUID.
-	Get(ctx context.Context, ownerId uuid.UUID) (entity.Deposit, error)
+	// Get returns the Deposit with the specified owner's UUID in the currency.
+	Get(ctx context.Context, ownerId uuid.UUID, currency string) (entity.Deposit, error)
+	// GetMany returns the existing Deposits of the given owners in the currency. Owners without a Deposit
+	// in the currency are skipped.
+	GetMany(ctx context.Context, ownerIds []uuid.UUID, currency string) ([]entity.Deposit, error)
 	// Create saves a new Deposit in the storage.
 	Create(ctx context.Context, deposit entity.Deposit) error
-	// Update updates the changes to the given Deposit to db.
-	Update(ctx context.Context, deposit entity.Deposit) error
+	// Lock makes sure that Deposits of the given owners in the currency exist and locks them until the end
+	// of the current DB transaction. Deposits are always locked in the same order, so that concurrent operations
+	// on them cannot deadlock. Several currencies of the same owner are locked in the order of their codes.
+	Lock(ctx context.Context, currency string, ownerIds ...uuid.UUID) error
+	// Modify atomically adds amount to the balance and reserved to the reserved funds of owner's Deposit
+	// in the currency. It returns sql.ErrNoRows if the Deposit does not exist, its status does not allow the change
+	// or the change would make its available balance or reserved funds negative.
+	Modify(ctx context.Context, ownerId uuid.UUID, currency string, amount, reserved int64) (entity.Deposit, error)
+	// SetStatus changes the status of owner's Deposit in the currency.
+	SetStatus(ctx context.Context, ownerId uuid.UUID, currency, status string) error
+	// CreateStatusChange saves a new DepositStatusChange in the storage.
+	CreateStatusChange(ctx context.Context, change *entity.DepositStatusChange) error
+	// GetStatusChanges returns the changes of the status of owner's Deposit in the currency from the oldest one.
+	GetStatusChanges(ctx context.Context, ownerId uuid.UUID, currency string) ([]entity.DepositStatusChange, error)
+	// Query returns the list of Deposits with the given offset and limit ordered by owner's UUID and currency.
+	Query(ctx context.Context, offset, limit int) ([]entity.Deposit, error)
 	// Count returns the number of Deposit records in the database.
 	Count(ctx context.Context) (int64, error)
+	// BalanceAt returns the available balance of owner's Deposit in the currency right after the last transaction
+	// made at or before the given time.
+	BalanceAt(ctx context.Context, ownerId uuid.UUID, currency string, at time.Time) (int64, error)
 }
 
 // repository persists Deposit in database
@@ -32,22 +57,118 @@ func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
 	return repository{db, logger}
 }
 
-// Get reads the Deposit with the specified OwnerId from the database.
-// If Deposit with specified OwnerId does not exist, it is created with balance=0.
-func (r repository) Get(ctx context.Context, ownerId uuid.UUID) (entity.Deposit, error) {
+// Get reads the Deposit with the specified OwnerId and Currency from the database.
+func (r repository) Get(ctx context.Context, ownerId uuid.UUID, currency string) (entity.Deposit, error) {
 	var deposit entity.Deposit
-	err := r.db.With(ctx).Select().Model(ownerId, &deposit)
+	err := r.db.With(ctx).Select().Where(dbx.HashExp{"owner_id": ownerId, "currency": currency}).One(&deposit)
 	return deposit, err
 }
 
-// Create saves a new Deposit record in the database.
+// GetMany reads the Deposits of all the owners in the currency from the database with a single query.
+func (r repository) GetMany(ctx context.Context, ownerIds []uuid.UUID, currency string) ([]entity.Deposit, error) {
+	var deposits []entity.Deposit
+	if len(ownerIds) == 0 {
+		return deposits, nil
+	}
+
+	ids := make([]interface{}, len(ownerIds))
+	for i, id := range ownerIds {
+		ids[i] = id
+	}
+	err := r.db.With(ctx).Select().
+		Where(dbx.And(dbx.HashExp{"currency": currency}, dbx.In("owner_id", ids...))).
+		All(&deposits)
+	return deposits, err
+}
+
+// Create saves a new Deposit record in the database. The Deposit is active unless its status is set.
 func (r repository) Create(ctx context.Context, deposit entity.Deposit) error {
+	if deposit.Status == "" {
+		deposit.Status = entity.DepositActive
+	}
 	return r.db.With(ctx).Model(&deposit).Insert()
 }
 
-// Update saves the changes to the Deposit in the database.
-func (r repository) Update(ctx context.Context, deposit entity.Deposit) error {
-	return r.db.With(ctx).Model(&deposit).Update()
+// Lock creates missing Deposits with zero balance and locks the Deposits rows with SELECT ... FOR UPDATE
+// one by one in the order of owners' UUIDs.
+func (r repository) Lock(ctx context.Context, currency string, ownerIds ...uuid.UUID) error {
+	ids := make([]uuid.UUID, len(ownerIds))
+	copy(ids, ownerIds)
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	for _, id := range ids {
+		_, err := r.db.With(ctx).NewQuery(`
+			INSERT INTO deposit (owner_id, currency, balance) VALUES ({:owner_id}, {:currency}, 0)
+			ON CONFLICT (owner_id, currency) DO NOTHING`).
+			Bind(dbx.Params{"owner_id": id, "currency": currency}).
+			Execute()
+		if err != nil {
+			return err
+		}
+
+		var locked uuid.UUID
+		err = r.db.With(ctx).NewQuery("SELECT owner_id FROM deposit WHERE owner_id={:owner_id} AND currency={:currency} FOR UPDATE").
+			Bind(dbx.Params{"owner_id": id, "currency": currency}).
+			Row(&locked)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Modify changes the Deposit with a single conditional UPDATE, so that the check of its status and available funds
+// and the change itself cannot be interleaved with a concurrent change of the same Deposit. The status is checked
+// the same way as by entity.Deposit.Allows.
+func (r repository) Modify(ctx context.Context, ownerId uuid.UUID, currency string, amount, reserved int64) (entity.Deposit, error) {
+	var deposit entity.Deposit
+	err := r.db.With(ctx).NewQuery(`
+		UPDATE deposit SET balance = balance + {:amount}, reserved = reserved + {:reserved}
+		WHERE owner_id={:owner_id} AND currency={:currency}
+			AND (status = {:active} OR (status = {:frozen} AND {:amount} >= 0 AND {:reserved} <= 0))
+			AND reserved + {:reserved} >= 0
+			AND balance + {:amount} - (reserved + {:reserved}) >= 0
+		RETURNING *`).
+		Bind(dbx.Params{
+			"owner_id": ownerId, "currency": currency, "amount": amount, "reserved": reserved,
+			"active": entity.DepositActive, "frozen": entity.DepositFrozen,
+		}).
+		One(&deposit)
+	return deposit, err
+}
+
+// SetStatus updates the status of the Deposit record in the database.
+func (r repository) SetStatus(ctx context.Context, ownerId uuid.UUID, currency, status string) error {
+	_, err := r.db.With(ctx).Update("deposit", dbx.Params{"status": status},
+		dbx.HashExp{"owner_id": ownerId, "currency": currency}).Execute()
+	return err
+}
+
+// CreateStatusChange saves a new DepositStatusChange record in the database.
+func (r repository) CreateStatusChange(ctx context.Context, change *entity.DepositStatusChange) error {
+	return r.db.With(ctx).Model(change).Insert()
+}
+
+// GetStatusChanges reads the DepositStatusChange records of the Deposit from the database in the order they were made.
+func (r repository) GetStatusChanges(ctx context.Context, ownerId uuid.UUID, currency string) ([]entity.DepositStatusChange, error) {
+	var changes []entity.DepositStatusChange
+	err := r.db.With(ctx).Select().
+		Where(dbx.HashExp{"owner_id": ownerId, "currency": currency}).
+		OrderBy("id").
+		All(&changes)
+	return changes, err
+}
+
+// Query retrieves the Deposit records with the specified offset and limit from the database.
+func (r repository) Query(ctx context.Context, offset, limit int) ([]entity.Deposit, error) {
+	var deposits []entity.Deposit
+	err := r.db.With(ctx).
+		Select().
+		OrderBy("owner_id", "currency").
+		Offset(int64(offset)).
+		Limit(int64(limit)).
+		All(&deposits)
+	return deposits, err
 }
 
 // Count returns the number of Deposit records in the database.
@@ -56,3 +177,24 @@ func (r repository) Count(ctx context.Context) (int64, error) {
 	err := r.db.With(ctx).Select("COUNT(*)").From("deposit").Row(&count)
 	return count, err
 }
+
+// BalanceAt starts from the latest BalanceSnapshot made at or before the given time and adds
+// the transaction.AvailableChange of the later transactions up to that time.
+func (r repository) BalanceAt(ctx context.Context, ownerId uuid.UUID, currency string, at time.Time) (int64, error) {
+	var balance int64
+	err := r.db.With(ctx).NewQuery(fmt.Sprintf(`
+		WITH snapshot AS (
+			SELECT transaction_id, balance FROM balance_snapshot
+			WHERE owner_id = {:owner} AND currency = {:currency} AND transaction_date <= {:at}
+			ORDER BY transaction_id DESC
+			LIMIT 1
+		)
+		SELECT COALESCE((SELECT balance FROM snapshot), 0) + COALESCE(SUM(%s), 0)
+		FROM transaction
+		WHERE (sender_id = {:owner} OR recipient_id = {:owner}) AND currency = {:currency}
+			AND id > COALESCE((SELECT transaction_id FROM snapshot), 0)
+			AND transaction_date <= {:at}`, transaction.AvailableChange("{:owner}"))).
+		Bind(dbx.Params{"owner": ownerId, "currency": currency, "at": at}).
+		Row(&balance)
+	return balance, err
+}
@@ -2,25 +2,31 @@ package deposit
 
 import (
 	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/requests"
 	"users-balance-microservice/internal/test"
 	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
 )
 
 func TestRepository(t *testing.T) {
 	logger, _ := log.NewForTest()
 	db := test.DB(t)
-	test.ResetTables(t, db, "deposit")
+	test.ResetTables(t, db, "deposit", "deposit_status_change")
 	repo := NewRepository(db, logger)
 
 	ctx := context.Background()
 
 	ownerId := uuid.New()
-	dep := entity.Deposit{OwnerId: ownerId, Balance: 1000}
+	dep := entity.Deposit{OwnerId: ownerId, Currency: entity.BaseCurrency, Balance: 1000}
 
 	// initial count
 	count, err := repo.Count(ctx)
@@ -34,25 +40,269 @@ func TestRepository(t *testing.T) {
 	}
 
 	// get balance
-	dep, err = repo.Get(ctx, ownerId)
+	dep, err = repo.Get(ctx, ownerId, entity.BaseCurrency)
 	if assert.NoError(t, err) {
 		assert.EqualValues(t, 1000, dep.Balance)
 	}
 
-	// update balance
-	dep.Balance -= 600
-	err = repo.Update(ctx, dep)
+	// get deposits of many owners, owners without a deposit in the currency are skipped
+	deposits, err := repo.GetMany(ctx, []uuid.UUID{ownerId, uuid.New()}, entity.BaseCurrency)
+	if assert.NoError(t, err) && assert.Len(t, deposits, 1) {
+		assert.Equal(t, ownerId, deposits[0].OwnerId)
+	}
+	deposits, err = repo.GetMany(ctx, []uuid.UUID{ownerId}, "USD")
+	if assert.NoError(t, err) {
+		assert.Empty(t, deposits)
+	}
+
+	// query deposits
+	deposits, err = repo.Query(ctx, 0, 10)
+	if assert.NoError(t, err) && assert.Len(t, deposits, 1) {
+		assert.Equal(t, ownerId, deposits[0].OwnerId)
+	}
+	deposits, err = repo.Query(ctx, 1, 10)
 	if assert.NoError(t, err) {
-		dep, _ = repo.Get(ctx, ownerId)
+		assert.Empty(t, deposits)
+	}
+
+	// modify balance
+	dep, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, -600, 0)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 400, dep.Balance)
+		dep, _ = repo.Get(ctx, ownerId, entity.BaseCurrency)
 		assert.EqualValues(t, 400, dep.Balance)
 	}
 
-	// push an update with negative balance -> get an error, update rejected
-	dep.Balance -= 20000
-	err = repo.Update(ctx, dep)
-	if assert.Error(t, err) {
-		dep, _ = repo.Get(ctx, ownerId)
+	// modify with negative balance -> no rows, update rejected
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, -20000, 0)
+	if assert.Equal(t, sql.ErrNoRows, err) {
+		dep, _ = repo.Get(ctx, ownerId, entity.BaseCurrency)
 		assert.EqualValues(t, 400, dep.Balance)
 	}
 
+	// reserve funds
+	dep, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, 0, 300)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 400, dep.Balance)
+		assert.EqualValues(t, 300, dep.Reserved)
+	}
+
+	// spend reserved funds -> no rows, update rejected
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, -200, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// release more than reserved -> no rows, update rejected
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, 0, -400)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// modify non-existing deposit -> no rows
+	_, err = repo.Modify(ctx, uuid.New(), entity.BaseCurrency, 100, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// frozen deposit -> credits are allowed, debits are rejected
+	err = repo.SetStatus(ctx, ownerId, entity.BaseCurrency, entity.DepositFrozen)
+	if assert.NoError(t, err) {
+		dep, _ = repo.Get(ctx, ownerId, entity.BaseCurrency)
+		assert.Equal(t, entity.DepositFrozen, dep.Status)
+	}
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, 0, -100)
+	assert.NoError(t, err)
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, -10, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// blocked deposit -> any change is rejected
+	err = repo.SetStatus(ctx, ownerId, entity.BaseCurrency, entity.DepositBlocked)
+	assert.NoError(t, err)
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, 10, 0)
+	assert.Equal(t, sql.ErrNoRows, err)
+	err = repo.SetStatus(ctx, ownerId, entity.BaseCurrency, entity.DepositActive)
+	assert.NoError(t, err)
+	_, err = repo.Modify(ctx, ownerId, entity.BaseCurrency, 0, 100)
+	assert.NoError(t, err)
+
+	// status changes are returned from the oldest one
+	for _, status := range []string{entity.DepositFrozen, entity.DepositBlocked} {
+		err = repo.CreateStatusChange(ctx, &entity.DepositStatusChange{
+			OwnerId: ownerId, Currency: entity.BaseCurrency, PreviousStatus: entity.DepositActive, Status: status,
+			Reason: "test", CreatedAt: time.Now(),
+		})
+		assert.NoError(t, err)
+	}
+	changes, err := repo.GetStatusChanges(ctx, ownerId, entity.BaseCurrency)
+	if assert.NoError(t, err) && assert.Len(t, changes, 2) {
+		assert.Equal(t, entity.DepositFrozen, changes[0].Status)
+		assert.Equal(t, entity.DepositBlocked, changes[1].Status)
+	}
+	changes, err = repo.GetStatusChanges(ctx, ownerId, "USD")
+	if assert.NoError(t, err) {
+		assert.Empty(t, changes)
+	}
+
+	// lock creates missing deposits
+	id1, id2 := uuid.New(), uuid.New()
+	count, _ = repo.Count(ctx)
+	err = db.Transactional(ctx, func(ctx context.Context) error {
+		return repo.Lock(ctx, entity.BaseCurrency, id1, id2, ownerId)
+	})
+	if assert.NoError(t, err) {
+		count2, _ := repo.Count(ctx)
+		assert.EqualValues(t, 2, count2-count)
+		dep, _ = repo.Get(ctx, id1, entity.BaseCurrency)
+		assert.EqualValues(t, 0, dep.Balance)
+	}
+
+	// another currency of the same owner is a separate deposit
+	err = db.Transactional(ctx, func(ctx context.Context) error {
+		return repo.Lock(ctx, "USD", ownerId)
+	})
+	if assert.NoError(t, err) {
+		dep, err = repo.Modify(ctx, ownerId, "USD", 50, 0)
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, 50, dep.Balance)
+		}
+		dep, _ = repo.Get(ctx, ownerId, entity.BaseCurrency)
+		assert.EqualValues(t, 400, dep.Balance)
+	}
+	_, err = repo.Get(ctx, id1, "USD")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestRepository_BalanceAt(t *testing.T) {
+	logger, _ := log.NewForTest()
+	db := test.DB(t)
+	test.ResetTables(t, db, "transaction", "balance_snapshot")
+	repo := NewRepository(db, logger)
+
+	ctx := context.Background()
+	id1, id2 := uuid.New(), uuid.New()
+	start := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+
+	// top-up 1000, transfer 300 to id2, hold 200, capture 200, withdrawal 100 - one per hour
+	txs := []entity.Transaction{
+		{RecipientId: id1, Amount: 1000},
+		{SenderId: id1, RecipientId: id2, Amount: 300},
+		{SenderId: id1, Amount: 200, Type: entity.TransactionTypeHold},
+		{SenderId: id1, Amount: 200, Type: entity.TransactionTypeCapture},
+		{SenderId: id1, Amount: 100},
+		// another currency does not change the balance
+		{RecipientId: id1, Amount: 70, Currency: "USD"},
+	}
+	for i := range txs {
+		if txs[i].Currency == "" {
+			txs[i].Currency = entity.BaseCurrency
+		}
+		txs[i].TransactionDate = start.Add(time.Duration(i) * time.Hour)
+		assert.NoError(t, db.With(ctx).Model(&txs[i]).Insert())
+	}
+
+	tests := []struct {
+		at      time.Time
+		balance int64
+	}{
+		{start.Add(-time.Minute), 0},
+		{start, 1000},
+		{start.Add(90 * time.Minute), 700},
+		{start.Add(2 * time.Hour), 500},
+		{start.Add(3 * time.Hour), 500},
+		{start.Add(10 * time.Hour), 400},
+	}
+	for _, tc := range tests {
+		balance, err := repo.BalanceAt(ctx, id1, entity.BaseCurrency, tc.at)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tc.balance, balance, "balance at %v", tc.at)
+		}
+	}
+
+	// the recipient of the transfer
+	balance, err := repo.BalanceAt(ctx, id2, entity.BaseCurrency, start.Add(10*time.Hour))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 300, balance)
+	}
+
+	// the deposit in another currency
+	balance, err = repo.BalanceAt(ctx, id1, "USD", start.Add(10*time.Hour))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 70, balance)
+	}
+
+	// a snapshot replaces the transactions it includes
+	snapshot := entity.BalanceSnapshot{OwnerId: id1, Currency: entity.BaseCurrency, TransactionId: txs[1].Id, TransactionDate: txs[1].TransactionDate, Balance: 5000}
+	assert.NoError(t, db.With(ctx).Model(&snapshot).Insert())
+	balance, err = repo.BalanceAt(ctx, id1, entity.BaseCurrency, start)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1000, balance)
+	}
+	balance, err = repo.BalanceAt(ctx, id1, entity.BaseCurrency, start.Add(10*time.Hour))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 4700, balance)
+	}
+}
+
+func TestRepository_Concurrency(t *testing.T) {
+	logger, _ := log.NewForTest()
+	db := test.DB(t)
+	test.ResetTables(t, db, "deposit")
+	s := NewService(NewRepository(db, logger), nil, logger)
+
+	ctx := context.Background()
+	id1, id2 := uuid.New(), uuid.New()
+	err := s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: 1000})
+	assert.NoError(t, err)
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id2.String(), Amount: 1000})
+	assert.NoError(t, err)
+
+	// hammer the deposit with withdrawals and top-ups, each in its own DB transaction
+	const workers = 50
+	var withdrawn int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err := db.Transactional(ctx, func(ctx context.Context) error {
+				return s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: -30})
+			})
+			if err == nil {
+				atomic.AddInt64(&withdrawn, 30)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			err := db.Transactional(ctx, func(ctx context.Context) error {
+				return s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: 10})
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, money.New(1000+10*workers-withdrawn, entity.BaseCurrency), balance.Money)
+		assert.GreaterOrEqual(t, balance.Amount(), int64(0))
+	}
+
+	// opposite transfers between the same deposits must neither deadlock nor lose money
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err := db.Transactional(ctx, func(ctx context.Context) error {
+				return s.Transfer(ctx, requests.TransferRequest{SenderId: id1.String(), RecipientId: id2.String(), Amount: 20})
+			})
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			err := db.Transactional(ctx, func(ctx context.Context) error {
+				return s.Transfer(ctx, requests.TransferRequest{SenderId: id2.String(), RecipientId: id1.String(), Amount: 20})
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	b1, _ := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+	b2, _ := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String()})
+	assert.Equal(t, int64(1000+10*workers-withdrawn+1000), b1.Amount()+b2.Amount())
 }
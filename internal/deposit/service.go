@@ -3,6 +3,12 @@ package deposit
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"users-balance-microservice/internal/entity"
@@ -10,13 +16,33 @@ import (
 	"users-balance-microservice/internal/rates"
 	"users-balance-microservice/internal/requests"
 	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
 )
 
 // Service encapsulates usecase logic for deposits.
 type Service interface {
-	GetBalance(ctx context.Context, req requests.GetBalanceRequest) (float32, error)
+	GetBalance(ctx context.Context, req requests.GetBalanceRequest) (Balance, error)
+	// GetBalances returns the balance of owner's Deposit converted to each of the requested currencies.
+	GetBalances(ctx context.Context, req requests.GetBalanceRequest) ([]ConvertedBalance, error)
+	// GetBulkBalances returns the balances of many users at once.
+	GetBulkBalances(ctx context.Context, req requests.GetBalancesRequest) (BulkBalances, error)
 	Update(ctx context.Context, req requests.UpdateBalanceRequest) error
 	Transfer(ctx context.Context, req requests.TransferRequest) error
+	// Exchange sells money of owner's Deposit in one currency for money of the Deposit in another currency.
+	Exchange(ctx context.Context, req requests.ExchangeRequest) (ExchangeResult, error)
+	// Refund reverses the given amount of the original Transaction, whose refundable amount is not refunded yet,
+	// on the Deposits of its participants. It returns the amount refunded.
+	Refund(ctx context.Context, req requests.RefundRequest, original entity.Transaction, refundable int64) (int64, error)
+	// Reserve moves the given amount of owner's available balance to the reserved funds.
+	Reserve(ctx context.Context, ownerId uuid.UUID, amount int64) error
+	// Capture withdraws the given amount of previously reserved funds from owner's Deposit.
+	Capture(ctx context.Context, ownerId uuid.UUID, amount int64) error
+	// Release returns the given amount of previously reserved funds to owner's available balance.
+	Release(ctx context.Context, ownerId uuid.UUID, amount int64) error
+	// SetStatus changes the status of owner's Deposit and records the change in its status history.
+	SetStatus(ctx context.Context, req requests.SetStatusRequest) (entity.DepositStatusChange, error)
+	// GetStatusHistory returns the changes of the status of owner's Deposit from the oldest one.
+	GetStatusHistory(ctx context.Context, req requests.StatusHistoryRequest) ([]entity.DepositStatusChange, error)
 	Count(ctx context.Context) (int64, error)
 }
 
@@ -30,6 +56,87 @@ type Transaction struct {
 	entity.Transaction
 }
 
+// Balance represents an available balance of a Deposit. If the balance was converted to another currency, RateTime
+// is the time the exchange rate applied was fetched at and RateId is the id of the saved set of rates it belongs to.
+type Balance struct {
+	money.Money
+	RateTime *time.Time
+	RateId   int64
+}
+
+// MarshalJSON encodes Balance as Money with the time and the id of the exchange rate, if there is one.
+func (b Balance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount        string     `json:"amount"`
+		Currency      string     `json:"currency"`
+		RateTimestamp *time.Time `json:"rate_timestamp,omitempty"`
+		RateId        int64      `json:"rate_id,omitempty"`
+	}{b.String(), b.Currency(), b.RateTime, b.RateId})
+}
+
+// ConvertedBalance represents the available balance of a Deposit converted to one of the requested currencies.
+// If the conversion failed, Error describes why and Balance is empty.
+type ConvertedBalance struct {
+	Currency string
+	Balance  Balance
+	Error    string
+}
+
+// MarshalJSON encodes ConvertedBalance as Balance, or as the currency and the Error if the conversion failed.
+func (b ConvertedBalance) MarshalJSON() ([]byte, error) {
+	if b.Error != "" {
+		return json.Marshal(struct {
+			Currency string `json:"currency"`
+			Error    string `json:"error"`
+		}{b.Currency, b.Error})
+	}
+	return json.Marshal(b.Balance)
+}
+
+// BulkBalances represents the available balances of the Deposits of many users in the same currency.
+// Missing lists the users without a Deposit if they were asked to be reported. If the balances were converted
+// to another currency, RateTime and RateId describe the exchange rate applied to all of them.
+type BulkBalances struct {
+	Balances map[uuid.UUID]money.Money
+	Missing  []uuid.UUID
+	RateTime *time.Time
+	RateId   int64
+}
+
+// MarshalJSON encodes BulkBalances with the balances as exact JSON numbers keyed by owners' UUIDs.
+func (b BulkBalances) MarshalJSON() ([]byte, error) {
+	balances := make(map[string]json.Number, len(b.Balances))
+	for ownerId, balance := range b.Balances {
+		balances[ownerId.String()] = balance.Number()
+	}
+	return json.Marshal(struct {
+		Balances      map[string]json.Number `json:"balances"`
+		Missing       []uuid.UUID            `json:"missing,omitempty"`
+		RateTimestamp *time.Time             `json:"rate_timestamp,omitempty"`
+		RateId        int64                  `json:"rate_id,omitempty"`
+	}{balances, b.Missing, b.RateTime, b.RateId})
+}
+
+// ExchangeResult represents the result of a currency exchange: the amount credited to the Deposit in minor units
+// of the target currency, the exchange rate applied and the id of the saved set of rates it was computed from.
+type ExchangeResult struct {
+	Credited int64
+	Rate     string
+	RateId   int64
+}
+
+// exchangeRatePrecision is the number of decimal places the exchange rate between two currencies is rounded to.
+const exchangeRatePrecision = 8
+
+var errCurrencyUnavailable = errors.InternalServerError("Requested currency is not available at the moment.")
+
+// statusErrors are the errors returned when the status of a Deposit does not allow to change its balance.
+var statusErrors = map[string]errors.ErrorResponse{
+	entity.DepositFrozen:  errors.Forbidden("Deposit is frozen, money cannot be debited from it."),
+	entity.DepositBlocked: errors.Forbidden("Deposit is blocked, its balance cannot be changed."),
+	entity.DepositClosed:  errors.Forbidden("Deposit is closed, its balance cannot be changed."),
+}
+
 type service struct {
 	repo            Repository
 	exchangeService rates.ExchangeRatesService
@@ -41,50 +148,203 @@ func NewService(depositRepo Repository, exchangeService rates.ExchangeRatesServi
 	return service{depositRepo, exchangeService, logger}
 }
 
-func (s service) modifyBalance(ctx context.Context, ownerId uuid.UUID, amount int64) error {
-	dep, err := s.repo.Get(ctx, ownerId)
-
-	// If deposit is not in DB yet, create it.
-	if err == sql.ErrNoRows {
-		dep = entity.Deposit{OwnerId: ownerId}
-		if err = s.repo.Create(ctx, dep); err != nil {
-			return err
-		}
-	} else if err != nil {
+// modifyBalance adds amount to the balance and reserved to the reserved funds of owner's Deposit in the currency.
+// The change must be allowed by the status of the Deposit, and the available balance (balance without reserved funds)
+// is not allowed to become negative. If the Deposit does not exist yet, it is created.
+func (s service) modifyBalance(ctx context.Context, ownerId uuid.UUID, currency string, amount, reserved int64) error {
+	if err := s.repo.Lock(ctx, currency, ownerId); err != nil {
 		return err
 	}
 
-	dep.Balance += amount
-	if dep.Balance < 0 {
-		return errors.Forbidden("Insufficient funds to perform operation.")
+	_, err := s.repo.Modify(ctx, ownerId, currency, amount, reserved)
+	if err != sql.ErrNoRows {
+		return err
 	}
 
-	return s.repo.Update(ctx, dep)
+	// The Deposit is locked, so it is safe to find out why the change was rejected.
+	dep, err := s.repo.Get(ctx, ownerId, currency)
+	if err != nil {
+		return err
+	}
+	if !dep.Allows(amount, reserved) {
+		return statusErrors[dep.Status]
+	}
+	if dep.Reserved+reserved < 0 {
+		return errors.Forbidden("Insufficient reserved funds to perform operation.")
+	}
+	return errors.Forbidden("Insufficient funds to perform operation.")
 }
 
-// GetBalance returns the balance of the Deposit whose owner whose OwnerId is equal to GetBalanceRequest.OwnerId.
-func (s service) GetBalance(ctx context.Context, req requests.GetBalanceRequest) (float32, error) {
+// GetBalance returns the available balance of the Deposit whose owner whose OwnerId is equal to GetBalanceRequest.OwnerId
+// in the GetBalanceRequest.Wallet currency. Reserved funds are not included into the available balance.
+// If GetBalanceRequest.At is set, the balance at that time is returned. If GetBalanceRequest.Currency is set,
+// the balance is converted to it at the current exchange rate and rounded to its minor units by GetBalanceRequest.Rounding.
+func (s service) GetBalance(ctx context.Context, req requests.GetBalanceRequest) (Balance, error) {
 	if err := req.Validate(); err != nil {
-		return 0, err
+		return Balance{}, err
 	}
 
-	deposit, err := s.repo.Get(ctx, uuid.MustParse(req.OwnerId))
-	if err == sql.ErrNoRows {
-		return 0, nil
-	} else if err != nil {
-		return 0, err
+	wallet := entity.CurrencyOrBase(req.Wallet)
+	balance, err := s.availableBalance(ctx, uuid.MustParse(req.OwnerId), wallet, req.At)
+	if err != nil {
+		return Balance{}, err
 	}
-	balance := float32(deposit.Balance)
 
-	if req.Currency != "" {
-		rate, err := s.exchangeService.Get(req.Currency)
+	if req.Currency != "" && req.Currency != wallet {
+		snapshot, err := s.snapshot(ctx)
 		if err != nil {
-			return 0, errors.InternalServerError("Requested currency is not available at the moment.")
+			return Balance{}, err
 		}
-		return balance * rate, nil
+		return convert(balance, req.Currency, money.Rounding(req.Rounding), snapshot)
+	}
+
+	return Balance{Money: balance}, nil
+}
+
+// GetBalances returns the available balance of the Deposit like GetBalance, converted to each of
+// GetBalanceRequest.Currencies in their order. Duplicate currencies are returned once. All conversions are made at
+// the same snapshot of exchange rates. A failed conversion is reported in its ConvertedBalance instead of failing
+// the whole request.
+func (s service) GetBalances(ctx context.Context, req requests.GetBalanceRequest) ([]ConvertedBalance, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	wallet := entity.CurrencyOrBase(req.Wallet)
+	balance, err := s.availableBalance(ctx, uuid.MustParse(req.OwnerId), wallet, req.At)
+	if err != nil {
+		return nil, err
 	}
 
-	return balance, nil
+	// the rates are requested only if there is a currency to convert to
+	var snapshot rates.Snapshot
+	var snapshotErr error
+	for _, currency := range req.Currencies {
+		if currency != wallet {
+			snapshot, snapshotErr = s.snapshot(ctx)
+			break
+		}
+	}
+
+	result := make([]ConvertedBalance, 0, len(req.Currencies))
+	seen := make(map[string]bool, len(req.Currencies))
+	for _, currency := range req.Currencies {
+		if seen[currency] {
+			continue
+		}
+		seen[currency] = true
+
+		item := ConvertedBalance{Currency: currency}
+		if currency == wallet {
+			item.Balance = Balance{Money: balance}
+		} else if snapshotErr != nil {
+			item.Error = errorMessage(snapshotErr)
+		} else if item.Balance, err = convert(balance, currency, money.Rounding(req.Rounding), snapshot); err != nil {
+			item.Error = errorMessage(err)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// GetBulkBalances returns the available balances of the Deposits of all GetBalancesRequest.OwnerIds in the
+// GetBalancesRequest.Wallet currency, read with a single query. The balances are converted like in GetBalance,
+// all at the same exchange rate. Users without a Deposit have zero balance, or are listed in BulkBalances.Missing
+// if GetBalancesRequest.ReportMissing is set.
+func (s service) GetBulkBalances(ctx context.Context, req requests.GetBalancesRequest) (BulkBalances, error) {
+	if err := req.Validate(); err != nil {
+		return BulkBalances{}, err
+	}
+
+	ownerIds := make([]uuid.UUID, 0, len(req.OwnerIds))
+	seen := make(map[uuid.UUID]bool, len(req.OwnerIds))
+	for _, id := range req.OwnerIds {
+		ownerId := uuid.MustParse(id)
+		if !seen[ownerId] {
+			seen[ownerId] = true
+			ownerIds = append(ownerIds, ownerId)
+		}
+	}
+
+	wallet := entity.CurrencyOrBase(req.Wallet)
+	deposits, err := s.repo.GetMany(ctx, ownerIds, wallet)
+	if err != nil {
+		return BulkBalances{}, err
+	}
+	available := make(map[uuid.UUID]money.Money, len(deposits))
+	for _, deposit := range deposits {
+		available[deposit.OwnerId] = deposit.Available()
+	}
+
+	var snapshot rates.Snapshot
+	converting := req.Currency != "" && req.Currency != wallet
+	if converting {
+		if snapshot, err = s.snapshot(ctx); err != nil {
+			return BulkBalances{}, err
+		}
+	}
+
+	result := BulkBalances{Balances: make(map[uuid.UUID]money.Money, len(ownerIds))}
+	for _, ownerId := range ownerIds {
+		balance, ok := available[ownerId]
+		if !ok {
+			if req.ReportMissing {
+				result.Missing = append(result.Missing, ownerId)
+				continue
+			}
+			balance = money.New(0, wallet)
+		}
+
+		if converting {
+			converted, err := convert(balance, req.Currency, money.Rounding(req.Rounding), snapshot)
+			if err != nil {
+				return BulkBalances{}, err
+			}
+			balance = converted.Money
+			result.RateTime, result.RateId = converted.RateTime, converted.RateId
+		}
+		result.Balances[ownerId] = balance
+	}
+	return result, nil
+}
+
+// convert converts the balance to the currency at the snapshot of exchange rates.
+func convert(balance money.Money, currency string, rounding money.Rounding, snapshot rates.Snapshot) (Balance, error) {
+	rate, err := snapshotRate(snapshot, balance.Currency(), currency)
+	if err != nil {
+		return Balance{}, err
+	}
+	converted, err := balance.Convert(rate, currency, rounding)
+	if err != nil {
+		return Balance{}, err
+	}
+	rateTime := snapshot.Time.UTC()
+	return Balance{Money: converted, RateTime: &rateTime, RateId: snapshot.Id}, nil
+}
+
+// errorMessage returns the message of an error of a single conversion to report it to the client.
+func errorMessage(err error) string {
+	if response, ok := err.(errors.ErrorResponse); ok {
+		return response.Message
+	}
+	return "The balance cannot be converted to the currency."
+}
+
+// availableBalance returns the current available balance of owner's Deposit in the currency, or the one at the given
+// time if it is set.
+func (s service) availableBalance(ctx context.Context, ownerId uuid.UUID, currency string, at *time.Time) (money.Money, error) {
+	if at != nil {
+		balance, err := s.repo.BalanceAt(ctx, ownerId, currency, at.UTC())
+		return money.New(balance, currency), err
+	}
+
+	deposit, err := s.repo.Get(ctx, ownerId, currency)
+	if err == sql.ErrNoRows {
+		return money.New(0, currency), nil
+	} else if err != nil {
+		return money.Money{}, err
+	}
+	return deposit.Available(), nil
 }
 
 // Update changes the balance of Deposit according to UpdateBalanceRequest.
@@ -95,7 +355,7 @@ func (s service) Update(ctx context.Context, req requests.UpdateBalanceRequest)
 	}
 
 	ownerUUID := uuid.MustParse(req.OwnerId)
-	if err := s.modifyBalance(ctx, ownerUUID, req.Amount); err != nil {
+	if err := s.modifyBalance(ctx, ownerUUID, entity.CurrencyOrBase(req.Currency), req.Amount, 0); err != nil {
 		return err
 	}
 
@@ -110,16 +370,233 @@ func (s service) Transfer(ctx context.Context, req requests.TransferRequest) err
 	}
 
 	senderUUID, recipientUUID := uuid.MustParse(req.SenderId), uuid.MustParse(req.RecipientId)
-	if err := s.modifyBalance(ctx, senderUUID, -req.Amount); err != nil {
+	currency := entity.CurrencyOrBase(req.Currency)
+	// Lock both participants at once, so that opposite transfers between the same users cannot deadlock.
+	if err := s.repo.Lock(ctx, currency, senderUUID, recipientUUID); err != nil {
 		return err
 	}
-	if err := s.modifyBalance(ctx, recipientUUID, req.Amount); err != nil {
+	if err := s.modifyBalance(ctx, senderUUID, currency, -req.Amount, 0); err != nil {
+		return err
+	}
+	if err := s.modifyBalance(ctx, recipientUUID, currency, req.Amount, 0); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// Exchange sells ExchangeRequest.Amount of owner's Deposit in the From currency for the To currency at the current
+// exchange rate rounded to exchangeRatePrecision decimal places. The credited amount is rounded down to minor units
+// of the To currency.
+func (s service) Exchange(ctx context.Context, req requests.ExchangeRequest) (ExchangeResult, error) {
+	if err := req.Validate(); err != nil {
+		return ExchangeResult{}, err
+	}
+
+	snapshot, err := s.snapshot(ctx)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	rate, err := snapshotRate(snapshot, req.From, req.To)
+	if err != nil {
+		return ExchangeResult{}, err
+	}
+	rateString := strings.TrimRight(strings.TrimRight(rate.FloatString(exchangeRatePrecision), "0"), ".")
+	rate, _ = new(big.Rat).SetString(rateString)
+
+	credited, err := money.New(req.Amount, req.From).Convert(rate, req.To, money.Down)
+	if err != nil {
+		return ExchangeResult{}, errors.BadRequest("The amount is too large to be exchanged.")
+	}
+	if credited.Amount() == 0 {
+		return ExchangeResult{}, errors.BadRequest("The amount is too small to be exchanged.")
+	}
+
+	// Lock both Deposits of the owner in the order of their currencies, so that opposite exchanges cannot deadlock.
+	ownerUUID := uuid.MustParse(req.OwnerId)
+	currencies := []string{req.From, req.To}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		if err := s.repo.Lock(ctx, currency, ownerUUID); err != nil {
+			return ExchangeResult{}, err
+		}
+	}
+	if err := s.modifyBalance(ctx, ownerUUID, req.From, -req.Amount, 0); err != nil {
+		return ExchangeResult{}, err
+	}
+	if err := s.modifyBalance(ctx, ownerUUID, req.To, credited.Amount(), 0); err != nil {
+		return ExchangeResult{}, err
+	}
+
+	return ExchangeResult{Credited: credited.Amount(), Rate: rateString, RateId: snapshot.Id}, nil
+}
+
+// Refund moves RefundRequest.Amount, or the whole refundable amount if it is not set, of the original Transaction back
+// from its recipient to its sender: a withdrawal or a captured reservation is returned to the available balance,
+// a top-up is withdrawn and a transfer is sent back. The original Transaction must be locked by the caller, so that
+// refundable is still up to date.
+func (s service) Refund(ctx context.Context, req requests.RefundRequest, original entity.Transaction, refundable int64) (int64, error) {
+	if err := req.Validate(); err != nil {
+		return 0, err
+	}
+
+	if original.Type != "" && original.Type != entity.TransactionTypeCapture {
+		return 0, errors.Conflict("Only top-ups, withdrawals, transfers and captures can be refunded.")
+	}
+	if refundable <= 0 {
+		return 0, errors.Conflict("Transaction is already refunded.")
+	}
+	currency := entity.CurrencyOrBase(original.Currency)
+	amount, err := req.AmountIn(currency)
+	if err != nil {
+		return 0, err
+	}
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount > refundable {
+		return 0, errors.Conflict(fmt.Sprintf("Only %s of the transaction can be refunded.", money.New(refundable, currency)))
+	}
+
+	// The money comes back to the sender and leaves the recipient, either of them is missing for top-ups and withdrawals.
+	var owners []uuid.UUID
+	for _, ownerId := range []uuid.UUID{original.SenderId, original.RecipientId} {
+		if ownerId != uuid.Nil {
+			owners = append(owners, ownerId)
+		}
+	}
+	if err := s.repo.Lock(ctx, currency, owners...); err != nil {
+		return 0, err
+	}
+	if original.RecipientId != uuid.Nil {
+		if err := s.modifyBalance(ctx, original.RecipientId, currency, -amount, 0); err != nil {
+			return 0, err
+		}
+	}
+	if original.SenderId != uuid.Nil {
+		if err := s.modifyBalance(ctx, original.SenderId, currency, amount, 0); err != nil {
+			return 0, err
+		}
+	}
+
+	return amount, nil
+}
+
+// SetStatus changes the status of owner's Deposit in the SetStatusRequest.Currency, creating the Deposit if it does
+// not exist yet, and saves the change with its reason. A closed Deposit cannot be reopened, and only a Deposit with zero
+// balance can be closed.
+func (s service) SetStatus(ctx context.Context, req requests.SetStatusRequest) (entity.DepositStatusChange, error) {
+	if err := req.Validate(); err != nil {
+		return entity.DepositStatusChange{}, err
+	}
+
+	ownerUUID, currency := uuid.MustParse(req.OwnerId), entity.CurrencyOrBase(req.Currency)
+	if err := s.repo.Lock(ctx, currency, ownerUUID); err != nil {
+		return entity.DepositStatusChange{}, err
+	}
+	dep, err := s.repo.Get(ctx, ownerUUID, currency)
+	if err != nil {
+		return entity.DepositStatusChange{}, err
+	}
+
+	previous := dep.Status
+	if previous == "" {
+		previous = entity.DepositActive
+	}
+	switch {
+	case previous == req.Status:
+		return entity.DepositStatusChange{}, errors.Conflict(fmt.Sprintf("Deposit is already %s.", req.Status))
+	case previous == entity.DepositClosed:
+		return entity.DepositStatusChange{}, errors.Conflict("Deposit is closed, its status cannot be changed.")
+	case req.Status == entity.DepositClosed && dep.Balance != 0:
+		return entity.DepositStatusChange{}, errors.Conflict("Only a deposit with zero balance can be closed.")
+	}
+
+	if err = s.repo.SetStatus(ctx, ownerUUID, currency, req.Status); err != nil {
+		return entity.DepositStatusChange{}, err
+	}
+	change := entity.DepositStatusChange{
+		OwnerId:        ownerUUID,
+		Currency:       currency,
+		PreviousStatus: previous,
+		Status:         req.Status,
+		Reason:         req.Reason,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err = s.repo.CreateStatusChange(ctx, &change); err != nil {
+		return entity.DepositStatusChange{}, err
+	}
+	return change, nil
+}
+
+// GetStatusHistory returns the changes of the status of owner's Deposit in the StatusHistoryRequest.Currency.
+func (s service) GetStatusHistory(ctx context.Context, req requests.StatusHistoryRequest) ([]entity.DepositStatusChange, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	changes, err := s.repo.GetStatusChanges(ctx, uuid.MustParse(req.OwnerId), entity.CurrencyOrBase(req.Currency))
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []entity.DepositStatusChange{}
+	}
+	return changes, nil
+}
+
+// snapshot returns the current snapshot of exchange rates.
+func (s service) snapshot(ctx context.Context) (rates.Snapshot, error) {
+	snapshot, err := s.exchangeService.Snapshot(ctx)
+	if err != nil {
+		return rates.Snapshot{}, errCurrencyUnavailable
+	}
+	return snapshot, nil
+}
+
+// snapshotRate returns the price of a major unit of the from currency in major units of the to currency at the
+// snapshot. The rates of rates.ExchangeRatesService are against entity.BaseCurrency, so the rate is their ratio.
+func snapshotRate(snapshot rates.Snapshot, from, to string) (*big.Rat, error) {
+	fromRate, err := baseRate(snapshot, from)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := baseRate(snapshot, to)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// baseRate returns the price of a major unit of entity.BaseCurrency in major units of the currency at the snapshot.
+// The rate of entity.BaseCurrency itself is always 1.
+func baseRate(snapshot rates.Snapshot, currency string) (*big.Rat, error) {
+	if currency == entity.BaseCurrency {
+		return big.NewRat(1, 1), nil
+	}
+	rate, ok := snapshot.Get(currency)
+	if !ok || rate.Value <= 0 {
+		return nil, errCurrencyUnavailable
+	}
+	return money.Rate(rate.Value), nil
+}
+
+// Reserve holds the given amount on owner's Deposit. Held money stays on the Deposit, but is not available for spending.
+// Reservations are made in entity.BaseCurrency only.
+func (s service) Reserve(ctx context.Context, ownerId uuid.UUID, amount int64) error {
+	return s.modifyBalance(ctx, ownerId, entity.BaseCurrency, 0, amount)
+}
+
+// Capture withdraws the given amount of held money from owner's Deposit.
+func (s service) Capture(ctx context.Context, ownerId uuid.UUID, amount int64) error {
+	return s.modifyBalance(ctx, ownerId, entity.BaseCurrency, -amount, -amount)
+}
+
+// Release makes the given amount of held money available for spending again.
+func (s service) Release(ctx context.Context, ownerId uuid.UUID, amount int64) error {
+	return s.modifyBalance(ctx, ownerId, entity.BaseCurrency, 0, -amount)
+}
+
 // Count returns a number of Deposits in the database.
 // Mainly used for testing purposes.
 func (s service) Count(ctx context.Context) (int64, error) {
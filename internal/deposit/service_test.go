@@ -2,19 +2,20 @@ package deposit
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/rates"
 	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/internal/test"
 	"users-balance-microservice/pkg/log"
 )
 
 var (
-	databaseError   = errors.New("database error")
+	databaseError   = test.ErrDatabase
 	logger, _       = log.NewForTest()
 	exchangeService = mockExchangeRatesService{}
 	ctx             = context.Background()
@@ -23,9 +24,9 @@ var (
 func TestService_GetBalance(t *testing.T) {
 	id1, id2 := uuid.New(), uuid.New()
 	s := NewService(
-		&mockDepositRepository{
-			items: []entity.Deposit{
-				{id1, 1000},
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000},
 			},
 		}, exchangeService, logger,
 	)
@@ -39,30 +40,236 @@ func TestService_GetBalance(t *testing.T) {
 	// get existing deposit's balance in RUB
 	balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 	if assert.NoError(t, err) {
-		assert.EqualValues(t, 1000, balance)
+		assert.Equal(t, "10.00", balance.String())
+		assert.Nil(t, balance.RateTime)
+		assert.Zero(t, balance.RateId)
 	}
 
 	// get existing deposit's balance in USD (fake exchange rate RUB/USD=0.1 is used)
 	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), Currency: "USD"})
 	if assert.NoError(t, err) {
-		assert.EqualValues(t, 100, balance)
+		assert.Equal(t, "1.00", balance.String())
+		assert.Equal(t, &rateTime, balance.RateTime)
+		assert.Equal(t, rateId, balance.RateId)
 	}
 
 	// get non-existing deposit's balance - 0 is returned regardless of currency, new deposit is not created.
 	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String(), Currency: "EUR"})
 	if assert.NoError(t, err) {
-		assert.EqualValues(t, 0, balance)
+		assert.Equal(t, "0.00", balance.String())
+	}
+}
+
+func TestService_GetBulkBalances(t *testing.T) {
+	id1, id2, id3 := uuid.New(), uuid.New(), uuid.New()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000, Reserved: 200},
+				{OwnerId: id2, Balance: 500},
+				{OwnerId: id2, Currency: "USD", Balance: 7},
+			},
+		}, exchangeService, logger,
+	)
+
+	// available balances, unknown users have zero balance, duplicates are returned once
+	balances, err := s.GetBulkBalances(ctx, requests.GetBalancesRequest{OwnerIds: []string{id1.String(), id2.String(), id3.String(), id1.String()}})
+	if assert.NoError(t, err) {
+		assert.Len(t, balances.Balances, 3)
+		assert.Equal(t, "8.00", balances.Balances[id1].String())
+		assert.Equal(t, "5.00", balances.Balances[id2].String())
+		assert.Equal(t, "0.00", balances.Balances[id3].String())
+		assert.Empty(t, balances.Missing)
+		assert.Nil(t, balances.RateTime)
+	}
+
+	// unknown users are reported as missing, the balances are converted at the same rate (fake exchange rate 0.1 is used)
+	balances, err = s.GetBulkBalances(ctx, requests.GetBalancesRequest{
+		OwnerIds:      []string{id1.String(), id2.String(), id3.String()},
+		Currency:      "USD",
+		ReportMissing: true,
+	})
+	if assert.NoError(t, err) {
+		assert.Len(t, balances.Balances, 2)
+		assert.Equal(t, "0.80", balances.Balances[id1].String())
+		assert.Equal(t, "USD", balances.Balances[id1].Currency())
+		assert.Equal(t, []uuid.UUID{id3}, balances.Missing)
+		assert.Equal(t, &rateTime, balances.RateTime)
+		assert.Equal(t, rateId, balances.RateId)
+	}
+
+	// the balances of another wallet
+	balances, err = s.GetBulkBalances(ctx, requests.GetBalancesRequest{OwnerIds: []string{id1.String(), id2.String()}, Wallet: "USD", ReportMissing: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.07", balances.Balances[id2].String())
+		assert.Equal(t, []uuid.UUID{id1}, balances.Missing)
 	}
 
+	// the currency is unavailable
+	_, err = s.GetBulkBalances(ctx, requests.GetBalancesRequest{OwnerIds: []string{id1.String()}, Currency: "KWD"})
+	assert.Error(t, err)
+
+	// validation error
+	_, err = s.GetBulkBalances(ctx, requests.GetBalancesRequest{})
+	assert.Error(t, err)
+
+	// database error
+	_, err = s.GetBulkBalances(ctx, requests.GetBalancesRequest{OwnerIds: []string{"11111111-1111-1111-1111-111111111111"}})
+	assert.Equal(t, databaseError, err)
+}
+
+func TestService_GetBalance_Precision(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 16777217},
+				{OwnerId: id2, Balance: 100500},
+			},
+		}, exchangeService, logger,
+	)
+
+	// a balance which float32 cannot represent is exact
+	balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "167772.17", balance.String())
+		assert.Equal(t, "RUB", balance.Currency())
+	}
+
+	// converted without float noise (fake exchange rate 0.1 is used)
+	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), Currency: "USD"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "16777.22", balance.String())
+		assert.Equal(t, "USD", balance.Currency())
+	}
+
+	// 100.5 JPY is rounded to whole yens by the requested rule
+	tests := []struct {
+		rounding string
+		want     string
+	}{
+		{"", "101"},
+		{"half_up", "101"},
+		{"half_even", "100"},
+		{"down", "100"},
+	}
+	for _, tc := range tests {
+		balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String(), Currency: "JPY", Rounding: tc.rounding})
+		if assert.NoError(t, err) {
+			assert.Equal(t, tc.want, balance.String(), tc.rounding)
+		}
+	}
+}
+
+func TestService_GetBalances(t *testing.T) {
+	id1 := uuid.New()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 100500},
+			},
+		}, exchangeService, logger,
+	)
+
+	// all conversions are made at the same rates, the failed one does not fail the others (fake exchange rate 0.1 is used)
+	balances, err := s.GetBalances(ctx, requests.GetBalanceRequest{
+		OwnerId:    id1.String(),
+		Currencies: []string{"USD", "RUB", "JPY", "KWD", "USD"},
+		Rounding:   "down",
+	})
+	if assert.NoError(t, err) && assert.Len(t, balances, 4) {
+		assert.Equal(t, "USD", balances[0].Currency)
+		assert.Equal(t, "100.50", balances[0].Balance.String())
+		assert.Equal(t, &rateTime, balances[0].Balance.RateTime)
+		assert.Equal(t, rateId, balances[0].Balance.RateId)
+
+		assert.Equal(t, "1005.00", balances[1].Balance.String())
+		assert.Nil(t, balances[1].Balance.RateTime)
+
+		assert.Equal(t, "100", balances[2].Balance.String())
+		assert.Empty(t, balances[2].Error)
+
+		assert.Equal(t, "KWD", balances[3].Currency)
+		assert.Equal(t, "Requested currency is not available at the moment.", balances[3].Error)
+	}
+
+	// invalid currencies
+	_, err = s.GetBalances(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), Currencies: []string{"EURUSDPLT"}})
+	assert.Error(t, err)
+
+	// database error fails the whole request
+	at := time.Now().Add(-time.Hour)
+	_, err = s.GetBalances(ctx, requests.GetBalanceRequest{OwnerId: "11111111-1111-1111-1111-111111111111", Currencies: []string{"USD"}, At: &at})
+	assert.Equal(t, databaseError, err)
+}
+
+func TestService_GetBalance_At(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now().UTC()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000},
+			},
+			History: []entity.BalanceSnapshot{
+				{OwnerId: id1, TransactionId: 1, TransactionDate: now.Add(-2 * time.Hour), Balance: 300},
+				{OwnerId: id1, TransactionId: 2, TransactionDate: now.Add(-time.Hour), Balance: 1000},
+			},
+		}, exchangeService, logger,
+	)
+
+	// balance between the transactions
+	at := now.Add(-90 * time.Minute)
+	balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), At: &at})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3.00", balance.String())
+	}
+
+	// the same time in another time zone
+	local := at.In(time.FixedZone("UTC+3", 3*60*60))
+	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), At: &local})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3.00", balance.String())
+	}
+
+	// converted to the currency the same way as the current balance
+	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), Currency: "USD", At: &at})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.30", balance.String())
+	}
+
+	// before the first transaction
+	at = now.Add(-3 * time.Hour)
+	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), At: &at})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.00", balance.String())
+	}
+
+	// non-existing deposit
+	balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String(), At: &at})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.00", balance.String())
+	}
+
+	// in the future
+	at = now.Add(time.Hour)
+	_, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), At: &at})
+	assert.Error(t, err)
+
+	// database error
+	at = now
+	_, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: "11111111-1111-1111-1111-111111111111", At: &at})
+	assert.Equal(t, databaseError, err)
+
 	// get
 }
 
 func TestService_Update(t *testing.T) {
 	id1, id2 := uuid.New(), uuid.New()
 	s := NewService(
-		&mockDepositRepository{
-			items: []entity.Deposit{
-				{id1, 1000},
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000},
 			},
 		}, exchangeService, logger,
 	)
@@ -78,7 +285,7 @@ func TestService_Update(t *testing.T) {
 	if assert.NoError(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1500, balance)
+			assert.Equal(t, "15.00", balance.String())
 		}
 	}
 
@@ -91,7 +298,7 @@ func TestService_Update(t *testing.T) {
 	if assert.NoError(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1000, balance)
+			assert.Equal(t, "10.00", balance.String())
 		}
 	}
 
@@ -109,7 +316,7 @@ func TestService_Update(t *testing.T) {
 
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 2000, balance)
+			assert.Equal(t, "20.00", balance.String())
 		}
 	}
 
@@ -122,7 +329,7 @@ func TestService_Update(t *testing.T) {
 	if assert.Error(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1000, balance)
+			assert.Equal(t, "10.00", balance.String())
 		}
 	}
 
@@ -138,10 +345,10 @@ func TestService_Update(t *testing.T) {
 func TestService_Transfer(t *testing.T) {
 	id1, id2, id3 := uuid.New(), uuid.New(), uuid.New()
 	s := NewService(
-		&mockDepositRepository{
-			items: []entity.Deposit{
-				{id1, 1000},
-				{id2, 2000},
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000},
+				{OwnerId: id2, Balance: 2000},
 			},
 		}, exchangeService, logger,
 	)
@@ -156,15 +363,19 @@ func TestService_Transfer(t *testing.T) {
 	if assert.NoError(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1300, balance)
+			assert.Equal(t, "13.00", balance.String())
 		}
 
 		balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1700, balance)
+			assert.Equal(t, "17.00", balance.String())
 		}
 	}
 
+	// both participants are locked before the transfer
+	repo := s.(service).repo.(*test.DepositRepository)
+	assert.ElementsMatch(t, []uuid.UUID{id1, id2}, repo.Locks[:2])
+
 	// transfer from existing to non-existing deposit success
 	err = s.Transfer(ctx, requests.TransferRequest{
 		SenderId:    id2.String(),
@@ -175,12 +386,12 @@ func TestService_Transfer(t *testing.T) {
 	if assert.NoError(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1000, balance)
+			assert.Equal(t, "10.00", balance.String())
 		}
 
 		balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id3.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 700, balance)
+			assert.Equal(t, "7.00", balance.String())
 		}
 	}
 
@@ -194,12 +405,12 @@ func TestService_Transfer(t *testing.T) {
 	if assert.Error(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1300, balance)
+			assert.Equal(t, "13.00", balance.String())
 		}
 
 		balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1000, balance)
+			assert.Equal(t, "10.00", balance.String())
 		}
 	}
 
@@ -213,63 +424,324 @@ func TestService_Transfer(t *testing.T) {
 	if assert.Error(t, err) {
 		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1300, balance)
+			assert.Equal(t, "13.00", balance.String())
 		}
 
 		balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id2.String()})
 		if assert.NoError(t, err) {
-			assert.EqualValues(t, 1000, balance)
+			assert.Equal(t, "10.00", balance.String())
 		}
 	}
 }
 
-type mockDepositRepository struct {
-	items []entity.Deposit
-}
+func TestService_Exchange(t *testing.T) {
+	id1 := uuid.New()
+	repo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: id1, Currency: "RUB", Balance: 1000},
+		},
+	}
+	s := NewService(repo, exchangeService, logger)
 
-func (m *mockDepositRepository) Get(ctx context.Context, ownerId uuid.UUID) (entity.Deposit, error) {
-	for _, item := range m.items {
-		if item.OwnerId == ownerId {
-			return item, nil
+	// exchange success (fake exchange rate RUB/USD=0.1 is used)
+	result, err := s.Exchange(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "USD", Amount: 300})
+	if assert.NoError(t, err) {
+		assert.Equal(t, ExchangeResult{Credited: 30, Rate: "0.1", RateId: rateId}, result)
+
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "7.00", balance.String())
+		}
+		balance, err = s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String(), Wallet: "USD"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "0.30", balance.String())
+			assert.Equal(t, "USD", balance.Currency())
 		}
 	}
-	return entity.Deposit{}, sql.ErrNoRows
+
+	// the rate between two currencies other than RUB is the ratio of their rates, the credited amount is rounded down
+	result, err = s.Exchange(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "USD", To: "EUR", Amount: 25})
+	if assert.NoError(t, err) {
+		assert.Equal(t, ExchangeResult{Credited: 25, Rate: "1", RateId: rateId}, result)
+	}
+	result, err = s.Exchange(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "USD", Amount: 19})
+	if assert.NoError(t, err) {
+		assert.Equal(t, ExchangeResult{Credited: 1, Rate: "0.1", RateId: rateId}, result)
+	}
+
+	// exchange insufficient funds failure
+	_, err = s.Exchange(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "USD", To: "RUB", Amount: 100})
+	assert.Error(t, err)
+
+	// amount which is worth less than a minor unit of the target currency failure
+	_, err = s.Exchange(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "USD", Amount: 9})
+	assert.Error(t, err)
+
+	// exchange to the same currency failure
+	_, err = s.Exchange(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "RUB", Amount: 100})
+	assert.Error(t, err)
+
+	// nothing is changed by failed exchanges
+	balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "6.81", balance.String())
+	}
 }
 
-func (m *mockDepositRepository) Create(ctx context.Context, deposit entity.Deposit) error {
-	if deposit.Balance < 0 {
-		return databaseError
+func TestService_Refund(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000},
+				{OwnerId: id2, Balance: 500},
+				{OwnerId: id2, Currency: "USD", Balance: 10},
+			},
+		}, exchangeService, logger,
+	)
+	balance := func(ownerId uuid.UUID, wallet string) string {
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: ownerId.String(), Wallet: wallet})
+		assert.NoError(t, err)
+		return balance.String()
 	}
-	m.items = append(m.items, deposit)
-	return nil
+
+	// partial refund of a withdrawal returns the money to the sender
+	withdrawal := entity.Transaction{Id: 1, SenderId: id1, Amount: 300}
+	amount, err := s.Refund(ctx, requests.RefundRequest{TransactionId: 1, Amount: "1"}, withdrawal, 300)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 100, amount)
+		assert.Equal(t, "11.00", balance(id1, ""))
+	}
+
+	// the rest of the withdrawal is refunded by default
+	amount, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 1}, withdrawal, 200)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 200, amount)
+		assert.Equal(t, "13.00", balance(id1, ""))
+	}
+
+	// refund of a refunded transaction failure
+	_, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 1}, withdrawal, 0)
+	assert.EqualError(t, err, "Transaction is already refunded.")
+
+	// refund of more than the refundable amount failure
+	_, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 1, Amount: "2.01"}, withdrawal, 200)
+	assert.EqualError(t, err, "Only 2.00 of the transaction can be refunded.")
+	assert.Equal(t, "13.00", balance(id1, ""))
+
+	// refund of a transfer sends the money back, both participants are locked
+	repo := s.(service).repo.(*test.DepositRepository)
+	repo.Locks = nil
+	transfer := entity.Transaction{Id: 2, SenderId: id1, RecipientId: id2, Amount: 400}
+	amount, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 2}, transfer, 400)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 400, amount)
+		assert.Equal(t, "17.00", balance(id1, ""))
+		assert.Equal(t, "1.00", balance(id2, ""))
+		assert.ElementsMatch(t, []uuid.UUID{id1, id2}, repo.Locks[:2])
+	}
+
+	// reversal of a top-up in another currency withdraws the money from the recipient
+	topUp := entity.Transaction{Id: 3, RecipientId: id2, Amount: 10, Currency: "USD"}
+	_, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 3, Amount: "0.04"}, topUp, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0.06", balance(id2, "USD"))
+	}
+
+	// reversal of a top-up which was already spent failure
+	_, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 3}, topUp, 10)
+	assert.EqualError(t, err, "Insufficient funds to perform operation.")
+
+	// refund of a hold failure
+	reservationId := int64(1)
+	hold := entity.Transaction{Id: 4, SenderId: id1, Amount: 100, Type: entity.TransactionTypeHold, ReservationId: &reservationId}
+	_, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 4}, hold, 100)
+	assert.Error(t, err)
+
+	// validation failure
+	_, err = s.Refund(ctx, requests.RefundRequest{TransactionId: 1, Amount: "-1"}, withdrawal, 300)
+	assert.Error(t, err)
+	assert.Equal(t, "17.00", balance(id1, ""))
 }
 
-func (m *mockDepositRepository) Update(ctx context.Context, deposit entity.Deposit) error {
-	if deposit.Balance < 0 {
-		return databaseError
+func TestService_Reservation(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000},
+			},
+		}, exchangeService, logger,
+	)
+
+	// reserve success -> reserved funds are not available anymore
+	err := s.Reserve(ctx, id1, 600)
+	if assert.NoError(t, err) {
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "4.00", balance.String())
+		}
 	}
-	// simulate database error
-	if deposit.OwnerId.String() == "11111111-1111-1111-1111-111111111111" {
-		return databaseError
+
+	// reserve more than available failure
+	err = s.Reserve(ctx, id1, 500)
+	if assert.Error(t, err) {
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "4.00", balance.String())
+		}
 	}
 
-	for i, item := range m.items {
-		if item.OwnerId == deposit.OwnerId {
-			m.items[i] = deposit
-			return nil
+	// withdrawal of reserved funds failure
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: -500})
+	assert.Error(t, err)
+
+	// release success -> funds are available again
+	err = s.Release(ctx, id1, 100)
+	if assert.NoError(t, err) {
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "5.00", balance.String())
+		}
+	}
+
+	// capture success -> available balance is not changed, reserved funds are withdrawn
+	err = s.Capture(ctx, id1, 500)
+	if assert.NoError(t, err) {
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: id1.String()})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "5.00", balance.String())
 		}
 	}
 
-	return m.Create(ctx, deposit)
+	// capture more than reserved failure
+	err = s.Capture(ctx, id1, 100)
+	assert.Error(t, err)
+
+	// reserve on non-existing deposit failure
+	err = s.Reserve(ctx, id2, 100)
+	assert.Error(t, err)
 }
 
-func (m *mockDepositRepository) Count(ctx context.Context) (int64, error) {
-	return int64(len(m.items)), nil
+func TestService_SetStatus(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	s := NewService(
+		&test.DepositRepository{
+			Items: []entity.Deposit{
+				{OwnerId: id1, Balance: 1000, Reserved: 200},
+				{OwnerId: id2, Balance: 500},
+			},
+		}, exchangeService, logger,
+	)
+	balance := func(ownerId uuid.UUID) string {
+		balance, err := s.GetBalance(ctx, requests.GetBalanceRequest{OwnerId: ownerId.String()})
+		assert.NoError(t, err)
+		return balance.String()
+	}
+
+	// freeze success
+	change, err := s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id1.String(), Status: entity.DepositFrozen, Reason: "fraud case #12"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, entity.DepositActive, change.PreviousStatus)
+		assert.Equal(t, entity.DepositFrozen, change.Status)
+		assert.Equal(t, entity.BaseCurrency, change.Currency)
+	}
+
+	// frozen deposit: credits land, debits are rejected
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: 100})
+	assert.NoError(t, err)
+	err = s.Transfer(ctx, requests.TransferRequest{SenderId: id2.String(), RecipientId: id1.String(), Amount: 100})
+	assert.NoError(t, err)
+	err = s.Release(ctx, id1, 100)
+	assert.NoError(t, err)
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: -100})
+	assert.EqualError(t, err, "Deposit is frozen, money cannot be debited from it.")
+	err = s.Transfer(ctx, requests.TransferRequest{SenderId: id1.String(), RecipientId: id2.String(), Amount: 100})
+	assert.EqualError(t, err, "Deposit is frozen, money cannot be debited from it.")
+	err = s.Reserve(ctx, id1, 100)
+	assert.EqualError(t, err, "Deposit is frozen, money cannot be debited from it.")
+	err = s.Capture(ctx, id1, 100)
+	assert.EqualError(t, err, "Deposit is frozen, money cannot be debited from it.")
+	assert.Equal(t, "11.00", balance(id1))
+
+	// blocked deposit: neither credits nor debits
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id1.String(), Status: entity.DepositBlocked, Reason: "court order"})
+	assert.NoError(t, err)
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: 100})
+	assert.EqualError(t, err, "Deposit is blocked, its balance cannot be changed.")
+	err = s.Release(ctx, id1, 100)
+	assert.EqualError(t, err, "Deposit is blocked, its balance cannot be changed.")
+
+	// the same status again failure
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id1.String(), Status: entity.DepositBlocked, Reason: "court order"})
+	assert.EqualError(t, err, "Deposit is already blocked.")
+
+	// closing a deposit with money on it failure
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id1.String(), Status: entity.DepositClosed, Reason: "user request"})
+	assert.EqualError(t, err, "Only a deposit with zero balance can be closed.")
+
+	// unblock success
+	change, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id1.String(), Status: entity.DepositActive, Reason: "case closed"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, entity.DepositBlocked, change.PreviousStatus)
+	}
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: -100})
+	assert.NoError(t, err)
+
+	// a deposit without money can be closed, and then it cannot be changed anymore
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id2.String(), Amount: -400})
+	assert.NoError(t, err)
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id2.String(), Status: entity.DepositClosed, Reason: "user request"})
+	assert.NoError(t, err)
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id2.String(), Amount: 100})
+	assert.EqualError(t, err, "Deposit is closed, its balance cannot be changed.")
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id2.String(), Status: entity.DepositActive, Reason: "user request"})
+	assert.EqualError(t, err, "Deposit is closed, its status cannot be changed.")
+
+	// a missing deposit is created with the status
+	id3 := uuid.New()
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id3.String(), Currency: "USD", Status: entity.DepositFrozen, Reason: "sanctions"})
+	assert.NoError(t, err)
+	err = s.Update(ctx, requests.UpdateBalanceRequest{OwnerId: id3.String(), Currency: "USD", Amount: 100})
+	assert.NoError(t, err)
+
+	// history of the status changes
+	history, err := s.GetStatusHistory(ctx, requests.StatusHistoryRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) && assert.Len(t, history, 3) {
+		assert.Equal(t, []string{entity.DepositFrozen, entity.DepositBlocked, entity.DepositActive},
+			[]string{history[0].Status, history[1].Status, history[2].Status})
+		assert.Equal(t, "court order", history[1].Reason)
+	}
+	history, err = s.GetStatusHistory(ctx, requests.StatusHistoryRequest{OwnerId: id1.String(), Currency: "USD"})
+	if assert.NoError(t, err) {
+		assert.Empty(t, history)
+	}
+
+	// invalid request failure
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: id1.String(), Status: "deleted", Reason: "test"})
+	assert.Error(t, err)
+	_, err = s.GetStatusHistory(ctx, requests.StatusHistoryRequest{})
+	assert.Error(t, err)
+
+	// database error
+	_, err = s.SetStatus(ctx, requests.SetStatusRequest{OwnerId: "11111111-1111-1111-1111-111111111111", Status: entity.DepositFrozen, Reason: "test"})
+	assert.Error(t, err)
 }
 
+// rateTime is the time the fake exchange rates were fetched at, rateId is the id of their saved set.
+var (
+	rateTime       = time.Date(2021, 11, 10, 9, 0, 0, 0, time.UTC)
+	rateId   int64 = 42
+)
+
 // Fake exchange rates service provides exchange ratio=0.1 regardless of currency code.
 type mockExchangeRatesService struct{}
 
-func (s mockExchangeRatesService) Get(code string) (float32, error) {
-	return 0.1, nil
+func (s mockExchangeRatesService) Get(ctx context.Context, code string) (rates.Rate, error) {
+	return rates.Rate{Value: 0.1, Time: rateTime, Id: rateId}, nil
+}
+
+// Snapshot returns the fake rate of USD, EUR and JPY, other currencies are unavailable.
+func (s mockExchangeRatesService) Snapshot(ctx context.Context) (rates.Snapshot, error) {
+	return rates.NewSnapshot(map[string]float32{"USD": 0.1, "EUR": 0.1, "JPY": 0.1}, rateTime, rateId), nil
 }
@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BalanceSnapshot represents the available balance of user's Deposit in a currency right after one of its transactions.
+//
+// Snapshots are taken periodically, so that the balance at a point in time can be computed from the latest snapshot
+// before it and the transactions made after the snapshot, instead of the whole history of the Deposit.
+type BalanceSnapshot struct {
+	// UUID of the Deposit this BalanceSnapshot is about.
+	OwnerId uuid.UUID `json:"owner_id" db:"pk"`
+	// ISO 4217 code of the currency of the Deposit.
+	Currency string `json:"currency" db:"pk"`
+	// Id of the last Transaction included into this BalanceSnapshot.
+	TransactionId int64 `json:"transaction_id" db:"pk"`
+	// The date and time when the last included Transaction was made.
+	TransactionDate time.Time `json:"transaction_date"`
+	// The available balance of the Deposit right after the last included Transaction, in minor units of the Currency.
+	Balance int64 `json:"balance"`
+}
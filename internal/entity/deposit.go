@@ -1,11 +1,81 @@
 package entity
 
-import "github.com/google/uuid"
+import (
+	"time"
 
-// Deposit represents a user's account in the database.
+	"github.com/google/uuid"
+	"users-balance-microservice/pkg/money"
+)
+
+// BaseCurrency is the default currency of Deposits. Exchange rates are quoted against it.
+const BaseCurrency = "RUB"
+
+// CurrencyOrBase returns the currency code, or BaseCurrency if it is empty.
+func CurrencyOrBase(currency string) string {
+	if currency == "" {
+		return BaseCurrency
+	}
+	return currency
+}
+
+const (
+	// DepositActive is a status of Deposit whose balance can be changed in any way. Deposits are active by default.
+	DepositActive = "active"
+	// DepositFrozen is a status of Deposit which money can be credited to, but cannot be debited from.
+	DepositFrozen = "frozen"
+	// DepositBlocked is a status of Deposit whose balance cannot be changed until it is unblocked.
+	DepositBlocked = "blocked"
+	// DepositClosed is a final status of Deposit whose balance cannot be changed anymore.
+	DepositClosed = "closed"
+)
+
+// Deposit represents a user's account (wallet) in a single currency in the database.
+// A user may have several Deposits in different currencies.
 type Deposit struct {
-	// OwnerId is a UUID of the user which this Deposit belongs to. Serves as primary key in the database.
+	// OwnerId is a UUID of the user which this Deposit belongs to. Together with Currency serves as primary key
+	// in the database.
 	OwnerId uuid.UUID `json:"owner_id" db:"pk"`
+	// ISO 4217 code of the currency of this Deposit. Balances are stored in minor units of it.
+	Currency string `json:"currency" db:"pk"`
 	// Balance is an amount of money which is available to this user. Non-negative.
 	Balance int64 `json:"balance"`
+	// Reserved is a part of Balance which is held by Reservations and is not available for spending. Non-negative.
+	Reserved int64 `json:"reserved"`
+	// Status limits the changes of the balance: active, frozen, blocked or closed. Empty status means active.
+	Status string `json:"status"`
+}
+
+// Available returns the part of Balance which is not reserved.
+func (d Deposit) Available() money.Money {
+	return money.New(d.Balance-d.Reserved, CurrencyOrBase(d.Currency))
+}
+
+// Allows reports whether the Status of this Deposit allows to add amount to its balance and reserved to its
+// reserved funds. A frozen Deposit allows only credits: top-ups, incoming transfers and releases of reserved funds.
+func (d Deposit) Allows(amount, reserved int64) bool {
+	switch d.Status {
+	case "", DepositActive:
+		return true
+	case DepositFrozen:
+		return amount >= 0 && reserved <= 0
+	}
+	return false
+}
+
+// DepositStatusChange represents a change of the Status of a Deposit, made by an administrator for the Reason.
+type DepositStatusChange struct {
+	// Database id of this DepositStatusChange.
+	Id int64 `json:"id" db:"pk"`
+	// UUID of the owner of the Deposit.
+	OwnerId uuid.UUID `json:"owner_id"`
+	// ISO 4217 code of the currency of the Deposit.
+	Currency string `json:"currency"`
+	// The status of the Deposit before the change.
+	PreviousStatus string `json:"previous_status"`
+	// The status of the Deposit after the change.
+	Status string `json:"status"`
+	// Why the status was changed, e.g. a fraud case or a court order.
+	Reason string `json:"reason"`
+	// The date and time when the status was changed.
+	CreatedAt time.Time `json:"created_at"`
 }
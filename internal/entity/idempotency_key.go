@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// IdempotencyKey represents a client-provided key which makes retries of a balance-changing request safe.
+//
+// The key is stored together with a fingerprint of the request it was first used with and the id of the resulting
+// Transaction, so that a retry of the same request returns the original Transaction instead of being applied twice.
+type IdempotencyKey struct {
+	// The key provided by the client. Serves as primary key in the database.
+	Key string `json:"key" db:"pk"`
+	// SHA-256 hash of the endpoint and the request this key was first used with.
+	Fingerprint string `json:"fingerprint"`
+	// Id of the Transaction resulting from the request. Empty while the request is being processed.
+	TransactionId *int64 `json:"transaction_id,omitempty"`
+	// The date and time when this key was first used. The key expires after the configured TTL.
+	CreatedAt time.Time `json:"created_at"`
+}
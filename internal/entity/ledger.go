@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// JournalEntry represents a single balanced record in the double-entry ledger.
+//
+// Every JournalEntry consists of Postings against ledger accounts whose amounts sum up to zero, so money never
+// appears or disappears without a counter-entry.
+type JournalEntry struct {
+	// Database id of this JournalEntry.
+	Id int64 `json:"id" db:"pk"`
+	// Id of the Transaction this JournalEntry reflects.
+	TransactionId int64 `json:"transaction_id"`
+	// The description of this JournalEntry. Optional.
+	Description string `json:"description"`
+	// The date and time when this JournalEntry was made.
+	CreatedAt time.Time `json:"created_at"`
+	// Postings of this JournalEntry. Stored separately.
+	Postings []Posting `json:"postings" db:"-"`
+}
+
+// Posting represents a change of a single ledger account within a JournalEntry.
+type Posting struct {
+	// Database id of this Posting.
+	Id int64 `json:"id" db:"pk"`
+	// Id of the JournalEntry this Posting belongs to.
+	EntryId int64 `json:"entry_id"`
+	// The ledger account this Posting is made against, e.g. "user:<UUID>" or "system:cash_in". Accounts in currencies
+	// other than BaseCurrency are suffixed with the currency code, e.g. "user:<UUID>:USD".
+	Account string `json:"account"`
+	// An amount in minor units of the currency of the account added to it. Negative amount is subtracted from
+	// the account. Non-zero.
+	Amount int64 `json:"amount"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent represents a change of user's Deposit which has to be delivered to other services.
+//
+// OutboxEvent is saved in the same DB transaction as the change itself, so that an event is stored if and only if
+// the change was committed. Events of an owner are delivered in the order of their ids.
+type OutboxEvent struct {
+	// Database id of this OutboxEvent.
+	Id int64 `json:"id" db:"pk"`
+	// UUID of the Deposit this OutboxEvent is about.
+	OwnerId uuid.UUID `json:"owner_id"`
+	// Type of this OutboxEvent, e.g. top_up or transfer_out.
+	Type string `json:"type"`
+	// JSON data of this OutboxEvent, e.g. the Transaction which changed the Deposit.
+	Payload string `json:"payload"`
+	// The date and time when this OutboxEvent was created.
+	CreatedAt time.Time `json:"created_at"`
+	// The date and time when this OutboxEvent was delivered to all sinks. Empty while it is pending.
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty"`
+	// The date and time until which this OutboxEvent is being delivered by a dispatcher. Other dispatchers skip
+	// the events of the same owner until then.
+	ClaimedUntil *time.Time `json:"claimed_until,omitempty"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// Rate represents a set of exchange rates fetched from a rates provider.
+//
+// Every fetched set is saved, so that it is known which rate any conversion was made at, and the rate of a currency
+// at a past date can be looked up.
+type Rate struct {
+	// Database id of this Rate.
+	Id int64 `json:"id" db:"pk"`
+	// Name of the rates provider the set was fetched from, e.g. cbr.
+	Source string `json:"source"`
+	// The date and time when the set was fetched.
+	FetchedAt time.Time `json:"fetched_at"`
+	// JSON object of the rates of all currencies known to the provider as units of the currency per 1 RUB,
+	// e.g. {"USD": 0.0137}.
+	Rates string `json:"rates"`
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/pkg/money"
+)
+
+const (
+	// ReservationHeld is a status of Reservation whose money is held on the Deposit.
+	ReservationHeld = "held"
+	// ReservationCaptured is a status of Reservation whose money was withdrawn from the Deposit.
+	ReservationCaptured = "captured"
+	// ReservationReleased is a status of Reservation whose money was returned to the available balance.
+	ReservationReleased = "released"
+)
+
+// Reservation represents an amount of money held on user's Deposit until it is either captured or released.
+//
+// Reserved money stays on the Deposit, but is not available for spending until the Reservation is released.
+type Reservation struct {
+	// Database id of this Reservation.
+	Id int64 `json:"id" db:"pk"`
+	// UUID of the Deposit the money is held on.
+	OwnerId uuid.UUID `json:"owner_id"`
+	// An amount of minor units of BaseCurrency (kopecks) held on the Deposit. Positive.
+	Amount int64 `json:"amount"`
+	// Current status of this Reservation: held, captured or released.
+	Status string `json:"status"`
+	// The description of this Reservation. Optional.
+	Description string `json:"description"`
+	// Id of the paid service the money is held for. Optional.
+	ServiceId *int64 `json:"service_id,omitempty"`
+	// Id of the order the money is held for. Optional.
+	OrderId *int64 `json:"order_id,omitempty"`
+	// The date and time when this Reservation was made.
+	CreatedAt time.Time `json:"created_at"`
+	// The date and time when the status of this Reservation was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MarshalJSON encodes Reservation with the Amount in major units of BaseCurrency, e.g. 123.45.
+func (r Reservation) MarshalJSON() ([]byte, error) {
+	type plain Reservation
+	return json.Marshal(struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}{plain(r), money.New(r.Amount, BaseCurrency).Number()})
+}
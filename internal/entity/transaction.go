@@ -1,9 +1,31 @@
 package entity
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"users-balance-microservice/pkg/money"
+)
+
+const (
+	// TransactionTypeHold is a type of Transaction which holds money on the sender's Deposit.
+	TransactionTypeHold = "hold"
+	// TransactionTypeCapture is a type of Transaction which withdraws held money from the sender's Deposit.
+	TransactionTypeCapture = "capture"
+	// TransactionTypeRelease is a type of Transaction which returns held money to the recipient's available balance.
+	TransactionTypeRelease = "release"
+	// TransactionTypeCorrection is a type of Transaction which brings the history of a Deposit in line with its
+	// stored balance after reconciliation. It does not change the balance.
+	TransactionTypeCorrection = "correction"
+	// TransactionTypeExchange is a type of Transaction which moves money between Deposits of the same user
+	// in different currencies. An exchange is made of two transactions: one from the Deposit in the sold currency
+	// and one to the Deposit in the bought currency.
+	TransactionTypeExchange = "exchange"
+	// TransactionTypeRefund is a type of Transaction which reverses the whole or a part of another Transaction:
+	// the money moves back from its recipient to its sender.
+	TransactionTypeRefund = "refund"
 )
 
 // Transaction represents a single change in user's Deposit.
@@ -14,6 +36,19 @@ import (
 // If a transaction is missing a RecipientId, it is considered a deposit withdrawal.
 // If a transaction is missing a SenderId, it is considered a deposit top-up.
 // Otherwise, a transaction is considered a money transfer between two users within the system.
+//
+// Transactions related to a Reservation have a Type and a ReservationId. Hold and release transactions only move
+// money between available and reserved parts of the Deposit and do not change its balance.
+//
+// Correction transactions are written by reconciliation and do not change the balance either, they only account for
+// a difference between the stored balance and the history.
+//
+// Every Transaction is made in a single currency and changes the Deposits of its participants in that currency.
+// The Amount is kept in minor units of the currency, and is sent in JSON as a number in major units, e.g. 123.45.
+// Exchange transactions come in pairs and record the ExchangeRate applied.
+//
+// Refund transactions swap the participants of the Transaction they reverse and refer to it by RefundOf.
+// The sum of the refunds of a Transaction never exceeds its Amount.
 type Transaction struct {
 	// Database id of this Transaction.
 	Id int64 `json:"id,omitempty" db:"pk"`
@@ -21,10 +56,78 @@ type Transaction struct {
 	SenderId uuid.UUID `json:"sender_id,omitempty"`
 	// UUID of recipient's Deposit. Optional.
 	RecipientId uuid.UUID `json:"recipient_id,omitempty"`
-	// An amount of rubles subtracted from sender's deposit and added to recipient's deposit. Positive.
+	// An amount of minor units of the Currency subtracted from sender's deposit and added to recipient's deposit.
+	// Positive.
 	Amount int64 `json:"amount"`
+	// ISO 4217 code of the currency of the Transaction, the same as of the Deposits it changes.
+	Currency string `json:"currency"`
 	// The description of this Transaction. Optional.
 	Description string `json:"description"`
 	// The date and time when this Transaction was made.
 	TransactionDate time.Time `json:"transaction_date,omitempty"`
+	// Type of this Transaction if it is not a plain top-up, withdrawal or transfer. Optional.
+	Type string `json:"type,omitempty"`
+	// Id of the Reservation this Transaction belongs to. Optional.
+	ReservationId *int64 `json:"reservation_id,omitempty"`
+	// Id of the paid service this Transaction is a payment for. Optional.
+	ServiceId *int64 `json:"service_id,omitempty"`
+	// Id of the order this Transaction is a payment for. Optional.
+	OrderId *int64 `json:"order_id,omitempty"`
+	// The exchange rate applied by an exchange Transaction: the price of a unit of the sold currency in units of
+	// the bought currency, as an exact decimal string. Optional.
+	ExchangeRate string `json:"exchange_rate,omitempty"`
+	// Id of the saved set of exchange rates the ExchangeRate was computed from. Optional.
+	RateId *int64 `json:"rate_id,omitempty"`
+	// Id of the Transaction this refund Transaction reverses. Optional.
+	RefundOf *int64 `json:"refund_of,omitempty"`
+}
+
+// Money returns the Amount of this Transaction in its Currency.
+func (t Transaction) Money() money.Money {
+	return money.New(t.Amount, CurrencyOrBase(t.Currency))
+}
+
+// MarshalJSON encodes Transaction with the Amount in major units of the Currency.
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	type plain Transaction
+	return json.Marshal(struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}{plain(t), t.Money().Number()})
+}
+
+// UnmarshalJSON decodes Transaction encoded by MarshalJSON.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type plain Transaction
+	v := struct {
+		*plain
+		Amount json.Number `json:"amount"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	amount, err := money.Parse(string(v.Amount), CurrencyOrBase(t.Currency))
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, v.Amount)
+	}
+	t.Amount = amount.Amount()
+	return nil
+}
+
+// BalanceChange returns the amount by which this Transaction changed the balance of the owner's Deposit.
+// Hold and release transactions do not change the balance, correction transactions are included, so that
+// the sum of BalanceChange over the whole history of a Deposit is equal to its balance.
+func (t Transaction) BalanceChange(ownerId uuid.UUID) int64 {
+	if t.Type == TransactionTypeHold || t.Type == TransactionTypeRelease {
+		return 0
+	}
+
+	var change int64
+	if t.RecipientId == ownerId {
+		change += t.Amount
+	}
+	if t.SenderId == ownerId {
+		change -= t.Amount
+	}
+	return change
 }
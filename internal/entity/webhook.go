@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription represents a callback URL registered by another service to receive events.
+//
+// A subscription receives all events unless it is limited to the events of a single owner and/or of a single type.
+type WebhookSubscription struct {
+	// Database id of this WebhookSubscription.
+	Id int64 `json:"id" db:"pk"`
+	// The URL events are posted to.
+	Url string `json:"url"`
+	// The secret deliveries are signed with. Returned only when the subscription is created.
+	Secret string `json:"secret,omitempty"`
+	// UUID of the Deposit whose events are delivered. Optional.
+	OwnerId *uuid.UUID `json:"owner_id,omitempty"`
+	// Type of events which are delivered. Optional.
+	EventType string `json:"event_type,omitempty"`
+	// The date and time when this WebhookSubscription was created.
+	CreatedAt time.Time `json:"created_at"`
+	// The date and time when this WebhookSubscription was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery represents a pending delivery of an event to a WebhookSubscription.
+type WebhookDelivery struct {
+	// Database id of this WebhookDelivery.
+	Id int64 `json:"id" db:"pk"`
+	// Id of the WebhookSubscription the event is delivered to.
+	SubscriptionId int64 `json:"subscription_id"`
+	// Id of the delivered event.
+	EventId int64 `json:"event_id"`
+	// UUID of the Deposit the event is about.
+	OwnerId uuid.UUID `json:"owner_id"`
+	// JSON body of the delivery.
+	Payload string `json:"payload"`
+	// The number of failed attempts to deliver the event.
+	Attempts int `json:"attempts"`
+	// The error of the last failed attempt. Optional.
+	LastError string `json:"last_error,omitempty"`
+	// The date and time of the next attempt.
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// The date and time until which this WebhookDelivery is being made by a worker. Other workers skip the deliveries
+	// of the same queue until then.
+	ClaimedUntil *time.Time `json:"claimed_until,omitempty"`
+	// The date and time when this WebhookDelivery was created.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDeadLetter represents a WebhookDelivery which has failed all its attempts.
+// It is kept until it is replayed by an administrator.
+type WebhookDeadLetter struct {
+	// Database id of this WebhookDeadLetter.
+	Id int64 `json:"id" db:"pk"`
+	// Id of the WebhookSubscription the event was delivered to.
+	SubscriptionId int64 `json:"subscription_id"`
+	// Id of the event which was not delivered.
+	EventId int64 `json:"event_id"`
+	// UUID of the Deposit the event is about.
+	OwnerId uuid.UUID `json:"owner_id"`
+	// JSON body of the delivery.
+	Payload string `json:"-"`
+	// The number of failed attempts to deliver the event.
+	Attempts int `json:"attempts"`
+	// The error of the last failed attempt.
+	LastError string `json:"last_error"`
+	// The date and time when the delivery was given up.
+	CreatedAt time.Time `json:"created_at"`
+	// The date and time when this WebhookDeadLetter was replayed. Empty if it was not replayed yet.
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+}
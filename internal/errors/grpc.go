@@ -0,0 +1,98 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"users-balance-microservice/pkg/log"
+)
+
+// UnaryServerInterceptor creates a gRPC interceptor that handles panics and errors encountered during RPC processing.
+// Errors are converted to ErrorResponse the same way as for HTTP requests and then to gRPC status.
+func UnaryServerInterceptor(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			l := logger.With(ctx)
+			if e := recover(); e != nil {
+				var ok bool
+				if err, ok = e.(error); !ok {
+					err = fmt.Errorf("%v", e)
+				}
+
+				l.Errorf("recovered from panic (%v): %s", err, debug.Stack())
+			}
+
+			if err != nil {
+				// errors which are already gRPC statuses, e.g. an unimplemented method, are returned as they are
+				if _, ok := err.(ErrorResponse); !ok {
+					if st, ok := status.FromError(err); ok {
+						err = st.Err()
+						return
+					}
+				}
+
+				res := buildErrorResponse(err)
+				if res.StatusCode() == http.StatusInternalServerError {
+					l.Errorf("encountered internal server error: %v", err)
+				}
+				resp, err = nil, res.GRPCStatus().Err()
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// GRPCStatus converts the ErrorResponse to gRPC status. It is used by status.FromError.
+// Details of a data validation error are converted to errdetails.BadRequest.
+func (e ErrorResponse) GRPCStatus() *status.Status {
+	st := status.New(grpcCode(e.Status), e.Message)
+
+	fields, ok := e.Details.([]invalidField)
+	if !ok {
+		return st
+	}
+	details := &errdetails.BadRequest{}
+	for _, field := range fields {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Error,
+		})
+	}
+	if withDetails, err := st.WithDetails(details); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// grpcCode returns the gRPC code corresponding to the HTTP status code.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		// Forbidden is returned when an operation is not allowed in the current state of a deposit,
+		// e.g. because of insufficient funds.
+		return codes.FailedPrecondition
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
@@ -57,6 +57,17 @@ func NotFound(msg string) ErrorResponse {
 	}
 }
 
+// Conflict creates a new error response representing a conflict with the current state of a resource (HTTP 409)
+func Conflict(msg string) ErrorResponse {
+	if msg == "" {
+		msg = "The request conflicts with the current state of the resource."
+	}
+	return ErrorResponse{
+		Status:  http.StatusConflict,
+		Message: msg,
+	}
+}
+
 // BadRequest creates a new error response representing a bad request (HTTP 400)
 func BadRequest(msg string) ErrorResponse {
 	if msg == "" {
@@ -68,6 +79,17 @@ func BadRequest(msg string) ErrorResponse {
 	}
 }
 
+// NotAcceptable creates a new error response representing a request for an unsupported content type (HTTP 406)
+func NotAcceptable(msg string) ErrorResponse {
+	if msg == "" {
+		msg = "The requested content type is not supported."
+	}
+	return ErrorResponse{
+		Status:  http.StatusNotAcceptable,
+		Message: msg,
+	}
+}
+
 type invalidField struct {
 	Field string `json:"field"`
 	Error string `json:"error"`
@@ -0,0 +1,45 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"users-balance-microservice/pkg/log"
+)
+
+// Cleaner periodically deletes expired idempotency keys, so that the table does not grow without bound.
+type Cleaner struct {
+	repo   Repository
+	ttl    time.Duration
+	logger log.Logger
+}
+
+// NewCleaner creates a new Cleaner which deletes the keys used earlier than ttl ago.
+func NewCleaner(repo Repository, ttl time.Duration, logger log.Logger) *Cleaner {
+	return &Cleaner{repo, ttl, logger}
+}
+
+// Run deletes expired keys every interval until the context is cancelled.
+func (c *Cleaner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := c.Clean(ctx); err != nil {
+			c.logger.With(ctx).Errorf("failed deleting expired idempotency keys: %v", err)
+		} else if n > 0 {
+			c.logger.With(ctx).Infof("deleted %d expired idempotency keys", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean deletes the expired keys once and returns their number.
+func (c *Cleaner) Clean(ctx context.Context) (int64, error) {
+	return c.repo.DeleteExpired(ctx, time.Now().UTC().Add(-c.ttl))
+}
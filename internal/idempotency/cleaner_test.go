@@ -0,0 +1,32 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+)
+
+func TestCleaner(t *testing.T) {
+	now := time.Now().UTC()
+	repo := &mockRepository{items: []entity.IdempotencyKey{
+		{Key: "old", CreatedAt: now.Add(-2 * time.Hour)},
+		{Key: "new", CreatedAt: now.Add(-time.Minute)},
+	}}
+	c := NewCleaner(repo, time.Hour, logger)
+
+	n, err := c.Clean(ctx)
+	if assert.NoError(t, err) && assert.Len(t, repo.items, 1) {
+		assert.EqualValues(t, 1, n)
+		assert.Equal(t, "new", repo.items[0].Key)
+	}
+
+	// Run cleans at once and stops with the context
+	repo.items[0].CreatedAt = now.Add(-2 * time.Hour)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	c.Run(cancelled, time.Hour)
+	assert.Empty(t, repo.items)
+}
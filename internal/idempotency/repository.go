@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// Repository encapsulates the logic to access idempotency keys from the database.
+type Repository interface {
+	// Get returns the IdempotencyKey with the specified key.
+	Get(ctx context.Context, key string) (entity.IdempotencyKey, error)
+	// Create saves a new IdempotencyKey in the storage unless the key is already present and was created
+	// at or after expiredBefore. An expired key is replaced. It reports whether the IdempotencyKey was created.
+	Create(ctx context.Context, key entity.IdempotencyKey, expiredBefore time.Time) (bool, error)
+	// Update saves the changes to the given IdempotencyKey.
+	Update(ctx context.Context, key entity.IdempotencyKey) error
+	// DeleteExpired deletes the IdempotencyKeys created before the given time and returns their number.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// repository persists IdempotencyKey in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new IdempotencyKey repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Get reads the IdempotencyKey with the specified key from the database.
+func (r repository) Get(ctx context.Context, key string) (entity.IdempotencyKey, error) {
+	var k entity.IdempotencyKey
+	err := r.db.With(ctx).Select().Model(key, &k)
+	return k, err
+}
+
+// Create inserts a new IdempotencyKey record into the database or overwrites an expired one.
+// If a concurrent transaction has inserted the same key, Create waits for it to finish and reports false
+// in case the key was committed.
+func (r repository) Create(ctx context.Context, key entity.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	result, err := r.db.With(ctx).NewQuery(`
+		INSERT INTO idempotency_key (key, fingerprint, created_at)
+		VALUES ({:key}, {:fingerprint}, {:created_at})
+		ON CONFLICT (key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, transaction_id = NULL, created_at = EXCLUDED.created_at
+		WHERE idempotency_key.created_at < {:expired_before}`).
+		Bind(dbx.Params{
+			"key":            key.Key,
+			"fingerprint":    key.Fingerprint,
+			"created_at":     key.CreatedAt,
+			"expired_before": expiredBefore,
+		}).
+		Execute()
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// Update saves the changes to the IdempotencyKey in the database.
+func (r repository) Update(ctx context.Context, key entity.IdempotencyKey) error {
+	return r.db.With(ctx).Model(&key).Update()
+}
+
+// DeleteExpired deletes the IdempotencyKey records created before the given time from the database.
+func (r repository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.With(ctx).Delete("idempotency_key", dbx.NewExp("created_at < {:before}", dbx.Params{"before": before})).
+		Execute()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package idempotency
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "idempotency_key")
+	repo := NewRepository(db, logger)
+
+	key := entity.IdempotencyKey{Key: uuid.NewString(), Fingerprint: "abc", CreatedAt: time.Now().UTC()}
+
+	// get non-existing key
+	_, err := repo.Get(ctx, key.Key)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// create new key
+	expiredBefore := key.CreatedAt.Add(-time.Hour)
+	created, err := repo.Create(ctx, key, expiredBefore)
+	if assert.NoError(t, err) {
+		assert.True(t, created)
+	}
+
+	// create existing key -> not created, no error
+	created, err = repo.Create(ctx, key, expiredBefore)
+	if assert.NoError(t, err) {
+		assert.False(t, created)
+	}
+
+	// update with transaction id
+	txId := int64(10)
+	key.TransactionId = &txId
+	if assert.NoError(t, repo.Update(ctx, key)) {
+		k, err := repo.Get(ctx, key.Key)
+		if assert.NoError(t, err) && assert.NotNil(t, k.TransactionId) {
+			assert.EqualValues(t, 10, *k.TransactionId)
+			assert.Equal(t, "abc", k.Fingerprint)
+		}
+	}
+	// an expired key is replaced
+	other := entity.IdempotencyKey{Key: key.Key, Fingerprint: "def", CreatedAt: key.CreatedAt.Add(2 * time.Hour)}
+	created, err = repo.Create(ctx, other, other.CreatedAt.Add(-time.Hour))
+	if assert.NoError(t, err) && assert.True(t, created) {
+		k, err := repo.Get(ctx, key.Key)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "def", k.Fingerprint)
+			assert.Nil(t, k.TransactionId)
+		}
+	}
+
+	// expired keys are deleted
+	n, err := repo.DeleteExpired(ctx, other.CreatedAt.Add(-time.Second))
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+	}
+	n, err = repo.DeleteExpired(ctx, other.CreatedAt.Add(time.Second))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1, n)
+	}
+	_, err = repo.Get(ctx, key.Key)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/pkg/log"
+)
+
+// Header is the HTTP header which carries an idempotency key.
+const Header = "Idempotency-Key"
+
+// Service encapsulates usecase logic for idempotency keys.
+type Service interface {
+	// Begin registers the key for a request with the given fingerprint.
+	// If the key was already used for the same request, Begin reports a replay
+	// and returns the id of the Transaction resulting from the original request.
+	Begin(ctx context.Context, key, fingerprint string) (transactionId int64, replay bool, err error)
+	// Complete saves the id of the Transaction resulting from the request with the given key.
+	Complete(ctx context.Context, key string, transactionId int64) error
+}
+
+type service struct {
+	repo   Repository
+	ttl    time.Duration
+	logger log.Logger
+}
+
+// NewService creates a new idempotency service. Keys are honored for ttl after their first use, a key used again
+// later is treated as a new one.
+func NewService(repo Repository, ttl time.Duration, logger log.Logger) Service {
+	return service{repo, ttl, logger}
+}
+
+// Begin should be called in the same DB transaction as the balance change, so that the key is committed atomically
+// with it. A concurrent request with the same key waits until the first one is finished.
+func (s service) Begin(ctx context.Context, key, fingerprint string) (int64, bool, error) {
+	if err := validation.Validate(key, validation.Length(1, 255)); err != nil {
+		return 0, false, validation.Errors{"idempotency_key": err}
+	}
+
+	now := time.Now().UTC()
+	created, err := s.repo.Create(ctx, entity.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+	}, now.Add(-s.ttl))
+	if err != nil || created {
+		return 0, false, err
+	}
+
+	existing, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return 0, false, err
+	}
+	if existing.Fingerprint != fingerprint {
+		return 0, false, errors.Conflict("Idempotency key was already used with a different request.")
+	}
+	if existing.TransactionId == nil {
+		return 0, false, errors.Conflict("A request with the same idempotency key is being processed.")
+	}
+	return *existing.TransactionId, true, nil
+}
+
+func (s service) Complete(ctx context.Context, key string, transactionId int64) error {
+	existing, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	existing.TransactionId = &transactionId
+	return s.repo.Update(ctx, existing)
+}
+
+// Fingerprint returns a SHA-256 hash of the endpoint and the JSON representation of the request.
+// The request must not contain the idempotency key itself.
+func Fingerprint(endpoint string, req interface{}) string {
+	data, _ := json.Marshal(req)
+	hash := sha256.Sum256(append([]byte(endpoint+"\n"), data...))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,143 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _ = log.NewForTest()
+	ctx       = context.Background()
+)
+
+func TestService(t *testing.T) {
+	s := NewService(&mockRepository{}, 24*time.Hour, logger)
+	fingerprint := Fingerprint("/v1/deposits/update", requests.UpdateBalanceRequest{OwnerId: "1", Amount: 100})
+
+	// first usage of the key
+	_, replay, err := s.Begin(ctx, "key-1", fingerprint)
+	if assert.NoError(t, err) {
+		assert.False(t, replay)
+	}
+
+	// retry while the original request is not completed
+	_, _, err = s.Begin(ctx, "key-1", fingerprint)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, err.(errors.ErrorResponse).StatusCode())
+	}
+
+	// complete the original request
+	assert.NoError(t, s.Complete(ctx, "key-1", 42))
+
+	// retry of the completed request
+	txId, replay, err := s.Begin(ctx, "key-1", fingerprint)
+	if assert.NoError(t, err) {
+		assert.True(t, replay)
+		assert.EqualValues(t, 42, txId)
+	}
+
+	// same key with a different request
+	other := Fingerprint("/v1/deposits/update", requests.UpdateBalanceRequest{OwnerId: "1", Amount: 200})
+	_, _, err = s.Begin(ctx, "key-1", other)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, err.(errors.ErrorResponse).StatusCode())
+	}
+
+	// same key with the same request to a different endpoint
+	other = Fingerprint("/v1/deposits/transfer", requests.UpdateBalanceRequest{OwnerId: "1", Amount: 100})
+	_, _, err = s.Begin(ctx, "key-1", other)
+	assert.Error(t, err)
+
+	// too long key
+	_, _, err = s.Begin(ctx, strings.Repeat("k", 256), fingerprint)
+	if assert.Error(t, err) {
+		assert.IsType(t, validation.Errors{}, err)
+	}
+}
+
+func TestService_Expiry(t *testing.T) {
+	repo := &mockRepository{}
+	s := NewService(repo, time.Hour, logger)
+	fingerprint := Fingerprint("/v1/deposits/update", requests.UpdateBalanceRequest{OwnerId: "1", Amount: 100})
+
+	_, _, err := s.Begin(ctx, "key-1", fingerprint)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Complete(ctx, "key-1", 42))
+
+	// the key expires, and its next use is a new request even with different parameters
+	repo.items[0].CreatedAt = time.Now().UTC().Add(-2 * time.Hour)
+	other := Fingerprint("/v1/deposits/update", requests.UpdateBalanceRequest{OwnerId: "1", Amount: 200})
+	_, replay, err := s.Begin(ctx, "key-1", other)
+	if assert.NoError(t, err) && assert.Len(t, repo.items, 1) {
+		assert.False(t, replay)
+		assert.Equal(t, other, repo.items[0].Fingerprint)
+		assert.Nil(t, repo.items[0].TransactionId)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	req := requests.TransferRequest{SenderId: "1", RecipientId: "2", Amount: 100}
+	assert.Equal(t, Fingerprint("/a", req), Fingerprint("/a", req))
+	assert.NotEqual(t, Fingerprint("/a", req), Fingerprint("/b", req))
+	assert.Len(t, Fingerprint("/a", req), 64)
+}
+
+type mockRepository struct {
+	items []entity.IdempotencyKey
+}
+
+func (m *mockRepository) Get(ctx context.Context, key string) (entity.IdempotencyKey, error) {
+	for _, item := range m.items {
+		if item.Key == key {
+			return item, nil
+		}
+	}
+	return entity.IdempotencyKey{}, sql.ErrNoRows
+}
+
+func (m *mockRepository) Create(ctx context.Context, key entity.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	for i, item := range m.items {
+		if item.Key == key.Key {
+			if !item.CreatedAt.Before(expiredBefore) {
+				return false, nil
+			}
+			m.items[i] = key
+			return true, nil
+		}
+	}
+	m.items = append(m.items, key)
+	return true, nil
+}
+
+func (m *mockRepository) Update(ctx context.Context, key entity.IdempotencyKey) error {
+	for i, item := range m.items {
+		if item.Key == key.Key {
+			m.items[i] = key
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	var kept []entity.IdempotencyKey
+	for _, item := range m.items {
+		if !item.CreatedAt.Before(before) {
+			kept = append(kept, item)
+		}
+	}
+	deleted := int64(len(m.items) - len(kept))
+	m.items = kept
+	return deleted, nil
+}
@@ -0,0 +1,26 @@
+package ledger
+
+import (
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"users-balance-microservice/pkg/log"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger) {
+	res := resource{service, logger}
+
+	r.Get("/admin/ledger/check", res.check)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) check(c *routing.Context) error {
+	report, err := r.service.Check(c.Request.Context())
+	if err != nil {
+		return err
+	}
+	return c.Write(report)
+}
@@ -0,0 +1,36 @@
+package ledger
+
+import (
+	"net/http"
+	"testing"
+
+	"users-balance-microservice/internal/test"
+)
+
+func TestAPI(t *testing.T) {
+	router := test.MockRouter(logger)
+	RegisterHandlers(router.Group(""), NewService(&mockRepository{}, logger), logger)
+
+	tests := []test.APITestCase{
+		{
+			"check success",
+			"GET",
+			"/admin/ledger/check",
+			"",
+			http.StatusOK,
+			`{"balanced":true,"total":0,"unbalanced_entries":[],"mismatches":[]}`,
+		},
+		{
+			"check failure invalid method",
+			"POST",
+			"/admin/ledger/check",
+			"",
+			http.StatusMethodNotAllowed,
+			"",
+		},
+	}
+
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+}
@@ -0,0 +1,114 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// Repository encapsulates the logic to access the ledger in the database.
+type Repository interface {
+	// Create saves a new JournalEntry together with its Postings.
+	// JournalEntry is assigned an id from database in case of success.
+	Create(ctx context.Context, entry *entity.JournalEntry) error
+	// Balance returns the sum of all Postings against the given account.
+	Balance(ctx context.Context, account string) (int64, error)
+	// Total returns the sum of all Postings in the ledger. It must always be zero.
+	Total(ctx context.Context) (int64, error)
+	// UnbalancedEntries returns ids of JournalEntries whose Postings do not sum up to zero.
+	UnbalancedEntries(ctx context.Context) ([]int64, error)
+	// Mismatches returns Deposits whose balance or reserved funds differ from the ledger.
+	Mismatches(ctx context.Context) ([]Mismatch, error)
+}
+
+// repository persists the ledger in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new ledger repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Create inserts the JournalEntry and then all its Postings with a single statement, so that the deferred check
+// of balanced entries in the database always sees the complete entry.
+func (r repository) Create(ctx context.Context, entry *entity.JournalEntry) error {
+	if err := r.db.With(ctx).Model(entry).Insert(); err != nil {
+		return err
+	}
+
+	values := make([]string, len(entry.Postings))
+	params := dbx.Params{"entry_id": entry.Id}
+	for i := range entry.Postings {
+		entry.Postings[i].EntryId = entry.Id
+		values[i] = fmt.Sprintf("({:entry_id}, {:account%d}, {:amount%d})", i, i)
+		params[fmt.Sprintf("account%d", i)] = entry.Postings[i].Account
+		params[fmt.Sprintf("amount%d", i)] = entry.Postings[i].Amount
+	}
+
+	var ids []int64
+	err := r.db.With(ctx).NewQuery("INSERT INTO posting (entry_id, account, amount) VALUES " +
+		strings.Join(values, ", ") + " RETURNING id").
+		Bind(params).
+		Column(&ids)
+	for i := range ids {
+		entry.Postings[i].Id = ids[i]
+	}
+	return err
+}
+
+// Balance returns the sum of all Postings against the given account.
+func (r repository) Balance(ctx context.Context, account string) (int64, error) {
+	var balance int64
+	err := r.db.With(ctx).Select("COALESCE(SUM(amount), 0)").From("posting").
+		Where(dbx.HashExp{"account": account}).
+		Row(&balance)
+	return balance, err
+}
+
+// Total returns the sum of all Postings in the ledger.
+func (r repository) Total(ctx context.Context) (int64, error) {
+	var total int64
+	err := r.db.With(ctx).Select("COALESCE(SUM(amount), 0)").From("posting").Row(&total)
+	return total, err
+}
+
+// UnbalancedEntries returns ids of JournalEntries whose Postings do not sum up to zero.
+func (r repository) UnbalancedEntries(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	err := r.db.With(ctx).Select("entry_id").From("posting").
+		GroupBy("entry_id").
+		Having(dbx.NewExp("SUM(amount) <> 0")).
+		OrderBy("entry_id").
+		Column(&ids)
+	return ids, err
+}
+
+// Mismatches compares every Deposit with the sums of Postings against its user and reserved accounts
+// in the currency of the Deposit, see CurrencyAccount.
+func (r repository) Mismatches(ctx context.Context) ([]Mismatch, error) {
+	var mismatches []Mismatch
+	err := r.db.With(ctx).NewQuery(`
+		SELECT owner_id, currency, balance, ledger_balance, reserved, ledger_reserved FROM (
+			SELECT d.owner_id, d.currency, d.balance, d.reserved,
+				COALESCE(u.amount, 0) + COALESCE(res.amount, 0) AS ledger_balance,
+				COALESCE(res.amount, 0) AS ledger_reserved
+			FROM (SELECT *, CASE WHEN currency = {:base} THEN '' ELSE ':' || currency END AS suffix FROM deposit) d
+			LEFT JOIN (SELECT account, SUM(amount) AS amount FROM posting GROUP BY account) u
+				ON u.account = {:user} || d.owner_id || d.suffix
+			LEFT JOIN (SELECT account, SUM(amount) AS amount FROM posting GROUP BY account) res
+				ON res.account = {:reserved} || d.owner_id || d.suffix
+		) s
+		WHERE balance <> ledger_balance OR reserved <> ledger_reserved
+		ORDER BY owner_id, currency`).
+		Bind(dbx.Params{"user": userAccountPrefix, "reserved": reservedAccountPrefix, "base": entity.BaseCurrency}).
+		All(&mismatches)
+	return mismatches, err
+}
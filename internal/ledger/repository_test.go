@@ -0,0 +1,95 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "journal_entry", "posting", "deposit")
+	repo := NewRepository(db, logger)
+
+	id1 := uuid.New()
+
+	// create balanced entry
+	entry := entity.JournalEntry{
+		TransactionId: 1,
+		Description:   "top-up",
+		CreatedAt:     time.Now().UTC(),
+		Postings: []entity.Posting{
+			{Account: AccountCashIn, Amount: -1000},
+			{Account: UserAccount(id1), Amount: 1000},
+		},
+	}
+	err := repo.Create(ctx, &entry)
+	if assert.NoError(t, err) {
+		assert.NotZero(t, entry.Id)
+		assert.NotZero(t, entry.Postings[1].Id)
+		assert.Equal(t, entry.Id, entry.Postings[1].EntryId)
+	}
+
+	// balances of accounts
+	balance, err := repo.Balance(ctx, UserAccount(id1))
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1000, balance)
+	}
+	balance, err = repo.Balance(ctx, AccountCashIn)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, -1000, balance)
+	}
+
+	// unbalanced entry is rejected by the database
+	err = db.Transactional(ctx, func(ctx context.Context) error {
+		return repo.Create(ctx, &entity.JournalEntry{
+			TransactionId: 2,
+			CreatedAt:     time.Now().UTC(),
+			Postings:      []entity.Posting{{Account: UserAccount(id1), Amount: 500}},
+		})
+	})
+	assert.Error(t, err)
+
+	// ledger is balanced
+	total, err := repo.Total(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, total)
+	}
+	ids, err := repo.UnbalancedEntries(ctx)
+	if assert.NoError(t, err) {
+		assert.Empty(t, ids)
+	}
+
+	// deposit which agrees with the ledger is not reported, the other one is
+	_, err = db.With(ctx).Insert("deposit", map[string]interface{}{"owner_id": id1, "balance": 1000, "reserved": 0}).Execute()
+	assert.NoError(t, err)
+	id2 := uuid.New()
+	_, err = db.With(ctx).Insert("deposit", map[string]interface{}{"owner_id": id2, "balance": 300, "reserved": 0}).Execute()
+	assert.NoError(t, err)
+
+	// deposit in another currency is compared with the accounts in that currency
+	err = repo.Create(ctx, &entity.JournalEntry{
+		TransactionId: 3,
+		CreatedAt:     time.Now().UTC(),
+		Postings: []entity.Posting{
+			{Account: CurrencyAccount(AccountExchange, "USD"), Amount: -50},
+			{Account: CurrencyAccount(UserAccount(id1), "USD"), Amount: 50},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = db.With(ctx).Insert("deposit", map[string]interface{}{"owner_id": id1, "currency": "USD", "balance": 50, "reserved": 0}).Execute()
+	assert.NoError(t, err)
+
+	mismatches, err := repo.Mismatches(ctx)
+	if assert.NoError(t, err) && assert.Len(t, mismatches, 1) {
+		assert.Equal(t, id2, mismatches[0].OwnerId)
+		assert.Equal(t, entity.BaseCurrency, mismatches[0].Currency)
+		assert.EqualValues(t, 300, mismatches[0].Balance)
+		assert.EqualValues(t, 0, mismatches[0].LedgerBalance)
+	}
+}
@@ -0,0 +1,205 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
+)
+
+const (
+	// AccountCashIn is a system account money comes from on deposit top-ups.
+	AccountCashIn = "system:cash_in"
+	// AccountCashOut is a system account money goes to on deposit withdrawals.
+	AccountCashOut = "system:cash_out"
+	// AccountFees is a system account for fees charged by the platform.
+	AccountFees = "system:fees"
+	// AccountCorrections is a system account which balances corrections made by reconciliation.
+	AccountCorrections = "system:corrections"
+	// AccountExchange is a system account which buys and sells currencies on exchanges between user's Deposits.
+	AccountExchange = "system:exchange"
+
+	userAccountPrefix     = "user:"
+	reservedAccountPrefix = "reserved:"
+)
+
+// UserAccount returns the ledger account of the available funds of user's Deposit.
+func UserAccount(ownerId uuid.UUID) string {
+	return userAccountPrefix + ownerId.String()
+}
+
+// ReservedAccount returns the ledger account of the reserved funds of user's Deposit.
+func ReservedAccount(ownerId uuid.UUID) string {
+	return reservedAccountPrefix + ownerId.String()
+}
+
+// CurrencyAccount returns the account in the currency. Accounts in entity.BaseCurrency have no suffix, accounts
+// in other currencies are suffixed with the currency code, e.g. "user:<uuid>:USD", so that amounts of different
+// currencies are never summed up.
+func CurrencyAccount(account, currency string) string {
+	if currency == "" || currency == entity.BaseCurrency {
+		return account
+	}
+	return account + ":" + currency
+}
+
+// Service encapsulates usecase logic for the double-entry ledger.
+type Service interface {
+	// Record creates a balanced JournalEntry which reflects the given Transaction.
+	Record(ctx context.Context, tx entity.Transaction) error
+	// Check verifies that the ledger is balanced and agrees with balances of all Deposits.
+	Check(ctx context.Context) (Report, error)
+}
+
+// Report represents the result of the ledger check.
+type Report struct {
+	// Balanced is true if the ledger has no problems.
+	Balanced bool `json:"balanced"`
+	// Total is the sum of all Postings in the ledger in minor units of their currencies. Must be zero.
+	Total int64 `json:"total"`
+	// Ids of JournalEntries whose Postings do not sum up to zero.
+	UnbalancedEntries []int64 `json:"unbalanced_entries"`
+	// Deposits whose balance or reserved funds differ from the ledger.
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Mismatch represents a Deposit which disagrees with the ledger. The amounts are in minor units of the Currency.
+type Mismatch struct {
+	OwnerId        uuid.UUID `json:"owner_id"`
+	Currency       string    `json:"currency"`
+	Balance        int64     `json:"balance"`
+	LedgerBalance  int64     `json:"ledger_balance"`
+	Reserved       int64     `json:"reserved"`
+	LedgerReserved int64     `json:"ledger_reserved"`
+}
+
+// MarshalJSON encodes Mismatch with the amounts in major units of the Currency.
+func (m Mismatch) MarshalJSON() ([]byte, error) {
+	type plain Mismatch
+	number := func(amount int64) json.Number {
+		return money.New(amount, entity.CurrencyOrBase(m.Currency)).Number()
+	}
+	return json.Marshal(struct {
+		plain
+		Balance        json.Number `json:"balance"`
+		LedgerBalance  json.Number `json:"ledger_balance"`
+		Reserved       json.Number `json:"reserved"`
+		LedgerReserved json.Number `json:"ledger_reserved"`
+	}{plain(m), number(m.Balance), number(m.LedgerBalance), number(m.Reserved), number(m.LedgerReserved)})
+}
+
+type service struct {
+	repo   Repository
+	logger log.Logger
+}
+
+// NewService creates a new ledger service.
+func NewService(repo Repository, logger log.Logger) Service {
+	return service{repo, logger}
+}
+
+// Record moves the Transaction amount from one ledger account to another. Top-ups come from AccountCashIn,
+// withdrawals go to AccountCashOut, and reservations move money between user and reserved accounts of the Deposit.
+// Refunds move money the opposite way: a refunded withdrawal comes back from AccountCashOut, a reversed top-up goes
+// back to AccountCashIn.
+// All the accounts are in the currency of the Transaction.
+func (s service) Record(ctx context.Context, tx entity.Transaction) error {
+	from, to := accounts(tx)
+	entry := entity.JournalEntry{
+		TransactionId: tx.Id,
+		Description:   tx.Description,
+		CreatedAt:     time.Now().UTC(),
+		Postings: []entity.Posting{
+			{Account: from, Amount: -tx.Amount},
+			{Account: to, Amount: tx.Amount},
+		},
+	}
+	if err := validate(entry); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, &entry)
+}
+
+func (s service) Check(ctx context.Context) (Report, error) {
+	var report Report
+	var err error
+
+	if report.Total, err = s.repo.Total(ctx); err != nil {
+		return Report{}, err
+	}
+	if report.UnbalancedEntries, err = s.repo.UnbalancedEntries(ctx); err != nil {
+		return Report{}, err
+	}
+	if report.Mismatches, err = s.repo.Mismatches(ctx); err != nil {
+		return Report{}, err
+	}
+
+	if report.UnbalancedEntries == nil {
+		report.UnbalancedEntries = []int64{}
+	}
+	if report.Mismatches == nil {
+		report.Mismatches = []Mismatch{}
+	}
+
+	report.Balanced = report.Total == 0 && len(report.UnbalancedEntries) == 0 && len(report.Mismatches) == 0
+	if !report.Balanced {
+		s.logger.With(ctx).Errorf("ledger check failed: total=%d, unbalanced entries=%d, mismatches=%d",
+			report.Total, len(report.UnbalancedEntries), len(report.Mismatches))
+	}
+	return report, nil
+}
+
+// accounts returns the ledger accounts in the currency of the Transaction its amount is moved from and to.
+func accounts(tx entity.Transaction) (from, to string) {
+	from, to = baseAccounts(tx)
+	return CurrencyAccount(from, tx.Currency), CurrencyAccount(to, tx.Currency)
+}
+
+// baseAccounts returns the ledger accounts the Transaction amount is moved from and to regardless of its currency.
+func baseAccounts(tx entity.Transaction) (from, to string) {
+	switch tx.Type {
+	case entity.TransactionTypeHold:
+		return UserAccount(tx.SenderId), ReservedAccount(tx.SenderId)
+	case entity.TransactionTypeCapture:
+		return ReservedAccount(tx.SenderId), AccountCashOut
+	case entity.TransactionTypeRelease:
+		return ReservedAccount(tx.RecipientId), UserAccount(tx.RecipientId)
+	}
+
+	from, to = AccountCashIn, AccountCashOut
+	switch tx.Type {
+	case entity.TransactionTypeCorrection:
+		from, to = AccountCorrections, AccountCorrections
+	case entity.TransactionTypeExchange:
+		from, to = AccountExchange, AccountExchange
+	case entity.TransactionTypeRefund:
+		from, to = AccountCashOut, AccountCashIn
+	}
+	if tx.SenderId != uuid.Nil {
+		from = UserAccount(tx.SenderId)
+	}
+	if tx.RecipientId != uuid.Nil {
+		to = UserAccount(tx.RecipientId)
+	}
+	return from, to
+}
+
+// validate checks that the JournalEntry has non-zero Postings which sum up to zero.
+func validate(entry entity.JournalEntry) error {
+	var sum int64
+	for _, p := range entry.Postings {
+		if p.Amount == 0 {
+			return fmt.Errorf("journal entry for transaction %d has a zero posting", entry.TransactionId)
+		}
+		sum += p.Amount
+	}
+	if len(entry.Postings) < 2 || sum != 0 {
+		return fmt.Errorf("journal entry for transaction %d is not balanced", entry.TransactionId)
+	}
+	return nil
+}
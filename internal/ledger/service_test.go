@@ -0,0 +1,157 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _ = log.NewForTest()
+	ctx       = context.Background()
+)
+
+func TestService_Record(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	s := NewService(repo, logger)
+	reservationId, transferId, withdrawalId := int64(1), int64(2), int64(3)
+
+	txs := []entity.Transaction{
+		{Id: 1, RecipientId: id1, Amount: 1000, Description: "top-up"},
+		{Id: 2, SenderId: id1, RecipientId: id2, Amount: 300, Description: "transfer"},
+		{Id: 3, SenderId: id2, Amount: 100, Description: "withdrawal"},
+		{Id: 4, SenderId: id1, Amount: 200, Type: entity.TransactionTypeHold, ReservationId: &reservationId},
+		{Id: 5, SenderId: id1, Amount: 150, Type: entity.TransactionTypeCapture, ReservationId: &reservationId},
+		{Id: 6, RecipientId: id1, Amount: 50, Type: entity.TransactionTypeRelease, ReservationId: &reservationId},
+		{Id: 7, RecipientId: id2, Amount: 20, Type: entity.TransactionTypeCorrection},
+		{Id: 8, SenderId: id1, Amount: 100, Currency: "RUB", Type: entity.TransactionTypeExchange, ExchangeRate: "0.1"},
+		{Id: 9, RecipientId: id1, Amount: 10, Currency: "USD", Type: entity.TransactionTypeExchange, ExchangeRate: "0.1"},
+		{Id: 10, SenderId: id1, RecipientId: id2, Amount: 4, Currency: "USD", Description: "transfer"},
+		{Id: 11, RecipientId: id2, Amount: 40, Type: entity.TransactionTypeRefund, RefundOf: &withdrawalId},
+		{Id: 12, SenderId: id2, RecipientId: id1, Amount: 100, Type: entity.TransactionTypeRefund, RefundOf: &transferId},
+	}
+	for _, tx := range txs {
+		assert.NoError(t, s.Record(ctx, tx))
+	}
+
+	if assert.Len(t, repo.entries, len(txs)) {
+		for i, entry := range repo.entries {
+			assert.Equal(t, txs[i].Id, entry.TransactionId)
+			assert.NoError(t, validate(entry))
+		}
+	}
+
+	// every entry is balanced, so the whole ledger is balanced
+	total, _ := repo.Total(ctx)
+	assert.Zero(t, total)
+
+	// deposits can be derived from postings
+	assert.EqualValues(t, 1000-300-200+50-100+100, repo.balance(UserAccount(id1)))
+	assert.EqualValues(t, 200-150-50, repo.balance(ReservedAccount(id1)))
+	assert.EqualValues(t, 300-100+20+40-100, repo.balance(UserAccount(id2)))
+	assert.EqualValues(t, -20, repo.balance(AccountCorrections))
+	assert.EqualValues(t, -1000, repo.balance(AccountCashIn))
+	assert.EqualValues(t, 100+150-40, repo.balance(AccountCashOut))
+
+	// amounts of other currencies are kept on separate accounts
+	assert.EqualValues(t, 10-4, repo.balance(CurrencyAccount(UserAccount(id1), "USD")))
+	assert.EqualValues(t, 4, repo.balance(CurrencyAccount(UserAccount(id2), "USD")))
+	assert.EqualValues(t, 100, repo.balance(AccountExchange))
+	assert.EqualValues(t, -10, repo.balance(CurrencyAccount(AccountExchange, "USD")))
+
+	// zero amount transaction is rejected
+	assert.Error(t, s.Record(ctx, entity.Transaction{Id: 13, RecipientId: id1}))
+	assert.Len(t, repo.entries, len(txs))
+}
+
+func TestService_Check(t *testing.T) {
+	id1 := uuid.New()
+	repo := &mockRepository{}
+	s := NewService(repo, logger)
+
+	// empty ledger is balanced
+	report, err := s.Check(ctx)
+	if assert.NoError(t, err) {
+		assert.True(t, report.Balanced)
+		assert.Empty(t, report.UnbalancedEntries)
+		assert.Empty(t, report.Mismatches)
+	}
+
+	// deposit which disagrees with the ledger is reported
+	repo.mismatches = []Mismatch{{OwnerId: id1, Balance: 100, LedgerBalance: 50}}
+	report, err = s.Check(ctx)
+	if assert.NoError(t, err) {
+		assert.False(t, report.Balanced)
+		assert.Equal(t, repo.mismatches, report.Mismatches)
+	}
+
+	// unbalanced entry is reported
+	repo.mismatches = nil
+	repo.entries = append(repo.entries, entity.JournalEntry{Id: 10, Postings: []entity.Posting{{EntryId: 10, Account: AccountFees, Amount: 5}}})
+	report, err = s.Check(ctx)
+	if assert.NoError(t, err) {
+		assert.False(t, report.Balanced)
+		assert.EqualValues(t, 5, report.Total)
+		assert.Equal(t, []int64{10}, report.UnbalancedEntries)
+	}
+}
+
+type mockRepository struct {
+	entries    []entity.JournalEntry
+	mismatches []Mismatch
+}
+
+func (m *mockRepository) Create(ctx context.Context, entry *entity.JournalEntry) error {
+	entry.Id = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, *entry)
+	return nil
+}
+
+func (m *mockRepository) balance(account string) int64 {
+	var balance int64
+	for _, entry := range m.entries {
+		for _, p := range entry.Postings {
+			if p.Account == account {
+				balance += p.Amount
+			}
+		}
+	}
+	return balance
+}
+
+func (m *mockRepository) Balance(ctx context.Context, account string) (int64, error) {
+	return m.balance(account), nil
+}
+
+func (m *mockRepository) Total(ctx context.Context) (int64, error) {
+	var total int64
+	for _, entry := range m.entries {
+		for _, p := range entry.Postings {
+			total += p.Amount
+		}
+	}
+	return total, nil
+}
+
+func (m *mockRepository) UnbalancedEntries(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	for _, entry := range m.entries {
+		var sum int64
+		for _, p := range entry.Postings {
+			sum += p.Amount
+		}
+		if sum != 0 {
+			ids = append(ids, entry.Id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockRepository) Mismatches(ctx context.Context) ([]Mismatch, error) {
+	return m.mismatches, nil
+}
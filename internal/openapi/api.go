@@ -0,0 +1,25 @@
+// Package openapi serves the OpenAPI 3 specification of the HTTP API.
+package openapi
+
+import (
+	_ "embed"
+
+	"github.com/go-ozzo/ozzo-routing/v2"
+)
+
+// Spec is the OpenAPI 3 specification of the deposit endpoints in JSON.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup) {
+	r.Get("/openapi.json", serve)
+}
+
+// serve writes the specification as it is, bypassing the content negotiation which would encode it once more.
+func serve(c *routing.Context) error {
+	c.Response.Header().Set("Content-Type", "application/json")
+	_, err := c.Response.Write(Spec)
+	return err
+}
@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/log"
+)
+
+type spec struct {
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func TestAPI(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := test.MockRouter(logger)
+	RegisterHandlers(router.Group(""))
+
+	test.Endpoint(t, router, test.APITestCase{"get spec", "GET", "/openapi.json", "", http.StatusOK, string(Spec)})
+}
+
+// TestSpec_Routes fails if a route of the deposit endpoints of any API version is missing from the specification.
+func TestSpec_Routes(t *testing.T) {
+	logger, _ := log.NewForTest()
+	router := routing.New()
+	transactionHandler := func(c *routing.Context) error { return c.Next() }
+	deposit.RegisterHandlers(router.Group("/v1"), nil, nil, nil, logger, transactionHandler, nil)
+	deposit.RegisterHandlersV2(router.Group("/v2"), nil, logger)
+
+	s := parse(t)
+	routes := router.Routes()
+	assert.NotEmpty(t, routes)
+	for _, route := range routes {
+		_, ok := s.Paths[route.Path()][strings.ToLower(route.Method())]
+		assert.True(t, ok, "route %s %s is missing from the specification", route.Method(), route.Path())
+	}
+}
+
+// TestSpec_Schemas fails if the schemas do not have the same fields as the structs they describe.
+func TestSpec_Schemas(t *testing.T) {
+	s := parse(t)
+	structs := map[string]interface{}{
+		"GetBalanceRequest":    requests.GetBalanceRequest{},
+		"GetBalancesRequest":   requests.GetBalancesRequest{},
+		"UpdateBalanceRequest": requests.UpdateBalanceRequest{},
+		"TransferRequest":      requests.TransferRequest{},
+		"ExchangeRequest":      requests.ExchangeRequest{},
+		"RefundRequest":        requests.RefundRequest{},
+		"BatchRequest":         requests.BatchRequest{},
+		"BatchOperation":       requests.BatchOperation{},
+		"GetHistoryRequest":    requests.GetHistoryRequest{},
+		"SetStatusRequest":     requests.SetStatusRequest{},
+		"StatusHistoryRequest": requests.StatusHistoryRequest{},
+		"DepositStatusChange":  entity.DepositStatusChange{},
+		"Transaction":          entity.Transaction{},
+		"HistoryItem":          transaction.HistoryItem{},
+		"HistoryPage":          transaction.HistoryPage{},
+		"ErrorResponse":        errors.ErrorResponse{},
+	}
+	for name, v := range structs {
+		schema, ok := s.Components.Schemas[name]
+		if assert.True(t, ok, "schema %s is missing from the specification", name) {
+			var properties []string
+			for property := range schema.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+			assert.Equal(t, jsonFields(v), properties, "schema %s does not match its struct", name)
+		}
+	}
+}
+
+func parse(t *testing.T) spec {
+	var s spec
+	if err := json.Unmarshal(Spec, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// jsonFields returns the sorted JSON names of the struct's fields, including the fields of embedded structs.
+func jsonFields(v interface{}) []string {
+	names := map[string]bool{}
+	collectFields(reflect.TypeOf(v), names)
+
+	var fields []string
+	for name := range names {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func collectFields(typ reflect.Type, names map[string]bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && name == "" {
+			collectFields(field.Type, names)
+		} else if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+}
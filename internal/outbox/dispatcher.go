@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+const (
+	// defaultBatchSize is the maximum number of events delivered in one round.
+	defaultBatchSize = 100
+	// defaultClaimTimeout is how long the events of a round stay claimed if the dispatcher stops before
+	// marking them.
+	defaultClaimTimeout = time.Minute
+)
+
+// Dispatcher delivers pending OutboxEvents to sinks.
+//
+// Every round claims a batch of events in a short DB transaction and sends them to the sinks after it is committed,
+// so no DB transaction or lock is held while the sinks are waited for.
+//
+// Delivery is at-least-once: an event is marked as dispatched only after all sinks have accepted it, so it may be
+// delivered again if the dispatcher stops in between or one of the sinks fails. Events of the same owner are
+// delivered in order: once an event of an owner fails, the following events of that owner wait for the next round.
+type Dispatcher struct {
+	repo          Repository
+	transactional dbcontext.TransactionFunc
+	sinks         []Sink
+	logger        log.Logger
+	batchSize     int
+	claimTimeout  time.Duration
+}
+
+// NewDispatcher creates a new Dispatcher which delivers events to the given sinks.
+// The events of every round are claimed within a DB transaction started by transactional.
+func NewDispatcher(repo Repository, transactional dbcontext.TransactionFunc, logger log.Logger, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{
+		repo:          repo,
+		transactional: transactional,
+		sinks:         sinks,
+		logger:        logger,
+		batchSize:     defaultBatchSize,
+		claimTimeout:  defaultClaimTimeout,
+	}
+}
+
+// Run delivers events every interval until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// keep delivering while full batches of pending events are dispatched
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil {
+				d.logger.With(ctx).Errorf("failed dispatching outbox events: %v", err)
+			}
+			if err != nil || n < d.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch runs a single round of delivery and returns the number of events it has dispatched.
+// If another dispatcher is claiming events at the moment, Dispatch does nothing.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	var events []entity.OutboxEvent
+	err := d.transactional(ctx, func(ctx context.Context) error {
+		locked, err := d.repo.TryLock(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		events, err = d.repo.Claim(ctx, now, now.Add(d.claimTimeout), d.batchSize)
+		return err
+	})
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	var dispatched, released []int64
+	failed := map[uuid.UUID]bool{}
+	for _, e := range events {
+		if failed[e.OwnerId] {
+			released = append(released, e.Id)
+			continue
+		}
+		if err := d.deliver(ctx, NewEvent(e)); err != nil {
+			d.logger.With(ctx, "event_id", e.Id).Infof("failed delivering outbox event: %v", err)
+			failed[e.OwnerId] = true
+			released = append(released, e.Id)
+			continue
+		}
+		dispatched = append(dispatched, e.Id)
+	}
+
+	err = d.transactional(ctx, func(ctx context.Context) error {
+		if err := d.repo.MarkDispatched(ctx, time.Now().UTC(), dispatched...); err != nil {
+			return err
+		}
+		return d.repo.Release(ctx, released...)
+	})
+	return len(dispatched), err
+}
+
+// deliver sends the event to every sink.
+func (d *Dispatcher) deliver(ctx context.Context, event Event) error {
+	for _, sink := range d.sinks {
+		if err := sink.Send(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+)
+
+// inPlace runs the function without a real DB transaction.
+func inPlace(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	for i, owner := range []uuid.UUID{id1, id2, id1, id2} {
+		_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: owner, Type: EventTopUp, Payload: "{}", CreatedAt: time.Now().UTC().Add(time.Duration(i))})
+	}
+
+	sink := NewMemorySink()
+	d := NewDispatcher(repo, inPlace, logger, sink)
+
+	// everything is delivered in order
+	n, err := d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, n)
+		assert.Equal(t, []int64{1, 2, 3, 4}, ids(sink.Events()))
+	}
+
+	// nothing left
+	n, err = d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+		assert.Len(t, sink.Events(), 4)
+	}
+
+	// another dispatcher is running
+	_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: id1, Type: EventWithdrawal, Payload: "{}"})
+	repo.locked = true
+	n, err = d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+		assert.Len(t, sink.Events(), 4)
+	}
+	repo.locked = false
+
+	// failed events are delivered again
+	sink.SetError(databaseError)
+	_, err = d.Dispatch(ctx)
+	assert.NoError(t, err)
+	sink.SetError(nil)
+	_, err = d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids(sink.Events()))
+	}
+
+	// repository error
+	repo.err = databaseError
+	_, err = d.Dispatch(ctx)
+	assert.Equal(t, databaseError, err)
+}
+
+func TestDispatcher_OrderPerOwner(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	for _, owner := range []uuid.UUID{id1, id2, id1, id2} {
+		_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: owner, Type: EventTopUp, Payload: "{}"})
+	}
+
+	// the first event of id1 fails once
+	sink := &failingSink{MemorySink: NewMemorySink(), failures: map[int64]int{1: 1}}
+	memory := NewMemorySink()
+	d := NewDispatcher(repo, inPlace, logger, sink, memory)
+
+	// events of id1 wait for the failed one, events of id2 are delivered
+	_, err := d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int64{2, 4}, ids(memory.Events()))
+	}
+
+	// events of id1 are delivered in order
+	_, err = d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int64{2, 4, 1, 3}, ids(memory.Events()))
+	}
+}
+
+func TestDispatcher_FairPerOwner(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	for _, owner := range []uuid.UUID{id1, id1, id1, id1, id1, id2} {
+		_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: owner, Type: EventTopUp, Payload: "{}"})
+	}
+	sink := NewMemorySink()
+	d := NewDispatcher(repo, inPlace, logger, sink)
+	d.batchSize = 3
+
+	// the backlog of id1 does not take the whole batch
+	n, err := d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []int64{1, 2, 6}, ids(sink.Events()))
+	}
+
+	// events of an owner claimed by another dispatcher are skipped
+	until := time.Now().UTC().Add(time.Hour)
+	repo.items[2].ClaimedUntil = &until
+	_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: id2, Type: EventTopUp, Payload: "{}"})
+	n, err = d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []int64{1, 2, 6, 7}, ids(sink.Events()))
+	}
+
+	// the claim expires
+	repo.items[2].ClaimedUntil = nil
+	_, err = d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int64{1, 2, 6, 7, 3, 4, 5}, ids(sink.Events()))
+	}
+}
+
+func TestDispatcher_OutsideTransaction(t *testing.T) {
+	repo := &mockRepository{}
+	_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: uuid.New(), Type: EventTopUp, Payload: "{}"})
+
+	var inTransaction, sentInTransaction bool
+	transactional := func(ctx context.Context, f func(ctx context.Context) error) error {
+		inTransaction = true
+		defer func() { inTransaction = false }()
+		return f(ctx)
+	}
+	sink := sinkFunc(func(ctx context.Context, event Event) error {
+		sentInTransaction = sentInTransaction || inTransaction
+		return nil
+	})
+	d := NewDispatcher(repo, transactional, logger, sink)
+
+	// events are claimed before and marked after they are sent
+	n, err := d.Dispatch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n)
+		assert.False(t, sentInTransaction)
+		assert.NotNil(t, repo.items[0].DispatchedAt)
+		assert.Nil(t, repo.items[0].ClaimedUntil)
+	}
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	repo := &mockRepository{}
+	for i := 0; i < defaultBatchSize+10; i++ {
+		_ = repo.Create(ctx, &entity.OutboxEvent{OwnerId: uuid.New(), Type: EventTopUp, Payload: "{}"})
+	}
+	sink := NewMemorySink()
+	d := NewDispatcher(repo, inPlace, logger, sink)
+
+	// all batches are delivered at once, and Run stops with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Run(ctx, time.Hour)
+	assert.Len(t, sink.Events(), defaultBatchSize+10)
+}
+
+// failingSink fails to send an event the given number of times.
+type failingSink struct {
+	*MemorySink
+	failures map[int64]int
+}
+
+func (s *failingSink) Send(ctx context.Context, event Event) error {
+	if s.failures[event.Id] > 0 {
+		s.failures[event.Id]--
+		return databaseError
+	}
+	return s.MemorySink.Send(ctx, event)
+}
+
+// sinkFunc sends events by calling itself.
+type sinkFunc func(ctx context.Context, event Event) error
+
+func (f sinkFunc) Send(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+func ids(events []Event) []int64 {
+	var result []int64
+	for _, e := range events {
+		result = append(result, e.Id)
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+const (
+	// dispatcherLockKey is the key of the PostgreSQL advisory lock held by the running dispatcher.
+	dispatcherLockKey = 7001
+	// ownerLockKey is the first key of the PostgreSQL advisory locks held while events of an owner are recorded,
+	// the second key is a hash of the owner id.
+	ownerLockKey = 7004
+)
+
+// Repository encapsulates the logic to access outbox events from the database.
+type Repository interface {
+	// Create saves a new OutboxEvent in the storage.
+	Create(ctx context.Context, event *entity.OutboxEvent) error
+	// LockOwner waits for the lock on recording events of the owner and holds it until the end of the current
+	// DB transaction, so that events of the owner are committed in the order of their ids.
+	LockOwner(ctx context.Context, ownerId uuid.UUID) error
+	// Claim returns up to limit OutboxEvents which were not dispatched yet, ordered by id, and claims them until
+	// the given time. Owners having events claimed at the moment now are skipped. The first pending events of all
+	// owners go before the second ones and so on, so that a single owner cannot take the whole batch.
+	Claim(ctx context.Context, now, until time.Time, limit int) ([]entity.OutboxEvent, error)
+	// Release removes the claims from the OutboxEvents with the given ids, so that they can be claimed again.
+	Release(ctx context.Context, ids ...int64) error
+	// MarkDispatched marks the OutboxEvents with the given ids as dispatched at the given time.
+	MarkDispatched(ctx context.Context, at time.Time, ids ...int64) error
+	// TryLock tries to acquire the dispatcher lock until the end of the current DB transaction.
+	// It reports whether the lock was acquired.
+	TryLock(ctx context.Context) (bool, error)
+}
+
+// repository persists OutboxEvent in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new OutboxEvent repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Create saves a new OutboxEvent record in the database.
+// OutboxEvent is assigned an auto-incremented id from database.
+func (r repository) Create(ctx context.Context, event *entity.OutboxEvent) error {
+	return r.db.With(ctx).Model(event).Insert()
+}
+
+// LockOwner acquires a transaction-level advisory lock keyed by a hash of the owner id. Different owners may share
+// a lock, which only makes them wait for each other.
+func (r repository) LockOwner(ctx context.Context, ownerId uuid.UUID) error {
+	_, err := r.db.With(ctx).NewQuery("SELECT pg_advisory_xact_lock({:key}, hashtext({:owner}))").
+		Bind(dbx.Params{"key": ownerLockKey, "owner": ownerId.String()}).
+		Execute()
+	return err
+}
+
+// Claim numbers the pending OutboxEvents within their owners, takes the first ones of the owners without claimed
+// events and sets claimed_until of the taken events in the database.
+func (r repository) Claim(ctx context.Context, now, until time.Time, limit int) ([]entity.OutboxEvent, error) {
+	var events []entity.OutboxEvent
+	err := r.db.With(ctx).NewQuery(`
+		WITH pending AS (
+			SELECT id FROM (
+				SELECT id,
+					ROW_NUMBER() OVER (PARTITION BY owner_id ORDER BY id) AS position,
+					COALESCE(BOOL_OR(claimed_until > {:now}) OVER (PARTITION BY owner_id), FALSE) AS busy
+				FROM outbox_event
+				WHERE dispatched_at IS NULL
+			) queue
+			WHERE NOT busy
+			ORDER BY position, id
+			LIMIT {:limit}
+		), claimed AS (
+			UPDATE outbox_event SET claimed_until = {:until}
+			FROM pending
+			WHERE outbox_event.id = pending.id
+			RETURNING outbox_event.*
+		)
+		SELECT * FROM claimed ORDER BY id`).
+		Bind(dbx.Params{"now": now, "until": until, "limit": limit}).
+		All(&events)
+	return events, err
+}
+
+// Release clears claimed_until of the OutboxEvents in the database.
+func (r repository) Release(ctx context.Context, ids ...int64) error {
+	return r.update(ctx, dbx.Params{"claimed_until": nil}, ids)
+}
+
+// MarkDispatched sets dispatched_at of the OutboxEvents in the database.
+func (r repository) MarkDispatched(ctx context.Context, at time.Time, ids ...int64) error {
+	return r.update(ctx, dbx.Params{"dispatched_at": at, "claimed_until": nil}, ids)
+}
+
+// update sets the given columns of the OutboxEvents with the given ids.
+func (r repository) update(ctx context.Context, columns dbx.Params, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	_, err := r.db.With(ctx).Update("outbox_event", columns, dbx.In("id", values...)).Execute()
+	return err
+}
+
+// TryLock acquires a transaction-level advisory lock, so that only one dispatcher claims events at a time
+// and events of an owner are never claimed by two dispatchers.
+func (r repository) TryLock(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.db.With(ctx).NewQuery("SELECT pg_try_advisory_xact_lock({:key})").
+		Bind(dbx.Params{"key": dispatcherLockKey}).
+		Row(&locked)
+	return locked, err
+}
@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "outbox_event")
+	repo := NewRepository(db, logger)
+
+	id1 := uuid.New()
+	now := time.Now().UTC()
+
+	// create events
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		event := entity.OutboxEvent{OwnerId: id1, Type: EventTopUp, Payload: `{"id":1}`, CreatedAt: now}
+		if assert.NoError(t, repo.Create(ctx, &event)) {
+			assert.NotZero(t, event.Id)
+			ids = append(ids, event.Id)
+		}
+	}
+
+	id2 := uuid.New()
+	event := entity.OutboxEvent{OwnerId: id2, Type: EventTopUp, Payload: `{"id":2}`, CreatedAt: now}
+	assert.NoError(t, repo.Create(ctx, &event))
+
+	// the first events of every owner go first, claimed events are returned in order
+	until := now.Add(time.Minute)
+	events, err := repo.Claim(ctx, now, until, 3)
+	if assert.NoError(t, err) && assert.Len(t, events, 3) {
+		assert.Equal(t, []int64{ids[0], ids[1], event.Id}, []int64{events[0].Id, events[1].Id, events[2].Id})
+		assert.Equal(t, `{"id":1}`, events[0].Payload)
+		assert.NotNil(t, events[0].ClaimedUntil)
+	}
+
+	// owners with claimed events are skipped until the claim expires
+	events, err = repo.Claim(ctx, now, until, 10)
+	if assert.NoError(t, err) {
+		assert.Empty(t, events)
+	}
+	events, err = repo.Claim(ctx, until.Add(time.Second), until.Add(time.Minute), 10)
+	if assert.NoError(t, err) {
+		assert.Len(t, events, 4)
+	}
+
+	// dispatched events are not claimed again, released ones are
+	assert.NoError(t, repo.MarkDispatched(ctx, now, ids[0], ids[1], event.Id))
+	assert.NoError(t, repo.Release(ctx, ids[2]))
+	events, err = repo.Claim(ctx, now, until, 10)
+	if assert.NoError(t, err) && assert.Len(t, events, 1) {
+		assert.Equal(t, ids[2], events[0].Id)
+	}
+
+	// only one transaction holds the lock
+	err = db.Transactional(ctx, func(ctx context.Context) error {
+		locked, err := repo.TryLock(ctx)
+		assert.NoError(t, err)
+		assert.True(t, locked)
+
+		return db.Transactional(context.Background(), func(other context.Context) error {
+			locked, err := repo.TryLock(other)
+			assert.NoError(t, err)
+			assert.False(t, locked)
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+}
+
+func TestRepository_OrderPerOwner(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "outbox_event")
+	repo := NewRepository(db, logger)
+	r := NewRecorder(repo, logger)
+	id1 := uuid.New()
+
+	// a top-up in RUB is recorded but not committed yet
+	recorded, commit, done := make(chan struct{}), make(chan struct{}), make(chan error, 2)
+	go func() {
+		done <- db.Transactional(ctx, func(ctx context.Context) error {
+			err := r.Record(ctx, entity.Transaction{Id: 1, RecipientId: id1, Amount: 100, Currency: "RUB"})
+			close(recorded)
+			<-commit
+			return err
+		})
+	}()
+	<-recorded
+
+	// a concurrent top-up in USD locks another Deposit, but waits to record its event
+	go func() {
+		done <- db.Transactional(ctx, func(ctx context.Context) error {
+			return r.Record(ctx, entity.Transaction{Id: 2, RecipientId: id1, Amount: 100, Currency: "USD"})
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	now := time.Now().UTC()
+	events, err := repo.Claim(ctx, now, now.Add(time.Minute), 10)
+	if assert.NoError(t, err) {
+		assert.Empty(t, events)
+	}
+
+	// events become visible in the order of their ids
+	close(commit)
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-done)
+	events, err = repo.Claim(ctx, now, now.Add(time.Minute), 10)
+	if assert.NoError(t, err) && assert.Len(t, events, 2) {
+		assert.Less(t, events[0].Id, events[1].Id)
+		assert.Contains(t, events[0].Payload, `"RUB"`)
+		assert.Contains(t, events[1].Payload, `"USD"`)
+	}
+}
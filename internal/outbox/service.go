@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/log"
+)
+
+// Types of events about changes of user's Deposit.
+const (
+	EventTopUp       = "top_up"
+	EventWithdrawal  = "withdrawal"
+	EventTransferIn  = "transfer_in"
+	EventTransferOut = "transfer_out"
+	EventHold        = "hold"
+	EventCapture     = "capture"
+	EventRelease     = "release"
+	EventCorrection  = "correction"
+	EventExchange    = "exchange"
+	EventRefund      = "refund"
+)
+
+// Event represents an OutboxEvent as it is delivered to sinks.
+type Event struct {
+	// Id of the event. Events may be delivered more than once, so consumers should deduplicate them by id.
+	Id      int64     `json:"id"`
+	Type    string    `json:"type"`
+	OwnerId uuid.UUID `json:"owner_id"`
+	// Data is the JSON data of the event, e.g. the Transaction which changed the Deposit.
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NewEvent converts the OutboxEvent into Event.
+func NewEvent(e entity.OutboxEvent) Event {
+	return Event{
+		Id:        e.Id,
+		Type:      e.Type,
+		OwnerId:   e.OwnerId,
+		Data:      json.RawMessage(e.Payload),
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// Recorder writes OutboxEvents about every created Transaction.
+// It is meant to be passed to transaction.NewService, so that events are saved in the same DB transaction
+// as the balance change.
+type Recorder struct {
+	repo   Repository
+	logger log.Logger
+}
+
+// NewRecorder creates a new Recorder.
+func NewRecorder(repo Repository, logger log.Logger) Recorder {
+	return Recorder{repo, logger}
+}
+
+// Record saves an OutboxEvent for every Deposit changed by the Transaction: one for top-ups, withdrawals and
+// reservations, and two for transfers and their refunds. The owners of the events stay locked until the DB
+// transaction ends, so events of an owner are committed in the order of their ids.
+func (r Recorder) Record(ctx context.Context, tx entity.Transaction) error {
+	payload, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+
+	// a Transaction in another currency of the same owner may be recorded concurrently, as it locks another Deposit
+	owners := events(tx)
+	locked := make([]uuid.UUID, 0, len(owners))
+	for _, e := range owners {
+		locked = append(locked, e.ownerId)
+	}
+	// the owners are locked in the same order by all DB transactions to avoid deadlocks
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].String() < locked[j].String()
+	})
+	for _, ownerId := range locked {
+		if err := r.repo.LockOwner(ctx, ownerId); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, e := range owners {
+		event := entity.OutboxEvent{
+			OwnerId:   e.ownerId,
+			Type:      e.eventType,
+			Payload:   string(payload),
+			CreatedAt: now,
+		}
+		if err := r.repo.Create(ctx, &event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ownerEvent struct {
+	ownerId   uuid.UUID
+	eventType string
+}
+
+// events returns the owners the Transaction is about and the types of events for them.
+func events(tx entity.Transaction) []ownerEvent {
+	switch tx.Type {
+	case entity.TransactionTypeHold:
+		return []ownerEvent{{tx.SenderId, EventHold}}
+	case entity.TransactionTypeCapture:
+		return []ownerEvent{{tx.SenderId, EventCapture}}
+	case entity.TransactionTypeRelease:
+		return []ownerEvent{{tx.RecipientId, EventRelease}}
+	case entity.TransactionTypeCorrection:
+		if tx.SenderId != uuid.Nil {
+			return []ownerEvent{{tx.SenderId, EventCorrection}}
+		}
+		return []ownerEvent{{tx.RecipientId, EventCorrection}}
+	case entity.TransactionTypeExchange:
+		if tx.SenderId != uuid.Nil {
+			return []ownerEvent{{tx.SenderId, EventExchange}}
+		}
+		return []ownerEvent{{tx.RecipientId, EventExchange}}
+	case entity.TransactionTypeRefund:
+		var result []ownerEvent
+		for _, ownerId := range []uuid.UUID{tx.SenderId, tx.RecipientId} {
+			if ownerId != uuid.Nil {
+				result = append(result, ownerEvent{ownerId, EventRefund})
+			}
+		}
+		return result
+	}
+
+	switch {
+	case tx.SenderId == uuid.Nil:
+		return []ownerEvent{{tx.RecipientId, EventTopUp}}
+	case tx.RecipientId == uuid.Nil:
+		return []ownerEvent{{tx.SenderId, EventWithdrawal}}
+	default:
+		return []ownerEvent{{tx.SenderId, EventTransferOut}, {tx.RecipientId, EventTransferIn}}
+	}
+}
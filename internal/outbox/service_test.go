@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _     = log.NewForTest()
+	ctx           = context.Background()
+	databaseError = errors.New("database error")
+)
+
+func TestRecorder_Record(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	reservationId, withdrawalId, transferId := int64(1), int64(2), int64(3)
+	repo := &mockRepository{}
+	r := NewRecorder(repo, logger)
+
+	txs := []entity.Transaction{
+		{Id: 1, RecipientId: id1, Amount: 1000},
+		{Id: 2, SenderId: id1, Amount: 100},
+		{Id: 3, SenderId: id1, RecipientId: id2, Amount: 300},
+		{Id: 4, SenderId: id1, Amount: 200, Type: entity.TransactionTypeHold, ReservationId: &reservationId},
+		{Id: 5, SenderId: id1, Amount: 200, Type: entity.TransactionTypeCapture, ReservationId: &reservationId},
+		{Id: 6, RecipientId: id1, Amount: 200, Type: entity.TransactionTypeRelease, ReservationId: &reservationId},
+		{Id: 7, SenderId: id2, Amount: 50, Type: entity.TransactionTypeCorrection},
+		{Id: 8, SenderId: id1, Amount: 100, Currency: "RUB", Type: entity.TransactionTypeExchange, ExchangeRate: "0.1"},
+		{Id: 9, RecipientId: id1, Amount: 10, Currency: "USD", Type: entity.TransactionTypeExchange, ExchangeRate: "0.1"},
+		{Id: 10, RecipientId: id1, Amount: 50, Type: entity.TransactionTypeRefund, RefundOf: &withdrawalId},
+		{Id: 11, SenderId: id2, RecipientId: id1, Amount: 300, Type: entity.TransactionTypeRefund, RefundOf: &transferId},
+	}
+	for _, tx := range txs {
+		assert.NoError(t, r.Record(ctx, tx))
+	}
+
+	expected := []struct {
+		ownerId   uuid.UUID
+		eventType string
+		txId      int64
+	}{
+		{id1, EventTopUp, 1},
+		{id1, EventWithdrawal, 2},
+		{id1, EventTransferOut, 3},
+		{id2, EventTransferIn, 3},
+		{id1, EventHold, 4},
+		{id1, EventCapture, 5},
+		{id1, EventRelease, 6},
+		{id2, EventCorrection, 7},
+		{id1, EventExchange, 8},
+		{id1, EventExchange, 9},
+		{id1, EventRefund, 10},
+		{id2, EventRefund, 11},
+		{id1, EventRefund, 11},
+	}
+	if assert.Len(t, repo.items, len(expected)) {
+		for i, e := range expected {
+			assert.Equal(t, e.ownerId, repo.items[i].OwnerId)
+			assert.Equal(t, e.eventType, repo.items[i].Type)
+
+			var tx entity.Transaction
+			if assert.NoError(t, json.Unmarshal([]byte(repo.items[i].Payload), &tx)) {
+				assert.Equal(t, e.txId, tx.Id)
+			}
+		}
+	}
+
+	// database error
+	repo.err = databaseError
+	assert.Equal(t, databaseError, r.Record(ctx, txs[0]))
+}
+
+func TestRecorder_LockOwners(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	if id2.String() < id1.String() {
+		id1, id2 = id2, id1
+	}
+	repo := &mockRepository{}
+	r := NewRecorder(repo, logger)
+
+	// owners of opposite transfers are locked in the same order
+	assert.NoError(t, r.Record(ctx, entity.Transaction{Id: 1, SenderId: id1, RecipientId: id2, Amount: 100}))
+	assert.NoError(t, r.Record(ctx, entity.Transaction{Id: 2, SenderId: id2, RecipientId: id1, Amount: 100}))
+	assert.NoError(t, r.Record(ctx, entity.Transaction{Id: 3, RecipientId: id2, Amount: 100, Currency: "USD"}))
+	assert.Equal(t, []uuid.UUID{id1, id2, id1, id2, id2}, repo.lockedOwners)
+}
+
+func TestNewEvent(t *testing.T) {
+	id1 := uuid.New()
+	now := time.Now().UTC()
+	event := NewEvent(entity.OutboxEvent{Id: 5, OwnerId: id1, Type: EventTopUp, Payload: `{"id":1}`, CreatedAt: now})
+
+	data, err := json.Marshal(event)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(data), `"data":{"id":1}`)
+		assert.Contains(t, string(data), `"owner_id":"`+id1.String()+`"`)
+	}
+}
+
+type mockRepository struct {
+	items        []entity.OutboxEvent
+	locked       bool
+	lockedOwners []uuid.UUID
+	err          error
+}
+
+func (m *mockRepository) LockOwner(ctx context.Context, ownerId uuid.UUID) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.lockedOwners = append(m.lockedOwners, ownerId)
+	return nil
+}
+
+func (m *mockRepository) Create(ctx context.Context, event *entity.OutboxEvent) error {
+	if m.err != nil {
+		return m.err
+	}
+	event.Id = int64(len(m.items) + 1)
+	m.items = append(m.items, *event)
+	return nil
+}
+
+// Claim takes the pending events in rounds: the n-th round takes the n-th event of every owner without claims.
+func (m *mockRepository) Claim(ctx context.Context, now, until time.Time, limit int) ([]entity.OutboxEvent, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	busy := map[uuid.UUID]bool{}
+	position := map[int64]int{}
+	count := map[uuid.UUID]int{}
+	for _, item := range m.items {
+		if item.DispatchedAt != nil {
+			continue
+		}
+		if item.ClaimedUntil != nil && item.ClaimedUntil.After(now) {
+			busy[item.OwnerId] = true
+		}
+		count[item.OwnerId]++
+		position[item.Id] = count[item.OwnerId]
+	}
+
+	claimed := map[int64]bool{}
+	for round := 1; len(claimed) < limit; round++ {
+		found := false
+		for _, item := range m.items {
+			if position[item.Id] == round && !busy[item.OwnerId] && len(claimed) < limit {
+				claimed[item.Id] = true
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	var result []entity.OutboxEvent
+	for i := range m.items {
+		if claimed[m.items[i].Id] {
+			m.items[i].ClaimedUntil = &until
+			result = append(result, m.items[i])
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepository) Release(ctx context.Context, ids ...int64) error {
+	for _, id := range ids {
+		for i := range m.items {
+			if m.items[i].Id == id {
+				m.items[i].ClaimedUntil = nil
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockRepository) MarkDispatched(ctx context.Context, at time.Time, ids ...int64) error {
+	for _, id := range ids {
+		for i := range m.items {
+			if m.items[i].Id == id {
+				m.items[i].DispatchedAt = &at
+				m.items[i].ClaimedUntil = nil
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockRepository) TryLock(ctx context.Context) (bool, error) {
+	return !m.locked, m.err
+}
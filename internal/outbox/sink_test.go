@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSink(t *testing.T) {
+	var received []Event
+	var headers []http.Header
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var event Event
+		_ = json.Unmarshal(body, &event)
+		received = append(received, event)
+		headers = append(headers, r.Header)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPSink(server.URL, time.Second)
+	event := Event{Id: 1, Type: EventTopUp, OwnerId: uuid.New(), Data: json.RawMessage(`{"id":1}`)}
+
+	// success
+	if assert.NoError(t, sink.Send(ctx, event)) && assert.Len(t, received, 1) {
+		assert.Equal(t, event.Id, received[0].Id)
+		assert.Equal(t, event.OwnerId, received[0].OwnerId)
+		assert.JSONEq(t, `{"id":1}`, string(received[0].Data))
+		assert.Equal(t, "application/json", headers[0].Get("Content-Type"))
+		assert.Equal(t, "1", headers[0].Get("X-Event-Id"))
+		assert.Equal(t, EventTopUp, headers[0].Get("X-Event-Type"))
+	}
+
+	// webhook failure
+	status = http.StatusServiceUnavailable
+	assert.Error(t, sink.Send(ctx, event))
+
+	// webhook unavailable
+	server.Close()
+	assert.Error(t, sink.Send(ctx, event))
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := NewFileSink(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		assert.NoError(t, sink.Send(ctx, Event{Id: i, Type: EventTopUp, Data: json.RawMessage(`{}`)}))
+	}
+	assert.NoError(t, sink.Close())
+
+	// events are appended to the existing file
+	sink, err = NewFileSink(path)
+	if assert.NoError(t, err) {
+		assert.NoError(t, sink.Send(ctx, Event{Id: 4, Type: EventTopUp, Data: json.RawMessage(`{}`)}))
+		assert.NoError(t, sink.Close())
+	}
+
+	file, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+
+	var lines []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event)) {
+			lines = append(lines, event)
+		}
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids(lines))
+
+	// invalid path
+	_, err = NewFileSink(filepath.Join(t.TempDir(), "missing", "events.ndjson"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package rates
+
+import (
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service *Service, logger log.Logger) {
+	res := resource{service, logger}
+
+	r.Get("/rates", res.get)
+}
+
+type resource struct {
+	service *Service
+	logger  log.Logger
+}
+
+func (r resource) get(c *routing.Context) error {
+	var input requests.GetRateRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	rate, err := r.service.GetAt(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(rate)
+}
@@ -0,0 +1,34 @@
+package rates
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/pkg/log"
+)
+
+func TestAPI(t *testing.T) {
+	logger, _ := log.NewForTest()
+	fetchedAt := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockRepository{items: []entity.Rate{
+		{Id: 1, Source: ProviderCBR, FetchedAt: fetchedAt, Rates: `{"USD":0.0137}`},
+	}}
+	router := test.MockRouter(logger)
+	RegisterHandlers(router.Group(""), NewService(repo, time.Minute, time.Hour, logger), logger)
+
+	tests := []test.APITestCase{
+		{"get success", "GET", "/rates?currency=USD&at=2021-11-10T13:00:00Z", "", http.StatusOK,
+			`{"id":1,"currency":"USD","rate":0.0137,"source":"cbr","fetched_at":"2021-11-10T12:00:00Z"}`},
+		{"get failure not found", "GET", "/rates?currency=USD&at=2021-11-10T11:00:00Z", "", http.StatusNotFound, ""},
+		{"get failure unknown currency", "GET", "/rates?currency=EUR&at=2021-11-10T13:00:00Z", "", http.StatusNotFound, ""},
+		{"get failure missing at", "GET", "/rates?currency=USD", "", http.StatusBadRequest, `*"at"*`},
+		{"get failure invalid at", "GET", "/rates?currency=USD&at=yesterday", "", http.StatusBadRequest, ""},
+		{"get failure base currency", "GET", "/rates?currency=RUB&at=2021-11-10T13:00:00Z", "", http.StatusBadRequest, `*"currency"*`},
+	}
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+}
@@ -0,0 +1,57 @@
+package rates
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// breaker is a circuit breaker of a Provider. After maxFailures consecutive failures the circuit opens and the provider
+// is not requested for cooldown. Then a single trial request is let through: its success closes the circuit,
+// its failure opens it for another cooldown.
+type breaker struct {
+	mu          sync.Mutex
+	failures    int
+	openedAt    time.Time
+	maxFailures int
+	cooldown    time.Duration
+	now         func() time.Time
+}
+
+func newBreaker(maxFailures int, cooldown time.Duration, now func() time.Time) *breaker {
+	return &breaker{maxFailures: maxFailures, cooldown: cooldown, now: now}
+}
+
+// Allow reports whether the provider may be requested now.
+func (b *breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.maxFailures {
+		return true
+	}
+	if b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	// let a single trial request through, the next one waits for another cooldown
+	b.openedAt = b.now()
+	return true
+}
+
+// Success closes the circuit.
+func (b *breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+// Failure counts a failed request and opens the circuit once there are maxFailures of them in a row.
+func (b *breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.maxFailures {
+		b.openedAt = b.now()
+	}
+}
@@ -1,43 +1,43 @@
 package rates
 
 import (
-	"github.com/patrickmn/go-cache"
+	"sync"
+	"time"
 )
 
-// CacheService handles in-memory caching of exchange rates.
+// CacheService keeps the last known exchange rates in memory as a Snapshot.
+// The rates are never evicted: whether they are still fresh enough is decided by the service.
 type CacheService struct {
-	store *cache.Cache
+	mu       sync.RWMutex
+	snapshot Snapshot
 }
 
-// NewCacheService creates a new handler for this service.
-func NewCacheService(store *cache.Cache) *CacheService {
-	return &CacheService{store}
+// NewCacheService creates a new empty cache.
+func NewCacheService() *CacheService {
+	return &CacheService{}
 }
 
-// Get will return our in-memory stored currency/rates.
-func (s *CacheService) Get(code string) (float32, bool) {
-	if x, found := s.store.Get(code); found {
-		return x.(float32), found
-	}
-	return 0, false
+// Get will return our in-memory stored rate of the currency together with the time it was fetched at
+// and the id of its set.
+func (s *CacheService) Get(code string) (Rate, bool) {
+	return s.Snapshot().Get(code)
 }
 
-// Store unpacks currencies and corresponding rates from ratesResponse and saves them to cache.
-func (s *CacheService) Store(rsp *ratesResponse) {
-	for code, rate := range rsp.Rates {
-		s.store.Set(code, rate, cache.DefaultExpiration)
-	}
+// Snapshot returns all cached rates. The returned Snapshot is not affected by later updates of the cache.
+func (s *CacheService) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot
 }
 
-// IsExpired checks whether the rate stored is expired.
-func (s *CacheService) IsExpired(code string) bool {
-	if _, found := s.store.Get(code); found {
-		return false
-	}
-	return true
+// Store replaces all cached rates with the given ones fetched at the given time and saved with the given id.
+func (s *CacheService) Store(rates map[string]float32, at time.Time, id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = NewSnapshot(rates, at, id)
 }
 
-// Expire will expire the cache for a given currency code.
-func (s *CacheService) Expire(code string) {
-	s.store.Delete(code)
+// UpdatedAt returns the time the cached rates were fetched at, or zero time if they were never fetched.
+func (s *CacheService) UpdatedAt() time.Time {
+	return s.Snapshot().Time
 }
@@ -0,0 +1,239 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+	"gopkg.in/yaml.v2"
+	"users-balance-microservice/internal/config"
+)
+
+const (
+	// ProviderExchangeRateHost is the kind of the provider of exchangerate.host API rates.
+	ProviderExchangeRateHost = "exchangerate_host"
+	// ProviderCBR is the kind of the provider of the Central Bank of Russia daily rates.
+	ProviderCBR = "cbr"
+	// ProviderFile is the kind of the provider of rates from a static YAML or JSON file.
+	ProviderFile = "file"
+
+	// DefaultExchangeRateHostURL is the endpoint of the latest exchangerate.host rates.
+	DefaultExchangeRateHostURL = "https://api.exchangerate.host/latest"
+	// DefaultCBRURL is the endpoint of the Central Bank of Russia daily XML feed.
+	DefaultCBRURL = "https://www.cbr.ru/scripts/XML_daily.asp"
+
+	// providerTimeout limits a whole request to a rates API, providerHeaderTimeout - waiting for its response headers.
+	providerTimeout       = 10 * time.Second
+	providerHeaderTimeout = 5 * time.Second
+)
+
+var errNoRates = errors.New("provider returned no rates")
+
+// Provider is a source of exchange rates.
+type Provider interface {
+	// Name returns the name of the provider used in logs.
+	Name() string
+	// Fetch returns the rates of all currencies known to the provider as units of the currency per 1 RUB.
+	Fetch(ctx context.Context) (map[string]float32, error)
+}
+
+// NewProvider creates the Provider described by the config. The URLs of API providers default to the public ones.
+func NewProvider(c config.RatesProvider) (Provider, error) {
+	client := newHTTPClient()
+	switch c.Kind {
+	case ProviderExchangeRateHost:
+		return NewExchangeRateHostProvider(orDefault(c.URL, DefaultExchangeRateHostURL), client), nil
+	case ProviderCBR:
+		return NewCBRProvider(orDefault(c.URL, DefaultCBRURL), client), nil
+	case ProviderFile:
+		if c.Path == "" {
+			return nil, errors.New("file rates provider requires a path")
+		}
+		return NewFileProvider(c.Path), nil
+	}
+	return nil, fmt.Errorf("unknown rates provider %q", c.Kind)
+}
+
+// ExchangeRateHostProvider fetches the latest rates from the exchangerate.host API.
+type ExchangeRateHostProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewExchangeRateHostProvider creates a new ExchangeRateHostProvider which requests the given URL.
+func NewExchangeRateHostProvider(url string, client *http.Client) ExchangeRateHostProvider {
+	return ExchangeRateHostProvider{url, client}
+}
+
+// ratesResponse holds an API response with a list of RUB\CURRENCY ratios for all currencies.
+type ratesResponse struct {
+	Rates map[string]float32 `json:"rates"`
+}
+
+// Name returns the name of the provider.
+func (p ExchangeRateHostProvider) Name() string {
+	return ProviderExchangeRateHost
+}
+
+// Fetch requests all RUB/CURRENCY rates from the API.
+func (p ExchangeRateHostProvider) Fetch(ctx context.Context) (map[string]float32, error) {
+	body, err := get(ctx, p.client, fmt.Sprintf("%s?base=%s", p.url, baseCurrency))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	latest := ratesResponse{}
+	if err = json.NewDecoder(body).Decode(&latest); err != nil {
+		return nil, err
+	}
+	return nonEmpty(latest.Rates)
+}
+
+// CBRProvider fetches the daily rates from the XML feed of the Central Bank of Russia.
+// The feed quotes rubles per Nominal units of a currency, e.g. 100 JPY, which are converted to units per 1 RUB.
+type CBRProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewCBRProvider creates a new CBRProvider which requests the given URL.
+func NewCBRProvider(url string, client *http.Client) CBRProvider {
+	return CBRProvider{url, client}
+}
+
+// cbrResponse holds the daily rates of the Central Bank of Russia.
+type cbrResponse struct {
+	Valutes []struct {
+		CharCode string `xml:"CharCode"`
+		Nominal  string `xml:"Nominal"`
+		Value    string `xml:"Value"`
+	} `xml:"Valute"`
+}
+
+// Name returns the name of the provider.
+func (p CBRProvider) Name() string {
+	return ProviderCBR
+}
+
+// Fetch requests the daily rates from the feed.
+func (p CBRProvider) Fetch(ctx context.Context) (map[string]float32, error) {
+	body, err := get(ctx, p.client, p.url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	daily := cbrResponse{}
+	decoder := xml.NewDecoder(body)
+	decoder.CharsetReader = charsetReader
+	if err = decoder.Decode(&daily); err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]float32, len(daily.Valutes))
+	for _, valute := range daily.Valutes {
+		nominal, err := strconv.ParseFloat(valute.Nominal, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nominal of %s: %w", valute.CharCode, err)
+		}
+		// the feed uses a decimal comma, e.g. 72,5250
+		value, err := strconv.ParseFloat(strings.Replace(valute.Value, ",", ".", 1), 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid value of %s: %q", valute.CharCode, valute.Value)
+		}
+		rates[valute.CharCode] = float32(nominal / value)
+	}
+	return nonEmpty(rates)
+}
+
+// charsetReader decodes the windows-1251 encoding of the CBR feed.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	if strings.EqualFold(label, "windows-1251") {
+		return charmap.Windows1251.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", label)
+}
+
+// FileProvider reads static rates from a YAML or JSON file of the form {"rates": {"USD": 0.0137}}.
+// The file is read on every Fetch, so it can be updated without restarting the service.
+type FileProvider struct {
+	path string
+}
+
+// NewFileProvider creates a new FileProvider which reads the file at the given path.
+func NewFileProvider(path string) FileProvider {
+	return FileProvider{path}
+}
+
+// fileRates holds the contents of a rates file. JSON is valid YAML, so both formats are decoded as YAML.
+type fileRates struct {
+	Rates map[string]float32 `yaml:"rates"`
+}
+
+// Name returns the name of the provider.
+func (p FileProvider) Name() string {
+	return ProviderFile
+}
+
+// Fetch reads the rates from the file.
+func (p FileProvider) Fetch(ctx context.Context) (map[string]float32, error) {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := fileRates{}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return nonEmpty(file.Rates)
+}
+
+// newHTTPClient creates a client for rates APIs which never waits for a slow API longer than providerTimeout.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = providerHeaderTimeout
+	return &http.Client{Timeout: providerTimeout, Transport: transport}
+}
+
+// get requests the URL within the context and returns the response body if the request succeeded.
+func get(ctx context.Context, client *http.Client, url string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("rates API responded with status %d", response.StatusCode)
+	}
+	return response.Body, nil
+}
+
+// nonEmpty returns an error if there are no rates, e.g. because an API has changed its response format.
+func nonEmpty(rates map[string]float32) (map[string]float32, error) {
+	if len(rates) == 0 {
+		return nil, errNoRates
+	}
+	return rates, nil
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package rates
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/config"
+)
+
+var ctx = context.Background()
+
+// serveFixture starts a server which responds with the contents of the file from testdata and records request URLs.
+func serveFixture(t *testing.T, fixture string, status int, urls *[]string) *httptest.Server {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if urls != nil {
+			*urls = append(*urls, r.URL.String())
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExchangeRateHostProvider(t *testing.T) {
+	var urls []string
+	server := serveFixture(t, "exchangerate_host.json", http.StatusOK, &urls)
+
+	rates, err := NewExchangeRateHostProvider(server.URL+"/latest", http.DefaultClient).Fetch(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"/latest?base=RUB"}, urls)
+		assert.Equal(t, float32(0.013929), rates["USD"])
+		assert.Equal(t, float32(1.572), rates["JPY"])
+		assert.Len(t, rates, 4)
+	}
+
+	// the API changed its response format
+	server = serveFixture(t, "cbr_daily.xml", http.StatusOK, nil)
+	_, err = NewExchangeRateHostProvider(server.URL, http.DefaultClient).Fetch(ctx)
+	assert.Error(t, err)
+
+	// the API responded with no rates
+	server = serveFixture(t, "exchangerate_host_error.json", http.StatusOK, nil)
+	_, err = NewExchangeRateHostProvider(server.URL, http.DefaultClient).Fetch(ctx)
+	assert.Equal(t, errNoRates, err)
+
+	// the API failed
+	server = serveFixture(t, "exchangerate_host.json", http.StatusInternalServerError, nil)
+	_, err = NewExchangeRateHostProvider(server.URL, http.DefaultClient).Fetch(ctx)
+	assert.EqualError(t, err, "rates API responded with status 500")
+}
+
+func TestCBRProvider(t *testing.T) {
+	server := serveFixture(t, "cbr_daily.xml", http.StatusOK, nil)
+
+	rates, err := NewCBRProvider(server.URL, http.DefaultClient).Fetch(ctx)
+	if assert.NoError(t, err) && assert.Len(t, rates, 3) {
+		assert.Equal(t, float32(1/71.7846), rates["USD"])
+		assert.Equal(t, float32(1/83.1069), rates["EUR"])
+		// the rate is quoted per 100 JPY
+		assert.Equal(t, float32(100/63.5820), rates["JPY"])
+	}
+
+	// not an XML feed
+	server = serveFixture(t, "exchangerate_host.json", http.StatusOK, nil)
+	_, err = NewCBRProvider(server.URL, http.DefaultClient).Fetch(ctx)
+	assert.Error(t, err)
+
+	// the feed is unavailable
+	server = serveFixture(t, "cbr_daily.xml", http.StatusServiceUnavailable, nil)
+	_, err = NewCBRProvider(server.URL, http.DefaultClient).Fetch(ctx)
+	assert.Error(t, err)
+}
+
+func TestFileProvider(t *testing.T) {
+	for _, file := range []string{"rates.yml", "rates.json"} {
+		rates, err := NewFileProvider(filepath.Join("testdata", file)).Fetch(ctx)
+		if assert.NoError(t, err, file) {
+			assert.Equal(t, map[string]float32{"USD": 0.0125, "EUR": 0.011}, rates, file)
+		}
+	}
+
+	// missing file
+	_, err := NewFileProvider(filepath.Join("testdata", "missing.yml")).Fetch(ctx)
+	assert.Error(t, err)
+
+	// file without rates
+	_, err = NewFileProvider(filepath.Join("testdata", "cbr_daily.xml")).Fetch(ctx)
+	assert.Error(t, err)
+}
+
+func TestNewProvider(t *testing.T) {
+	provider, err := NewProvider(config.RatesProvider{Kind: ProviderExchangeRateHost})
+	if assert.NoError(t, err) {
+		assert.Equal(t, DefaultExchangeRateHostURL, provider.(ExchangeRateHostProvider).url)
+	}
+
+	provider, err = NewProvider(config.RatesProvider{Kind: ProviderCBR, URL: "http://localhost/daily"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://localhost/daily", provider.(CBRProvider).url)
+	}
+
+	provider, err = NewProvider(config.RatesProvider{Kind: ProviderFile, Path: "rates.yml"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, ProviderFile, provider.Name())
+	}
+
+	_, err = NewProvider(config.RatesProvider{Kind: ProviderFile})
+	assert.Error(t, err)
+
+	_, err = NewProvider(config.RatesProvider{Kind: "unknown"})
+	assert.EqualError(t, err, `unknown rates provider "unknown"`)
+}
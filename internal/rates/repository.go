@@ -0,0 +1,50 @@
+package rates
+
+import (
+	"context"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// Repository encapsulates the logic to access fetched exchange rates from the database.
+type Repository interface {
+	// Create saves a new fetched Rate set in the storage.
+	// Rate is assigned an id from database in case of success.
+	Create(ctx context.Context, rate *entity.Rate) error
+	// GetAt returns the latest Rate set fetched not later than at which contains the rate of the currency.
+	// It returns sql.ErrNoRows if there is no such set.
+	GetAt(ctx context.Context, currency string, at time.Time) (entity.Rate, error)
+}
+
+// repository persists Rate in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new Rate repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Create saves a new Rate record in the database.
+// Rate is assigned an auto-incremented id from database.
+func (r repository) Create(ctx context.Context, rate *entity.Rate) error {
+	return r.db.With(ctx).Model(rate).Insert()
+}
+
+// GetAt reads the latest Rate record fetched not later than at whose rates have the currency key.
+func (r repository) GetAt(ctx context.Context, currency string, at time.Time) (entity.Rate, error) {
+	var rate entity.Rate
+	err := r.db.With(ctx).Select().
+		Where(dbx.NewExp("fetched_at <= {:at} AND (rates ->> {:currency}) IS NOT NULL",
+			dbx.Params{"at": at.UTC(), "currency": currency})).
+		OrderBy("fetched_at DESC", "id DESC").
+		Limit(1).
+		One(&rate)
+	return rate, err
+}
@@ -0,0 +1,55 @@
+package rates
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/pkg/log"
+)
+
+func TestRepository(t *testing.T) {
+	logger, _ := log.NewForTest()
+	db := test.DB(t)
+	test.ResetTables(t, db, "rate")
+	repo := NewRepository(db, logger)
+
+	fetchedAt := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	rates := []entity.Rate{
+		{Source: ProviderCBR, FetchedAt: fetchedAt, Rates: `{"USD": 0.0137, "EUR": 0.0121}`},
+		{Source: ProviderExchangeRateHost, FetchedAt: fetchedAt.Add(time.Hour), Rates: `{"USD": 0.0139}`},
+	}
+	for i := range rates {
+		if assert.NoError(t, repo.Create(ctx, &rates[i])) {
+			assert.NotZero(t, rates[i].Id)
+		}
+	}
+
+	// the latest set fetched before the time
+	rate, err := repo.GetAt(ctx, "USD", fetchedAt.Add(90*time.Minute))
+	if assert.NoError(t, err) {
+		assert.Equal(t, rates[1].Id, rate.Id)
+		assert.Equal(t, ProviderExchangeRateHost, rate.Source)
+		assert.Equal(t, fetchedAt.Add(time.Hour), rate.FetchedAt.UTC())
+	}
+	rate, err = repo.GetAt(ctx, "USD", fetchedAt)
+	if assert.NoError(t, err) {
+		assert.Equal(t, rates[0].Id, rate.Id)
+	}
+
+	// the latest set which has the currency
+	rate, err = repo.GetAt(ctx, "EUR", fetchedAt.Add(90*time.Minute))
+	if assert.NoError(t, err) {
+		assert.Equal(t, rates[0].Id, rate.Id)
+		assert.JSONEq(t, `{"USD": 0.0137, "EUR": 0.0121}`, rate.Rates)
+	}
+
+	// nothing was fetched before the time or the currency is unknown
+	_, err = repo.GetAt(ctx, "USD", fetchedAt.Add(-time.Minute))
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = repo.GetAt(ctx, "JPY", fetchedAt.Add(time.Hour))
+	assert.Equal(t, sql.ErrNoRows, err)
+}
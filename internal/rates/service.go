@@ -1,89 +1,253 @@
 package rates
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
-	"github.com/patrickmn/go-cache"
+	"golang.org/x/sync/singleflight"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/requests"
 	"users-balance-microservice/pkg/log"
 )
 
 const (
 	baseCurrency = "RUB"
-	apiPath      = "https://api.exchangerate.host/latest"
+
+	// fetchTimeout limits a single refresh of the rates through all providers.
+	fetchTimeout = 15 * time.Second
+	// retryInterval is the delay before the next background refresh after a failed one.
+	retryInterval = 10 * time.Second
+	// breakerFailures is the number of consecutive failures of a provider which opens its circuit breaker.
+	breakerFailures = 3
+	// breakerCooldown is the time a provider with an open circuit breaker is not requested for.
+	breakerCooldown = time.Minute
 )
 
-var currencyUnavailableError = errors.New("currency is not present in either cache or API response")
+var (
+	currencyUnavailableError = errors.New("currency is not present in either cache or API response")
+	errNoProviders           = errors.New("no rates providers are configured")
+)
 
 // ExchangeRatesService provides exchange rates for currencies.
 type ExchangeRatesService interface {
 	// Get returns the exchange ratio for specific currency code against baseCurrency(RUB).
-	Get(code string) (float32, error)
+	Get(ctx context.Context, code string) (Rate, error)
+	// Snapshot returns the rates of all currencies fetched at once, so that several conversions can be made
+	// at consistent rates.
+	Snapshot(ctx context.Context) (Snapshot, error)
 }
 
-type service struct {
-	cache  *CacheService
-	logger log.Logger
+// Rate is an exchange rate of a currency: units of the currency per 1 RUB, the time the rate was fetched at and
+// the id of the saved entity.Rate set it belongs to. The rate of the base currency itself has no Id.
+type Rate struct {
+	Value float32
+	Time  time.Time
+	Id    int64
 }
 
-// NewService creates a new exchange rates service.
-func NewService(expiry time.Duration, logger log.Logger) ExchangeRatesService {
-	store := cache.New(expiry, 5*time.Minute)
-	cacheService := NewCacheService(store)
-	return service{cache: cacheService, logger: logger}
+// HistoricalRate is the rate of a currency from a saved entity.Rate set: units of the currency per 1 RUB.
+type HistoricalRate struct {
+	Id        int64     `json:"id"`
+	Currency  string    `json:"currency"`
+	Rate      float32   `json:"rate"`
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
 }
 
-// ratesResponse holds an API response with a list of RUB\CURRENCY ratios for all currencies.
-type ratesResponse struct {
-	Rates map[string]float32 `json:"rates"`
+// Service is an ExchangeRatesService which caches the rates of the providers.
+//
+// The rates are fresh for expiry after they were fetched. Run refreshes them in background shortly before that,
+// so requests normally never wait for providers. Stale rates are still served up to maxStaleness while they are
+// refreshed in background, which keeps conversions working during an outage of all providers. Concurrent refreshes
+// are deduplicated into a single request to the providers. Every fetched set of rates is saved to the repository,
+// so that conversions can refer to the rates they were made at.
+type Service struct {
+	repo          Repository
+	cache         *CacheService
+	providers     []Provider
+	breakers      []*breaker
+	expiry        time.Duration
+	maxStaleness  time.Duration
+	retryInterval time.Duration
+	group         singleflight.Group
+	logger        log.Logger
+	now           func() time.Time
 }
 
-// Get will fetch a single rate for a given currency either from the cache or the API.
-func (s service) Get(code string) (float32, error) {
-	if code == baseCurrency {
-		return 1, nil
+// NewService creates a new exchange rates service. The rates are fetched from the providers in the given order:
+// if a provider fails, the next one is used.
+func NewService(repo Repository, expiry, maxStaleness time.Duration, logger log.Logger, providers ...Provider) *Service {
+	s := &Service{
+		repo:          repo,
+		cache:         NewCacheService(),
+		providers:     providers,
+		expiry:        expiry,
+		maxStaleness:  maxStaleness,
+		retryInterval: retryInterval,
+		logger:        logger,
+		now:           time.Now,
+	}
+	for range providers {
+		s.breakers = append(s.breakers, newBreaker(breakerFailures, breakerCooldown, s.clock))
 	}
+	return s
+}
 
-	// If we have cached results, use them.
-	if result, ok := s.cache.Get(code); ok {
-		return result, nil
+// Get will return a single rate for a given currency from the cache, fetching the rates first if there are no
+// cached rates or they are older than maxStaleness.
+func (s *Service) Get(ctx context.Context, code string) (Rate, error) {
+	if code == baseCurrency {
+		return Rate{Value: 1, Time: s.clock()}, nil
 	}
 
-	// No cached results, go and fetch them.
-	if err := s.fetch(); err != nil {
-		s.logger.Error("failed to fetch currency rates: ", err)
-		return 0, err
+	snapshot, err := s.Snapshot(ctx)
+	if err != nil {
+		return Rate{}, err
 	}
 
 	// Currency should be in cache by now. If failed, then particular currency is unavailable in service right now.
-	if result, ok := s.cache.Get(code); ok {
+	if result, ok := snapshot.Get(code); ok {
 		return result, nil
 	} else {
 		s.logger.Info(fmt.Sprintf("client requested rate for \"%s\", which was not found in API response", code))
-		return 0, currencyUnavailableError
+		return Rate{}, currencyUnavailableError
 	}
 }
 
-// Fetch all RUB/CURRENCY rates from API.
-func (s service) fetch() error {
-	fullUrl := fmt.Sprintf("%s?base=%s", apiPath, baseCurrency)
-	response, err := http.Get(fullUrl)
+// Snapshot returns all cached rates, fetching them first if there are no cached rates or they are older than
+// maxStaleness.
+func (s *Service) Snapshot(ctx context.Context) (Snapshot, error) {
+	updatedAt := s.cache.UpdatedAt()
+	age := s.clock().Sub(updatedAt)
+	switch {
+	case !updatedAt.IsZero() && age < s.expiry:
+		// the cached rates are fresh
+	case !updatedAt.IsZero() && age < s.maxStaleness:
+		// serve the stale rates while they are refreshed
+		s.group.DoChan("rates", s.fetch)
+	default:
+		if err := s.refresh(ctx); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return s.cache.Snapshot(), nil
+}
+
+// GetAt returns the rate of GetRateRequest.Currency from the latest set of rates fetched not later than
+// GetRateRequest.At.
+func (s *Service) GetAt(ctx context.Context, req requests.GetRateRequest) (HistoricalRate, error) {
+	if err := req.Validate(); err != nil {
+		return HistoricalRate{}, err
+	}
+
+	rate, err := s.repo.GetAt(ctx, req.Currency, req.At)
 	if err != nil {
-		return err
+		return HistoricalRate{}, err
+	}
+	var values map[string]float32
+	if err := json.Unmarshal([]byte(rate.Rates), &values); err != nil {
+		return HistoricalRate{}, err
 	}
-	defer response.Body.Close()
+	return HistoricalRate{
+		Id:        rate.Id,
+		Currency:  req.Currency,
+		Rate:      values[req.Currency],
+		Source:    rate.Source,
+		FetchedAt: rate.FetchedAt.UTC(),
+	}, nil
+}
 
-	latest := ratesResponse{}
-	err = json.NewDecoder(response.Body).Decode(&latest)
+// Run refreshes the rates in background shortly before they expire until the context is cancelled.
+// A failed refresh is retried every retryInterval.
+func (s *Service) Run(ctx context.Context) {
+	for {
+		delay := s.cache.UpdatedAt().Add(s.expiry * 4 / 5).Sub(s.clock())
+		if !sleep(ctx, delay) {
+			return
+		}
+		if err := s.refresh(ctx); err != nil && !sleep(ctx, s.retryInterval) {
+			return
+		}
+	}
+}
+
+// refresh fetches the rates, joining the refresh already in progress if there is one.
+// It waits for the refresh until the context is cancelled.
+func (s *Service) refresh(ctx context.Context) error {
+	select {
+	case result := <-s.group.DoChan("rates", s.fetch):
+		return result.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch saves and caches all RUB/CURRENCY rates of the first provider which succeeds to return them. Providers with
+// an open circuit breaker are skipped. The fetch is not bound to the context of any request, as other requests may
+// join it. The rates are not cached if they cannot be saved, as conversions at them could not be traced back.
+func (s *Service) fetch() (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	err := errNoProviders
+	for i, provider := range s.providers {
+		if !s.breakers[i].Allow() {
+			err = fmt.Errorf("rates provider %s: %w", provider.Name(), errCircuitOpen)
+			continue
+		}
+
+		var rates map[string]float32
+		if rates, err = provider.Fetch(ctx); err != nil {
+			s.breakers[i].Failure()
+			s.logger.Errorf("rates provider %s failed: %s", provider.Name(), err)
+			continue
+		}
+		s.breakers[i].Success()
+
+		rate, err := s.save(ctx, provider.Name(), rates)
+		if err != nil {
+			s.logger.Error("failed to save currency rates: ", err)
+			return nil, err
+		}
+		// Store our results.
+		s.cache.Store(rates, rate.FetchedAt, rate.Id)
+		return nil, nil
+	}
+
+	s.logger.Error("failed to fetch currency rates: ", err)
+	return nil, err
+}
+
+// save saves the rates fetched from the source as a new entity.Rate set.
+func (s *Service) save(ctx context.Context, source string, rates map[string]float32) (entity.Rate, error) {
+	data, err := json.Marshal(rates)
 	if err != nil {
-		return err
+		return entity.Rate{}, err
 	}
+	rate := entity.Rate{Source: source, FetchedAt: s.clock().UTC(), Rates: string(data)}
+	err = s.repo.Create(ctx, &rate)
+	return rate, err
+}
 
-	// Store our results.
-	s.cache.Store(&latest)
+// clock returns the current time. It is a method value, so that tests can replace now after the breakers are made.
+func (s *Service) clock() time.Time {
+	return s.now()
+}
 
-	return nil
+// sleep waits for the duration and reports whether the context is still alive.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
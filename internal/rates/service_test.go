@@ -0,0 +1,368 @@
+package rates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+var databaseError = errors.New("database error")
+
+func TestService_Get(t *testing.T) {
+	logger, entries := log.NewForTest()
+	var failedUrls, cbrUrls []string
+	failed := serveFixture(t, "exchangerate_host.json", http.StatusInternalServerError, &failedUrls)
+	cbr := serveFixture(t, "cbr_daily.xml", http.StatusOK, &cbrUrls)
+
+	repo := &mockRepository{}
+	s := NewService(repo, time.Minute, time.Hour, logger,
+		NewExchangeRateHostProvider(failed.URL, http.DefaultClient),
+		NewCBRProvider(cbr.URL, http.DefaultClient),
+		NewFileProvider(filepath.Join("testdata", "rates.yml")),
+	)
+	now := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// base currency is not fetched
+	rate, err := s.Get(ctx, "RUB")
+	if assert.NoError(t, err) {
+		assert.Equal(t, float32(1), rate.Value)
+		assert.Empty(t, failedUrls)
+	}
+
+	// the failed provider falls back to the next one
+	rate, err = s.Get(ctx, "USD")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Rate{Value: float32(1 / 71.7846), Time: now, Id: 1}, rate)
+		assert.Len(t, failedUrls, 1)
+		assert.Len(t, cbrUrls, 1)
+		assert.Equal(t, "rates provider exchangerate_host failed: rates API responded with status 500", entries.All()[0].Message)
+		// the fetched rates are saved with their source
+		if assert.Len(t, repo.items, 1) {
+			assert.Equal(t, ProviderCBR, repo.items[0].Source)
+			assert.Equal(t, now, repo.items[0].FetchedAt)
+			assert.Contains(t, repo.items[0].Rates, `"USD":0.01393`)
+		}
+	}
+
+	// cached rates are not fetched again
+	rate, err = s.Get(ctx, "JPY")
+	if assert.NoError(t, err) {
+		assert.Equal(t, float32(100/63.5820), rate.Value)
+		assert.Len(t, cbrUrls, 1)
+	}
+
+	// the currency is missing in the rates of the provider which succeeded
+	_, err = s.Get(ctx, "KWD")
+	assert.Equal(t, currencyUnavailableError, err)
+
+	// all providers failed
+	s = NewService(&mockRepository{}, time.Minute, time.Hour, logger,
+		NewExchangeRateHostProvider(failed.URL, http.DefaultClient),
+		NewFileProvider(filepath.Join("testdata", "missing.yml")),
+	)
+	_, err = s.Get(ctx, "USD")
+	assert.Error(t, err)
+
+	// no providers
+	_, err = NewService(&mockRepository{}, time.Minute, time.Hour, logger).Get(ctx, "USD")
+	assert.Equal(t, errNoProviders, err)
+
+	// the rates which cannot be saved are not served
+	s = NewService(&mockRepository{err: databaseError}, time.Minute, time.Hour, logger,
+		NewFileProvider(filepath.Join("testdata", "rates.yml")),
+	)
+	_, err = s.Get(ctx, "USD")
+	assert.Equal(t, databaseError, err)
+	assert.True(t, s.cache.UpdatedAt().IsZero())
+}
+
+func TestService_GetAt(t *testing.T) {
+	logger, _ := log.NewForTest()
+	fetchedAt := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	repo := &mockRepository{items: []entity.Rate{
+		{Id: 1, Source: ProviderCBR, FetchedAt: fetchedAt, Rates: `{"USD":0.0137,"EUR":0.0121}`},
+		{Id: 2, Source: ProviderFile, FetchedAt: fetchedAt.Add(time.Hour), Rates: `{"USD":0.0139}`},
+	}}
+	s := NewService(repo, time.Minute, time.Hour, logger)
+
+	rate, err := s.GetAt(ctx, requests.GetRateRequest{Currency: "USD", At: fetchedAt.Add(30 * time.Minute)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, HistoricalRate{Id: 1, Currency: "USD", Rate: 0.0137, Source: ProviderCBR, FetchedAt: fetchedAt}, rate)
+	}
+
+	// the latest set which has the currency
+	rate, err = s.GetAt(ctx, requests.GetRateRequest{Currency: "EUR", At: fetchedAt.Add(2 * time.Hour)})
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1, rate.Id)
+		assert.Equal(t, float32(0.0121), rate.Rate)
+	}
+
+	// no rates were fetched before
+	_, err = s.GetAt(ctx, requests.GetRateRequest{Currency: "USD", At: fetchedAt.Add(-time.Minute)})
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// validation error
+	_, err = s.GetAt(ctx, requests.GetRateRequest{Currency: "USD"})
+	assert.Error(t, err)
+}
+
+func TestService_Staleness(t *testing.T) {
+	logger, _ := log.NewForTest()
+	provider := &mockProvider{rates: map[string]float32{"USD": 0.01}}
+	s := NewService(&mockRepository{}, 10*time.Minute, time.Hour, logger, provider)
+	fetchedAt := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	now := fetchedAt
+	s.now = func() time.Time { return now }
+
+	rate, err := s.Get(ctx, "USD")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Rate{Value: 0.01, Time: fetchedAt, Id: 1}, rate)
+	}
+
+	// expired rates are served while they are refreshed in background, even if providers are down
+	provider.setError(errors.New("provider is down"))
+	now = fetchedAt.Add(30 * time.Minute)
+	rate, err = s.Get(ctx, "USD")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Rate{Value: 0.01, Time: fetchedAt, Id: 1}, rate)
+		assert.Eventually(t, func() bool { return provider.calls() == 2 }, time.Second, time.Millisecond)
+	}
+
+	// the background refresh updates the rates once providers are up again
+	provider.setError(nil)
+	provider.setRates(map[string]float32{"USD": 0.02})
+	_, _ = s.Get(ctx, "USD")
+	assert.Eventually(t, func() bool {
+		rate, _ := s.cache.Get("USD")
+		return rate == Rate{Value: 0.02, Time: now, Id: 2}
+	}, time.Second, time.Millisecond)
+
+	// rates older than the max staleness are not served
+	provider.setError(errors.New("provider is down"))
+	now = now.Add(2 * time.Hour)
+	_, err = s.Get(ctx, "USD")
+	assert.EqualError(t, err, "provider is down")
+}
+
+func TestService_Snapshot(t *testing.T) {
+	logger, _ := log.NewForTest()
+	provider := &mockProvider{rates: map[string]float32{"USD": 0.01, "EUR": 0.009}}
+	s := NewService(&mockRepository{}, 0, time.Hour, logger, provider)
+	fetchedAt := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return fetchedAt }
+
+	snapshot, err := s.Snapshot(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, fetchedAt, snapshot.Time)
+		assert.EqualValues(t, 1, snapshot.Id)
+	}
+
+	// the snapshot keeps its rates after they are refreshed
+	provider.setRates(map[string]float32{"USD": 0.02})
+	assert.NoError(t, s.refresh(ctx))
+	usd, _ := snapshot.Get("USD")
+	eur, _ := snapshot.Get("EUR")
+	assert.Equal(t, Rate{Value: 0.01, Time: fetchedAt, Id: 1}, usd)
+	assert.Equal(t, Rate{Value: 0.009, Time: fetchedAt, Id: 1}, eur)
+	usd, _ = s.cache.Get("USD")
+	assert.Equal(t, float32(0.02), usd.Value)
+
+	// the currency missing from the snapshot
+	_, ok := s.cache.Snapshot().Get("EUR")
+	assert.False(t, ok)
+
+	// all providers failed
+	provider.setError(errors.New("provider is down"))
+	s = NewService(&mockRepository{}, 0, time.Hour, logger, provider)
+	_, err = s.Snapshot(ctx)
+	assert.EqualError(t, err, "provider is down")
+}
+
+func TestService_ConcurrentFetch(t *testing.T) {
+	logger, _ := log.NewForTest()
+	release := make(chan struct{})
+	provider := &mockProvider{rates: map[string]float32{"USD": 0.01}, wait: release}
+	s := NewService(&mockRepository{}, time.Minute, time.Hour, logger, provider)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rate, err := s.Get(ctx, "USD")
+			assert.NoError(t, err)
+			assert.Equal(t, float32(0.01), rate.Value)
+		}()
+	}
+	// let all requests join the fetch in progress
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 1, provider.calls())
+
+	// a request stops waiting for the fetch once its context is cancelled
+	blocked := make(chan struct{})
+	defer close(blocked)
+	s = NewService(&mockRepository{}, time.Minute, time.Hour, logger, &mockProvider{wait: blocked})
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := s.Get(cancelled, "USD")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestService_CircuitBreaker(t *testing.T) {
+	logger, _ := log.NewForTest()
+	failing := &mockProvider{err: errors.New("provider is down")}
+	backup := &mockProvider{rates: map[string]float32{"USD": 0.01}}
+	// rates expire immediately, so that every request fetches them
+	s := NewService(&mockRepository{}, 0, 0, logger, failing, backup)
+	now := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		_, err := s.Get(ctx, "USD")
+		assert.NoError(t, err)
+	}
+	// the circuit is open after breakerFailures failures in a row
+	assert.Equal(t, breakerFailures, failing.calls())
+	assert.Equal(t, 5, backup.calls())
+
+	// a single trial request is made after the cooldown
+	now = now.Add(breakerCooldown)
+	_, _ = s.Get(ctx, "USD")
+	_, _ = s.Get(ctx, "USD")
+	assert.Equal(t, breakerFailures+1, failing.calls())
+
+	// the successful trial closes the circuit
+	now = now.Add(breakerCooldown)
+	failing.setError(nil)
+	_, _ = s.Get(ctx, "USD")
+	_, _ = s.Get(ctx, "USD")
+	assert.Equal(t, breakerFailures+3, failing.calls())
+	assert.Equal(t, 7, backup.calls())
+
+	// all providers have an open circuit
+	s = NewService(&mockRepository{}, 0, 0, logger, &mockProvider{err: errors.New("provider is down")})
+	for i := 0; i < breakerFailures; i++ {
+		_, _ = s.Get(ctx, "USD")
+	}
+	_, err := s.Get(ctx, "USD")
+	assert.ErrorIs(t, err, errCircuitOpen)
+}
+
+func TestService_Run(t *testing.T) {
+	logger, _ := log.NewForTest()
+	provider := &mockProvider{err: errors.New("provider is down")}
+	s := NewService(&mockRepository{}, 50*time.Millisecond, time.Hour, logger, provider)
+	s.retryInterval = 10 * time.Millisecond
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		s.Run(runCtx)
+		close(done)
+	}()
+
+	// the rates are fetched at once and a failed refresh is retried
+	assert.Eventually(t, func() bool { return provider.calls() >= 2 }, time.Second, time.Millisecond)
+	provider.setError(nil)
+	provider.setRates(map[string]float32{"USD": 0.01})
+	assert.Eventually(t, func() bool { return !s.cache.UpdatedAt().IsZero() }, time.Second, time.Millisecond)
+
+	// the rates are refreshed before they expire
+	fetched := s.cache.UpdatedAt()
+	assert.Eventually(t, func() bool { return s.cache.UpdatedAt().After(fetched) }, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Run did not stop after the context was cancelled")
+	}
+}
+
+// mockProvider returns the given rates or error and counts the calls. If wait is set, Fetch blocks until it is closed.
+type mockProvider struct {
+	mu    sync.Mutex
+	rates map[string]float32
+	err   error
+	n     int
+	wait  chan struct{}
+}
+
+func (p *mockProvider) Name() string {
+	return "mock"
+}
+
+func (p *mockProvider) Fetch(ctx context.Context) (map[string]float32, error) {
+	p.mu.Lock()
+	p.n++
+	p.mu.Unlock()
+	if p.wait != nil {
+		<-p.wait
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rates, p.err
+}
+
+func (p *mockProvider) setRates(rates map[string]float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates = rates
+}
+
+func (p *mockProvider) setError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *mockProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.n
+}
+
+// mockRepository keeps saved rates in memory. If err is set, Create fails with it.
+type mockRepository struct {
+	mu    sync.Mutex
+	items []entity.Rate
+	err   error
+}
+
+func (m *mockRepository) Create(ctx context.Context, rate *entity.Rate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	rate.Id = int64(len(m.items) + 1)
+	m.items = append(m.items, *rate)
+	return nil
+}
+
+func (m *mockRepository) GetAt(ctx context.Context, currency string, at time.Time) (entity.Rate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.items) - 1; i >= 0; i-- {
+		if m.items[i].FetchedAt.After(at) || !strings.Contains(m.items[i].Rates, `"`+currency+`"`) {
+			continue
+		}
+		return m.items[i], nil
+	}
+	return entity.Rate{}, sql.ErrNoRows
+}
@@ -0,0 +1,25 @@
+package rates
+
+import "time"
+
+// Snapshot is a set of exchange rates of all currencies fetched at once, so that conversions to several currencies
+// made at the same Snapshot are consistent with each other. Snapshot is immutable.
+type Snapshot struct {
+	// The time the rates were fetched at.
+	Time time.Time
+	// The id of the saved set of the rates.
+	Id    int64
+	rates map[string]float32
+}
+
+// NewSnapshot creates a Snapshot of the rates fetched at the given time and saved with the given id.
+// The rates must not be changed afterwards.
+func NewSnapshot(rates map[string]float32, at time.Time, id int64) Snapshot {
+	return Snapshot{Time: at, Id: id, rates: rates}
+}
+
+// Get returns the rate of the currency from the Snapshot and reports whether it is present.
+func (s Snapshot) Get(code string) (Rate, bool) {
+	rate, found := s.rates[code]
+	return Rate{Value: rate, Time: s.Time, Id: s.Id}, found
+}
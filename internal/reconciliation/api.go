@@ -0,0 +1,35 @@
+package reconciliation
+
+import (
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"users-balance-microservice/pkg/log"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger) {
+	res := resource{service, logger}
+
+	r.Get("/admin/reconciliation", res.check)
+	r.Post("/admin/reconciliation/fix", res.fix)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) check(c *routing.Context) error {
+	report, err := r.service.Reconcile(c.Request.Context(), false)
+	if err != nil {
+		return err
+	}
+	return c.Write(report)
+}
+
+func (r resource) fix(c *routing.Context) error {
+	report, err := r.service.Reconcile(c.Request.Context(), true)
+	if err != nil {
+		return err
+	}
+	return c.Write(report)
+}
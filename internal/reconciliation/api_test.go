@@ -0,0 +1,55 @@
+package reconciliation
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/internal/transaction"
+)
+
+func TestAPI(t *testing.T) {
+	id1 := uuid.MustParse("8c5593a0-37d3-11ec-8d3d-0242ac130001")
+	depositRepo := &test.DepositRepository{Items: []entity.Deposit{{OwnerId: id1, Currency: "RUB", Balance: 150000}}}
+	transactionRepo := &mockTransactionRepository{items: []entity.Transaction{{Id: 1, RecipientId: id1, Amount: 100000}}}
+
+	router := test.MockRouter(logger)
+	RegisterHandlers(
+		router.Group(""),
+		NewService(depositRepo, transactionRepo, transaction.NewService(transactionRepo, logger), inPlace, inPlace, logger),
+		logger,
+	)
+
+	tests := []test.APITestCase{
+		{
+			"check success",
+			"GET",
+			"/admin/reconciliation",
+			"",
+			http.StatusOK,
+			`{"checked":1,"fixed":false,"mismatches":[{"owner_id":"8c5593a0-37d3-11ec-8d3d-0242ac130001","currency":"RUB","balance":1500,"computed_balance":1000,"difference":500}]}`,
+		},
+		{
+			"fix success",
+			"POST",
+			"/admin/reconciliation/fix",
+			"",
+			http.StatusOK,
+			`{"checked":1,"fixed":true,"mismatches":[{"owner_id":"8c5593a0-37d3-11ec-8d3d-0242ac130001","currency":"RUB","balance":1500,"computed_balance":1000,"difference":500,"correction_id":2}]}`,
+		},
+		{
+			"check after fix",
+			"GET",
+			"/admin/reconciliation",
+			"",
+			http.StatusOK,
+			`{"checked":1,"fixed":false,"mismatches":[]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+}
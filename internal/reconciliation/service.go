@@ -0,0 +1,208 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
+)
+
+// batchSize is the number of Deposits read from the database at once.
+const batchSize = 100
+
+// Service encapsulates usecase logic for reconciliation of Deposits with their transaction history.
+type Service interface {
+	// Reconcile replays the transactions of every Deposit and compares the result with its stored balance.
+	// If fix is true, a correction Transaction is written for every mismatch found.
+	Reconcile(ctx context.Context, fix bool) (Report, error)
+}
+
+// Report represents the result of reconciliation.
+type Report struct {
+	// Checked is the number of Deposits which were checked.
+	Checked int `json:"checked"`
+	// Fixed is true if corrections were written for the found mismatches.
+	Fixed bool `json:"fixed"`
+	// Mismatches lists Deposits whose balance differs from their transaction history.
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Mismatch describes a Deposit whose stored balance differs from its transaction history.
+// The balances are in minor units of the Currency.
+type Mismatch struct {
+	OwnerId  uuid.UUID `json:"owner_id"`
+	Currency string    `json:"currency"`
+	// Balance is the balance stored in the Deposit.
+	Balance int64 `json:"balance"`
+	// ComputedBalance is the balance computed from the transaction history.
+	ComputedBalance int64 `json:"computed_balance"`
+	// Difference is Balance minus ComputedBalance.
+	Difference int64 `json:"difference"`
+	// CorrectionId is the id of the correction Transaction written in fix mode. Optional.
+	CorrectionId *int64 `json:"correction_id,omitempty"`
+}
+
+// MarshalJSON encodes Mismatch with the balances in major units of the Currency.
+func (m Mismatch) MarshalJSON() ([]byte, error) {
+	type plain Mismatch
+	return json.Marshal(struct {
+		plain
+		Balance         json.Number `json:"balance"`
+		ComputedBalance json.Number `json:"computed_balance"`
+		Difference      json.Number `json:"difference"`
+	}{plain(m), m.inCurrency(m.Balance).Number(), m.inCurrency(m.ComputedBalance).Number(), m.inCurrency(m.Difference).Number()})
+}
+
+// inCurrency returns the amount in the Currency of the Mismatch.
+func (m Mismatch) inCurrency(amount int64) money.Money {
+	return money.New(amount, entity.CurrencyOrBase(m.Currency))
+}
+
+type service struct {
+	depositRepo        deposit.Repository
+	transactionRepo    transaction.Repository
+	transactionService transaction.Service
+	transactional      dbcontext.TransactionFunc
+	snapshot           dbcontext.TransactionFunc
+	logger             log.Logger
+}
+
+// NewService creates a new reconciliation service.
+// In fix mode every Deposit is checked and corrected within its own DB transaction started by transactional.
+// Otherwise every batch of Deposits is checked within a DB transaction started by snapshot, which must see a single
+// snapshot of the database, e.g. dbcontext.DB.RepeatableRead.
+func NewService(
+	depositRepo deposit.Repository,
+	transactionRepo transaction.Repository,
+	transactionService transaction.Service,
+	transactional dbcontext.TransactionFunc,
+	snapshot dbcontext.TransactionFunc,
+	logger log.Logger,
+) Service {
+	return service{depositRepo, transactionRepo, transactionService, transactional, snapshot, logger}
+}
+
+func (s service) Reconcile(ctx context.Context, fix bool) (Report, error) {
+	report := Report{Fixed: fix, Mismatches: []Mismatch{}}
+
+	for offset := 0; ; offset += batchSize {
+		var n int
+		var err error
+		if fix {
+			n, err = s.fixBatch(ctx, offset, &report)
+		} else {
+			// the balances and the transaction history are read at the same moment, so that a concurrent
+			// balance change is not reported as a mismatch
+			err = s.snapshot(ctx, func(ctx context.Context) error {
+				n, err = s.checkBatch(ctx, offset, &report)
+				return err
+			})
+		}
+		if err != nil {
+			return Report{}, err
+		}
+
+		if n < batchSize {
+			return report, nil
+		}
+	}
+}
+
+// checkBatch checks a batch of Deposits starting at the offset, adds them to the report and returns their number.
+func (s service) checkBatch(ctx context.Context, offset int, report *Report) (int, error) {
+	deposits, err := s.depositRepo.Query(ctx, offset, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deposits {
+		mismatch, err := s.check(ctx, d)
+		if err != nil {
+			return 0, err
+		}
+		report.add(mismatch)
+	}
+	return len(deposits), nil
+}
+
+// fixBatch fixes a batch of Deposits starting at the offset, adds them to the report and returns their number.
+func (s service) fixBatch(ctx context.Context, offset int, report *Report) (int, error) {
+	deposits, err := s.depositRepo.Query(ctx, offset, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range deposits {
+		var mismatch *Mismatch
+		err = s.transactional(ctx, func(ctx context.Context) error {
+			mismatch, err = s.fix(ctx, d.OwnerId, d.Currency)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		report.add(mismatch)
+	}
+	return len(deposits), nil
+}
+
+// add counts a checked Deposit and its Mismatch if there is one.
+func (r *Report) add(mismatch *Mismatch) {
+	r.Checked++
+	if mismatch != nil {
+		r.Mismatches = append(r.Mismatches, *mismatch)
+	}
+}
+
+// check compares the Deposit with the balance computed from its transactions by the database.
+// It returns nil if they are equal.
+func (s service) check(ctx context.Context, d entity.Deposit) (*Mismatch, error) {
+	computed, err := s.transactionRepo.Balance(ctx, d.OwnerId, entity.CurrencyOrBase(d.Currency))
+	if err != nil {
+		return nil, err
+	}
+	if computed == d.Balance {
+		return nil, nil
+	}
+
+	return &Mismatch{
+		OwnerId:         d.OwnerId,
+		Currency:        d.Currency,
+		Balance:         d.Balance,
+		ComputedBalance: computed,
+		Difference:      d.Balance - computed,
+	}, nil
+}
+
+// fix locks the Deposit of the owner in the currency, checks it and writes a correction Transaction for the difference if there is one.
+// The stored balance is never changed.
+func (s service) fix(ctx context.Context, ownerId uuid.UUID, currency string) (*Mismatch, error) {
+	if err := s.depositRepo.Lock(ctx, currency, ownerId); err != nil {
+		return nil, err
+	}
+	d, err := s.depositRepo.Get(ctx, ownerId, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatch, err := s.check(ctx, d)
+	if err != nil || mismatch == nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Reconciliation correction: balance %s, history %s",
+		mismatch.inCurrency(mismatch.Balance), mismatch.inCurrency(mismatch.ComputedBalance))
+	tx, err := s.transactionService.CreateCorrectionTransaction(ctx, ownerId, currency, mismatch.Difference, description)
+	if err != nil {
+		return nil, err
+	}
+	mismatch.CorrectionId = &tx.Id
+
+	s.logger.With(ctx, "owner_id", ownerId, "currency", currency, "difference", mismatch.Difference).Info("deposit corrected by reconciliation")
+	return mismatch, nil
+}
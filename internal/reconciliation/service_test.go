@@ -0,0 +1,217 @@
+package reconciliation
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _     = log.NewForTest()
+	ctx           = context.Background()
+	databaseError = test.ErrDatabase
+)
+
+// inPlace runs the function without a real DB transaction.
+func inPlace(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+func TestService_Reconcile(t *testing.T) {
+	id1, id2, id3 := uuid.New(), uuid.New(), uuid.New()
+	reservationId := int64(1)
+	depositRepo := &test.DepositRepository{Items: []entity.Deposit{
+		{OwnerId: id1, Currency: "RUB", Balance: 700, Reserved: 100},
+		{OwnerId: id1, Currency: "USD", Balance: 50},
+		{OwnerId: id2, Currency: "RUB", Balance: 500},
+		{OwnerId: id3, Currency: "RUB", Balance: 0},
+	}}
+	transactionRepo := &mockTransactionRepository{items: []entity.Transaction{
+		{Id: 1, RecipientId: id1, Amount: 1000},
+		{Id: 2, SenderId: id1, RecipientId: id2, Amount: 300},
+		{Id: 3, SenderId: id1, Amount: 200, Type: entity.TransactionTypeHold, ReservationId: &reservationId},
+		{Id: 4, RecipientId: id1, Amount: 100, Type: entity.TransactionTypeRelease, ReservationId: &reservationId},
+		{Id: 5, SenderId: id3, Amount: 100},
+		{Id: 6, RecipientId: id1, Amount: 30, Currency: "USD"},
+	}}
+	s := NewService(depositRepo, transactionRepo, transaction.NewService(transactionRepo, logger), inPlace, inPlace, logger)
+
+	// check finds mismatches but does not change anything
+	report, err := s.Reconcile(ctx, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, report.Checked)
+		assert.False(t, report.Fixed)
+		assert.Equal(t, []Mismatch{
+			{OwnerId: id1, Currency: "USD", Balance: 50, ComputedBalance: 30, Difference: 20},
+			{OwnerId: id2, Currency: "RUB", Balance: 500, ComputedBalance: 300, Difference: 200},
+			{OwnerId: id3, Currency: "RUB", Balance: 0, ComputedBalance: -100, Difference: 100},
+		}, report.Mismatches)
+	}
+	assert.Len(t, transactionRepo.items, 6)
+
+	// fix writes correction transactions and keeps balances
+	report, err = s.Reconcile(ctx, true)
+	if assert.NoError(t, err) && assert.Len(t, report.Mismatches, 3) {
+		assert.True(t, report.Fixed)
+		for _, m := range report.Mismatches {
+			if assert.NotNil(t, m.CorrectionId) {
+				tx, _ := transactionRepo.Get(ctx, *m.CorrectionId)
+				assert.Equal(t, entity.TransactionTypeCorrection, tx.Type)
+				assert.Equal(t, m.Currency, tx.Currency)
+				assert.Equal(t, m.Difference, tx.BalanceChange(m.OwnerId))
+			}
+		}
+	}
+	assert.Len(t, transactionRepo.items, 9)
+	assert.EqualValues(t, 500, depositRepo.Items[2].Balance)
+	assert.ElementsMatch(t, []uuid.UUID{id1, id1, id2, id3}, depositRepo.Locks)
+
+	// nothing to fix anymore
+	report, err = s.Reconcile(ctx, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, report.Checked)
+		assert.Empty(t, report.Mismatches)
+	}
+
+	// failure of DB transaction
+	failing := func(ctx context.Context, f func(ctx context.Context) error) error { return databaseError }
+	s = NewService(depositRepo, transactionRepo, transaction.NewService(transactionRepo, logger), failing, failing, logger)
+	_, err = s.Reconcile(ctx, true)
+	assert.Equal(t, databaseError, err)
+	_, err = s.Reconcile(ctx, false)
+	assert.Equal(t, databaseError, err)
+}
+
+func TestService_Reconcile_Snapshot(t *testing.T) {
+	id1 := uuid.New()
+	depositRepo := &test.DepositRepository{Items: []entity.Deposit{{OwnerId: id1, Currency: "RUB", Balance: 100}}}
+	transactionRepo := &mockTransactionRepository{items: []entity.Transaction{{Id: 1, RecipientId: id1, Amount: 100}}}
+
+	var snapshots, transactions int
+	counting := func(counter *int) dbcontext.TransactionFunc {
+		return func(ctx context.Context, f func(ctx context.Context) error) error {
+			*counter++
+			return f(ctx)
+		}
+	}
+	s := NewService(depositRepo, transactionRepo, transaction.NewService(transactionRepo, logger),
+		counting(&transactions), counting(&snapshots), logger)
+
+	// the deposits and their history are checked within a single snapshot
+	report, err := s.Reconcile(ctx, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, report.Checked)
+		assert.Empty(t, report.Mismatches)
+		assert.Equal(t, 1, snapshots)
+		assert.Zero(t, transactions)
+	}
+}
+
+func TestService_Reconcile_Batches(t *testing.T) {
+	depositRepo := &test.DepositRepository{}
+	for i := 0; i < batchSize*2+1; i++ {
+		depositRepo.Items = append(depositRepo.Items, entity.Deposit{OwnerId: uuid.New()})
+	}
+	transactionRepo := &mockTransactionRepository{}
+	s := NewService(depositRepo, transactionRepo, transaction.NewService(transactionRepo, logger), inPlace, inPlace, logger)
+
+	report, err := s.Reconcile(ctx, false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, batchSize*2+1, report.Checked)
+		assert.Empty(t, report.Mismatches)
+	}
+
+	// database error while reading history
+	depositRepo.Items = append(depositRepo.Items, entity.Deposit{OwnerId: uuid.MustParse("11111111-1111-1111-1111-111111111111")})
+	_, err = s.Reconcile(ctx, false)
+	assert.Equal(t, databaseError, err)
+}
+
+type mockTransactionRepository struct {
+	items []entity.Transaction
+}
+
+func (m *mockTransactionRepository) Get(ctx context.Context, id int64) (entity.Transaction, error) {
+	for _, tx := range m.items {
+		if tx.Id == id {
+			return tx, nil
+		}
+	}
+	return entity.Transaction{}, sql.ErrNoRows
+}
+
+// Lock does not lock anything, as the mock is not used concurrently
+func (m *mockTransactionRepository) Lock(ctx context.Context, id int64) (entity.Transaction, error) {
+	return m.Get(ctx, id)
+}
+
+func (m *mockTransactionRepository) RefundedAmount(ctx context.Context, id int64) (int64, error) {
+	var refunded int64
+	for _, tx := range m.items {
+		if tx.RefundOf != nil && *tx.RefundOf == id {
+			refunded += tx.Amount
+		}
+	}
+	return refunded, nil
+}
+
+func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Transaction) error {
+	tx.Id = int64(len(m.items) + 1)
+	m.items = append(m.items, *tx)
+	return nil
+}
+
+func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
+	var result []entity.Transaction
+
+	// simulate database error
+	if ownerId.String() == "11111111-1111-1111-1111-111111111111" {
+		return result, databaseError
+	}
+
+	for _, tx := range m.items {
+		if (tx.SenderId == ownerId || tx.RecipientId == ownerId) && entity.CurrencyOrBase(tx.Currency) == entity.CurrencyOrBase(filter.Currency) {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockTransactionRepository) GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error) {
+	return nil, nil
+}
+
+func (m *mockTransactionRepository) ExportForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error {
+	return nil
+}
+
+func (m *mockTransactionRepository) Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error) {
+	// simulate database error
+	if ownerId.String() == "11111111-1111-1111-1111-111111111111" {
+		return 0, databaseError
+	}
+
+	var balance int64
+	for _, tx := range m.items {
+		if entity.CurrencyOrBase(tx.Currency) == entity.CurrencyOrBase(currency) {
+			balance += tx.BalanceChange(ownerId)
+		}
+	}
+	return balance, nil
+}
+
+func (m *mockTransactionRepository) BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error) {
+	return map[int64]int64{}, nil
+}
+
+func (m *mockTransactionRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.items)), nil
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger) {
+	res := resource{service, logger}
+
+	r.Get("/reports/revenue", res.revenue)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) revenue(c *routing.Context) error {
+	var input requests.RevenueReportRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	w := &csvWriter{
+		response: c.Response,
+		filename: fmt.Sprintf("revenue-%04d-%02d.csv", input.Year, input.Month),
+		header:   []string{"service_id", "currency", "operations", "revenue"},
+	}
+	err := r.service.Revenue(c.Request.Context(), input, func(revenue ServiceRevenue) error {
+		return w.Write([]string{
+			strconv.FormatInt(revenue.ServiceId, 10),
+			revenue.Currency,
+			strconv.FormatInt(revenue.Operations, 10),
+			money.New(revenue.Revenue, revenue.Currency).Number().String(),
+		})
+	})
+	if err != nil {
+		if !w.started {
+			return err
+		}
+		// the response is already being sent, so the error can only be logged
+		r.logger.With(c.Request.Context()).Errorf("failed streaming revenue report: %v", err)
+		return nil
+	}
+	return w.Flush()
+}
+
+// csvWriter streams CSV records to the response as a file download.
+// The response headers are sent with the first record, so that errors which happen before it
+// can still be reported as usual.
+type csvWriter struct {
+	response http.ResponseWriter
+	filename string
+	header   []string
+	started  bool
+	writer   *csv.Writer
+}
+
+// Write writes a single CSV record.
+func (w *csvWriter) Write(record []string) error {
+	if !w.started {
+		w.start()
+	}
+	return w.writer.Write(record)
+}
+
+// Flush sends all written records to the client. The header is sent even if there were no records.
+func (w *csvWriter) Flush() error {
+	if !w.started {
+		w.start()
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) start() {
+	w.started = true
+	w.response.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, w.filename))
+	w.response.WriteHeader(http.StatusOK)
+	w.writer = csv.NewWriter(w.response)
+	_ = w.writer.Write(w.header)
+}
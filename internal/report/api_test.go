@@ -0,0 +1,41 @@
+package report
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/test"
+)
+
+func TestAPI(t *testing.T) {
+	repo := &mockRepository{items: []ServiceRevenue{{1, "RUB", 2, 150000}, {4, "USD", 1, 30050}}}
+	router := test.MockRouter(logger)
+	RegisterHandlers(router.Group(""), NewService(repo, logger), logger)
+
+	tests := []test.APITestCase{
+		{"revenue success", "GET", "/reports/revenue?year=2021&month=11", "", http.StatusOK, "*service_id,currency,operations,revenue\n1,RUB,2,1500\n4,USD,1,300.5\n*"},
+		{"revenue failure missing month", "GET", "/reports/revenue?year=2021", "", http.StatusBadRequest, `*"month"*`},
+		{"revenue failure invalid month", "GET", "/reports/revenue?year=2021&month=13", "", http.StatusBadRequest, `*"month"*`},
+		{"revenue failure invalid year", "GET", "/reports/revenue?year=abc&month=1", "", http.StatusBadRequest, ""},
+	}
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+
+	// download headers
+	req, _ := http.NewRequest("GET", "/reports/revenue?year=2021&month=2", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(t, "text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="revenue-2021-02.csv"`, res.Header().Get("Content-Disposition"))
+
+	// empty month still has a header
+	repo.items = nil
+	test.Endpoint(t, router, test.APITestCase{"revenue empty", "GET", "/reports/revenue?year=2021&month=1", "", http.StatusOK, "*service_id,currency,operations,revenue\n*"})
+
+	// database error before streaming
+	repo.err = databaseError
+	test.Endpoint(t, router, test.APITestCase{"revenue failure database", "GET", "/reports/revenue?year=2021&month=1", "", http.StatusInternalServerError, ""})
+}
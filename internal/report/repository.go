@@ -0,0 +1,67 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// Repository encapsulates the logic to build reports from the database.
+type Repository interface {
+	// Revenue calls fn for every paid service and currency with the revenue from transactions made within [from, to),
+	// ordered by service id and currency. Rows are read from the database one by one.
+	Revenue(ctx context.Context, from, to time.Time, fn func(ServiceRevenue) error) error
+}
+
+// repository builds reports from the database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new report repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Revenue sums up the withdrawals and captured reservations which have a service id, less their refunds.
+// Refunds keep the service id of the payment they refund and are counted in the month they are made.
+func (r repository) Revenue(ctx context.Context, from, to time.Time, fn func(ServiceRevenue) error) error {
+	rows, err := r.db.With(ctx).NewQuery(`
+		SELECT service_id, currency,
+			COUNT(*) FILTER (WHERE type <> {:refund}) AS operations,
+			SUM(CASE WHEN type = {:refund} THEN -amount ELSE amount END) AS revenue
+		FROM transaction
+		WHERE service_id IS NOT NULL
+			AND transaction_date >= {:from} AND transaction_date < {:to}
+			AND type IN ({:withdrawal}, {:capture}, {:refund})
+		GROUP BY service_id, currency
+		ORDER BY service_id, currency`).
+		Bind(dbx.Params{
+			"from":       from,
+			"to":         to,
+			"withdrawal": "",
+			"capture":    entity.TransactionTypeCapture,
+			"refund":     entity.TransactionTypeRefund,
+		}).
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revenue ServiceRevenue
+		if err := rows.ScanStruct(&revenue); err != nil {
+			return err
+		}
+		if err := fn(revenue); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
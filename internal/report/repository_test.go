@@ -0,0 +1,55 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "transaction")
+	repo := NewRepository(db, logger)
+
+	id1 := uuid.New()
+	service1, service2 := int64(1), int64(2)
+	november := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	reservationId := int64(1)
+	txs := []entity.Transaction{
+		{SenderId: id1, Amount: 100, TransactionDate: november, ServiceId: &service1},
+		{SenderId: id1, Amount: 200, TransactionDate: november, ServiceId: &service1},
+		{SenderId: id1, Amount: 700, TransactionDate: november, ServiceId: &service2, Type: entity.TransactionTypeCapture, ReservationId: &reservationId},
+		// not a revenue: hold, other month, no service
+		{SenderId: id1, Amount: 700, TransactionDate: november, ServiceId: &service2, Type: entity.TransactionTypeHold, ReservationId: &reservationId},
+		{SenderId: id1, Amount: 50, TransactionDate: november.AddDate(0, 1, 0), ServiceId: &service1},
+		{SenderId: id1, Amount: 50, TransactionDate: november},
+		// refunds are subtracted, but not counted as payments
+		{RecipientId: id1, Amount: 60, TransactionDate: november, ServiceId: &service1, Type: entity.TransactionTypeRefund},
+		// another currency is counted separately
+		{SenderId: id1, Amount: 5, TransactionDate: november, ServiceId: &service1, Currency: "USD"},
+	}
+	for i := range txs {
+		if txs[i].Currency == "" {
+			txs[i].Currency = entity.BaseCurrency
+		}
+		assert.NoError(t, db.With(ctx).Model(&txs[i]).Insert())
+	}
+
+	var result []ServiceRevenue
+	from := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	err := repo.Revenue(ctx, from, from.AddDate(0, 1, 0), func(revenue ServiceRevenue) error {
+		result = append(result, revenue)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ServiceRevenue{{1, "RUB", 2, 240}, {1, "USD", 1, 5}, {2, "RUB", 1, 700}}, result)
+	}
+
+	// callback error is returned
+	err = repo.Revenue(ctx, from, from.AddDate(0, 1, 0), func(ServiceRevenue) error { return databaseError })
+	assert.Equal(t, databaseError, err)
+}
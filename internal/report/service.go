@@ -0,0 +1,45 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+// Service encapsulates usecase logic for reports.
+type Service interface {
+	// Revenue calls fn for every paid service with its revenue for the requested month.
+	Revenue(ctx context.Context, req requests.RevenueReportRequest, fn func(ServiceRevenue) error) error
+}
+
+// ServiceRevenue represents the money debited from users for a single paid service.
+// Payments in different currencies are counted separately.
+type ServiceRevenue struct {
+	ServiceId int64  `json:"service_id"`
+	Currency  string `json:"currency"`
+	// Operations is the number of payments for the service.
+	Operations int64 `json:"operations"`
+	// Revenue is the total amount of the currency paid for the service less the refunds, in minor units.
+	Revenue int64 `json:"revenue"`
+}
+
+type service struct {
+	repo   Repository
+	logger log.Logger
+}
+
+// NewService creates a new report service.
+func NewService(repo Repository, logger log.Logger) Service {
+	return service{repo, logger}
+}
+
+func (s service) Revenue(ctx context.Context, req requests.RevenueReportRequest, fn func(ServiceRevenue) error) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	from := time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
+	return s.repo.Revenue(ctx, from, from.AddDate(0, 1, 0), fn)
+}
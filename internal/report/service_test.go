@@ -0,0 +1,67 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _     = log.NewForTest()
+	ctx           = context.Background()
+	databaseError = errors.New("database error")
+)
+
+func TestService_Revenue(t *testing.T) {
+	repo := &mockRepository{items: []ServiceRevenue{{1, "RUB", 2, 1500}, {4, "USD", 1, 300}}}
+	s := NewService(repo, logger)
+
+	// success
+	var result []ServiceRevenue
+	err := s.Revenue(ctx, requests.RevenueReportRequest{Year: 2021, Month: 12}, func(revenue ServiceRevenue) error {
+		result = append(result, revenue)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, repo.items, result)
+		assert.Equal(t, time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC), repo.from)
+		assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), repo.to)
+	}
+
+	// fail validation
+	err = s.Revenue(ctx, requests.RevenueReportRequest{Year: 2021, Month: 0}, func(ServiceRevenue) error { return nil })
+	assert.Error(t, err)
+
+	// error of the callback stops the report
+	calls := 0
+	err = s.Revenue(ctx, requests.RevenueReportRequest{Year: 2021, Month: 11}, func(ServiceRevenue) error {
+		calls++
+		return databaseError
+	})
+	assert.Equal(t, databaseError, err)
+	assert.Equal(t, 1, calls)
+}
+
+type mockRepository struct {
+	items    []ServiceRevenue
+	from, to time.Time
+	err      error
+}
+
+func (m *mockRepository) Revenue(ctx context.Context, from, to time.Time, fn func(ServiceRevenue) error) error {
+	m.from, m.to = from, to
+	if m.err != nil {
+		return m.err
+	}
+	for _, item := range m.items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
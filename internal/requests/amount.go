@@ -0,0 +1,177 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/money"
+)
+
+// Amounts of money are kept in minor units of their currency, e.g. kopecks, while clients send them in JSON as numbers
+// in major units, e.g. 123.45 rubles, so that whole amounts are sent the same way as before minor units were introduced.
+// The requests with amounts convert them on decoding and back on encoding by the scale of their currency.
+
+// parseAmount converts an amount in major units of the currency to minor units. An empty number is zero.
+// The amount must not have more fractional digits than the currency has minor units.
+func parseAmount(number json.Number, currency string) (int64, error) {
+	if number == "" {
+		return 0, nil
+	}
+	amount, err := money.Parse(string(number), entity.CurrencyOrBase(currency))
+	if err != nil {
+		return 0, fmt.Errorf("%w of %s: %s", err, entity.CurrencyOrBase(currency), number)
+	}
+	return amount.Amount(), nil
+}
+
+// formatAmount converts an amount in minor units of the currency to major units. Zero is formatted as an empty
+// number if omitZero is set, so that it is omitted from JSON like an empty optional field.
+func formatAmount(amount int64, currency string, omitZero bool) json.Number {
+	if amount == 0 && omitZero {
+		return ""
+	}
+	return money.New(amount, entity.CurrencyOrBase(currency)).Number()
+}
+
+// UnmarshalJSON decodes UpdateBalanceRequest with the Amount in major units of the Currency.
+func (r *UpdateBalanceRequest) UnmarshalJSON(data []byte) error {
+	type plain UpdateBalanceRequest
+	v := struct {
+		*plain
+		Amount json.Number `json:"amount"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	r.Amount, err = parseAmount(v.Amount, r.Currency)
+	return err
+}
+
+// MarshalJSON encodes UpdateBalanceRequest with the Amount in major units of the Currency.
+func (r UpdateBalanceRequest) MarshalJSON() ([]byte, error) {
+	type plain UpdateBalanceRequest
+	return json.Marshal(struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}{plain(r), formatAmount(r.Amount, r.Currency, false)})
+}
+
+// UnmarshalJSON decodes TransferRequest with the Amount in major units of the Currency.
+func (r *TransferRequest) UnmarshalJSON(data []byte) error {
+	type plain TransferRequest
+	v := struct {
+		*plain
+		Amount json.Number `json:"amount"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	r.Amount, err = parseAmount(v.Amount, r.Currency)
+	return err
+}
+
+// MarshalJSON encodes TransferRequest with the Amount in major units of the Currency.
+func (r TransferRequest) MarshalJSON() ([]byte, error) {
+	type plain TransferRequest
+	return json.Marshal(struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}{plain(r), formatAmount(r.Amount, r.Currency, false)})
+}
+
+// UnmarshalJSON decodes ExchangeRequest with the Amount in major units of the From currency.
+func (r *ExchangeRequest) UnmarshalJSON(data []byte) error {
+	type plain ExchangeRequest
+	v := struct {
+		*plain
+		Amount json.Number `json:"amount"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	r.Amount, err = parseAmount(v.Amount, r.From)
+	return err
+}
+
+// MarshalJSON encodes ExchangeRequest with the Amount in major units of the From currency.
+func (r ExchangeRequest) MarshalJSON() ([]byte, error) {
+	type plain ExchangeRequest
+	return json.Marshal(struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}{plain(r), formatAmount(r.Amount, r.From, false)})
+}
+
+// AmountIn returns the Amount in minor units of the currency of the refunded Transaction, or zero if it is not set.
+// The currency is known only after the Transaction is found, so the Amount is kept in major units until then.
+func (r RefundRequest) AmountIn(currency string) (int64, error) {
+	amount, err := parseAmount(r.Amount, currency)
+	if errors.Is(err, money.ErrOverflow) {
+		return 0, validation.Errors{"amount": validation.NewError("validation_amount_range", "is out of range.")}
+	} else if err != nil {
+		return 0, validation.Errors{
+			"amount": validation.NewError("validation_amount_scale",
+				fmt.Sprintf("must have at most %d fractional digits.", money.Scale(entity.CurrencyOrBase(currency)))),
+		}
+	}
+	return amount, nil
+}
+
+// UnmarshalJSON decodes GetHistoryRequest with MinAmount and MaxAmount in major units of the Currency.
+func (r *GetHistoryRequest) UnmarshalJSON(data []byte) error {
+	type plain GetHistoryRequest
+	v := struct {
+		*plain
+		MinAmount json.Number `json:"min_amount,omitempty"`
+		MaxAmount json.Number `json:"max_amount,omitempty"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	if r.MinAmount, err = parseAmount(v.MinAmount, r.Currency); err != nil {
+		return err
+	}
+	r.MaxAmount, err = parseAmount(v.MaxAmount, r.Currency)
+	return err
+}
+
+// MarshalJSON encodes GetHistoryRequest with MinAmount and MaxAmount in major units of the Currency.
+func (r GetHistoryRequest) MarshalJSON() ([]byte, error) {
+	type plain GetHistoryRequest
+	return json.Marshal(struct {
+		plain
+		MinAmount json.Number `json:"min_amount,omitempty"`
+		MaxAmount json.Number `json:"max_amount,omitempty"`
+	}{plain(r), formatAmount(r.MinAmount, r.Currency, true), formatAmount(r.MaxAmount, r.Currency, true)})
+}
+
+// UnmarshalJSON decodes ReserveRequest with the Amount in major units of entity.BaseCurrency.
+func (r *ReserveRequest) UnmarshalJSON(data []byte) error {
+	type plain ReserveRequest
+	v := struct {
+		*plain
+		Amount json.Number `json:"amount"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var err error
+	r.Amount, err = parseAmount(v.Amount, entity.BaseCurrency)
+	return err
+}
+
+// MarshalJSON encodes ReserveRequest with the Amount in major units of entity.BaseCurrency.
+func (r ReserveRequest) MarshalJSON() ([]byte, error) {
+	type plain ReserveRequest
+	return json.Marshal(struct {
+		plain
+		Amount json.Number `json:"amount"`
+	}{plain(r), formatAmount(r.Amount, entity.BaseCurrency, false)})
+}
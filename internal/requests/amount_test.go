@@ -0,0 +1,88 @@
+package requests
+
+import (
+	"encoding/json"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBalanceRequest_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		amount  int64
+		wantErr bool
+	}{
+		{"whole rubles", `{"amount":100}`, 10000, false},
+		{"kopecks", `{"amount":-10.05}`, -1005, false},
+		{"exponential notation", `{"amount":1e2}`, 10000, false},
+		{"exponential notation with fraction", `{"amount":1.5E1}`, 1500, false},
+		{"exponential notation of kopecks", `{"amount":1.05e-1}`, 0, true},
+		{"currency without minor units", `{"amount":100,"currency":"JPY"}`, 100, false},
+		{"currency with thousandths", `{"amount":0.005,"currency":"KWD"}`, 5, false},
+		{"missing amount", `{}`, 0, false},
+		{"too many fractional digits", `{"amount":0.001}`, 0, true},
+		{"fraction of a currency without minor units", `{"amount":1.5,"currency":"JPY"}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r UpdateBalanceRequest
+			err := json.Unmarshal([]byte(tt.data), &r)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if err == nil {
+				assert.Equal(t, tt.amount, r.Amount)
+			}
+		})
+	}
+}
+
+func TestUpdateBalanceRequest_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(UpdateBalanceRequest{OwnerId: nilUuidString, Amount: -1005})
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"owner_id":"`+nilUuidString+`","amount":-10.05}`, string(data))
+	}
+
+	// the request is stored in batches and decoded back
+	var r UpdateBalanceRequest
+	if assert.NoError(t, json.Unmarshal(data, &r)) {
+		assert.Equal(t, int64(-1005), r.Amount)
+	}
+}
+
+func TestGetHistoryRequest_UnmarshalJSON(t *testing.T) {
+	var r GetHistoryRequest
+	if assert.NoError(t, json.Unmarshal([]byte(`{"currency":"USD","min_amount":0.5,"max_amount":20}`), &r)) {
+		assert.Equal(t, int64(50), r.MinAmount)
+		assert.Equal(t, int64(2000), r.MaxAmount)
+	}
+
+	data, err := json.Marshal(GetHistoryRequest{Currency: "USD", MaxAmount: 2050})
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(data), `"max_amount":20.5`)
+		assert.NotContains(t, string(data), `"min_amount"`)
+	}
+}
+
+func TestRefundRequest_AmountIn(t *testing.T) {
+	amount, err := RefundRequest{Amount: "2.01"}.AmountIn("RUB")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(201), amount)
+	}
+
+	amount, err = RefundRequest{}.AmountIn("RUB")
+	if assert.NoError(t, err) {
+		assert.Zero(t, amount)
+	}
+
+	_, err = RefundRequest{Amount: "2.01"}.AmountIn("JPY")
+	if assert.IsType(t, validation.Errors{}, err) {
+		assert.EqualError(t, err.(validation.Errors)["amount"], "must have at most 0 fractional digits.")
+	}
+
+	_, err = RefundRequest{Amount: "92233720368547758.08"}.AmountIn("RUB")
+	if assert.IsType(t, validation.Errors{}, err) {
+		assert.EqualError(t, err.(validation.Errors)["amount"], "is out of range.")
+	}
+}
@@ -1,12 +1,28 @@
 package requests
 
 import (
+	"encoding/json"
+	"regexp"
+	"time"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"users-balance-microservice/pkg/money"
 )
 
 var notNilUuidRule = validation.NotIn("00000000-0000-0000-0000-000000000000").Error("value cannot be Nil UUID.")
 
+var httpUrlRule = validation.Match(regexp.MustCompile(`^https?://`)).Error("must be an http or https URL.")
+
+var decimalRule = validation.Match(regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)).Error("must be a non-negative decimal number.")
+
+// eventTypes lists the types of events about changes of user's Deposit, see outbox package.
+// The same types are used as operations in the filter of user's history.
+var eventTypes = []interface{}{
+	"top_up", "withdrawal", "transfer_in", "transfer_out", "hold", "capture", "release", "correction", "exchange",
+	"refund",
+}
+
 // Request represents a JSON data of an API request.
 type Request interface {
 	// Validate validates the request's fields.
@@ -14,40 +30,104 @@ type Request interface {
 }
 
 // GetBalanceRequest represents a request to get balance of specific user.
+// Wallet is the currency of the user's Deposit, RUB by default, and Currency is the currency to convert its balance to.
+// Instead of a single Currency, the balance can be converted to several Currencies at once.
+// If At is set, the balance at that point in time is requested.
+// Rounding is the rule of rounding the balance converted to the Currency, half_up by default.
 type GetBalanceRequest struct {
-	OwnerId  string `json:"owner_id"`
-	Currency string `json:"currency,omitempty"`
+	OwnerId    string     `json:"owner_id"`
+	Wallet     string     `json:"wallet,omitempty"`
+	Currency   string     `json:"currency,omitempty"`
+	Currencies []string   `json:"currencies,omitempty"`
+	At         *time.Time `json:"at,omitempty"`
+	Rounding   string     `json:"rounding,omitempty"`
 }
 
+// maxCurrencies is the maximum number of currencies the balance can be converted to in a single request.
+const maxCurrencies = 20
+
 // Validate validates the GetBalanceRequest fields.
 func (r GetBalanceRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
+		validation.Field(&r.Wallet, is.CurrencyCode),
+		validation.Field(&r.Currency, is.CurrencyCode,
+			validation.When(len(r.Currencies) > 0, validation.Empty.Error("must be blank when currencies are set."))),
+		validation.Field(&r.Currencies, validation.Length(0, maxCurrencies), validation.Each(is.CurrencyCode)),
+		validation.Field(&r.At, validation.When(r.At != nil, validation.By(func(interface{}) error {
+			if r.At.After(time.Now()) {
+				return validation.NewError("validation_at_not_in_future", "must not be in the future.")
+			}
+			return nil
+		}))),
+		validation.Field(&r.Rounding, validation.In(string(money.HalfUp), string(money.HalfEven), string(money.Down))),
+	)
+}
+
+// GetBalancesRequest represents a request to get balances of many users at once.
+// Wallet, Currency and Rounding are the same as in GetBalanceRequest and apply to every user.
+// Users without a Deposit have zero balance, unless ReportMissing is set: then they are reported as missing.
+type GetBalancesRequest struct {
+	OwnerIds      []string `json:"owner_ids"`
+	Wallet        string   `json:"wallet,omitempty"`
+	Currency      string   `json:"currency,omitempty"`
+	Rounding      string   `json:"rounding,omitempty"`
+	ReportMissing bool     `json:"report_missing,omitempty"`
+}
+
+// maxBulkOwners is the maximum number of users whose balances can be requested at once.
+const maxBulkOwners = 1000
+
+// Validate validates the GetBalancesRequest fields.
+func (r GetBalancesRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.OwnerIds, validation.Required, validation.Length(1, maxBulkOwners),
+			validation.Each(is.UUID, notNilUuidRule)),
+		validation.Field(&r.Wallet, is.CurrencyCode),
 		validation.Field(&r.Currency, is.CurrencyCode),
+		validation.Field(&r.Rounding, validation.In(string(money.HalfUp), string(money.HalfEven), string(money.Down))),
 	)
 }
 
-// UpdateBalanceRequest represents a request to update user's balance.
+// UpdateBalanceRequest represents a request to update user's balance in the Currency, RUB by default.
+// Amount is in minor units of the Currency.
+// ServiceId and OrderId describe what the money is withdrawn for and are allowed for withdrawals only.
 type UpdateBalanceRequest struct {
-	OwnerId     string `json:"owner_id"`
-	Amount      int64  `json:"amount"`
-	Description string `json:"description,omitempty"`
+	OwnerId        string `json:"owner_id"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency,omitempty"`
+	Description    string `json:"description,omitempty"`
+	ServiceId      *int64 `json:"service_id,omitempty"`
+	OrderId        *int64 `json:"order_id,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 func (r UpdateBalanceRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
 		validation.Field(&r.Amount, validation.Required),
+		validation.Field(&r.Currency, is.CurrencyCode),
 		validation.Field(&r.Description, validation.Length(0, 100)),
+		validation.Field(&r.ServiceId, validation.NilOrNotEmpty, validation.Min(int64(1)), withdrawalOnlyRule(r.Amount)),
+		validation.Field(&r.OrderId, validation.NilOrNotEmpty, validation.Min(int64(1)), withdrawalOnlyRule(r.Amount)),
+		validation.Field(&r.IdempotencyKey, validation.Length(0, 255)),
 	)
 }
 
-// TransferRequest represents a request to transfer money from one user to another.
+// withdrawalOnlyRule checks that the field is not set if amount is not a withdrawal.
+func withdrawalOnlyRule(amount int64) validation.Rule {
+	return validation.When(amount > 0, validation.Nil.Error("allowed for withdrawals only."))
+}
+
+// TransferRequest represents a request to transfer money from one user to another in the Currency, RUB by default.
+// Amount is in minor units of the Currency.
 type TransferRequest struct {
-	SenderId    string `json:"sender_id"`
-	RecipientId string `json:"recipient_id"`
-	Amount      int64  `json:"amount"`
-	Description string `json:"description"`
+	SenderId       string `json:"sender_id"`
+	RecipientId    string `json:"recipient_id"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency,omitempty"`
+	Description    string `json:"description"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // Validate validates the TransferRequest fields.
@@ -56,26 +136,284 @@ func (r TransferRequest) Validate() error {
 		validation.Field(&r.SenderId, validation.Required, is.UUID, notNilUuidRule),
 		validation.Field(&r.RecipientId, validation.Required, is.UUID, notNilUuidRule),
 		validation.Field(&r.Amount, validation.Required, validation.Min(0).Exclusive()),
+		validation.Field(&r.Currency, is.CurrencyCode),
 		validation.Field(&r.Description, validation.Length(0, 100)),
+		validation.Field(&r.IdempotencyKey, validation.Length(0, 255)),
+	)
+}
+
+// ExchangeRequest represents a request to exchange money between Deposits of the same user in different currencies:
+// Amount of the From currency, in its minor units, is sold for the To currency at the current exchange rate.
+type ExchangeRequest struct {
+	OwnerId     string `json:"owner_id"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description,omitempty"`
+}
+
+// Validate validates the ExchangeRequest fields.
+func (r ExchangeRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
+		validation.Field(&r.From, validation.Required, is.CurrencyCode),
+		validation.Field(&r.To, validation.Required, is.CurrencyCode, validation.NotIn(r.From).Error("must be different from from.")),
+		validation.Field(&r.Amount, validation.Required, validation.Min(int64(0)).Exclusive()),
+		validation.Field(&r.Description, validation.Length(0, 100)),
+	)
+}
+
+// RefundRequest represents a request to refund the Transaction with TransactionId: to reverse Amount of it,
+// the whole amount which was not refunded yet by default. Amount is a decimal number in major units of the currency
+// of the Transaction, see AmountIn.
+type RefundRequest struct {
+	TransactionId  int64       `json:"transaction_id"`
+	Amount         json.Number `json:"amount,omitempty"`
+	Description    string      `json:"description,omitempty"`
+	IdempotencyKey string      `json:"idempotency_key,omitempty"`
+}
+
+// Validate validates the RefundRequest fields.
+func (r RefundRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.TransactionId, validation.Required, validation.Min(int64(1))),
+		validation.Field(&r.Amount, decimalRule),
+		validation.Field(&r.Description, validation.Length(0, 100)),
+		validation.Field(&r.IdempotencyKey, validation.Length(0, 255)),
+	)
+}
+
+// BatchRequest represents a request to perform several Operations at once: either all of them succeed or none.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// maxBatchOperations is the maximum number of operations in a single batch.
+const maxBatchOperations = 100
+
+// Validate validates the BatchRequest fields.
+func (r BatchRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Operations, validation.Required, validation.Length(1, maxBatchOperations)),
+	)
+}
+
+// BatchOperation represents a single operation of a BatchRequest: either an Update or a Transfer.
+// The operations of a batch have no idempotency keys, since a batch creates many transactions at once.
+type BatchOperation struct {
+	Update   *UpdateBalanceRequest `json:"update,omitempty"`
+	Transfer *TransferRequest      `json:"transfer,omitempty"`
+}
+
+var batchIdempotencyKeyRule = validation.By(func(interface{}) error {
+	return validation.NewError("validation_batch_idempotency_key", "idempotency keys are not allowed in a batch.")
+})
+
+// Validate validates the BatchOperation fields.
+func (o BatchOperation) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Update,
+			validation.When(o.Transfer == nil, validation.Required.Error("either update or transfer is required.")),
+			validation.When(o.Transfer != nil, validation.Nil.Error("must be blank when transfer is set.")),
+			validation.When(o.Update != nil && o.Update.IdempotencyKey != "", batchIdempotencyKeyRule)),
+		validation.Field(&o.Transfer,
+			validation.When(o.Transfer != nil && o.Transfer.IdempotencyKey != "", batchIdempotencyKeyRule)),
 	)
 }
 
 // GetHistoryRequest represents a request to get a list of all user's transactions: top-ups, withdrawals and transfers.
+// The history of the user's Deposit in the Currency, RUB by default, is requested.
+// If Cursor is set, the history is paged by the cursor instead of Offset: an empty Cursor requests the first page,
+// the cursor of every next page is returned with the previous one.
+//
+// The list can be filtered by the date range [From, To), the operation from the user's point of view, the amount
+// range [MinAmount, MaxAmount] in minor units of the Currency, the other participant of a transfer and a substring
+// of the description.
 type GetHistoryRequest struct {
-	OwnerId        string `json:"owner_id"`
-	Offset         int    `json:"offset,omitempty"`
-	Limit          int    `json:"limit,omitempty"`
-	OrderBy        string `json:"order_by,omitempty"`
-	OrderDirection string `json:"order_direction,omitempty"`
+	OwnerId        string     `json:"owner_id"`
+	Currency       string     `json:"currency,omitempty"`
+	Offset         int        `json:"offset,omitempty"`
+	Limit          int        `json:"limit,omitempty"`
+	OrderBy        string     `json:"order_by,omitempty"`
+	OrderDirection string     `json:"order_direction,omitempty"`
+	Cursor         *string    `json:"cursor,omitempty"`
+	From           *time.Time `json:"from,omitempty"`
+	To             *time.Time `json:"to,omitempty"`
+	Operation      string     `json:"operation,omitempty"`
+	MinAmount      int64      `json:"min_amount,omitempty"`
+	MaxAmount      int64      `json:"max_amount,omitempty"`
+	CounterpartyId string     `json:"counterparty_id,omitempty"`
+	Description    string     `json:"description,omitempty"`
 }
 
 // Validate validates the GetHistoryRequest.
 func (r GetHistoryRequest) Validate() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
-		validation.Field(&r.Offset, validation.Min(0)),
+		validation.Field(&r.Currency, is.CurrencyCode),
+		validation.Field(&r.Offset, validation.Min(0), validation.When(r.Cursor != nil, validation.Empty.Error("not allowed with cursor."))),
 		validation.Field(&r.Limit, validation.Min(1)),
 		validation.Field(&r.OrderBy, validation.In("transaction_date", "amount")),
 		validation.Field(&r.OrderDirection, validation.In("ASC", "DESC")),
+		validation.Field(&r.Cursor, validation.Length(0, 512)),
+		validation.Field(&r.To, validation.When(r.From != nil && r.To != nil, validation.By(func(interface{}) error {
+			if !r.To.After(*r.From) {
+				return validation.NewError("validation_to_after_from", "must be after from.")
+			}
+			return nil
+		}))),
+		validation.Field(&r.Operation, validation.In(eventTypes...)),
+		validation.Field(&r.MinAmount, validation.Min(int64(1))),
+		validation.Field(&r.MaxAmount, validation.Min(int64(1)), validation.When(r.MinAmount > 0, validation.Min(r.MinAmount).Error("must be no less than min_amount."))),
+		validation.Field(&r.CounterpartyId, is.UUID, notNilUuidRule),
+		validation.Field(&r.Description, validation.Length(0, 100)),
+	)
+}
+
+// ValidateExport validates the GetHistoryRequest for the export of the history. The export contains the whole
+// filtered history, so the pagination fields must not be set.
+func (r GetHistoryRequest) ValidateExport() error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Offset, validation.Empty),
+		validation.Field(&r.Limit, validation.Empty),
+		validation.Field(&r.Cursor, validation.Nil),
+	)
+}
+
+// SetStatusRequest represents a request of an administrator to change the Status of user's Deposit in the Currency,
+// RUB by default, for the Reason.
+type SetStatusRequest struct {
+	OwnerId  string `json:"owner_id"`
+	Currency string `json:"currency,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason"`
+}
+
+// Validate validates the SetStatusRequest fields.
+func (r SetStatusRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
+		validation.Field(&r.Currency, is.CurrencyCode),
+		validation.Field(&r.Status, validation.Required, validation.In("active", "frozen", "blocked", "closed")),
+		validation.Field(&r.Reason, validation.Required, validation.Length(0, 255)),
+	)
+}
+
+// StatusHistoryRequest represents a request to get the history of the status of user's Deposit in the Currency,
+// RUB by default.
+type StatusHistoryRequest struct {
+	OwnerId  string `json:"owner_id"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// Validate validates the StatusHistoryRequest fields.
+func (r StatusHistoryRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
+		validation.Field(&r.Currency, is.CurrencyCode),
+	)
+}
+
+// ReserveRequest represents a request to hold money on user's deposit until the order is either completed or cancelled.
+// Amount is in minor units of RUB.
+type ReserveRequest struct {
+	OwnerId     string `json:"owner_id"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description,omitempty"`
+	ServiceId   *int64 `json:"service_id,omitempty"`
+	OrderId     *int64 `json:"order_id,omitempty"`
+}
+
+// Validate validates the ReserveRequest fields.
+func (r ReserveRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.OwnerId, validation.Required, is.UUID, notNilUuidRule),
+		validation.Field(&r.Amount, validation.Required, validation.Min(0).Exclusive()),
+		validation.Field(&r.Description, validation.Length(0, 100)),
+		validation.Field(&r.ServiceId, validation.NilOrNotEmpty, validation.Min(int64(1))),
+		validation.Field(&r.OrderId, validation.NilOrNotEmpty, validation.Min(int64(1))),
+	)
+}
+
+// ReservationRequest represents a request to capture or release a previously made reservation.
+type ReservationRequest struct {
+	ReservationId int64 `json:"reservation_id"`
+}
+
+// Validate validates the ReservationRequest fields.
+func (r ReservationRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ReservationId, validation.Required, validation.Min(1)),
+	)
+}
+
+// RevenueReportRequest represents a request to get the revenue of every paid service for the given month.
+type RevenueReportRequest struct {
+	Year  int `json:"year" form:"year"`
+	Month int `json:"month" form:"month"`
+}
+
+// Validate validates the RevenueReportRequest fields.
+func (r RevenueReportRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Year, validation.Required, validation.Min(2000), validation.Max(9999)),
+		validation.Field(&r.Month, validation.Required, validation.Min(1), validation.Max(12)),
+	)
+}
+
+// GetRateRequest represents a request to get the exchange rate of the Currency against RUB at a past date and time.
+type GetRateRequest struct {
+	Currency string    `json:"currency" form:"currency"`
+	At       time.Time `json:"at" form:"at"`
+}
+
+// Validate validates the GetRateRequest fields.
+func (r GetRateRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Currency, validation.Required, is.CurrencyCode,
+			validation.NotIn("RUB").Error("rates are quoted against RUB.")),
+		validation.Field(&r.At, validation.Required, validation.By(func(interface{}) error {
+			if r.At.After(time.Now()) {
+				return validation.NewError("validation_at_not_in_future", "must not be in the future.")
+			}
+			return nil
+		})),
+	)
+}
+
+// WebhookRequest represents a request to create or update a webhook subscription.
+type WebhookRequest struct {
+	Url       string `json:"url"`
+	Secret    string `json:"secret,omitempty"`
+	OwnerId   string `json:"owner_id,omitempty"`
+	EventType string `json:"event_type,omitempty"`
+}
+
+// Validate validates the WebhookRequest fields.
+func (r WebhookRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Url, validation.Required, validation.Length(0, 2048), is.URL, httpUrlRule),
+		validation.Field(&r.Secret, validation.Length(16, 128)),
+		validation.Field(&r.OwnerId, is.UUID, notNilUuidRule),
+		validation.Field(&r.EventType, validation.In(eventTypes...)),
+	)
+}
+
+// DeadLettersRequest represents a request to get a list of webhook deliveries which have failed all their attempts.
+type DeadLettersRequest struct {
+	SubscriptionId int64 `json:"subscription_id,omitempty" form:"subscription_id"`
+	Offset         int   `json:"offset,omitempty" form:"offset"`
+	Limit          int   `json:"limit,omitempty" form:"limit"`
+}
+
+// Validate validates the DeadLettersRequest fields.
+func (r DeadLettersRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.SubscriptionId, validation.Min(int64(1))),
+		validation.Field(&r.Offset, validation.Min(0)),
+		validation.Field(&r.Limit, validation.Min(1), validation.Max(1000)),
 	)
 }
@@ -3,6 +3,7 @@ package requests
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -27,6 +28,7 @@ func testValidation(t *testing.T, tests []validationTestcase) {
 
 func TestGetBalanceRequest_Validate(t *testing.T) {
 	id1 := uuid.NewString()
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
 	testValidation(t, []validationTestcase{
 		{"success", GetBalanceRequest{OwnerId: id1}, false},
 		{"success with currency", GetBalanceRequest{OwnerId: id1, Currency: "EUR"}, false},
@@ -34,45 +36,136 @@ func TestGetBalanceRequest_Validate(t *testing.T) {
 		{"fail invalid OwnerId", GetBalanceRequest{OwnerId: "12712912"}, true},
 		{"fail nil OwnerId", GetBalanceRequest{OwnerId: nilUuidString}, true},
 		{"fail invalid currency", GetBalanceRequest{OwnerId: id1, Currency: "EURUSDPLT"}, true},
+		{"success with wallet", GetBalanceRequest{OwnerId: id1, Wallet: "USD", Currency: "EUR"}, false},
+		{"fail invalid wallet", GetBalanceRequest{OwnerId: id1, Wallet: "EURUSDPLT"}, true},
+		{"success in the past", GetBalanceRequest{OwnerId: id1, At: &past}, false},
+		{"fail in the future", GetBalanceRequest{OwnerId: id1, At: &future}, true},
+		{"success with rounding", GetBalanceRequest{OwnerId: id1, Currency: "USD", Rounding: "half_even"}, false},
+		{"success with currencies", GetBalanceRequest{OwnerId: id1, Currencies: []string{"RUB", "USD", "EUR"}}, false},
+		{"fail invalid currencies", GetBalanceRequest{OwnerId: id1, Currencies: []string{"USD", "EURUSDPLT"}}, true},
+		{"fail too many currencies", GetBalanceRequest{OwnerId: id1, Currencies: strings.Split(strings.Repeat("USD,", maxCurrencies+1), ",")[:maxCurrencies+1]}, true},
+		{"fail both currency and currencies", GetBalanceRequest{OwnerId: id1, Currency: "USD", Currencies: []string{"EUR"}}, true},
+		{"fail invalid rounding", GetBalanceRequest{OwnerId: id1, Currency: "USD", Rounding: "ceil"}, true},
+	})
+}
+
+func TestGetBalancesRequest_Validate(t *testing.T) {
+	id1, id2 := uuid.NewString(), uuid.NewString()
+	tooMany := make([]string, maxBulkOwners+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.NewString()
+	}
+	testValidation(t, []validationTestcase{
+		{"success", GetBalancesRequest{OwnerIds: []string{id1, id2}}, false},
+		{"success with currency", GetBalancesRequest{OwnerIds: []string{id1}, Wallet: "USD", Currency: "EUR", Rounding: "down"}, false},
+		{"fail missing OwnerIds", GetBalancesRequest{}, true},
+		{"fail invalid OwnerId", GetBalancesRequest{OwnerIds: []string{id1, "12712912"}}, true},
+		{"fail nil OwnerId", GetBalancesRequest{OwnerIds: []string{nilUuidString}}, true},
+		{"fail too many OwnerIds", GetBalancesRequest{OwnerIds: tooMany}, true},
+		{"fail invalid currency", GetBalancesRequest{OwnerIds: []string{id1}, Currency: "EURUSDPLT"}, true},
+		{"fail invalid rounding", GetBalancesRequest{OwnerIds: []string{id1}, Rounding: "ceil"}, true},
 	})
 }
 
 func TestUpdateBalanceRequest_Validate(t *testing.T) {
 	id1 := uuid.NewString()
+	serviceId, orderId, invalidId := int64(3), int64(1024), int64(-1)
 	testValidation(t, []validationTestcase{
-		{"success positive amount", UpdateBalanceRequest{id1, 500, "visa"}, false},
-		{"success negative amount", UpdateBalanceRequest{id1, -500, "mastercard"}, false},
-		{"success no description", UpdateBalanceRequest{id1, 500, ""}, false},
-		{"fail zero amount", UpdateBalanceRequest{id1, 0, ""}, true},
-		{"fail invalid OwnerId", UpdateBalanceRequest{"i'm invalid", 500, ""}, true},
-		{"fail nil OwnerId", UpdateBalanceRequest{nilUuidString, 500, ""}, true},
-		{"fail too long description", UpdateBalanceRequest{id1, 500, strings.Repeat("test", 100)}, true},
+		{"success positive amount", UpdateBalanceRequest{OwnerId: id1, Amount: 500, Description: "visa"}, false},
+		{"success negative amount", UpdateBalanceRequest{OwnerId: id1, Amount: -500, Description: "mastercard"}, false},
+		{"success no description", UpdateBalanceRequest{OwnerId: id1, Amount: 500, Description: ""}, false},
+		{"fail zero amount", UpdateBalanceRequest{OwnerId: id1, Amount: 0, Description: ""}, true},
+		{"fail invalid OwnerId", UpdateBalanceRequest{OwnerId: "i'm invalid", Amount: 500, Description: ""}, true},
+		{"fail nil OwnerId", UpdateBalanceRequest{OwnerId: nilUuidString, Amount: 500, Description: ""}, true},
+		{"fail too long description", UpdateBalanceRequest{OwnerId: id1, Amount: 500, Description: strings.Repeat("test", 100)}, true},
+		{"success with idempotency key", UpdateBalanceRequest{OwnerId: id1, Amount: 500, IdempotencyKey: uuid.NewString()}, false},
+		{"fail too long idempotency key", UpdateBalanceRequest{OwnerId: id1, Amount: 500, IdempotencyKey: strings.Repeat("k", 256)}, true},
+		{"success withdrawal for service", UpdateBalanceRequest{OwnerId: id1, Amount: -500, ServiceId: &serviceId, OrderId: &orderId}, false},
+		{"fail top-up for service", UpdateBalanceRequest{OwnerId: id1, Amount: 500, ServiceId: &serviceId}, true},
+		{"fail top-up for order", UpdateBalanceRequest{OwnerId: id1, Amount: 500, OrderId: &orderId}, true},
+		{"fail invalid ServiceId", UpdateBalanceRequest{OwnerId: id1, Amount: -500, ServiceId: &invalidId}, true},
+		{"fail invalid OrderId", UpdateBalanceRequest{OwnerId: id1, Amount: -500, OrderId: &invalidId}, true},
+		{"success with currency", UpdateBalanceRequest{OwnerId: id1, Amount: 500, Currency: "USD"}, false},
+		{"fail invalid currency", UpdateBalanceRequest{OwnerId: id1, Amount: 500, Currency: "DOLLAR"}, true},
 	})
 }
 
 func TestTransferRequest_Validate(t *testing.T) {
 	id1, id2 := uuid.NewString(), uuid.NewString()
 	testValidation(t, []validationTestcase{
-		{"success no description", TransferRequest{id1, id2, 500, ""}, false},
-		{"success with description", TransferRequest{id1, id2, 500, "thanks for dinner"}, false},
-		{"fail negative amount", TransferRequest{id1, id2, -500, ""}, true},
-		{"fail missing missing SenderId", TransferRequest{"", id2, 500, ""}, true},
-		{"fail missing missing RecipientId", TransferRequest{id1, "", 500, ""}, true},
-		{"fail SenderId invalid", TransferRequest{"124124-12412-12412", id2, 500, ""}, true},
-		{"fail RecipientId invalid", TransferRequest{id1, "982312-124-124-43", 500, ""}, true},
-		{"fail nil SenderId", TransferRequest{nilUuidString, id2, 500, ""}, true},
-		{"fail nil RecipientId", TransferRequest{id1, nilUuidString, 500, ""}, true},
-		{"fail description too long", TransferRequest{id1, id2, 500, strings.Repeat("test", 100)}, true},
+		{"success no description", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, Description: ""}, false},
+		{"success with description", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, Description: "thanks for dinner"}, false},
+		{"fail negative amount", TransferRequest{SenderId: id1, RecipientId: id2, Amount: -500, Description: ""}, true},
+		{"fail missing missing SenderId", TransferRequest{SenderId: "", RecipientId: id2, Amount: 500, Description: ""}, true},
+		{"fail missing missing RecipientId", TransferRequest{SenderId: id1, RecipientId: "", Amount: 500, Description: ""}, true},
+		{"fail SenderId invalid", TransferRequest{SenderId: "124124-12412-12412", RecipientId: id2, Amount: 500, Description: ""}, true},
+		{"fail RecipientId invalid", TransferRequest{SenderId: id1, RecipientId: "982312-124-124-43", Amount: 500, Description: ""}, true},
+		{"fail nil SenderId", TransferRequest{SenderId: nilUuidString, RecipientId: id2, Amount: 500, Description: ""}, true},
+		{"fail nil RecipientId", TransferRequest{SenderId: id1, RecipientId: nilUuidString, Amount: 500, Description: ""}, true},
+		{"fail description too long", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, Description: strings.Repeat("test", 100)}, true},
+		{"success with idempotency key", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, IdempotencyKey: uuid.NewString()}, false},
+		{"fail too long idempotency key", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, IdempotencyKey: strings.Repeat("k", 256)}, true},
+		{"success with currency", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, Currency: "EUR"}, false},
+		{"fail invalid currency", TransferRequest{SenderId: id1, RecipientId: id2, Amount: 500, Currency: "EURO"}, true},
+	})
+}
+
+func TestExchangeRequest_Validate(t *testing.T) {
+	id1 := uuid.NewString()
+	testValidation(t, []validationTestcase{
+		{"success", ExchangeRequest{OwnerId: id1, From: "RUB", To: "USD", Amount: 1000}, false},
+		{"success with description", ExchangeRequest{OwnerId: id1, From: "USD", To: "EUR", Amount: 10, Description: "travel"}, false},
+		{"fail nil OwnerId", ExchangeRequest{OwnerId: nilUuidString, From: "RUB", To: "USD", Amount: 1000}, true},
+		{"fail missing from", ExchangeRequest{OwnerId: id1, To: "USD", Amount: 1000}, true},
+		{"fail missing to", ExchangeRequest{OwnerId: id1, From: "RUB", Amount: 1000}, true},
+		{"fail invalid currency", ExchangeRequest{OwnerId: id1, From: "RUB", To: "DOLLAR", Amount: 1000}, true},
+		{"fail same currency", ExchangeRequest{OwnerId: id1, From: "USD", To: "USD", Amount: 1000}, true},
+		{"fail zero amount", ExchangeRequest{OwnerId: id1, From: "RUB", To: "USD"}, true},
+		{"fail negative amount", ExchangeRequest{OwnerId: id1, From: "RUB", To: "USD", Amount: -1000}, true},
+		{"fail description too long", ExchangeRequest{OwnerId: id1, From: "RUB", To: "USD", Amount: 1000, Description: strings.Repeat("test", 100)}, true},
+	})
+}
+
+func TestRefundRequest_Validate(t *testing.T) {
+	testValidation(t, []validationTestcase{
+		{"success whole transaction", RefundRequest{TransactionId: 1}, false},
+		{"success all params", RefundRequest{TransactionId: 1, Amount: "500", Description: "order cancelled", IdempotencyKey: "key"}, false},
+		{"fail missing transaction id", RefundRequest{Amount: "500"}, true},
+		{"fail negative transaction id", RefundRequest{TransactionId: -1}, true},
+		{"fail negative amount", RefundRequest{TransactionId: 1, Amount: "-500"}, true},
+		{"fail description too long", RefundRequest{TransactionId: 1, Description: strings.Repeat("test", 100)}, true},
+		{"fail idempotency key too long", RefundRequest{TransactionId: 1, IdempotencyKey: strings.Repeat("k", 256)}, true},
+	})
+}
+
+func TestBatchRequest_Validate(t *testing.T) {
+	id1, id2 := uuid.NewString(), uuid.NewString()
+	update := &UpdateBalanceRequest{OwnerId: id1, Amount: 500}
+	transfer := &TransferRequest{SenderId: id1, RecipientId: id2, Amount: 300}
+	testValidation(t, []validationTestcase{
+		{"success update", BatchRequest{Operations: []BatchOperation{{Update: update}}}, false},
+		{"success update and transfer", BatchRequest{Operations: []BatchOperation{{Update: update}, {Transfer: transfer}}}, false},
+		{"fail no operations", BatchRequest{}, true},
+		{"fail too many operations", BatchRequest{Operations: make([]BatchOperation, 101)}, true},
+		{"fail empty operation", BatchRequest{Operations: []BatchOperation{{Update: update}, {}}}, true},
+		{"fail both update and transfer", BatchRequest{Operations: []BatchOperation{{Update: update, Transfer: transfer}}}, true},
+		{"fail invalid update", BatchRequest{Operations: []BatchOperation{{Update: &UpdateBalanceRequest{OwnerId: id1}}}}, true},
+		{"fail invalid transfer", BatchRequest{Operations: []BatchOperation{{Transfer: &TransferRequest{SenderId: id1, Amount: 300}}}}, true},
+		{"fail idempotency key", BatchRequest{Operations: []BatchOperation{{Update: &UpdateBalanceRequest{OwnerId: id1, Amount: 500, IdempotencyKey: "key"}}}}, true},
 	})
 }
 
 func TestGetHistoryRequest_Validate(t *testing.T) {
 	id1 := uuid.NewString()
+	emptyCursor, cursor, longCursor := "", "eyJpZCI6MTd9", strings.Repeat("c", 513)
+	march, april := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
 	testValidation(t, []validationTestcase{
 		{"success only OwnerId", GetHistoryRequest{OwnerId: id1}, false},
+		{"success with currency", GetHistoryRequest{OwnerId: id1, Currency: "USD"}, false},
+		{"fail invalid currency", GetHistoryRequest{OwnerId: id1, Currency: "usd1"}, true},
 		{"success with ordering", GetHistoryRequest{OwnerId: id1, OrderBy: "amount", OrderDirection: "ASC"}, false},
 		{"success with limit&offset", GetHistoryRequest{OwnerId: id1, Offset: 10, Limit: 5}, false},
-		{"success all params", GetHistoryRequest{id1, 10, 5, "transaction_date", "DESC"}, false},
+		{"success all params", GetHistoryRequest{OwnerId: id1, Offset: 10, Limit: 5, OrderBy: "transaction_date", OrderDirection: "DESC"}, false},
 		{"fail missing OwnerId", GetHistoryRequest{OwnerId: ""}, true},
 		{"fail invalid OwnerId", GetHistoryRequest{OwnerId: "128312-1241-12"}, true},
 		{"fail nil OwnerId", GetHistoryRequest{OwnerId: nilUuidString}, true},
@@ -80,5 +173,133 @@ func TestGetHistoryRequest_Validate(t *testing.T) {
 		{"fail invalid OrderDirection", GetHistoryRequest{OwnerId: id1, OrderBy: "amount", OrderDirection: "MEDIAN"}, true},
 		{"fail negative offset", GetHistoryRequest{OwnerId: id1, Offset: -10}, true},
 		{"fail negative limit", GetHistoryRequest{OwnerId: id1, Limit: -5}, true},
+		{"success first page by cursor", GetHistoryRequest{OwnerId: id1, Limit: 5, Cursor: &emptyCursor}, false},
+		{"success next page by cursor", GetHistoryRequest{OwnerId: id1, Limit: 5, Cursor: &cursor}, false},
+		{"fail offset with cursor", GetHistoryRequest{OwnerId: id1, Offset: 10, Cursor: &cursor}, true},
+		{"fail too long cursor", GetHistoryRequest{OwnerId: id1, Cursor: &longCursor}, true},
+		{"success all filters", GetHistoryRequest{
+			OwnerId: id1, From: &march, To: &april, Operation: "transfer_in", MinAmount: 1000, MaxAmount: 5000,
+			CounterpartyId: uuid.NewString(), Description: "dinner",
+		}, false},
+		{"success only from", GetHistoryRequest{OwnerId: id1, From: &april}, false},
+		{"success only to", GetHistoryRequest{OwnerId: id1, To: &march}, false},
+		{"fail to before from", GetHistoryRequest{OwnerId: id1, From: &april, To: &march}, true},
+		{"fail to equal to from", GetHistoryRequest{OwnerId: id1, From: &march, To: &march}, true},
+		{"fail invalid operation", GetHistoryRequest{OwnerId: id1, Operation: "transfer"}, true},
+		{"fail negative min amount", GetHistoryRequest{OwnerId: id1, MinAmount: -1}, true},
+		{"success equal amounts", GetHistoryRequest{OwnerId: id1, MinAmount: 1000, MaxAmount: 1000}, false},
+		{"fail max amount less than min amount", GetHistoryRequest{OwnerId: id1, MinAmount: 1000, MaxAmount: 999}, true},
+		{"fail invalid counterparty", GetHistoryRequest{OwnerId: id1, CounterpartyId: "123-456"}, true},
+		{"fail nil counterparty", GetHistoryRequest{OwnerId: id1, CounterpartyId: nilUuidString}, true},
+		{"fail too long description", GetHistoryRequest{OwnerId: id1, Description: strings.Repeat("test", 26)}, true},
+	})
+}
+
+func TestGetHistoryRequest_ValidateExport(t *testing.T) {
+	id1 := uuid.NewString()
+	cursor := ""
+	march := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, GetHistoryRequest{OwnerId: id1}.ValidateExport())
+	assert.NoError(t, GetHistoryRequest{OwnerId: id1, OrderBy: "amount", From: &march, Operation: "top_up"}.ValidateExport())
+	assert.Error(t, GetHistoryRequest{OwnerId: "123-456"}.ValidateExport())
+	assert.Error(t, GetHistoryRequest{OwnerId: id1, Offset: 10}.ValidateExport())
+	assert.Error(t, GetHistoryRequest{OwnerId: id1, Limit: 10}.ValidateExport())
+	assert.Error(t, GetHistoryRequest{OwnerId: id1, Cursor: &cursor}.ValidateExport())
+}
+
+func TestSetStatusRequest_Validate(t *testing.T) {
+	id1 := uuid.NewString()
+	testValidation(t, []validationTestcase{
+		{"success", SetStatusRequest{OwnerId: id1, Status: "frozen", Reason: "fraud case #12"}, false},
+		{"success with currency", SetStatusRequest{OwnerId: id1, Currency: "USD", Status: "active", Reason: "case closed"}, false},
+		{"fail missing OwnerId", SetStatusRequest{Status: "blocked", Reason: "court order"}, true},
+		{"fail nil OwnerId", SetStatusRequest{OwnerId: nilUuidString, Status: "blocked", Reason: "court order"}, true},
+		{"fail invalid currency", SetStatusRequest{OwnerId: id1, Currency: "usd1", Status: "blocked", Reason: "court order"}, true},
+		{"fail missing status", SetStatusRequest{OwnerId: id1, Reason: "court order"}, true},
+		{"fail invalid status", SetStatusRequest{OwnerId: id1, Status: "deleted", Reason: "court order"}, true},
+		{"fail missing reason", SetStatusRequest{OwnerId: id1, Status: "closed"}, true},
+		{"fail reason too long", SetStatusRequest{OwnerId: id1, Status: "closed", Reason: strings.Repeat("r", 256)}, true},
+	})
+}
+
+func TestStatusHistoryRequest_Validate(t *testing.T) {
+	testValidation(t, []validationTestcase{
+		{"success", StatusHistoryRequest{OwnerId: uuid.NewString()}, false},
+		{"success with currency", StatusHistoryRequest{OwnerId: uuid.NewString(), Currency: "USD"}, false},
+		{"fail missing OwnerId", StatusHistoryRequest{}, true},
+		{"fail invalid currency", StatusHistoryRequest{OwnerId: uuid.NewString(), Currency: "usd1"}, true},
+	})
+}
+
+func TestReserveRequest_Validate(t *testing.T) {
+	id1 := uuid.NewString()
+	serviceId, orderId, invalidId := int64(3), int64(1024), int64(0)
+	testValidation(t, []validationTestcase{
+		{"success", ReserveRequest{OwnerId: id1, Amount: 500, Description: "order #1"}, false},
+		{"success no description", ReserveRequest{OwnerId: id1, Amount: 500, Description: ""}, false},
+		{"fail zero amount", ReserveRequest{OwnerId: id1, Amount: 0, Description: ""}, true},
+		{"fail negative amount", ReserveRequest{OwnerId: id1, Amount: -500, Description: ""}, true},
+		{"fail invalid OwnerId", ReserveRequest{OwnerId: "i'm invalid", Amount: 500, Description: ""}, true},
+		{"fail nil OwnerId", ReserveRequest{OwnerId: nilUuidString, Amount: 500, Description: ""}, true},
+		{"fail too long description", ReserveRequest{OwnerId: id1, Amount: 500, Description: strings.Repeat("test", 100)}, true},
+		{"success with service and order", ReserveRequest{OwnerId: id1, Amount: 500, ServiceId: &serviceId, OrderId: &orderId}, false},
+		{"fail invalid ServiceId", ReserveRequest{OwnerId: id1, Amount: 500, ServiceId: &invalidId}, true},
+	})
+}
+
+func TestReservationRequest_Validate(t *testing.T) {
+	testValidation(t, []validationTestcase{
+		{"success", ReservationRequest{1}, false},
+		{"fail missing ReservationId", ReservationRequest{}, true},
+		{"fail negative ReservationId", ReservationRequest{-1}, true},
+	})
+}
+
+func TestRevenueReportRequest_Validate(t *testing.T) {
+	testValidation(t, []validationTestcase{
+		{"success", RevenueReportRequest{Year: 2021, Month: 11}, false},
+		{"fail missing Year", RevenueReportRequest{Month: 11}, true},
+		{"fail missing Month", RevenueReportRequest{Year: 2021}, true},
+		{"fail invalid Month", RevenueReportRequest{Year: 2021, Month: 13}, true},
+		{"fail invalid Year", RevenueReportRequest{Year: 21, Month: 1}, true},
+	})
+}
+
+func TestGetRateRequest_Validate(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	testValidation(t, []validationTestcase{
+		{"success", GetRateRequest{Currency: "USD", At: past}, false},
+		{"fail missing Currency", GetRateRequest{At: past}, true},
+		{"fail invalid Currency", GetRateRequest{Currency: "EURUSDPLT", At: past}, true},
+		{"fail base Currency", GetRateRequest{Currency: "RUB", At: past}, true},
+		{"fail missing At", GetRateRequest{Currency: "USD"}, true},
+		{"fail in the future", GetRateRequest{Currency: "USD", At: future}, true},
+	})
+}
+
+func TestWebhookRequest_Validate(t *testing.T) {
+	id1 := uuid.NewString()
+	testValidation(t, []validationTestcase{
+		{"success only url", WebhookRequest{Url: "https://example.com/hook"}, false},
+		{"success all params", WebhookRequest{Url: "http://example.com/hook", Secret: strings.Repeat("s", 32), OwnerId: id1, EventType: "top_up"}, false},
+		{"fail missing url", WebhookRequest{}, true},
+		{"fail invalid url", WebhookRequest{Url: "not a url"}, true},
+		{"fail url without scheme", WebhookRequest{Url: "example.com/hook"}, true},
+		{"fail url with other scheme", WebhookRequest{Url: "ftp://example.com/hook"}, true},
+		{"fail short secret", WebhookRequest{Url: "https://example.com/hook", Secret: "secret"}, true},
+		{"fail invalid OwnerId", WebhookRequest{Url: "https://example.com/hook", OwnerId: "12345"}, true},
+		{"fail nil OwnerId", WebhookRequest{Url: "https://example.com/hook", OwnerId: nilUuidString}, true},
+		{"fail invalid event type", WebhookRequest{Url: "https://example.com/hook", EventType: "chargeback"}, true},
+	})
+}
+
+func TestDeadLettersRequest_Validate(t *testing.T) {
+	testValidation(t, []validationTestcase{
+		{"success empty", DeadLettersRequest{}, false},
+		{"success all params", DeadLettersRequest{SubscriptionId: 1, Offset: 10, Limit: 10}, false},
+		{"fail negative SubscriptionId", DeadLettersRequest{SubscriptionId: -1}, true},
+		{"fail negative offset", DeadLettersRequest{Offset: -1}, true},
+		{"fail too big limit", DeadLettersRequest{Limit: 1001}, true},
 	})
 }
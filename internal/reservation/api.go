@@ -0,0 +1,69 @@
+package reservation
+
+import (
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(
+	r *routing.RouteGroup,
+	service Service,
+	logger log.Logger,
+	transactionHandler routing.Handler,
+) {
+	res := resource{service, logger}
+
+	r.Post("/deposits/reserve", transactionHandler, res.reserve)
+	r.Post("/deposits/capture", transactionHandler, res.capture)
+	r.Post("/deposits/release", transactionHandler, res.release)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) reserve(c *routing.Context) error {
+	var input requests.ReserveRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	reservation, err := r.service.Reserve(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(reservation)
+}
+
+func (r resource) capture(c *routing.Context) error {
+	var input requests.ReservationRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	reservation, err := r.service.Capture(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(reservation)
+}
+
+func (r resource) release(c *routing.Context) error {
+	var input requests.ReservationRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	reservation, err := r.service.Release(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(reservation)
+}
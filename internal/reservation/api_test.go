@@ -0,0 +1,103 @@
+package reservation
+
+import (
+	"net/http"
+	"testing"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/internal/transaction"
+)
+
+func TestAPI(t *testing.T) {
+	router := test.MockRouter(logger)
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{
+			{OwnerId: uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), Balance: 100000},
+		},
+	}
+	reservationRepo := &mockReservationRepository{
+		items: []entity.Reservation{
+			{Id: 1, OwnerId: uuid.MustParse("615f3e76-37d3-11ec-8d3d-0242ac130003"), Amount: 10000, Status: entity.ReservationCaptured},
+		},
+	}
+	transactionHandler := func(c *routing.Context) error { return c.Next() }
+
+	RegisterHandlers(
+		router.Group(""),
+		NewService(
+			reservationRepo,
+			deposit.NewService(depositRepo, nil, logger),
+			transaction.NewService(&mockTransactionRepository{}, logger),
+			logger,
+		),
+		logger,
+		transactionHandler,
+	)
+
+	tests := []test.APITestCase{
+		{
+			"reserve success",
+			"POST",
+			"/deposits/reserve",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":500,"description":"order #2"}`,
+			http.StatusOK,
+			`*"status":"held"*`,
+		},
+		{
+			"reserve failure insufficient funds",
+			"POST",
+			"/deposits/reserve",
+			`{"owner_id":"615f3e76-37d3-11ec-8d3d-0242ac130003","amount":5000}`,
+			http.StatusForbidden,
+			"",
+		},
+		{
+			"reserve failure invalid request",
+			"POST",
+			"/deposits/reserve",
+			`{"owner_id":`,
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"capture success",
+			"POST",
+			"/deposits/capture",
+			`{"reservation_id":2}`,
+			http.StatusOK,
+			`*"status":"captured"*`,
+		},
+		{
+			"capture failure already captured",
+			"POST",
+			"/deposits/capture",
+			`{"reservation_id":1}`,
+			http.StatusConflict,
+			`{"status":409,"message":"Reservation is already captured."}`,
+		},
+		{
+			"release failure not found",
+			"POST",
+			"/deposits/release",
+			`{"reservation_id":100}`,
+			http.StatusNotFound,
+			"",
+		},
+		{
+			"release failure invalid reservation_id",
+			"POST",
+			"/deposits/release",
+			`{"reservation_id":-1}`,
+			http.StatusBadRequest,
+			"",
+		},
+	}
+
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+}
@@ -0,0 +1,69 @@
+package reservation
+
+import (
+	"context"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// Repository encapsulates the logic to access reservations from the database.
+type Repository interface {
+	// Get returns the Reservation with the specified id.
+	Get(ctx context.Context, id int64) (entity.Reservation, error)
+	// Create saves a new Reservation in the storage.
+	// Reservation is assigned an id from database in case of success.
+	Create(ctx context.Context, reservation *entity.Reservation) error
+	// Finish changes the status of a held Reservation with the specified id to the given status.
+	// It returns sql.ErrNoRows if the Reservation does not exist or is not held anymore.
+	Finish(ctx context.Context, id int64, status string) (entity.Reservation, error)
+	// Count returns the number of Reservation records in the database.
+	Count(ctx context.Context) (int64, error)
+}
+
+// repository persists Reservation in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new Reservation repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Get reads the Reservation with the specified id from the database.
+func (r repository) Get(ctx context.Context, id int64) (entity.Reservation, error) {
+	var reservation entity.Reservation
+	err := r.db.With(ctx).Select().Model(id, &reservation)
+	return reservation, err
+}
+
+// Create saves a new Reservation record in the database.
+// Reservation is assigned an auto-incremented id from database.
+func (r repository) Create(ctx context.Context, reservation *entity.Reservation) error {
+	return r.db.With(ctx).Model(reservation).Insert()
+}
+
+// Finish changes the status of a held Reservation in a single conditional update,
+// so that concurrent requests cannot capture or release the same Reservation twice.
+func (r repository) Finish(ctx context.Context, id int64, status string) (entity.Reservation, error) {
+	var reservation entity.Reservation
+	err := r.db.With(ctx).NewQuery(`
+		UPDATE reservation SET status={:status}, updated_at={:now}
+		WHERE id={:id} AND status={:held}
+		RETURNING *`).
+		Bind(dbx.Params{"id": id, "status": status, "held": entity.ReservationHeld, "now": time.Now().UTC()}).
+		One(&reservation)
+	return reservation, err
+}
+
+// Count returns the number of Reservation records in the database.
+func (r repository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.With(ctx).Select("COUNT(*)").From("reservation").Row(&count)
+	return count, err
+}
@@ -0,0 +1,61 @@
+package reservation
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "reservation")
+	repo := NewRepository(db, logger)
+
+	// initial count
+	count, err := repo.Count(ctx)
+	assert.NoError(t, err)
+
+	// create
+	now := time.Now().UTC()
+	reservation := entity.Reservation{
+		OwnerId:     uuid.New(),
+		Amount:      500,
+		Status:      entity.ReservationHeld,
+		Description: "order #1",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = repo.Create(ctx, &reservation)
+	if assert.NoError(t, err) {
+		assert.NotZero(t, reservation.Id)
+
+		count2, _ := repo.Count(ctx)
+		assert.EqualValues(t, 1, count2-count)
+	}
+
+	// get
+	r, err := repo.Get(ctx, reservation.Id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, reservation.OwnerId, r.OwnerId)
+		assert.EqualValues(t, 500, r.Amount)
+	}
+
+	// finish held reservation
+	r, err = repo.Finish(ctx, reservation.Id, entity.ReservationCaptured)
+	if assert.NoError(t, err) {
+		assert.Equal(t, entity.ReservationCaptured, r.Status)
+	}
+
+	// finish already finished reservation -> no rows
+	_, err = repo.Finish(ctx, reservation.Id, entity.ReservationReleased)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// create with non-positive amount -> db error
+	err = repo.Create(ctx, &entity.Reservation{OwnerId: uuid.New(), Amount: 0, Status: entity.ReservationHeld})
+	assert.Error(t, err)
+}
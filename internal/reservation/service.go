@@ -0,0 +1,134 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/log"
+)
+
+// Service encapsulates usecase logic for reservations.
+type Service interface {
+	// Reserve holds money on user's Deposit according to ReserveRequest.
+	Reserve(ctx context.Context, req requests.ReserveRequest) (entity.Reservation, error)
+	// Capture withdraws the money held by the Reservation from user's Deposit.
+	Capture(ctx context.Context, req requests.ReservationRequest) (entity.Reservation, error)
+	// Release returns the money held by the Reservation to user's available balance.
+	Release(ctx context.Context, req requests.ReservationRequest) (entity.Reservation, error)
+	// Count returns a number of all Reservations in the database. Mainly used for testing purposes.
+	Count(ctx context.Context) (int64, error)
+}
+
+type service struct {
+	repo               Repository
+	depositService     deposit.Service
+	transactionService transaction.Service
+	logger             log.Logger
+}
+
+// NewService creates a new Reservation service.
+func NewService(repo Repository, depositService deposit.Service, transactionService transaction.Service, logger log.Logger) Service {
+	return service{repo, depositService, transactionService, logger}
+}
+
+// Reserve creates a held Reservation, moves its amount to the reserved funds of the Deposit
+// and records the corresponding hold Transaction.
+func (s service) Reserve(ctx context.Context, req requests.ReserveRequest) (entity.Reservation, error) {
+	if err := req.Validate(); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	ownerUUID := uuid.MustParse(req.OwnerId)
+	if err := s.depositService.Reserve(ctx, ownerUUID, req.Amount); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	now := time.Now().UTC()
+	reservation := entity.Reservation{
+		OwnerId:     ownerUUID,
+		Amount:      req.Amount,
+		Status:      entity.ReservationHeld,
+		Description: req.Description,
+		ServiceId:   req.ServiceId,
+		OrderId:     req.OrderId,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Create(ctx, &reservation); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	if _, err := s.transactionService.CreateReservationTransaction(ctx, reservation, entity.TransactionTypeHold); err != nil {
+		return entity.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// Capture marks the Reservation as captured, withdraws its amount from the Deposit
+// and records the corresponding capture Transaction.
+func (s service) Capture(ctx context.Context, req requests.ReservationRequest) (entity.Reservation, error) {
+	if err := req.Validate(); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	reservation, err := s.finish(ctx, req.ReservationId, entity.ReservationCaptured)
+	if err != nil {
+		return entity.Reservation{}, err
+	}
+	if err = s.depositService.Capture(ctx, reservation.OwnerId, reservation.Amount); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	if _, err = s.transactionService.CreateReservationTransaction(ctx, reservation, entity.TransactionTypeCapture); err != nil {
+		return entity.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// Release marks the Reservation as released, returns its amount to the available balance of the Deposit
+// and records the corresponding release Transaction.
+func (s service) Release(ctx context.Context, req requests.ReservationRequest) (entity.Reservation, error) {
+	if err := req.Validate(); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	reservation, err := s.finish(ctx, req.ReservationId, entity.ReservationReleased)
+	if err != nil {
+		return entity.Reservation{}, err
+	}
+	if err = s.depositService.Release(ctx, reservation.OwnerId, reservation.Amount); err != nil {
+		return entity.Reservation{}, err
+	}
+
+	if _, err = s.transactionService.CreateReservationTransaction(ctx, reservation, entity.TransactionTypeRelease); err != nil {
+		return entity.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// finish changes the status of a held Reservation and explains why it is not possible otherwise.
+func (s service) finish(ctx context.Context, id int64, status string) (entity.Reservation, error) {
+	reservation, err := s.repo.Finish(ctx, id, status)
+	if err != sql.ErrNoRows {
+		return reservation, err
+	}
+
+	reservation, err = s.repo.Get(ctx, id)
+	if err == sql.ErrNoRows {
+		return entity.Reservation{}, errors.NotFound("Reservation not found.")
+	} else if err != nil {
+		return entity.Reservation{}, err
+	}
+	return entity.Reservation{}, errors.Conflict("Reservation is already " + reservation.Status + ".")
+}
+
+func (s service) Count(ctx context.Context) (int64, error) {
+	return s.repo.Count(ctx)
+}
@@ -0,0 +1,196 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	errs "users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _ = log.NewForTest()
+	ctx       = context.Background()
+)
+
+func TestService(t *testing.T) {
+	id1 := uuid.New()
+	depositRepo := &test.DepositRepository{Items: []entity.Deposit{{OwnerId: id1, Balance: 1000}}}
+	transactionRepo := &mockTransactionRepository{}
+	depositService := deposit.NewService(depositRepo, nil, logger)
+	s := NewService(
+		&mockReservationRepository{},
+		depositService,
+		transaction.NewService(transactionRepo, logger),
+		logger,
+	)
+
+	// reserve success
+	r1, err := s.Reserve(ctx, requests.ReserveRequest{OwnerId: id1.String(), Amount: 600, Description: "order #1"})
+	if assert.NoError(t, err) {
+		assert.NotZero(t, r1.Id)
+		assert.Equal(t, entity.ReservationHeld, r1.Status)
+		assert.EqualValues(t, 600, depositRepo.Items[0].Reserved)
+		assert.EqualValues(t, 1000, depositRepo.Items[0].Balance)
+		if assert.Len(t, transactionRepo.items, 1) {
+			assert.Equal(t, entity.TransactionTypeHold, transactionRepo.items[0].Type)
+			assert.Equal(t, r1.Id, *transactionRepo.items[0].ReservationId)
+		}
+	}
+
+	// reserve insufficient funds failure
+	_, err = s.Reserve(ctx, requests.ReserveRequest{OwnerId: id1.String(), Amount: 500})
+	if assert.Error(t, err) {
+		count, _ := s.Count(ctx)
+		assert.EqualValues(t, 1, count)
+	}
+
+	// reserve invalid request failure
+	_, err = s.Reserve(ctx, requests.ReserveRequest{OwnerId: id1.String(), Amount: -500})
+	assert.Error(t, err)
+
+	// capture success
+	r1, err = s.Capture(ctx, requests.ReservationRequest{ReservationId: r1.Id})
+	if assert.NoError(t, err) {
+		assert.Equal(t, entity.ReservationCaptured, r1.Status)
+		assert.EqualValues(t, 0, depositRepo.Items[0].Reserved)
+		assert.EqualValues(t, 400, depositRepo.Items[0].Balance)
+		if assert.Len(t, transactionRepo.items, 2) {
+			assert.Equal(t, entity.TransactionTypeCapture, transactionRepo.items[1].Type)
+		}
+	}
+
+	// capture already captured reservation failure
+	_, err = s.Capture(ctx, requests.ReservationRequest{ReservationId: r1.Id})
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, err.(errs.ErrorResponse).StatusCode())
+	}
+
+	// release already captured reservation failure
+	_, err = s.Release(ctx, requests.ReservationRequest{ReservationId: r1.Id})
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, err.(errs.ErrorResponse).StatusCode())
+	}
+
+	// release success
+	r2, err := s.Reserve(ctx, requests.ReserveRequest{OwnerId: id1.String(), Amount: 300})
+	if assert.NoError(t, err) {
+		r2, err = s.Release(ctx, requests.ReservationRequest{ReservationId: r2.Id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, entity.ReservationReleased, r2.Status)
+			assert.EqualValues(t, 0, depositRepo.Items[0].Reserved)
+			assert.EqualValues(t, 400, depositRepo.Items[0].Balance)
+			if assert.Len(t, transactionRepo.items, 4) {
+				assert.Equal(t, entity.TransactionTypeRelease, transactionRepo.items[3].Type)
+			}
+		}
+	}
+
+	// capture non-existing reservation failure
+	_, err = s.Capture(ctx, requests.ReservationRequest{ReservationId: 100})
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, err.(errs.ErrorResponse).StatusCode())
+	}
+}
+
+type mockReservationRepository struct {
+	items []entity.Reservation
+}
+
+func (m *mockReservationRepository) Get(ctx context.Context, id int64) (entity.Reservation, error) {
+	for _, item := range m.items {
+		if item.Id == id {
+			return item, nil
+		}
+	}
+	return entity.Reservation{}, sql.ErrNoRows
+}
+
+func (m *mockReservationRepository) Create(ctx context.Context, reservation *entity.Reservation) error {
+	reservation.Id = int64(len(m.items) + 1)
+	m.items = append(m.items, *reservation)
+	return nil
+}
+
+func (m *mockReservationRepository) Finish(ctx context.Context, id int64, status string) (entity.Reservation, error) {
+	for i, item := range m.items {
+		if item.Id == id && item.Status == entity.ReservationHeld {
+			m.items[i].Status = status
+			m.items[i].UpdatedAt = time.Now()
+			return m.items[i], nil
+		}
+	}
+	return entity.Reservation{}, sql.ErrNoRows
+}
+
+func (m *mockReservationRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.items)), nil
+}
+
+type mockTransactionRepository struct {
+	items []entity.Transaction
+}
+
+func (m *mockTransactionRepository) Get(ctx context.Context, id int64) (entity.Transaction, error) {
+	for _, tx := range m.items {
+		if tx.Id == id {
+			return tx, nil
+		}
+	}
+	return entity.Transaction{}, sql.ErrNoRows
+}
+
+// Lock does not lock anything, as the mock is not used concurrently
+func (m *mockTransactionRepository) Lock(ctx context.Context, id int64) (entity.Transaction, error) {
+	return m.Get(ctx, id)
+}
+
+func (m *mockTransactionRepository) RefundedAmount(ctx context.Context, id int64) (int64, error) {
+	var refunded int64
+	for _, tx := range m.items {
+		if tx.RefundOf != nil && *tx.RefundOf == id {
+			refunded += tx.Amount
+		}
+	}
+	return refunded, nil
+}
+
+func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Transaction) error {
+	tx.Id = int64(len(m.items) + 1)
+	m.items = append(m.items, *tx)
+	return nil
+}
+
+func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
+	return nil, nil
+}
+
+func (m *mockTransactionRepository) GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error) {
+	return nil, nil
+}
+
+func (m *mockTransactionRepository) ExportForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error {
+	return nil
+}
+
+func (m *mockTransactionRepository) Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockTransactionRepository) BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error) {
+	return map[int64]int64{}, nil
+}
+
+func (m *mockTransactionRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.items)), nil
+}
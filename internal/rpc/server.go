@@ -0,0 +1,438 @@
+// Package rpc provides the gRPC API of the service which mirrors the HTTP endpoints of deposits.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/idempotency"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/balancepb"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
+)
+
+// IdempotencyMetadata is the gRPC metadata key which carries an idempotency key.
+const IdempotencyMetadata = "idempotency-key"
+
+// transactionalMethods lists the RPCs which are run in a DB transaction, the same as the corresponding HTTP endpoints.
+var transactionalMethods = map[string]bool{
+	"/balance.v1.Balance/UpdateBalance": true,
+	"/balance.v1.Balance/Transfer":      true,
+	"/balance.v1.Balance/Exchange":      true,
+	"/balance.v1.Balance/Refund":        true,
+}
+
+// NewServer creates a gRPC server which serves the Balance service.
+// Errors are converted to gRPC status by errors.UnaryServerInterceptor, balance changes are run within transactional.
+func NewServer(
+	depositService deposit.Service,
+	transactionService transaction.Service,
+	idempotencyService idempotency.Service,
+	transactional dbcontext.TransactionFunc,
+	logger log.Logger,
+) *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		errors.UnaryServerInterceptor(logger),
+		transactionInterceptor(transactional),
+	))
+	balancepb.RegisterBalanceServer(s, server{
+		depositService:     depositService,
+		transactionService: transactionService,
+		idempotencyService: idempotencyService,
+		logger:             logger,
+	})
+	return s
+}
+
+// transactionInterceptor runs the transactional methods within a DB transaction, which is rolled back if an error
+// is returned.
+func transactionInterceptor(transactional dbcontext.TransactionFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !transactionalMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		var resp interface{}
+		err := transactional(ctx, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+type server struct {
+	balancepb.UnimplementedBalanceServer
+	depositService     deposit.Service
+	transactionService transaction.Service
+	idempotencyService idempotency.Service
+	logger             log.Logger
+}
+
+func (s server) GetBalance(ctx context.Context, in *balancepb.GetBalanceRequest) (*balancepb.GetBalanceResponse, error) {
+	req := requests.GetBalanceRequest{
+		OwnerId:    in.OwnerId,
+		Wallet:     in.Wallet,
+		Currency:   in.Currency,
+		Currencies: in.Currencies,
+		At:         timeOrNil(in.At),
+		Rounding:   in.Rounding,
+	}
+	if len(in.Currencies) > 0 {
+		return s.getBalances(ctx, req)
+	}
+
+	balance, err := s.depositService.GetBalance(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	value, _ := strconv.ParseFloat(balance.String(), 32)
+	res := &balancepb.GetBalanceResponse{
+		Balance:  float32(value),
+		Amount:   balance.String(),
+		Currency: balance.Currency(),
+		RateId:   balance.RateId,
+	}
+	if balance.RateTime != nil {
+		res.RateTimestamp = timestamppb.New(*balance.RateTime)
+	}
+	return res, nil
+}
+
+// getBalances returns the balance converted to several currencies at once.
+func (s server) getBalances(ctx context.Context, req requests.GetBalanceRequest) (*balancepb.GetBalanceResponse, error) {
+	balances, err := s.depositService.GetBalances(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res := &balancepb.GetBalanceResponse{}
+	for _, balance := range balances {
+		converted := &balancepb.ConvertedBalance{Currency: balance.Currency, Error: balance.Error}
+		if balance.Error == "" {
+			converted.Amount = balance.Balance.String()
+			converted.RateId = balance.Balance.RateId
+			if balance.Balance.RateTime != nil {
+				converted.RateTimestamp = timestamppb.New(*balance.Balance.RateTime)
+			}
+		}
+		res.Balances = append(res.Balances, converted)
+	}
+	return res, nil
+}
+
+func (s server) UpdateBalance(ctx context.Context, in *balancepb.UpdateBalanceRequest) (*balancepb.Transaction, error) {
+	amount, err := minorUnits("amount", in.AmountDecimal, in.Amount, in.Currency)
+	if err != nil {
+		return nil, err
+	}
+	input := requests.UpdateBalanceRequest{
+		OwnerId:     in.OwnerId,
+		Amount:      amount,
+		Currency:    in.Currency,
+		Description: in.Description,
+		ServiceId:   in.ServiceId,
+		OrderId:     in.OrderId,
+	}
+
+	key := idempotencyKey(ctx, in.IdempotencyKey)
+	if tx, ok, err := s.replay(ctx, key, "/balance.v1.Balance/UpdateBalance", input); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return toProto(tx.Transaction), nil
+	}
+
+	err = s.depositService.Update(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.transactionService.CreateUpdateTransaction(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.complete(ctx, key, tx.Id); err != nil {
+		return nil, err
+	}
+	return toProto(tx.Transaction), nil
+}
+
+func (s server) Transfer(ctx context.Context, in *balancepb.TransferRequest) (*balancepb.Transaction, error) {
+	amount, err := minorUnits("amount", in.AmountDecimal, in.Amount, in.Currency)
+	if err != nil {
+		return nil, err
+	}
+	input := requests.TransferRequest{
+		SenderId:    in.SenderId,
+		RecipientId: in.RecipientId,
+		Amount:      amount,
+		Currency:    in.Currency,
+		Description: in.Description,
+	}
+
+	key := idempotencyKey(ctx, in.IdempotencyKey)
+	if tx, ok, err := s.replay(ctx, key, "/balance.v1.Balance/Transfer", input); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return toProto(tx.Transaction), nil
+	}
+
+	err = s.depositService.Transfer(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.transactionService.CreateTransferTransaction(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.complete(ctx, key, tx.Id); err != nil {
+		return nil, err
+	}
+	return toProto(tx.Transaction), nil
+}
+
+func (s server) Exchange(ctx context.Context, in *balancepb.ExchangeRequest) (*balancepb.ExchangeResponse, error) {
+	amount, err := minorUnits("amount", in.AmountDecimal, in.Amount, in.From)
+	if err != nil {
+		return nil, err
+	}
+	input := requests.ExchangeRequest{
+		OwnerId:     in.OwnerId,
+		From:        in.From,
+		To:          in.To,
+		Amount:      amount,
+		Description: in.Description,
+	}
+
+	result, err := s.depositService.Exchange(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.transactionService.CreateExchangeTransactions(ctx, input, result.Credited, result.Rate, result.RateId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &balancepb.ExchangeResponse{Transactions: make([]*balancepb.Transaction, 0, len(txs))}
+	for _, tx := range txs {
+		res.Transactions = append(res.Transactions, toProto(tx.Transaction))
+	}
+	return res, nil
+}
+
+func (s server) Refund(ctx context.Context, in *balancepb.RefundRequest) (*balancepb.Transaction, error) {
+	input := requests.RefundRequest{
+		TransactionId: in.TransactionId,
+		Description:   in.Description,
+		Amount:        json.Number(in.AmountDecimal),
+	}
+	if in.Amount != 0 {
+		if in.AmountDecimal != "" {
+			return nil, ambiguousAmount("amount")
+		}
+		input.Amount = json.Number(strconv.FormatInt(in.Amount, 10))
+	}
+
+	key := idempotencyKey(ctx, in.IdempotencyKey)
+	if tx, ok, err := s.replay(ctx, key, "/balance.v1.Balance/Refund", input); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return toProto(tx.Transaction), nil
+	}
+
+	original, refundable, err := s.transactionService.GetRefundable(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := s.depositService.Refund(ctx, input, original.Transaction, refundable)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.transactionService.CreateRefundTransaction(ctx, input, original.Transaction, amount)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.complete(ctx, key, tx.Id); err != nil {
+		return nil, err
+	}
+	return toProto(tx.Transaction), nil
+}
+
+func (s server) GetHistory(ctx context.Context, in *balancepb.GetHistoryRequest) (*balancepb.GetHistoryResponse, error) {
+	minAmount, err := minorUnits("min_amount", in.MinAmountDecimal, in.MinAmount, in.Currency)
+	if err != nil {
+		return nil, err
+	}
+	maxAmount, err := minorUnits("max_amount", in.MaxAmountDecimal, in.MaxAmount, in.Currency)
+	if err != nil {
+		return nil, err
+	}
+	input := requests.GetHistoryRequest{
+		OwnerId:        in.OwnerId,
+		Currency:       in.Currency,
+		Offset:         int(in.Offset),
+		Limit:          int(in.Limit),
+		OrderBy:        in.OrderBy,
+		OrderDirection: in.OrderDirection,
+		Cursor:         in.Cursor,
+		From:           timeOrNil(in.From),
+		To:             timeOrNil(in.To),
+		Operation:      in.Operation,
+		MinAmount:      minAmount,
+		MaxAmount:      maxAmount,
+		CounterpartyId: in.CounterpartyId,
+		Description:    in.Description,
+	}
+
+	var page transaction.HistoryPage
+	if input.Cursor != nil {
+		page, err = s.transactionService.GetHistoryPage(ctx, input)
+	} else {
+		page.Transactions, err = s.transactionService.GetHistory(ctx, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &balancepb.GetHistoryResponse{
+		Transactions: make([]*balancepb.Transaction, 0, len(page.Transactions)),
+		NextCursor:   page.NextCursor,
+	}
+	for _, item := range page.Transactions {
+		tx := toProto(item.Transaction)
+		tx.Direction = item.Direction
+		tx.CounterpartyId = uuidString(item.CounterpartyId)
+		tx.Operation = item.Operation
+		tx.BalanceAfter = wholeUnits(item.BalanceAfter, item.Currency)
+		tx.BalanceAfterDecimal = money.New(item.BalanceAfter, entity.CurrencyOrBase(item.Currency)).String()
+		res.Transactions = append(res.Transactions, tx)
+	}
+	return res, nil
+}
+
+// replay registers the idempotency key of the request. In case the request is a retry of an already processed one,
+// replay returns the Transaction created by the original request.
+func (s server) replay(ctx context.Context, key, method string, req interface{}) (transaction.Transaction, bool, error) {
+	if key == "" {
+		return transaction.Transaction{}, false, nil
+	}
+
+	txId, replay, err := s.idempotencyService.Begin(ctx, key, idempotency.Fingerprint(method, req))
+	if err != nil || !replay {
+		return transaction.Transaction{}, false, err
+	}
+
+	tx, err := s.transactionService.Get(ctx, txId)
+	if err != nil {
+		return transaction.Transaction{}, false, err
+	}
+	return tx, true, nil
+}
+
+// complete saves the Transaction created by the request with the given idempotency key.
+func (s server) complete(ctx context.Context, key string, transactionId int64) error {
+	if key == "" {
+		return nil
+	}
+	return s.idempotencyService.Complete(ctx, key, transactionId)
+}
+
+// idempotencyKey returns the idempotency key of the request. The metadata takes precedence over the field.
+func idempotencyKey(ctx context.Context, field string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(IdempotencyMetadata); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return field
+}
+
+// toProto converts the Transaction to its gRPC representation. Nil UUIDs are represented by empty strings.
+func toProto(tx entity.Transaction) *balancepb.Transaction {
+	return &balancepb.Transaction{
+		Id:              tx.Id,
+		SenderId:        uuidString(tx.SenderId),
+		RecipientId:     uuidString(tx.RecipientId),
+		Amount:          wholeUnits(tx.Amount, tx.Currency),
+		AmountDecimal:   tx.Money().String(),
+		Currency:        tx.Currency,
+		Description:     tx.Description,
+		TransactionDate: timestamppb.New(tx.TransactionDate),
+		Type:            tx.Type,
+		ReservationId:   tx.ReservationId,
+		ServiceId:       tx.ServiceId,
+		OrderId:         tx.OrderId,
+		ExchangeRate:    tx.ExchangeRate,
+		RateId:          tx.RateId,
+		RefundOf:        tx.RefundOf,
+	}
+}
+
+// minorUnits converts an amount of the request to minor units of the currency. The amount is sent either as
+// a decimal string in the field suffixed with "_decimal", or in whole units in the deprecated int64 field.
+// An amount which does not fit into minor units, or has more fractional digits than them, is rejected as an invalid
+// value of the field.
+func minorUnits(field, decimal string, units int64, currency string) (int64, error) {
+	currency = entity.CurrencyOrBase(currency)
+	if decimal == "" {
+		amount, err := money.FromUnits(units, currency)
+		if err != nil {
+			return 0, validation.Errors{field: validation.NewError("validation_amount_range", "is out of range.")}
+		}
+		return amount.Amount(), nil
+	}
+	if units != 0 {
+		return 0, ambiguousAmount(field)
+	}
+
+	amount, err := money.Parse(decimal, currency)
+	if err == money.ErrOverflow {
+		return 0, validation.Errors{field + "_decimal": validation.NewError("validation_amount_range", "is out of range.")}
+	} else if err != nil {
+		return 0, validation.Errors{field + "_decimal": validation.NewError("validation_amount_scale",
+			fmt.Sprintf("must be a decimal number with at most %d fractional digits.", money.Scale(currency)))}
+	}
+	return amount.Amount(), nil
+}
+
+// ambiguousAmount is the error of a request which has both the decimal and the deprecated int64 amount set.
+func ambiguousAmount(field string) error {
+	return validation.Errors{field: validation.NewError("validation_amount_ambiguous", "must be blank when "+field+"_decimal is set.")}
+}
+
+// wholeUnits converts an amount in minor units of the currency to whole units for the deprecated int64 fields,
+// which were sent to older clients before amounts with minor units were introduced.
+func wholeUnits(amount int64, currency string) int64 {
+	return money.New(amount, entity.CurrencyOrBase(currency)).Units()
+}
+
+// timeOrNil converts the optional Timestamp to time.Time, a missing Timestamp is converted to nil.
+func timeOrNil(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func uuidString(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,467 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"users-balance-microservice/internal/deposit"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/idempotency"
+	"users-balance-microservice/internal/rates"
+	"users-balance-microservice/internal/test"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/balancepb"
+	"users-balance-microservice/pkg/log"
+)
+
+var ctx = context.Background()
+
+func TestServer(t *testing.T) {
+	logger, _ := log.NewForTest()
+	id1, id2 := uuid.New(), uuid.New()
+	depositRepo := &test.DepositRepository{
+		Items: []entity.Deposit{{OwnerId: id1, Balance: 100000}},
+		History: []entity.BalanceSnapshot{
+			{OwnerId: id1, Balance: 25000, TransactionDate: time.Date(2021, 11, 10, 11, 0, 0, 0, time.UTC)},
+			{OwnerId: id1, Balance: 30000, TransactionDate: time.Date(2021, 11, 10, 13, 0, 0, 0, time.UTC)},
+		},
+	}
+	transactionRepo := &mockTransactionRepository{}
+	transactions := 0
+	transactional := func(ctx context.Context, f func(ctx context.Context) error) error {
+		transactions++
+		return f(ctx)
+	}
+
+	client := serve(t, NewServer(
+		deposit.NewService(depositRepo, mockExchangeRatesService{}, logger),
+		transaction.NewService(transactionRepo, logger),
+		idempotency.NewService(&mockIdempotencyRepository{}, 24*time.Hour, logger),
+		transactional,
+		logger,
+	))
+
+	// get balance success
+	balance, err := client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1000.00", balance.Amount)
+		assert.Equal(t, "RUB", balance.Currency)
+		assert.EqualValues(t, 1000, balance.Balance)
+		assert.Nil(t, balance.RateTimestamp)
+		assert.Equal(t, 0, transactions)
+	}
+
+	// get balance at a point in time
+	at := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	balance, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: id1.String(), At: timestamppb.New(at)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "250.00", balance.Amount)
+		assert.Equal(t, at, depositRepo.LastAt)
+	}
+
+	// get balance converted and rounded (fake exchange rate 0.1 is used)
+	balance, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: id1.String(), Currency: "USD", Rounding: "down"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "100.00", balance.Amount)
+		assert.Equal(t, "USD", balance.Currency)
+		assert.Equal(t, rateTime, balance.RateTimestamp.AsTime())
+		assert.Equal(t, rateId, balance.RateId)
+	}
+
+	// get balance in several currencies, a failed conversion is reported per currency
+	balance, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: id1.String(), Currencies: []string{"RUB", "USD", "KWD"}})
+	if assert.NoError(t, err) && assert.Len(t, balance.Balances, 3) {
+		assert.Equal(t, "1000.00", balance.Balances[0].Amount)
+		assert.Nil(t, balance.Balances[0].RateTimestamp)
+		assert.Equal(t, "100.00", balance.Balances[1].Amount)
+		assert.Equal(t, rateTime, balance.Balances[1].RateTimestamp.AsTime())
+		assert.Equal(t, rateId, balance.Balances[1].RateId)
+		assert.Equal(t, "KWD", balance.Balances[2].Currency)
+		assert.Empty(t, balance.Balances[2].Amount)
+		assert.Equal(t, "Requested currency is not available at the moment.", balance.Balances[2].Error)
+	}
+
+	// get balance invalid owner_id -> InvalidArgument with field violations
+	_, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: "0123456789"})
+	st := status.Convert(err)
+	if assert.Equal(t, codes.InvalidArgument, st.Code()) && assert.Len(t, st.Details(), 1) {
+		violations := st.Details()[0].(*errdetails.BadRequest).FieldViolations
+		if assert.Len(t, violations, 1) {
+			assert.Equal(t, "owner_id", violations[0].Field)
+			assert.Equal(t, "must be a valid UUID", violations[0].Description)
+		}
+	}
+
+	// update balance success within a DB transaction
+	orderId := int64(7)
+	tx, err := client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{OwnerId: id1.String(), Amount: -300, OrderId: &orderId})
+	if assert.NoError(t, err) {
+		assert.Equal(t, id1.String(), tx.SenderId)
+		assert.Empty(t, tx.RecipientId)
+		assert.EqualValues(t, 300, tx.Amount)
+		assert.Equal(t, orderId, tx.GetOrderId())
+		assert.Nil(t, tx.ServiceId)
+		assert.False(t, tx.TransactionDate.AsTime().IsZero())
+		assert.EqualValues(t, 70000, depositRepo.Items[0].Balance)
+		assert.Equal(t, 1, transactions)
+	}
+
+	// update balance with an amount which does not fit into kopecks -> InvalidArgument instead of a wrapped around one
+	balanceBefore := depositRepo.Items[0].Balance
+	for _, amount := range []int64{math.MaxInt64/100 + 1, math.MinInt64/100 - 1} {
+		_, err = client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{OwnerId: id1.String(), Amount: amount})
+		st = status.Convert(err)
+		if assert.Equal(t, codes.InvalidArgument, st.Code()) && assert.Len(t, st.Details(), 1) {
+			violations := st.Details()[0].(*errdetails.BadRequest).FieldViolations
+			if assert.Len(t, violations, 1) {
+				assert.Equal(t, "amount", violations[0].Field)
+				assert.Equal(t, "is out of range.", violations[0].Description)
+			}
+		}
+	}
+	_, err = client.Transfer(ctx, &balancepb.TransferRequest{SenderId: id1.String(), RecipientId: id2.String(), Amount: math.MaxInt64/100 + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Exchange(ctx, &balancepb.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "USD", Amount: math.MaxInt64/100 + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Refund(ctx, &balancepb.RefundRequest{TransactionId: 1, Amount: math.MaxInt64/100 + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id1.String(), MinAmount: math.MaxInt64/100 + 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, balanceBefore, depositRepo.Items[0].Balance)
+
+	// update balance insufficient funds -> FailedPrecondition
+	_, err = client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{OwnerId: id1.String(), Amount: -5000})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "Insufficient funds to perform operation.", status.Convert(err).Message())
+
+	// transfer with idempotency key in metadata is replayed
+	req := &balancepb.TransferRequest{SenderId: id1.String(), RecipientId: id2.String(), Amount: 200, IdempotencyKey: "ignored"}
+	mdCtx := metadata.AppendToOutgoingContext(ctx, IdempotencyMetadata, "transfer-1")
+	tx1, err := client.Transfer(mdCtx, req)
+	if assert.NoError(t, err) {
+		tx2, err := client.Transfer(mdCtx, req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tx1.Id, tx2.Id)
+			assert.EqualValues(t, 50000, depositRepo.Items[0].Balance)
+			assert.Len(t, transactionRepo.items, 2)
+		}
+	}
+
+	// reusing the idempotency key with another request -> Aborted
+	req.Amount = 100
+	_, err = client.Transfer(mdCtx, req)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	// get history success
+	history, err := client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id2.String()})
+	if assert.NoError(t, err) && assert.Len(t, history.Transactions, 1) {
+		assert.Equal(t, tx1.Id, history.Transactions[0].Id)
+		assert.Equal(t, id2.String(), history.Transactions[0].RecipientId)
+		assert.Equal(t, "credit", history.Transactions[0].Direction)
+		assert.Equal(t, id1.String(), history.Transactions[0].CounterpartyId)
+		assert.Equal(t, "transfer_in", history.Transactions[0].Operation)
+		assert.EqualValues(t, 200, history.Transactions[0].BalanceAfter)
+	}
+
+	// get history with a filter success
+	history, err = client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id1.String(), Operation: "transfer_out", CounterpartyId: id2.String(), MinAmount: 100})
+	if assert.NoError(t, err) {
+		assert.Equal(t, transaction.HistoryFilter{Currency: "RUB", Operation: "transfer_out", CounterpartyId: id2, MinAmount: 10000}, transactionRepo.lastFilter)
+	}
+
+	// get history with invalid date range -> InvalidArgument
+	from := timestamppb.New(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	_, err = client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id1.String(), From: from, To: from})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// get history by cursor success
+	cursor := ""
+	history, err = client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id1.String(), Limit: 1, Cursor: &cursor})
+	if assert.NoError(t, err) && assert.Len(t, history.Transactions, 1) {
+		assert.NotEmpty(t, history.NextCursor)
+		next, err := client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id1.String(), Limit: 5, Cursor: &history.NextCursor})
+		if assert.NoError(t, err) && assert.Len(t, next.Transactions, 1) {
+			assert.Equal(t, tx1.Id, next.Transactions[0].Id)
+			assert.Empty(t, next.NextCursor)
+		}
+	}
+
+	// exchange success within a DB transaction (fake exchange rate 0.1 is used)
+	transactions = 0
+	exchanged, err := client.Exchange(ctx, &balancepb.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "USD", Amount: 300})
+	if assert.NoError(t, err) && assert.Len(t, exchanged.Transactions, 2) {
+		assert.Equal(t, id1.String(), exchanged.Transactions[0].SenderId)
+		assert.Equal(t, "RUB", exchanged.Transactions[0].Currency)
+		assert.EqualValues(t, 300, exchanged.Transactions[0].Amount)
+		assert.Equal(t, id1.String(), exchanged.Transactions[1].RecipientId)
+		assert.Equal(t, "USD", exchanged.Transactions[1].Currency)
+		assert.EqualValues(t, 30, exchanged.Transactions[1].Amount)
+		assert.Equal(t, "0.1", exchanged.Transactions[1].ExchangeRate)
+		assert.Equal(t, rateId, exchanged.Transactions[1].GetRateId())
+		assert.Equal(t, 1, transactions)
+	}
+
+	// get balance of the other wallet
+	balance, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: id1.String(), Wallet: "USD"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "30.00", balance.Amount)
+		assert.Equal(t, "USD", balance.Currency)
+	}
+
+	// exchange insufficient funds -> FailedPrecondition
+	_, err = client.Exchange(ctx, &balancepb.ExchangeRequest{OwnerId: id1.String(), From: "USD", To: "RUB", Amount: 31})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// partial refund of the withdrawal success within a DB transaction
+	transactions = 0
+	refund, err := client.Refund(ctx, &balancepb.RefundRequest{TransactionId: tx.Id, Amount: 100, Description: "order cancelled"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, id1.String(), refund.RecipientId)
+		assert.Empty(t, refund.SenderId)
+		assert.EqualValues(t, 100, refund.Amount)
+		assert.Equal(t, "refund", refund.Type)
+		assert.Equal(t, tx.Id, refund.GetRefundOf())
+		assert.Equal(t, orderId, refund.GetOrderId())
+		assert.EqualValues(t, 30000, depositRepo.Items[0].Balance)
+		assert.Equal(t, 1, transactions)
+	}
+
+	// refund of more than the remaining amount -> Aborted
+	_, err = client.Refund(ctx, &balancepb.RefundRequest{TransactionId: tx.Id, Amount: 201})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	// refund of a missing transaction -> NotFound
+	_, err = client.Refund(ctx, &balancepb.RefundRequest{TransactionId: 1000})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// exact amounts with minor units (fake exchange rate 0.1 is used)
+	id3 := uuid.New()
+	tx, err = client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{OwnerId: id3.String(), AmountDecimal: "10.05"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "10.05", tx.AmountDecimal)
+		assert.EqualValues(t, 10, tx.Amount)
+	}
+	exchanged, err = client.Exchange(ctx, &balancepb.ExchangeRequest{OwnerId: id3.String(), From: "RUB", To: "USD", AmountDecimal: "7.5"})
+	if assert.NoError(t, err) && assert.Len(t, exchanged.Transactions, 2) {
+		assert.Equal(t, "7.50", exchanged.Transactions[0].AmountDecimal)
+		assert.Equal(t, "0.75", exchanged.Transactions[1].AmountDecimal)
+		assert.Zero(t, exchanged.Transactions[1].Amount)
+	}
+	history, err = client.GetHistory(ctx, &balancepb.GetHistoryRequest{OwnerId: id3.String(), MinAmountDecimal: "2.55"})
+	if assert.NoError(t, err) && assert.GreaterOrEqual(t, len(history.Transactions), 2) {
+		assert.EqualValues(t, 255, transactionRepo.lastFilter.MinAmount)
+		assert.Equal(t, "10.05", history.Transactions[0].BalanceAfterDecimal)
+		assert.Equal(t, "2.55", history.Transactions[1].BalanceAfterDecimal)
+	}
+	balance, err = client.GetBalance(ctx, &balancepb.GetBalanceRequest{OwnerId: id3.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2.55", balance.Amount)
+	}
+
+	// invalid exact amounts -> InvalidArgument
+	invalid := map[string]*balancepb.UpdateBalanceRequest{
+		"amount_decimal": {OwnerId: id3.String(), AmountDecimal: "0.001"},
+		"amount":         {OwnerId: id3.String(), AmountDecimal: "1", Amount: 1},
+	}
+	for field, in := range invalid {
+		_, err = client.UpdateBalance(ctx, in)
+		st = status.Convert(err)
+		if assert.Equal(t, codes.InvalidArgument, st.Code()) && assert.Len(t, st.Details(), 1) {
+			violations := st.Details()[0].(*errdetails.BadRequest).FieldViolations
+			if assert.Len(t, violations, 1) {
+				assert.Equal(t, field, violations[0].Field)
+			}
+		}
+	}
+	_, err = client.Refund(ctx, &balancepb.RefundRequest{TransactionId: tx.Id, AmountDecimal: "1", Amount: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// database error -> Internal
+	_, err = client.UpdateBalance(ctx, &balancepb.UpdateBalanceRequest{OwnerId: "11111111-1111-1111-1111-111111111111", Amount: 100})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+// serve starts the server on an in-memory connection and returns a client connected to it.
+func serve(t *testing.T, s *grpc.Server) balancepb.BalanceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return balancepb.NewBalanceClient(conn)
+}
+
+type mockTransactionRepository struct {
+	items      []entity.Transaction
+	lastFilter transaction.HistoryFilter
+}
+
+func (m *mockTransactionRepository) Get(ctx context.Context, id int64) (entity.Transaction, error) {
+	for _, tx := range m.items {
+		if tx.Id == id {
+			return tx, nil
+		}
+	}
+	return entity.Transaction{}, sql.ErrNoRows
+}
+
+// Lock does not lock anything, as the mock is not used concurrently
+func (m *mockTransactionRepository) Lock(ctx context.Context, id int64) (entity.Transaction, error) {
+	return m.Get(ctx, id)
+}
+
+func (m *mockTransactionRepository) RefundedAmount(ctx context.Context, id int64) (int64, error) {
+	var refunded int64
+	for _, tx := range m.items {
+		if tx.RefundOf != nil && *tx.RefundOf == id {
+			refunded += tx.Amount
+		}
+	}
+	return refunded, nil
+}
+
+func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Transaction) error {
+	tx.Id = int64(len(m.items) + 1)
+	m.items = append(m.items, *tx)
+	return nil
+}
+
+// Filter, offset, limit and order are ignored for simplicity, the filter is only recorded
+func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
+	var result []entity.Transaction
+	m.lastFilter = filter
+	for _, tx := range m.items {
+		if tx.SenderId == ownerId || tx.RecipientId == ownerId {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+// Order is ignored for simplicity, transactions are paged by id
+func (m *mockTransactionRepository) GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error) {
+	var result []entity.Transaction
+	for _, tx := range m.items {
+		if (tx.SenderId == ownerId || tx.RecipientId == ownerId) && (after == nil || tx.Id > after.Id) && len(result) < limit {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockTransactionRepository) ExportForUser(ctx context.Context, ownerId uuid.UUID, filter transaction.HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error {
+	return nil
+}
+
+// Transactions are assumed to be stored in the order they were made, ids which are not requested are also returned
+func (m *mockTransactionRepository) Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error) {
+	var balance int64
+	for _, tx := range m.items {
+		if entity.CurrencyOrBase(tx.Currency) == entity.CurrencyOrBase(currency) {
+			balance += tx.BalanceChange(ownerId)
+		}
+	}
+	return balance, nil
+}
+
+func (m *mockTransactionRepository) BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error) {
+	result := map[int64]int64{}
+	var balance int64
+	for _, tx := range m.items {
+		if tx.SenderId != ownerId && tx.RecipientId != ownerId || entity.CurrencyOrBase(tx.Currency) != currency {
+			continue
+		}
+		if tx.Type != entity.TransactionTypeCapture {
+			if tx.RecipientId == ownerId {
+				balance += tx.Amount
+			} else {
+				balance -= tx.Amount
+			}
+		}
+		result[tx.Id] = balance
+	}
+	return result, nil
+}
+
+func (m *mockTransactionRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.items)), nil
+}
+
+type mockIdempotencyRepository struct {
+	items []entity.IdempotencyKey
+}
+
+func (m *mockIdempotencyRepository) Get(ctx context.Context, key string) (entity.IdempotencyKey, error) {
+	for _, item := range m.items {
+		if item.Key == key {
+			return item, nil
+		}
+	}
+	return entity.IdempotencyKey{}, sql.ErrNoRows
+}
+
+func (m *mockIdempotencyRepository) Create(ctx context.Context, key entity.IdempotencyKey, expiredBefore time.Time) (bool, error) {
+	for i, item := range m.items {
+		if item.Key == key.Key {
+			if !item.CreatedAt.Before(expiredBefore) {
+				return false, nil
+			}
+			m.items[i] = key
+			return true, nil
+		}
+	}
+	m.items = append(m.items, key)
+	return true, nil
+}
+
+func (m *mockIdempotencyRepository) Update(ctx context.Context, key entity.IdempotencyKey) error {
+	for i, item := range m.items {
+		if item.Key == key.Key {
+			m.items[i] = key
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// rateTime is the time the fake exchange rates were fetched at, rateId is the id of their saved set.
+var (
+	rateTime       = time.Date(2021, 11, 10, 9, 0, 0, 0, time.UTC)
+	rateId   int64 = 42
+)
+
+// Fake exchange rates service provides exchange ratio=0.1 regardless of currency code.
+type mockExchangeRatesService struct{}
+
+func (s mockExchangeRatesService) Get(ctx context.Context, code string) (rates.Rate, error) {
+	return rates.Rate{Value: 0.1, Time: rateTime, Id: rateId}, nil
+}
+
+// Snapshot returns the fake rate of USD, EUR and JPY, other currencies are unavailable.
+func (s mockExchangeRatesService) Snapshot(ctx context.Context) (rates.Snapshot, error) {
+	return rates.NewSnapshot(map[string]float32{"USD": 0.1, "EUR": 0.1, "JPY": 0.1}, rateTime, rateId), nil
+}
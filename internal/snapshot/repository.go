@@ -0,0 +1,80 @@
+// Package snapshot periodically saves the available balances of Deposits, so that the balance at a point in time
+// does not have to be computed from the whole history of a Deposit.
+package snapshot
+
+import (
+	"context"
+	"fmt"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"users-balance-microservice/internal/transaction"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// workerLockKey is the key of the PostgreSQL advisory lock held by the running snapshot worker.
+const workerLockKey = 7003
+
+// Repository encapsulates the logic to access balance snapshots from the database.
+type Repository interface {
+	// Take saves a new BalanceSnapshot of every Deposit (a wallet of an owner in a currency) which has at least minTransactions transactions made after
+	// its latest BalanceSnapshot. It returns the number of saved snapshots.
+	Take(ctx context.Context, minTransactions int) (int64, error)
+	// TryLock tries to acquire the snapshot worker lock until the end of the current DB transaction.
+	// It reports whether the lock was acquired.
+	TryLock(ctx context.Context) (bool, error)
+}
+
+// repository persists BalanceSnapshot in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new BalanceSnapshot repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Take reads for every Deposit its latest BalanceSnapshot and only the transactions made after it, and adds their
+// transaction.AvailableChange to the balance of that snapshot with a single INSERT ... SELECT. Both are read from
+// indexes by owner and currency, so the cost of a round depends on the number of new transactions rather than
+// on the whole history.
+func (r repository) Take(ctx context.Context, minTransactions int) (int64, error) {
+	result, err := r.db.With(ctx).NewQuery(fmt.Sprintf(`
+		INSERT INTO balance_snapshot (owner_id, currency, transaction_id, transaction_date, balance)
+		SELECT deposit.owner_id, deposit.currency, changes.transaction_id, changes.transaction_date,
+			COALESCE(last.balance, 0) + changes.change
+		FROM deposit
+		LEFT JOIN LATERAL (
+			SELECT transaction_id, balance FROM balance_snapshot
+			WHERE owner_id = deposit.owner_id AND currency = deposit.currency
+			ORDER BY transaction_id DESC
+			LIMIT 1
+		) last ON TRUE
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS count, MAX(id) AS transaction_id,
+				(ARRAY_AGG(transaction_date ORDER BY id DESC))[1] AS transaction_date,
+				SUM(%s) AS change
+			FROM transaction
+			WHERE (sender_id = deposit.owner_id OR recipient_id = deposit.owner_id)
+				AND transaction.currency = deposit.currency
+				AND id > COALESCE(last.transaction_id, 0)
+		) changes
+		WHERE changes.count > 0 AND changes.count >= {:min}`, transaction.AvailableChange("deposit.owner_id"))).
+		Bind(dbx.Params{"min": minTransactions}).
+		Execute()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// TryLock acquires a transaction-level advisory lock, so that only one worker takes snapshots at a time.
+func (r repository) TryLock(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.db.With(ctx).NewQuery("SELECT pg_try_advisory_xact_lock({:key})").
+		Bind(dbx.Params{"key": workerLockKey}).
+		Row(&locked)
+	return locked, err
+}
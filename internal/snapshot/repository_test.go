@@ -0,0 +1,119 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "deposit", "transaction", "balance_snapshot")
+	repo := NewRepository(db, logger)
+
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now().UTC()
+	insert := func(txs ...entity.Transaction) {
+		for _, tx := range txs {
+			if tx.Currency == "" {
+				tx.Currency = entity.BaseCurrency
+			}
+			tx.TransactionDate = now
+			assert.NoError(t, db.With(ctx).Model(&tx).Insert())
+		}
+	}
+	for _, d := range []entity.Deposit{{OwnerId: id1, Currency: entity.BaseCurrency}, {OwnerId: id2, Currency: entity.BaseCurrency}, {OwnerId: id2, Currency: "USD"}} {
+		assert.NoError(t, db.With(ctx).Model(&d).Insert())
+	}
+	snapshots := func(ownerId uuid.UUID) []entity.BalanceSnapshot {
+		var result []entity.BalanceSnapshot
+		err := db.With(ctx).Select().Where(dbx.HashExp{"owner_id": ownerId}).OrderBy("currency", "transaction_id").All(&result)
+		assert.NoError(t, err)
+		return result
+	}
+
+	// top-up 1000, transfer 300 to id2, hold 200, capture 200
+	insert(
+		entity.Transaction{RecipientId: id1, Amount: 1000},
+		entity.Transaction{SenderId: id1, RecipientId: id2, Amount: 300},
+		entity.Transaction{SenderId: id1, Amount: 200, Type: entity.TransactionTypeHold},
+		entity.Transaction{SenderId: id1, Amount: 200, Type: entity.TransactionTypeCapture},
+	)
+
+	// id2 has too few transactions
+	n, err := repo.Take(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1, n)
+		s := snapshots(id1)
+		if assert.Len(t, s, 1) {
+			assert.EqualValues(t, 500, s[0].Balance)
+			assert.Equal(t, now.Truncate(time.Microsecond), s[0].TransactionDate.UTC())
+		}
+		assert.Empty(t, snapshots(id2))
+	}
+
+	// id1 has no new transactions, id2 is taken with a lower minimum
+	n, err = repo.Take(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1, n)
+		assert.Len(t, snapshots(id1), 1)
+		s := snapshots(id2)
+		if assert.Len(t, s, 1) {
+			assert.EqualValues(t, 300, s[0].Balance)
+		}
+	}
+
+	// a new snapshot continues the previous one
+	insert(
+		entity.Transaction{SenderId: id1, Amount: 100},
+		entity.Transaction{SenderId: id2, RecipientId: id1, Amount: 50},
+	)
+	n, err = repo.Take(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1, n)
+		s := snapshots(id1)
+		if assert.Len(t, s, 2) {
+			assert.EqualValues(t, 450, s[1].Balance)
+			assert.Greater(t, s[1].TransactionId, s[0].TransactionId)
+		}
+		assert.Len(t, snapshots(id2), 1)
+	}
+
+	// deposits in different currencies of the same owner are taken separately
+	insert(
+		entity.Transaction{RecipientId: id2, Amount: 70, Currency: "USD"},
+		entity.Transaction{SenderId: id2, Amount: 20, Currency: "USD"},
+	)
+	n, err = repo.Take(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 1, n)
+		s := snapshots(id2)
+		if assert.Len(t, s, 2) {
+			assert.Equal(t, entity.BaseCurrency, s[0].Currency)
+			assert.EqualValues(t, 300, s[0].Balance)
+			assert.Equal(t, "USD", s[1].Currency)
+			assert.EqualValues(t, 50, s[1].Balance)
+		}
+	}
+
+	// only one transaction holds the lock
+	err = db.Transactional(ctx, func(ctx context.Context) error {
+		locked, err := repo.TryLock(ctx)
+		assert.NoError(t, err)
+		assert.True(t, locked)
+
+		return db.Transactional(context.Background(), func(other context.Context) error {
+			locked, err := repo.TryLock(other)
+			assert.NoError(t, err)
+			assert.False(t, locked)
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,69 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// defaultMinTransactions is the number of new transactions of a Deposit which makes it worth a new snapshot.
+const defaultMinTransactions = 100
+
+// Worker periodically takes snapshots of the available balances of Deposits.
+//
+// A Deposit gets a new snapshot once it has minTransactions transactions after the previous one, so computing
+// the balance at any point in time never has to sum up more transactions than that.
+type Worker struct {
+	repo            Repository
+	transactional   dbcontext.TransactionFunc
+	logger          log.Logger
+	minTransactions int
+}
+
+// NewWorker creates a new Worker. Every round of snapshots runs within a DB transaction started by transactional.
+func NewWorker(repo Repository, transactional dbcontext.TransactionFunc, logger log.Logger) *Worker {
+	return &Worker{
+		repo:            repo,
+		transactional:   transactional,
+		logger:          logger,
+		minTransactions: defaultMinTransactions,
+	}
+}
+
+// Run takes snapshots every interval until the context is cancelled.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := w.Take(ctx); err != nil {
+			w.logger.With(ctx).Errorf("failed taking balance snapshots: %v", err)
+		} else if n > 0 {
+			w.logger.With(ctx).Infof("took %d balance snapshots", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Take runs a single round of snapshots and returns the number of snapshots taken.
+// If another worker is running at the moment, Take does nothing.
+func (w *Worker) Take(ctx context.Context) (int64, error) {
+	var taken int64
+	err := w.transactional(ctx, func(ctx context.Context) error {
+		locked, err := w.repo.TryLock(ctx)
+		if err != nil || !locked {
+			return err
+		}
+
+		taken, err = w.repo.Take(ctx, w.minTransactions)
+		return err
+	})
+	return taken, err
+}
@@ -0,0 +1,91 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	databaseError = errors.New("database error")
+	logger, _     = log.NewForTest()
+	ctx           = context.Background()
+)
+
+// inPlace runs the function without a real DB transaction.
+func inPlace(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+func TestWorker_Take(t *testing.T) {
+	repo := &mockRepository{pending: 3}
+	w := NewWorker(repo, inPlace, logger)
+
+	// the minimum number of transactions is passed to the repository
+	n, err := w.Take(ctx)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 3, n)
+		assert.Equal(t, defaultMinTransactions, repo.minTransactions)
+	}
+
+	// nothing left
+	n, err = w.Take(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+	}
+
+	// another worker is running
+	repo.pending = 2
+	repo.locked = true
+	n, err = w.Take(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+		assert.EqualValues(t, 2, repo.pending)
+	}
+	repo.locked = false
+
+	// repository error
+	repo.err = databaseError
+	_, err = w.Take(ctx)
+	assert.Equal(t, databaseError, err)
+}
+
+func TestWorker_Run(t *testing.T) {
+	repo := &mockRepository{pending: 1}
+	w := NewWorker(repo, inPlace, logger)
+
+	// the first round is taken right away, Run returns once the context is cancelled
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	w.Run(ctx, time.Hour)
+	assert.Zero(t, repo.pending)
+}
+
+type mockRepository struct {
+	// the number of Deposits which need a snapshot
+	pending         int64
+	minTransactions int
+	locked          bool
+	err             error
+}
+
+func (m *mockRepository) Take(ctx context.Context, minTransactions int) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.minTransactions = minTransactions
+	taken := m.pending
+	m.pending = 0
+	return taken, nil
+}
+
+func (m *mockRepository) TryLock(ctx context.Context) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	return !m.locked, nil
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+)
+
+var (
+	// ErrDatabase is returned by the test doubles to simulate a database error.
+	ErrDatabase = errors.New("database error")
+	// FailingOwnerId is the UUID of the owner whose operations fail with ErrDatabase in the test doubles.
+	FailingOwnerId = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+)
+
+// DepositRepository is an in-memory deposit.Repository for testing services and APIs which change Deposits.
+type DepositRepository struct {
+	Items []entity.Deposit
+	// Locks lists the owners locked by Lock, in order.
+	Locks []uuid.UUID
+	// History lists the balances at points in time returned by BalanceAt.
+	History       []entity.BalanceSnapshot
+	StatusChanges []entity.DepositStatusChange
+	// LastAt is the point in time of the latest BalanceAt call.
+	LastAt time.Time
+}
+
+func (m *DepositRepository) Get(ctx context.Context, ownerId uuid.UUID, currency string) (entity.Deposit, error) {
+	for _, item := range m.Items {
+		if item.OwnerId == ownerId && entity.CurrencyOrBase(item.Currency) == currency {
+			return item, nil
+		}
+	}
+	return entity.Deposit{}, sql.ErrNoRows
+}
+
+func (m *DepositRepository) GetMany(ctx context.Context, ownerIds []uuid.UUID, currency string) ([]entity.Deposit, error) {
+	var result []entity.Deposit
+	for _, id := range ownerIds {
+		if id == FailingOwnerId {
+			return nil, ErrDatabase
+		}
+	}
+	for _, item := range m.Items {
+		for _, id := range ownerIds {
+			if item.OwnerId == id && entity.CurrencyOrBase(item.Currency) == currency {
+				result = append(result, item)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *DepositRepository) Create(ctx context.Context, deposit entity.Deposit) error {
+	if deposit.Balance < 0 || deposit.Reserved < 0 || deposit.Reserved > deposit.Balance {
+		return ErrDatabase
+	}
+	m.Items = append(m.Items, deposit)
+	return nil
+}
+
+// Lock creates the missing Deposits like the real repository does.
+func (m *DepositRepository) Lock(ctx context.Context, currency string, ownerIds ...uuid.UUID) error {
+	for _, id := range ownerIds {
+		if id == FailingOwnerId {
+			return ErrDatabase
+		}
+		if _, err := m.Get(ctx, id, currency); err == sql.ErrNoRows {
+			m.Items = append(m.Items, entity.Deposit{OwnerId: id, Currency: currency})
+		}
+		m.Locks = append(m.Locks, id)
+	}
+	return nil
+}
+
+func (m *DepositRepository) Modify(ctx context.Context, ownerId uuid.UUID, currency string, amount, reserved int64) (entity.Deposit, error) {
+	for i, item := range m.Items {
+		if item.OwnerId == ownerId && entity.CurrencyOrBase(item.Currency) == currency {
+			if !item.Allows(amount, reserved) || item.Reserved+reserved < 0 || item.Balance+amount-(item.Reserved+reserved) < 0 {
+				return entity.Deposit{}, sql.ErrNoRows
+			}
+			m.Items[i].Balance += amount
+			m.Items[i].Reserved += reserved
+			return m.Items[i], nil
+		}
+	}
+	return entity.Deposit{}, sql.ErrNoRows
+}
+
+func (m *DepositRepository) SetStatus(ctx context.Context, ownerId uuid.UUID, currency, status string) error {
+	for i, item := range m.Items {
+		if item.OwnerId == ownerId && entity.CurrencyOrBase(item.Currency) == currency {
+			m.Items[i].Status = status
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *DepositRepository) CreateStatusChange(ctx context.Context, change *entity.DepositStatusChange) error {
+	if change.OwnerId == FailingOwnerId {
+		return ErrDatabase
+	}
+	change.Id = int64(len(m.StatusChanges) + 1)
+	m.StatusChanges = append(m.StatusChanges, *change)
+	return nil
+}
+
+func (m *DepositRepository) GetStatusChanges(ctx context.Context, ownerId uuid.UUID, currency string) ([]entity.DepositStatusChange, error) {
+	if ownerId == FailingOwnerId {
+		return nil, ErrDatabase
+	}
+	var result []entity.DepositStatusChange
+	for _, change := range m.StatusChanges {
+		if change.OwnerId == ownerId && change.Currency == currency {
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
+
+func (m *DepositRepository) Query(ctx context.Context, offset, limit int) ([]entity.Deposit, error) {
+	if offset >= len(m.Items) {
+		return nil, nil
+	}
+	end := len(m.Items)
+	if limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return m.Items[offset:end], nil
+}
+
+func (m *DepositRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.Items)), nil
+}
+
+// BalanceAt returns the balance of the latest History item made at or before the given time.
+func (m *DepositRepository) BalanceAt(ctx context.Context, ownerId uuid.UUID, currency string, at time.Time) (int64, error) {
+	if ownerId == FailingOwnerId {
+		return 0, ErrDatabase
+	}
+	m.LastAt = at
+	var balance int64
+	for _, item := range m.History {
+		if item.OwnerId == ownerId && entity.CurrencyOrBase(item.Currency) == currency && !item.TransactionDate.After(at) {
+			balance = item.Balance
+		}
+	}
+	return balance, nil
+}
@@ -0,0 +1,44 @@
+package transaction
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"users-balance-microservice/internal/entity"
+)
+
+// errInvalidCursor is returned for a cursor which was not returned with a page of the same history.
+var errInvalidCursor = validation.Errors{
+	"cursor": validation.NewError("validation_invalid_cursor", "must be a cursor returned with the previous page."),
+}
+
+// cursor points at the last Transaction of a page of history. It is passed to clients as an opaque string.
+// The ordering is kept in the cursor, so that it cannot be used with a different one.
+type cursor struct {
+	OrderBy         string    `json:"o"`
+	OrderDirection  string    `json:"d"`
+	Id              int64     `json:"i"`
+	Amount          int64     `json:"a"`
+	TransactionDate time.Time `json:"t"`
+}
+
+// encode returns the opaque representation of the cursor.
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses the cursor returned by encode and checks that it was made for the given ordering.
+func decodeCursor(s, orderBy, orderDirection string) (entity.Transaction, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return entity.Transaction{}, errInvalidCursor
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.OrderBy != orderBy || c.OrderDirection != orderDirection {
+		return entity.Transaction{}, errInvalidCursor
+	}
+	return entity.Transaction{Id: c.Id, Amount: c.Amount, TransactionDate: c.TransactionDate}, nil
+}
@@ -2,6 +2,9 @@ package transaction
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	dbx "github.com/go-ozzo/ozzo-dbx"
 	"github.com/google/uuid"
@@ -12,12 +15,107 @@ import (
 
 // Repository encapsulates the logic to access transactions from the database.
 type Repository interface {
+	// Get returns the Transaction with the specified id.
+	Get(ctx context.Context, id int64) (entity.Transaction, error)
+	// Lock returns the Transaction with the specified id and locks it until the end of the current DB transaction.
+	Lock(ctx context.Context, id int64) (entity.Transaction, error)
+	// RefundedAmount returns the sum of the amounts of all refunds of the Transaction with the specified id.
+	RefundedAmount(ctx context.Context, id int64) (int64, error)
 	// Create saves a new Transaction in the storage.
 	// Transaction tx is assigned an id from database in case of successful transaction.
 	Create(ctx context.Context, tx *entity.Transaction) error
 	Count(ctx context.Context) (int64, error)
-	// GetForUser returns a list of all transactions related to given userId.
-	GetForUser(ctx context.Context, ownerId uuid.UUID, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error)
+	// GetForUser returns a list of all transactions related to given userId which match the filter.
+	GetForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error)
+	// GetPageForUser returns at most limit transactions related to given userId which match the filter and follow
+	// the after Transaction in the given order. Transactions are ordered by orderBy and then by id.
+	// If after is nil, the first page is returned.
+	GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error)
+	// ExportForUser calls fn for every transaction related to given userId which matches the filter, in the given order
+	// and then by id. Rows are read from the database one by one.
+	ExportForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error
+	// Balance returns the sum of the changes of the balance made by all transactions of the user's Deposit in
+	// the currency. It is equal to the stored balance of the Deposit unless they are out of sync.
+	Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error)
+	// BalancesAfter returns the available balance of the user's Deposit in the currency right after each of the given
+	// transactions, keyed by transaction id.
+	BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error)
+}
+
+// Operations from the user's point of view which are not represented by a Transaction type.
+const (
+	OperationTopUp       = "top_up"
+	OperationWithdrawal  = "withdrawal"
+	OperationTransferIn  = "transfer_in"
+	OperationTransferOut = "transfer_out"
+)
+
+// HistoryFilter narrows down the list of transactions related to a user. Zero fields are not applied.
+type HistoryFilter struct {
+	// Transactions in the currency.
+	Currency string
+	// Transactions made at or after From.
+	From time.Time
+	// Transactions made before To.
+	To time.Time
+	// One of the Operation* constants or a Transaction type.
+	Operation string
+	// Transactions with the amount of at least MinAmount.
+	MinAmount int64
+	// Transactions with the amount of at most MaxAmount.
+	MaxAmount int64
+	// Transactions to or from the other user.
+	CounterpartyId uuid.UUID
+	// Transactions whose description contains the substring, case-insensitive.
+	Description string
+}
+
+// expression builds the condition of the filter for the transactions of the user.
+func (f HistoryFilter) expression(ownerId uuid.UUID) dbx.Expression {
+	var exps []dbx.Expression
+	if f.Currency != "" {
+		exps = append(exps, dbx.HashExp{"currency": f.Currency})
+	}
+	if !f.From.IsZero() {
+		exps = append(exps, dbx.NewExp("transaction_date >= {:from}", dbx.Params{"from": f.From}))
+	}
+	if !f.To.IsZero() {
+		exps = append(exps, dbx.NewExp("transaction_date < {:to}", dbx.Params{"to": f.To}))
+	}
+	if f.MinAmount > 0 {
+		exps = append(exps, dbx.NewExp("amount >= {:min_amount}", dbx.Params{"min_amount": f.MinAmount}))
+	}
+	if f.MaxAmount > 0 {
+		exps = append(exps, dbx.NewExp("amount <= {:max_amount}", dbx.Params{"max_amount": f.MaxAmount}))
+	}
+	if f.CounterpartyId != uuid.Nil {
+		exps = append(exps, dbx.Or(dbx.HashExp{"sender_id": f.CounterpartyId}, dbx.HashExp{"recipient_id": f.CounterpartyId}))
+	}
+	if f.Description != "" {
+		like := dbx.Like("description", f.Description)
+		like.Like = "ILIKE"
+		exps = append(exps, like)
+	}
+
+	switch f.Operation {
+	case "":
+	case OperationTopUp:
+		exps = append(exps, dbx.HashExp{"type": "", "recipient_id": ownerId}, missing("sender_id"))
+	case OperationWithdrawal:
+		exps = append(exps, dbx.HashExp{"type": "", "sender_id": ownerId}, missing("recipient_id"))
+	case OperationTransferIn:
+		exps = append(exps, dbx.HashExp{"type": "", "recipient_id": ownerId}, dbx.Not(missing("sender_id")))
+	case OperationTransferOut:
+		exps = append(exps, dbx.HashExp{"type": "", "sender_id": ownerId}, dbx.Not(missing("recipient_id")))
+	default:
+		exps = append(exps, dbx.HashExp{"type": f.Operation})
+	}
+	return dbx.And(exps...)
+}
+
+// missing builds the condition of a transaction participant being absent, which is stored either as NULL or Nil UUID.
+func missing(col string) dbx.Expression {
+	return dbx.Or(dbx.HashExp{col: nil}, dbx.HashExp{col: uuid.Nil})
 }
 
 // repository persists Transaction in database
@@ -31,6 +129,32 @@ func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
 	return repository{db, logger}
 }
 
+// Get reads the Transaction with the specified id from the database.
+func (r repository) Get(ctx context.Context, id int64) (entity.Transaction, error) {
+	var tx entity.Transaction
+	err := r.db.With(ctx).Select().Model(id, &tx)
+	return tx, err
+}
+
+// Lock reads the Transaction with the specified id with SELECT ... FOR UPDATE.
+func (r repository) Lock(ctx context.Context, id int64) (entity.Transaction, error) {
+	var tx entity.Transaction
+	err := r.db.With(ctx).NewQuery("SELECT * FROM transaction WHERE id={:id} FOR UPDATE").
+		Bind(dbx.Params{"id": id}).
+		One(&tx)
+	return tx, err
+}
+
+// RefundedAmount sums up the amounts of the refund transactions which refer to the Transaction.
+func (r repository) RefundedAmount(ctx context.Context, id int64) (int64, error) {
+	var refunded int64
+	err := r.db.With(ctx).Select("COALESCE(SUM(amount), 0)").
+		From("transaction").
+		Where(dbx.HashExp{"refund_of": id}).
+		Row(&refunded)
+	return refunded, err
+}
+
 // Create saves a new Transaction record in the database.
 // Transaction is assigned an auto-incremented id from database.
 func (r repository) Create(ctx context.Context, tx *entity.Transaction) error {
@@ -43,11 +167,12 @@ func (r repository) Count(ctx context.Context) (int64, error) {
 	return count, err
 }
 
-// GetForUser returns all transactions from and to the user with given id.
-func (r repository) GetForUser(ctx context.Context, ownerId uuid.UUID, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
+// GetForUser returns all transactions from and to the user with given id which match the filter.
+func (r repository) GetForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
 	var result []entity.Transaction
 	query := r.db.With(ctx).Select().
 		Where(dbx.Or(dbx.HashExp{"sender_id": ownerId}, dbx.HashExp{"recipient_id": ownerId})).
+		AndWhere(filter.expression(ownerId)).
 		Offset(int64(offset)).
 		Limit(int64(limit))
 
@@ -62,3 +187,162 @@ func (r repository) GetForUser(ctx context.Context, ownerId uuid.UUID, orderBy,
 	err := query.All(&result)
 	return result, err
 }
+
+// GetPageForUser returns a page of transactions from and to the user with given id using keyset pagination.
+// The transactions sent and received by the user are read separately, so that each part can be read
+// in order from the index by (sender_id, orderBy, id) or (recipient_id, orderBy, id).
+// orderBy and orderDirection must be validated by the caller, as they are put into the query as they are.
+func (r repository) GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error) {
+	comparison := ">"
+	if orderDirection == "DESC" {
+		comparison = "<"
+	}
+	order := fmt.Sprintf("%s %s, id %s", orderBy, orderDirection, orderDirection)
+
+	params := dbx.Params{"owner": ownerId, "limit": limit}
+	conditions := ""
+	if condition := filter.expression(ownerId).Build(r.db.DB(), params); condition != "" {
+		conditions = " AND (" + condition + ")"
+	}
+	if after != nil {
+		conditions += fmt.Sprintf(" AND (%s, id) %s ({:key}, {:id})", orderBy, comparison)
+		params["id"] = after.Id
+		if orderBy == "amount" {
+			params["key"] = after.Amount
+		} else {
+			params["key"] = after.TransactionDate
+		}
+	}
+
+	var result []entity.Transaction
+	err := r.db.With(ctx).NewQuery(fmt.Sprintf(`
+		SELECT * FROM (
+			(SELECT * FROM transaction WHERE sender_id = {:owner}%[1]s ORDER BY %[2]s LIMIT {:limit})
+			UNION ALL
+			(SELECT * FROM transaction WHERE recipient_id = {:owner} AND sender_id IS DISTINCT FROM {:owner}%[1]s ORDER BY %[2]s LIMIT {:limit})
+		) page ORDER BY %[2]s LIMIT {:limit}`, conditions, order),
+	).Bind(params).All(&result)
+	return result, err
+}
+
+// ExportForUser reads the transactions from and to the user with given id which match the filter without loading
+// them all into memory. orderBy and orderDirection must be validated by the caller.
+func (r repository) ExportForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error {
+	rows, err := r.db.With(ctx).Select().
+		From("transaction").
+		Where(dbx.Or(dbx.HashExp{"sender_id": ownerId}, dbx.HashExp{"recipient_id": ownerId})).
+		AndWhere(filter.expression(ownerId)).
+		OrderBy(orderBy+" "+orderDirection, "id "+orderDirection).
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tx entity.Transaction
+		if err := rows.ScanStruct(&tx); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Balance sums up the BalanceChange of the transactions of the user in the currency with a single query.
+func (r repository) Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error) {
+	var balance int64
+	err := r.db.With(ctx).NewQuery(fmt.Sprintf(`
+		SELECT COALESCE(SUM(%s), 0)
+		FROM transaction
+		WHERE (sender_id = {:owner} OR recipient_id = {:owner}) AND currency = {:currency}`, BalanceChange("{:owner}"))).
+		Bind(dbx.Params{"owner": ownerId, "currency": currency}).
+		Row(&balance)
+	return balance, err
+}
+
+// AvailableChange returns the SQL expression of the change of the available balance of the owner made by a row of
+// the transaction table. Captures do not change the available balance, as the money was already held.
+// owner is an SQL expression of the owner's id, e.g. a column or a query parameter.
+func AvailableChange(owner string) string {
+	return change(owner, entity.TransactionTypeCapture)
+}
+
+// BalanceChange returns the SQL expression of the change of the stored balance of the owner made by a row of
+// the transaction table, the same as entity.Transaction.BalanceChange. Holds and releases do not change the stored
+// balance. owner is an SQL expression of the owner's id, e.g. a column or a query parameter.
+func BalanceChange(owner string) string {
+	return change(owner, entity.TransactionTypeHold, entity.TransactionTypeRelease)
+}
+
+// change returns the SQL expression of the change of a balance of the owner made by a row of the transaction table,
+// which must be a transaction of the owner. Transactions of the given types do not change the balance.
+//
+// The balance of a Deposit right after one of its transactions is the sum of the changes made by the transactions
+// with ids up to its id: transactions of a Deposit are made one at a time under its lock, so their ids are in the same
+// order and a committed transaction never gets an id lower than the one of an already committed transaction of
+// the same Deposit.
+func change(owner string, unchanged ...string) string {
+	types := make([]string, len(unchanged))
+	for i, t := range unchanged {
+		types[i] = "'" + t + "'"
+	}
+	return fmt.Sprintf(`CASE
+		WHEN type IN (%s) OR sender_id = recipient_id THEN 0
+		WHEN recipient_id = %s THEN amount
+		ELSE -amount
+	END`, strings.Join(types, ", "), owner)
+}
+
+// BalancesAfter starts from the latest BalanceSnapshot taken before the first of the given transactions and sums up
+// the AvailableChange of the later transactions up to each of the given ones in the order of ids, so that a page
+// of history does not read the whole history of the user.
+func (r repository) BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error) {
+	result := make(map[int64]int64, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	minId, maxId := ids[0], ids[0]
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+		if id < minId {
+			minId = id
+		}
+		if id > maxId {
+			maxId = id
+		}
+	}
+	params := dbx.Params{"owner": ownerId, "currency": currency, "min_id": minId, "max_id": maxId}
+	condition := dbx.In("id", values...).Build(r.db.DB(), params)
+
+	var rows []struct {
+		Id           int64
+		BalanceAfter int64
+	}
+	err := r.db.With(ctx).NewQuery(fmt.Sprintf(`
+		WITH snapshot AS (
+			SELECT transaction_id, balance FROM balance_snapshot
+			WHERE owner_id = {:owner} AND currency = {:currency} AND transaction_id < {:min_id}
+			ORDER BY transaction_id DESC
+			LIMIT 1
+		)
+		SELECT id, COALESCE((SELECT balance FROM snapshot), 0) + balance_after AS balance_after FROM (
+			SELECT id, SUM(%s) OVER (ORDER BY id) AS balance_after
+			FROM transaction
+			WHERE (sender_id = {:owner} OR recipient_id = {:owner}) AND currency = {:currency}
+				AND id > COALESCE((SELECT transaction_id FROM snapshot), 0) AND id <= {:max_id}
+		) history WHERE %s`, AvailableChange("{:owner}"), condition),
+	).Bind(params).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.Id] = row.BalanceAfter
+	}
+	return result, nil
+}
@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -15,7 +16,7 @@ import (
 func TestRepository(t *testing.T) {
 	logger, _ := log.NewForTest()
 	db := test.DB(t)
-	test.ResetTables(t, db, "transaction")
+	test.ResetTables(t, db, "transaction", "balance_snapshot")
 	repo := NewRepository(db, logger)
 
 	ctx := context.Background()
@@ -32,6 +33,7 @@ func TestRepository(t *testing.T) {
 		SenderId:        id1,
 		RecipientId:     uuid.Nil,
 		Amount:          300,
+		Currency:        entity.BaseCurrency,
 		Description:     "Monthly subscription",
 		TransactionDate: time.Now(),
 	}
@@ -50,6 +52,7 @@ func TestRepository(t *testing.T) {
 		SenderId:        uuid.Nil,
 		RecipientId:     id1,
 		Amount:          500,
+		Currency:        entity.BaseCurrency,
 		Description:     "VISA top-up",
 		TransactionDate: time.Now(),
 	}
@@ -70,6 +73,7 @@ func TestRepository(t *testing.T) {
 		SenderId:        id1,
 		RecipientId:     id2,
 		Amount:          1500,
+		Currency:        entity.BaseCurrency,
 		Description:     "thanks for dinner!",
 		TransactionDate: time.Now(),
 	}
@@ -84,6 +88,14 @@ func TestRepository(t *testing.T) {
 		}
 	}
 
+	// get by id
+	tx2, err := repo.Get(ctx, tx.Id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tx.SenderId, tx2.SenderId)
+		assert.Equal(t, tx.RecipientId, tx2.RecipientId)
+		assert.Equal(t, tx.Amount, tx2.Amount)
+	}
+
 	// create with negative amount -> db error
 	err = repo.Create(ctx, &entity.Transaction{
 		Id:              0,
@@ -101,19 +113,19 @@ func TestRepository(t *testing.T) {
 	}
 
 	// list for user
-	txs, err := repo.GetForUser(ctx, id1, "", "", 0, -1)
+	txs, err := repo.GetForUser(ctx, id1, HistoryFilter{}, "", "", 0, -1)
 	if assert.NoError(t, err) {
 		assert.Len(t, txs, 3)
 	}
 
 	// list for user with pagination
-	txs, err = repo.GetForUser(ctx, id1, "", "", 1, 1)
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{}, "", "", 1, 1)
 	if assert.NoError(t, err) {
 		assert.Len(t, txs, 1)
 	}
 
 	// list for user with order
-	txs, err = repo.GetForUser(ctx, id1, "amount", "", 0, -1)
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{}, "amount", "", 0, -1)
 	if assert.NoError(t, err) {
 		assert.Len(t, txs, 3)
 
@@ -127,7 +139,7 @@ func TestRepository(t *testing.T) {
 	}
 
 	// list for user with order and direction
-	txs, err = repo.GetForUser(ctx, id1, "amount", "DESC", 0, -1)
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{}, "amount", "DESC", 0, -1)
 	if assert.NoError(t, err) {
 		assert.Len(t, txs, 3)
 
@@ -138,4 +150,174 @@ func TestRepository(t *testing.T) {
 
 		assert.IsNonIncreasing(t, amounts)
 	}
+
+	// list for user by pages: 3 transactions, ordered by amount and then by id
+	page, err := repo.GetPageForUser(ctx, id1, HistoryFilter{}, "amount", "ASC", nil, 2)
+	if assert.NoError(t, err) && assert.Len(t, page, 2) {
+		assert.True(t, page[0].Amount <= page[1].Amount)
+
+		next, err := repo.GetPageForUser(ctx, id1, HistoryFilter{}, "amount", "ASC", &page[1], 2)
+		if assert.NoError(t, err) && assert.Len(t, next, 1) {
+			assert.True(t, page[1].Amount <= next[0].Amount)
+			assert.NotEqual(t, page[1].Id, next[0].Id)
+		}
+	}
+
+	// list for user by pages in descending order of date
+	page, err = repo.GetPageForUser(ctx, id1, HistoryFilter{}, "transaction_date", "DESC", nil, 1)
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		next, err := repo.GetPageForUser(ctx, id1, HistoryFilter{}, "transaction_date", "DESC", &page[0], 10)
+		if assert.NoError(t, err) && assert.Len(t, next, 2) {
+			assert.False(t, next[0].TransactionDate.After(page[0].TransactionDate))
+			assert.False(t, next[1].TransactionDate.After(next[0].TransactionDate))
+		}
+	}
+
+	// list for user filtered by operation
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{Operation: OperationTopUp}, "", "", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 1) {
+		assert.Equal(t, int64(500), txs[0].Amount)
+	}
+	txs, err = repo.GetForUser(ctx, id2, HistoryFilter{Operation: OperationTransferIn}, "", "", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 1) {
+		assert.Equal(t, int64(1500), txs[0].Amount)
+	}
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{Operation: OperationTransferIn}, "", "", 0, -1)
+	if assert.NoError(t, err) {
+		assert.Empty(t, txs)
+	}
+
+	// list for user filtered by amount range, counterparty and description
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{MinAmount: 400, MaxAmount: 1500}, "amount", "ASC", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 2) {
+		assert.Equal(t, int64(500), txs[0].Amount)
+		assert.Equal(t, int64(1500), txs[1].Amount)
+	}
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{CounterpartyId: id2}, "", "", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 1) {
+		assert.Equal(t, id2, txs[0].RecipientId)
+	}
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{Description: "visa"}, "", "", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 1) {
+		assert.Equal(t, "VISA top-up", txs[0].Description)
+	}
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{Description: "100%"}, "", "", 0, -1)
+	if assert.NoError(t, err) {
+		assert.Empty(t, txs) // wildcards are escaped
+	}
+
+	// list for user filtered by date range
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}, "", "", 0, -1)
+	if assert.NoError(t, err) {
+		assert.Len(t, txs, 3)
+	}
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{To: time.Now().Add(-time.Hour)}, "", "", 0, -1)
+	if assert.NoError(t, err) {
+		assert.Empty(t, txs)
+	}
+
+	// list for user by pages with a filter
+	page, err = repo.GetPageForUser(ctx, id1, HistoryFilter{MinAmount: 400}, "amount", "DESC", nil, 1)
+	if assert.NoError(t, err) && assert.Len(t, page, 1) {
+		assert.Equal(t, int64(1500), page[0].Amount)
+
+		next, err := repo.GetPageForUser(ctx, id1, HistoryFilter{MinAmount: 400}, "amount", "DESC", &page[0], 10)
+		if assert.NoError(t, err) && assert.Len(t, next, 1) {
+			assert.Equal(t, int64(500), next[0].Amount)
+		}
+	}
+
+	// export for user in descending order of amount
+	var exported []entity.Transaction
+	err = repo.ExportForUser(ctx, id1, HistoryFilter{}, "amount", "DESC", func(tx entity.Transaction) error {
+		exported = append(exported, tx)
+		return nil
+	})
+	if assert.NoError(t, err) && assert.Len(t, exported, 3) {
+		assert.Equal(t, int64(1500), exported[0].Amount)
+		assert.Equal(t, int64(500), exported[1].Amount)
+		assert.Equal(t, int64(300), exported[2].Amount)
+	}
+
+	// export for user with a filter
+	exported = nil
+	err = repo.ExportForUser(ctx, id2, HistoryFilter{Operation: OperationTransferIn}, "transaction_date", "ASC", func(tx entity.Transaction) error {
+		exported = append(exported, tx)
+		return nil
+	})
+	if assert.NoError(t, err) && assert.Len(t, exported, 1) {
+		assert.Equal(t, id1, exported[0].SenderId)
+	}
+
+	// balances after transactions in the order they were made, regardless of the order they are requested in
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{}, "amount", "DESC", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 3) {
+		balances, err := repo.BalancesAfter(ctx, id1, entity.BaseCurrency, []int64{txs[0].Id, txs[1].Id, txs[2].Id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[int64]int64{txs[2].Id: -300, txs[1].Id: 200, txs[0].Id: -1300}, balances)
+		}
+
+		balances, err = repo.BalancesAfter(ctx, id2, entity.BaseCurrency, []int64{txs[0].Id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[int64]int64{txs[0].Id: 1500}, balances)
+		}
+
+		// the sum starts from the latest snapshot before the first transaction
+		snapshot := entity.BalanceSnapshot{OwnerId: id1, Currency: entity.BaseCurrency, TransactionId: txs[2].Id, TransactionDate: txs[2].TransactionDate, Balance: 700}
+		assert.NoError(t, db.With(ctx).Model(&snapshot).Insert())
+		balances, err = repo.BalancesAfter(ctx, id1, entity.BaseCurrency, []int64{txs[0].Id, txs[1].Id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[int64]int64{txs[1].Id: 1200, txs[0].Id: -300}, balances)
+		}
+		balances, err = repo.BalancesAfter(ctx, id1, entity.BaseCurrency, []int64{txs[2].Id})
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[int64]int64{txs[2].Id: -300}, balances)
+		}
+	}
+
+	// balance over the whole history
+	balance, err := repo.Balance(ctx, id1, entity.BaseCurrency)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, -1300, balance)
+	}
+
+	// transactions in another currency are listed and summed up separately
+	tx = entity.Transaction{RecipientId: id1, Amount: 70, Currency: "USD", TransactionDate: time.Now()}
+	assert.NoError(t, repo.Create(ctx, &tx))
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{Currency: "USD"}, "", "", 0, -1)
+	if assert.NoError(t, err) && assert.Len(t, txs, 1) {
+		assert.Equal(t, tx.Id, txs[0].Id)
+	}
+	txs, err = repo.GetForUser(ctx, id1, HistoryFilter{Currency: entity.BaseCurrency}, "", "", 0, -1)
+	if assert.NoError(t, err) {
+		assert.Len(t, txs, 3)
+	}
+	balances, err := repo.BalancesAfter(ctx, id1, "USD", []int64{tx.Id})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[int64]int64{tx.Id: 70}, balances)
+	}
+	balance, err = repo.Balance(ctx, id1, "USD")
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 70, balance)
+	}
+
+	// lock the transaction and sum up its refunds
+	locked, err := repo.Lock(ctx, tx.Id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tx.Amount, locked.Amount)
+	}
+	_, err = repo.Lock(ctx, tx.Id+1000)
+	assert.Equal(t, sql.ErrNoRows, err)
+	refunded, err := repo.RefundedAmount(ctx, tx.Id)
+	if assert.NoError(t, err) {
+		assert.Zero(t, refunded)
+	}
+	for _, amount := range []int64{20, 30} {
+		refund := entity.Transaction{SenderId: id1, Amount: amount, Currency: "USD", TransactionDate: time.Now(), Type: entity.TransactionTypeRefund, RefundOf: &tx.Id}
+		assert.NoError(t, repo.Create(ctx, &refund))
+	}
+	refunded, err = repo.RefundedAmount(ctx, tx.Id)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 50, refunded)
+	}
 }
@@ -2,22 +2,49 @@ package transaction
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
 	"users-balance-microservice/internal/requests"
 	"users-balance-microservice/pkg/log"
+	"users-balance-microservice/pkg/money"
 )
 
 // Service encapsulates usecase logic for transactions.
 type Service interface {
+	// Get returns the Transaction with the specified id.
+	Get(ctx context.Context, id int64) (Transaction, error)
 	// CreateUpdateTransaction creates a Transaction based on UpdateBalanceRequest.
 	CreateUpdateTransaction(ctx context.Context, req requests.UpdateBalanceRequest) (Transaction, error)
 	// CreateTransferTransaction creates a Transaction based on TransferRequest.
 	CreateTransferTransaction(ctx context.Context, req requests.TransferRequest) (Transaction, error)
+	// CreateReservationTransaction creates a Transaction of the given type which belongs to the Reservation.
+	CreateReservationTransaction(ctx context.Context, reservation entity.Reservation, txType string) (Transaction, error)
+	// CreateCorrectionTransaction creates a correction Transaction which adds amount (positive or negative)
+	// to the history of the owner's Deposit in the currency without changing its balance.
+	CreateCorrectionTransaction(ctx context.Context, ownerId uuid.UUID, currency string, amount int64, description string) (Transaction, error)
+	// CreateExchangeTransactions creates the pair of Transactions of a currency exchange based on ExchangeRequest:
+	// the debit of the From currency and the credit of the given amount of the To currency at the given rate
+	// computed from the saved set of rates with the given id.
+	CreateExchangeTransactions(ctx context.Context, req requests.ExchangeRequest, credited int64, rate string, rateId int64) ([]Transaction, error)
+	// GetRefundable locks the Transaction refunded by RefundRequest until the end of the DB transaction and returns it
+	// together with its amount which was not refunded yet.
+	GetRefundable(ctx context.Context, req requests.RefundRequest) (Transaction, int64, error)
+	// CreateRefundTransaction creates a refund Transaction of the given amount based on RefundRequest, which reverses
+	// the original Transaction.
+	CreateRefundTransaction(ctx context.Context, req requests.RefundRequest, original entity.Transaction, amount int64) (Transaction, error)
 	// GetHistory returns a list of all transactions related to the user with the given ID.
-	GetHistory(ctx context.Context, req requests.GetHistoryRequest) ([]entity.Transaction, error)
+	GetHistory(ctx context.Context, req requests.GetHistoryRequest) ([]HistoryItem, error)
+	// GetHistoryPage returns a page of transactions related to the user with the given ID
+	// starting after GetHistoryRequest.Cursor.
+	GetHistoryPage(ctx context.Context, req requests.GetHistoryRequest) (HistoryPage, error)
+	// ExportHistory calls fn for every transaction related to the user with the given ID without loading
+	// the whole history into memory.
+	ExportHistory(ctx context.Context, req requests.GetHistoryRequest, fn func(entity.Transaction) error) error
 	// Count returns a number of all Transactions in the database. Mainly used for testing purposes.
 	Count(ctx context.Context) (int64, error)
 }
@@ -27,14 +54,116 @@ type Transaction struct {
 	entity.Transaction
 }
 
+// Directions of a HistoryItem.
+const (
+	// DirectionCredit means that the money came to the user's Deposit.
+	DirectionCredit = "credit"
+	// DirectionDebit means that the money left the user's Deposit.
+	DirectionDebit = "debit"
+)
+
+// HistoryItem represents a Transaction in the history of a user, as seen by the user.
+type HistoryItem struct {
+	entity.Transaction
+	// DirectionCredit or DirectionDebit.
+	Direction string `json:"direction"`
+	// UUID of the other participant of a transfer. Nil for other transactions.
+	CounterpartyId uuid.UUID `json:"counterparty_id"`
+	// The operation from the user's point of view: one of the Operation* constants or the Transaction type.
+	// It replaces the type of the Transaction in JSON.
+	Operation string `json:"type"`
+	// The available balance of the user's Deposit right after the Transaction, in minor units of its currency.
+	BalanceAfter int64 `json:"balance_after"`
+}
+
+// MarshalJSON encodes HistoryItem with the amount of the Transaction and BalanceAfter in major units of the currency.
+// The Transaction is encoded field by field, since its own MarshalJSON would replace the fields of HistoryItem.
+func (i HistoryItem) MarshalJSON() ([]byte, error) {
+	type plain entity.Transaction
+	return json.Marshal(struct {
+		plain
+		Amount         json.Number `json:"amount"`
+		Direction      string      `json:"direction"`
+		CounterpartyId uuid.UUID   `json:"counterparty_id"`
+		Operation      string      `json:"type"`
+		BalanceAfter   json.Number `json:"balance_after"`
+	}{
+		plain(i.Transaction), i.Money().Number(), i.Direction, i.CounterpartyId, i.Operation,
+		money.New(i.BalanceAfter, entity.CurrencyOrBase(i.Currency)).Number(),
+	})
+}
+
+// newHistoryItem describes the Transaction from the point of view of the owner.
+func newHistoryItem(tx entity.Transaction, ownerId uuid.UUID, balanceAfter int64) HistoryItem {
+	item := HistoryItem{Transaction: tx, Direction: DirectionDebit, Operation: tx.Type, BalanceAfter: balanceAfter}
+	if tx.RecipientId == ownerId {
+		item.Direction = DirectionCredit
+	}
+
+	if tx.Type == "" {
+		switch {
+		case tx.SenderId == uuid.Nil:
+			item.Operation = OperationTopUp
+		case tx.RecipientId == uuid.Nil:
+			item.Operation = OperationWithdrawal
+		case item.Direction == DirectionCredit:
+			item.Operation = OperationTransferIn
+			item.CounterpartyId = tx.SenderId
+		default:
+			item.Operation = OperationTransferOut
+			item.CounterpartyId = tx.RecipientId
+		}
+	}
+	return item
+}
+
+// HistoryPage represents a page of the history of transactions.
+type HistoryPage struct {
+	Transactions []HistoryItem `json:"transactions"`
+	// The cursor of the next page. Empty if there are no more transactions.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// defaultPageSize is the number of transactions on a page of history if the limit is not specified.
+const defaultPageSize = 100
+
+// Recorder records every created Transaction somewhere else within the same DB transaction, e.g. into the ledger.
+type Recorder interface {
+	// Record records the given Transaction.
+	Record(ctx context.Context, tx entity.Transaction) error
+}
+
 type service struct {
-	repo   Repository
-	logger log.Logger
+	repo      Repository
+	logger    log.Logger
+	recorders []Recorder
 }
 
 // NewService creates a new Transaction service.
-func NewService(repo Repository, logger log.Logger) Service {
-	return service{repo, logger}
+// Every Transaction created by the service is passed to the given recorders.
+func NewService(repo Repository, logger log.Logger, recorders ...Recorder) Service {
+	return service{repo, logger, recorders}
+}
+
+// create saves the Transaction and passes it to all recorders.
+func (s service) create(ctx context.Context, tx *entity.Transaction) error {
+	if err := s.repo.Create(ctx, tx); err != nil {
+		return err
+	}
+	for _, recorder := range s.recorders {
+		if err := recorder.Record(ctx, *tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s service) Get(ctx context.Context, id int64) (Transaction, error) {
+	tx, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{tx}, nil
 }
 
 func (s service) CreateUpdateTransaction(ctx context.Context, req requests.UpdateBalanceRequest) (Transaction, error) {
@@ -44,8 +173,11 @@ func (s service) CreateUpdateTransaction(ctx context.Context, req requests.Updat
 
 	ownerUUID := uuid.MustParse(req.OwnerId)
 	tx := entity.Transaction{
+		Currency:        entity.CurrencyOrBase(req.Currency),
 		Description:     req.Description,
 		TransactionDate: time.Now().UTC(),
+		ServiceId:       req.ServiceId,
+		OrderId:         req.OrderId,
 	}
 	if req.Amount < 0 {
 		tx.SenderId = ownerUUID
@@ -55,7 +187,7 @@ func (s service) CreateUpdateTransaction(ctx context.Context, req requests.Updat
 		tx.Amount = req.Amount
 	}
 
-	err := s.repo.Create(ctx, &tx)
+	err := s.create(ctx, &tx)
 	if err != nil {
 		return Transaction{}, err
 	}
@@ -73,18 +205,142 @@ func (s service) CreateTransferTransaction(ctx context.Context, req requests.Tra
 		SenderId:        senderUUID,
 		RecipientId:     recipientUUID,
 		Amount:          req.Amount,
+		Currency:        entity.CurrencyOrBase(req.Currency),
 		Description:     req.Description,
 		TransactionDate: time.Now().UTC(),
 	}
 
-	err := s.repo.Create(ctx, &tx)
+	err := s.create(ctx, &tx)
 	if err != nil {
 		return Transaction{}, err
 	}
 	return Transaction{tx}, err
 }
 
-func (s service) GetHistory(ctx context.Context, req requests.GetHistoryRequest) ([]entity.Transaction, error) {
+func (s service) CreateReservationTransaction(ctx context.Context, reservation entity.Reservation, txType string) (Transaction, error) {
+	tx := entity.Transaction{
+		Amount:          reservation.Amount,
+		Currency:        entity.BaseCurrency,
+		Description:     reservation.Description,
+		TransactionDate: time.Now().UTC(),
+		Type:            txType,
+		ReservationId:   &reservation.Id,
+		ServiceId:       reservation.ServiceId,
+		OrderId:         reservation.OrderId,
+	}
+	// Money leaves the available balance on hold and capture, and returns to it on release.
+	if txType == entity.TransactionTypeRelease {
+		tx.RecipientId = reservation.OwnerId
+	} else {
+		tx.SenderId = reservation.OwnerId
+	}
+
+	err := s.create(ctx, &tx)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{tx}, err
+}
+
+func (s service) CreateCorrectionTransaction(ctx context.Context, ownerId uuid.UUID, currency string, amount int64, description string) (Transaction, error) {
+	tx := entity.Transaction{
+		Currency:        currency,
+		Description:     description,
+		TransactionDate: time.Now().UTC(),
+		Type:            entity.TransactionTypeCorrection,
+	}
+	if amount < 0 {
+		tx.SenderId = ownerId
+		tx.Amount = -amount
+	} else {
+		tx.RecipientId = ownerId
+		tx.Amount = amount
+	}
+
+	err := s.create(ctx, &tx)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{tx}, err
+}
+
+func (s service) CreateExchangeTransactions(ctx context.Context, req requests.ExchangeRequest, credited int64, rate string, rateId int64) ([]Transaction, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	ownerUUID := uuid.MustParse(req.OwnerId)
+	now := time.Now().UTC()
+	txs := []entity.Transaction{
+		{SenderId: ownerUUID, Amount: req.Amount, Currency: req.From},
+		{RecipientId: ownerUUID, Amount: credited, Currency: req.To},
+	}
+
+	result := make([]Transaction, 0, len(txs))
+	for _, tx := range txs {
+		tx.Description = req.Description
+		tx.TransactionDate = now
+		tx.Type = entity.TransactionTypeExchange
+		tx.ExchangeRate = rate
+		if rateId != 0 {
+			tx.RateId = &rateId
+		}
+		if err := s.create(ctx, &tx); err != nil {
+			return nil, err
+		}
+		result = append(result, Transaction{tx})
+	}
+	return result, nil
+}
+
+// GetRefundable locks the original Transaction before summing up its refunds, so that concurrent refunds of it are
+// made one at a time and cannot exceed its amount together.
+func (s service) GetRefundable(ctx context.Context, req requests.RefundRequest) (Transaction, int64, error) {
+	if err := req.Validate(); err != nil {
+		return Transaction{}, 0, err
+	}
+
+	tx, err := s.repo.Lock(ctx, req.TransactionId)
+	if err == sql.ErrNoRows {
+		return Transaction{}, 0, errors.NotFound("Transaction not found.")
+	} else if err != nil {
+		return Transaction{}, 0, err
+	}
+
+	refunded, err := s.repo.RefundedAmount(ctx, tx.Id)
+	if err != nil {
+		return Transaction{}, 0, err
+	}
+	return Transaction{tx}, tx.Amount - refunded, nil
+}
+
+func (s service) CreateRefundTransaction(ctx context.Context, req requests.RefundRequest, original entity.Transaction, amount int64) (Transaction, error) {
+	if err := req.Validate(); err != nil {
+		return Transaction{}, err
+	}
+
+	// The refund moves the money back, and keeps the service and the order, so that it is subtracted from revenue.
+	tx := entity.Transaction{
+		SenderId:        original.RecipientId,
+		RecipientId:     original.SenderId,
+		Amount:          amount,
+		Currency:        entity.CurrencyOrBase(original.Currency),
+		Description:     req.Description,
+		TransactionDate: time.Now().UTC(),
+		Type:            entity.TransactionTypeRefund,
+		ServiceId:       original.ServiceId,
+		OrderId:         original.OrderId,
+		RefundOf:        &original.Id,
+	}
+
+	err := s.create(ctx, &tx)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{tx}, err
+}
+
+func (s service) GetHistory(ctx context.Context, req requests.GetHistoryRequest) ([]HistoryItem, error) {
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
@@ -96,7 +352,118 @@ func (s service) GetHistory(ctx context.Context, req requests.GetHistoryRequest)
 
 	ownerUUID := uuid.MustParse(req.OwnerId)
 
-	return s.repo.GetForUser(ctx, ownerUUID, req.OrderBy, req.OrderDirection, req.Offset, req.Limit)
+	transactions, err := s.repo.GetForUser(ctx, ownerUUID, historyFilter(req), req.OrderBy, req.OrderDirection, req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.historyItems(ctx, ownerUUID, entity.CurrencyOrBase(req.Currency), transactions)
+}
+
+func (s service) GetHistoryPage(ctx context.Context, req requests.GetHistoryRequest) (HistoryPage, error) {
+	if err := req.Validate(); err != nil {
+		return HistoryPage{}, err
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultPageSize
+	}
+	if req.OrderBy == "" {
+		req.OrderBy = "transaction_date"
+	}
+	if req.OrderDirection == "" {
+		req.OrderDirection = "ASC"
+	}
+
+	var after *entity.Transaction
+	if req.Cursor != nil && *req.Cursor != "" {
+		tx, err := decodeCursor(*req.Cursor, req.OrderBy, req.OrderDirection)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		after = &tx
+	}
+
+	// one more Transaction is read to find out whether there is a next page
+	ownerUUID := uuid.MustParse(req.OwnerId)
+	transactions, err := s.repo.GetPageForUser(ctx, ownerUUID, historyFilter(req), req.OrderBy, req.OrderDirection, after, req.Limit+1)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	var page HistoryPage
+	if len(transactions) > req.Limit {
+		transactions = transactions[:req.Limit]
+		last := transactions[req.Limit-1]
+		page.NextCursor = cursor{
+			OrderBy:         req.OrderBy,
+			OrderDirection:  req.OrderDirection,
+			Id:              last.Id,
+			Amount:          last.Amount,
+			TransactionDate: last.TransactionDate,
+		}.encode()
+	}
+	page.Transactions, err = s.historyItems(ctx, ownerUUID, entity.CurrencyOrBase(req.Currency), transactions)
+	return page, err
+}
+
+// historyItems converts the transactions of the owner's Deposit in the currency to HistoryItems with the balance after each of them.
+func (s service) historyItems(ctx context.Context, ownerId uuid.UUID, currency string, transactions []entity.Transaction) ([]HistoryItem, error) {
+	items := make([]HistoryItem, 0, len(transactions))
+	if len(transactions) == 0 {
+		return items, nil
+	}
+
+	ids := make([]int64, len(transactions))
+	for i, tx := range transactions {
+		ids[i] = tx.Id
+	}
+	balances, err := s.repo.BalancesAfter(ctx, ownerId, currency, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tx := range transactions {
+		items = append(items, newHistoryItem(tx, ownerId, balances[tx.Id]))
+	}
+	return items, nil
+}
+
+func (s service) ExportHistory(ctx context.Context, req requests.GetHistoryRequest, fn func(entity.Transaction) error) error {
+	if err := req.ValidateExport(); err != nil {
+		return err
+	}
+
+	if req.OrderBy == "" {
+		req.OrderBy = "transaction_date"
+	}
+	if req.OrderDirection == "" {
+		req.OrderDirection = "ASC"
+	}
+
+	ownerUUID := uuid.MustParse(req.OwnerId)
+
+	return s.repo.ExportForUser(ctx, ownerUUID, historyFilter(req), req.OrderBy, req.OrderDirection, fn)
+}
+
+// historyFilter returns the filter of the validated GetHistoryRequest.
+func historyFilter(req requests.GetHistoryRequest) HistoryFilter {
+	filter := HistoryFilter{
+		Currency:    entity.CurrencyOrBase(req.Currency),
+		Operation:   req.Operation,
+		MinAmount:   req.MinAmount,
+		MaxAmount:   req.MaxAmount,
+		Description: req.Description,
+	}
+	if req.From != nil {
+		filter.From = req.From.UTC()
+	}
+	if req.To != nil {
+		filter.To = req.To.UTC()
+	}
+	if req.CounterpartyId != "" {
+		filter.CounterpartyId = uuid.MustParse(req.CounterpartyId)
+	}
+	return filter
 }
 
 func (s service) Count(ctx context.Context) (int64, error) {
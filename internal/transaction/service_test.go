@@ -2,9 +2,12 @@ package transaction
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -40,6 +43,7 @@ func TestService_CreateUpdateTransaction(t *testing.T) {
 		assert.Equal(t, id1, tx.RecipientId)
 		assert.EqualValues(t, 1000, tx.Amount)
 		assert.Equal(t, "visa top-up", tx.Description)
+		assert.Equal(t, entity.BaseCurrency, tx.Currency)
 
 		count2, err := s.Count(ctx)
 		if assert.NoError(t, err) {
@@ -94,6 +98,24 @@ func TestService_CreateUpdateTransaction(t *testing.T) {
 	}
 }
 
+func TestService_Get(t *testing.T) {
+	id1 := uuid.New()
+	s := NewService(&mockTransactionRepository{
+		items: []entity.Transaction{{Id: 5, RecipientId: id1, Amount: 1000, Description: "visa top-up"}},
+	}, logger)
+
+	// success existing transaction
+	tx, err := s.Get(ctx, 5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, id1, tx.RecipientId)
+		assert.EqualValues(t, 1000, tx.Amount)
+	}
+
+	// fail non-existing transaction
+	_, err = s.Get(ctx, 6)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
 func TestService_CreateTransferTransaction(t *testing.T) {
 	id1, id2 := uuid.New(), uuid.New()
 	s := NewService(&mockTransactionRepository{}, logger)
@@ -215,6 +237,223 @@ func TestService_CreateTransferTransaction(t *testing.T) {
 	}
 }
 
+func TestService_CreateReservationTransaction(t *testing.T) {
+	id1 := uuid.New()
+	s := NewService(&mockTransactionRepository{}, logger)
+	reservation := entity.Reservation{Id: 7, OwnerId: id1, Amount: 1000, Description: "order #7"}
+
+	// hold success
+	tx, err := s.CreateReservationTransaction(ctx, reservation, entity.TransactionTypeHold)
+	if assert.NoError(t, err) {
+		assert.Equal(t, id1, tx.SenderId)
+		assert.Equal(t, uuid.Nil, tx.RecipientId)
+		assert.EqualValues(t, 1000, tx.Amount)
+		assert.Equal(t, entity.TransactionTypeHold, tx.Type)
+		if assert.NotNil(t, tx.ReservationId) {
+			assert.EqualValues(t, 7, *tx.ReservationId)
+		}
+	}
+
+	// capture success
+	tx, err = s.CreateReservationTransaction(ctx, reservation, entity.TransactionTypeCapture)
+	if assert.NoError(t, err) {
+		assert.Equal(t, id1, tx.SenderId)
+		assert.Equal(t, uuid.Nil, tx.RecipientId)
+		assert.Equal(t, entity.TransactionTypeCapture, tx.Type)
+	}
+
+	// release success
+	tx, err = s.CreateReservationTransaction(ctx, reservation, entity.TransactionTypeRelease)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uuid.Nil, tx.SenderId)
+		assert.Equal(t, id1, tx.RecipientId)
+		assert.Equal(t, entity.TransactionTypeRelease, tx.Type)
+	}
+
+	count, err := s.Count(ctx)
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 3, count)
+	}
+}
+
+func TestService_CreateUpdateTransaction_Service(t *testing.T) {
+	id1 := uuid.New()
+	serviceId, orderId := int64(3), int64(1024)
+	s := NewService(&mockTransactionRepository{}, logger)
+
+	// withdrawal for a paid service keeps service and order ids
+	tx, err := s.CreateUpdateTransaction(ctx, requests.UpdateBalanceRequest{
+		OwnerId:   id1.String(),
+		Amount:    -300,
+		ServiceId: &serviceId,
+		OrderId:   &orderId,
+	})
+	if assert.NoError(t, err) && assert.NotNil(t, tx.ServiceId) && assert.NotNil(t, tx.OrderId) {
+		assert.Equal(t, id1, tx.SenderId)
+		assert.EqualValues(t, 3, *tx.ServiceId)
+		assert.EqualValues(t, 1024, *tx.OrderId)
+	}
+
+	// top-up cannot be a payment for a service
+	_, err = s.CreateUpdateTransaction(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: 300, ServiceId: &serviceId})
+	assert.Error(t, err)
+}
+
+func TestService_CreateCorrectionTransaction(t *testing.T) {
+	id1 := uuid.New()
+	s := NewService(&mockTransactionRepository{}, logger)
+
+	// positive correction credits the owner
+	tx, err := s.CreateCorrectionTransaction(ctx, id1, entity.BaseCurrency, 500, "correction")
+	if assert.NoError(t, err) {
+		assert.Equal(t, uuid.Nil, tx.SenderId)
+		assert.Equal(t, id1, tx.RecipientId)
+		assert.EqualValues(t, 500, tx.Amount)
+		assert.Equal(t, entity.TransactionTypeCorrection, tx.Type)
+		assert.EqualValues(t, 500, tx.BalanceChange(id1))
+	}
+
+	// negative correction debits the owner
+	tx, err = s.CreateCorrectionTransaction(ctx, id1, "USD", -300, "correction")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "USD", tx.Currency)
+		assert.Equal(t, id1, tx.SenderId)
+		assert.Equal(t, uuid.Nil, tx.RecipientId)
+		assert.EqualValues(t, 300, tx.Amount)
+		assert.EqualValues(t, -300, tx.BalanceChange(id1))
+	}
+
+	// fail database error
+	_, err = s.CreateCorrectionTransaction(ctx, uuid.MustParse("11111111-1111-1111-1111-111111111111"), entity.BaseCurrency, 100, "")
+	assert.Error(t, err)
+}
+
+func TestService_CreateExchangeTransactions(t *testing.T) {
+	id1 := uuid.New()
+	s := NewService(&mockTransactionRepository{}, logger)
+
+	// success: the debit of one currency and the credit of another one at the same rate
+	req := requests.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "USD", Amount: 1000, Description: "savings"}
+	txs, err := s.CreateExchangeTransactions(ctx, req, 13, "0.013", 7)
+	if assert.NoError(t, err) && assert.Len(t, txs, 2) {
+		assert.Equal(t, id1, txs[0].SenderId)
+		assert.Equal(t, uuid.Nil, txs[0].RecipientId)
+		assert.EqualValues(t, 1000, txs[0].Amount)
+		assert.Equal(t, "RUB", txs[0].Currency)
+
+		assert.Equal(t, uuid.Nil, txs[1].SenderId)
+		assert.Equal(t, id1, txs[1].RecipientId)
+		assert.EqualValues(t, 13, txs[1].Amount)
+		assert.Equal(t, "USD", txs[1].Currency)
+
+		for _, tx := range txs {
+			assert.Equal(t, entity.TransactionTypeExchange, tx.Type)
+			assert.Equal(t, "0.013", tx.ExchangeRate)
+			if assert.NotNil(t, tx.RateId) {
+				assert.EqualValues(t, 7, *tx.RateId)
+			}
+			assert.Equal(t, "savings", tx.Description)
+		}
+	}
+
+	// fail validation
+	_, err = s.CreateExchangeTransactions(ctx, requests.ExchangeRequest{OwnerId: id1.String(), From: "RUB", To: "RUB", Amount: 1}, 1, "1", 7)
+	assert.Error(t, err)
+
+	// fail database error
+	req.OwnerId = "11111111-1111-1111-1111-111111111111"
+	_, err = s.CreateExchangeTransactions(ctx, req, 13, "0.013", 7)
+	assert.Error(t, err)
+}
+
+func TestService_GetRefundable(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockTransactionRepository{lastInsertedId: 1}
+	s := NewService(repo, logger)
+	transfer, err := s.CreateTransferTransaction(ctx, requests.TransferRequest{SenderId: id1.String(), RecipientId: id2.String(), Amount: 500})
+	assert.NoError(t, err)
+
+	// the whole amount is refundable
+	tx, refundable, err := s.GetRefundable(ctx, requests.RefundRequest{TransactionId: transfer.Id})
+	if assert.NoError(t, err) {
+		assert.Equal(t, transfer, tx)
+		assert.EqualValues(t, 500, refundable)
+	}
+
+	// the refunded amount is subtracted
+	_, err = s.CreateRefundTransaction(ctx, requests.RefundRequest{TransactionId: transfer.Id}, transfer.Transaction, 200)
+	assert.NoError(t, err)
+	_, refundable, err = s.GetRefundable(ctx, requests.RefundRequest{TransactionId: transfer.Id})
+	if assert.NoError(t, err) {
+		assert.EqualValues(t, 300, refundable)
+	}
+
+	// fail missing transaction
+	_, _, err = s.GetRefundable(ctx, requests.RefundRequest{TransactionId: 100})
+	assert.EqualError(t, err, "Transaction not found.")
+
+	// fail validation
+	_, _, err = s.GetRefundable(ctx, requests.RefundRequest{})
+	assert.Error(t, err)
+}
+
+func TestService_CreateRefundTransaction(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	serviceId, orderId := int64(3), int64(7)
+	s := NewService(&mockTransactionRepository{}, logger)
+
+	// the refund of a payment returns the money to the payer and keeps the service and the order
+	payment := entity.Transaction{Id: 5, SenderId: id1, Amount: 1000, Currency: "USD", ServiceId: &serviceId, OrderId: &orderId}
+	tx, err := s.CreateRefundTransaction(ctx, requests.RefundRequest{TransactionId: 5, Description: "order cancelled"}, payment, 400)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uuid.Nil, tx.SenderId)
+		assert.Equal(t, id1, tx.RecipientId)
+		assert.EqualValues(t, 400, tx.Amount)
+		assert.Equal(t, "USD", tx.Currency)
+		assert.Equal(t, "order cancelled", tx.Description)
+		assert.Equal(t, entity.TransactionTypeRefund, tx.Type)
+		assert.Equal(t, &payment.Id, tx.RefundOf)
+		assert.Equal(t, &serviceId, tx.ServiceId)
+		assert.Equal(t, &orderId, tx.OrderId)
+		assert.EqualValues(t, 400, tx.BalanceChange(id1))
+	}
+
+	// the refund of a transfer sends the money back
+	transfer := entity.Transaction{Id: 6, SenderId: id1, RecipientId: id2, Amount: 300}
+	tx, err = s.CreateRefundTransaction(ctx, requests.RefundRequest{TransactionId: 6}, transfer, 300)
+	if assert.NoError(t, err) {
+		assert.Equal(t, id2, tx.SenderId)
+		assert.Equal(t, id1, tx.RecipientId)
+		assert.Equal(t, entity.BaseCurrency, tx.Currency)
+	}
+
+	// fail validation
+	_, err = s.CreateRefundTransaction(ctx, requests.RefundRequest{}, transfer, 300)
+	assert.Error(t, err)
+
+	// fail database error
+	transfer.SenderId = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	_, err = s.CreateRefundTransaction(ctx, requests.RefundRequest{TransactionId: 6}, transfer, 300)
+	assert.Error(t, err)
+}
+
+func TestService_Recorders(t *testing.T) {
+	id1 := uuid.New()
+	recorder := &mockRecorder{}
+	s := NewService(&mockTransactionRepository{}, logger, recorder)
+
+	// created transaction is recorded
+	tx, err := s.CreateUpdateTransaction(ctx, requests.UpdateBalanceRequest{OwnerId: id1.String(), Amount: 1000})
+	if assert.NoError(t, err) && assert.Len(t, recorder.items, 1) {
+		assert.Equal(t, tx.Transaction, recorder.items[0])
+	}
+
+	// recorder error fails the creation
+	recorder.err = databaseError
+	_, err = s.CreateTransferTransaction(ctx, requests.TransferRequest{SenderId: id1.String(), RecipientId: uuid.NewString(), Amount: 100})
+	assert.Equal(t, databaseError, err)
+}
+
 func TestService_GetHistory(t *testing.T) {
 	id1, id2 := uuid.New(), uuid.New()
 	txsList := []entity.Transaction{
@@ -229,19 +468,20 @@ func TestService_GetHistory(t *testing.T) {
 	// success id1's transactions
 	txs, err := s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String()})
 	if assert.NoError(t, err) {
-		assert.Equal(t, txsList, txs)
+		assert.Equal(t, txsList, transactionsOf(txs))
 	}
 
 	// success id2's transactions
 	txs, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id2.String()})
 	if assert.NoError(t, err) {
-		assert.Equal(t, txsList[:3], txs)
+		assert.Equal(t, txsList[:3], transactionsOf(txs))
 	}
 
-	// success id2's transactions
-	txs, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id2.String()})
+	// success no transactions
+	txs, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: uuid.NewString()})
 	if assert.NoError(t, err) {
-		assert.Equal(t, txsList[:3], txs)
+		assert.NotNil(t, txs)
+		assert.Empty(t, txs)
 	}
 
 	// fail invalid OwnerId
@@ -257,9 +497,289 @@ func TestService_GetHistory(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestService_GetHistory_Items(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	reservationId := int64(1)
+	txsList := []entity.Transaction{
+		{Id: 1, SenderId: uuid.Nil, RecipientId: id1, Amount: 5000},
+		{Id: 2, SenderId: id1, RecipientId: id2, Amount: 1000},
+		{Id: 3, SenderId: id2, RecipientId: id1, Amount: 300},
+		{Id: 4, SenderId: id1, Amount: 700, Type: entity.TransactionTypeHold, ReservationId: &reservationId},
+		{Id: 5, SenderId: id1, Amount: 700, Type: entity.TransactionTypeCapture, ReservationId: &reservationId},
+		{Id: 6, SenderId: id1, RecipientId: uuid.Nil, Amount: 100},
+	}
+	s := NewService(&mockTransactionRepository{items: txsList}, logger)
+
+	expected := []HistoryItem{
+		{Transaction: txsList[0], Direction: DirectionCredit, Operation: OperationTopUp, BalanceAfter: 5000},
+		{Transaction: txsList[1], Direction: DirectionDebit, CounterpartyId: id2, Operation: OperationTransferOut, BalanceAfter: 4000},
+		{Transaction: txsList[2], Direction: DirectionCredit, CounterpartyId: id2, Operation: OperationTransferIn, BalanceAfter: 4300},
+		{Transaction: txsList[3], Direction: DirectionDebit, Operation: entity.TransactionTypeHold, BalanceAfter: 3600},
+		{Transaction: txsList[4], Direction: DirectionDebit, Operation: entity.TransactionTypeCapture, BalanceAfter: 3600},
+		{Transaction: txsList[5], Direction: DirectionDebit, Operation: OperationWithdrawal, BalanceAfter: 3500},
+	}
+
+	// success whole history
+	items, err := s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, items)
+	}
+
+	// success balances do not depend on ordering and pagination
+	page, err := s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 2, OrderBy: "amount", OrderDirection: "DESC", Cursor: new(string)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []HistoryItem{expected[0], expected[1]}, page.Transactions)
+		page, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 2, OrderBy: "amount", OrderDirection: "DESC", Cursor: &page.NextCursor})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []HistoryItem{expected[4], expected[3]}, page.Transactions)
+		}
+	}
+
+	// success the other participant of a transfer sees it as incoming
+	items, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id2.String()})
+	if assert.NoError(t, err) && assert.Len(t, items, 2) {
+		assert.Equal(t, HistoryItem{Transaction: txsList[1], Direction: DirectionCredit, CounterpartyId: id1, Operation: OperationTransferIn, BalanceAfter: 1000}, items[0])
+		assert.Equal(t, HistoryItem{Transaction: txsList[2], Direction: DirectionDebit, CounterpartyId: id1, Operation: OperationTransferOut, BalanceAfter: 700}, items[1])
+	}
+}
+
+// transactionsOf returns the transactions of the history items.
+func transactionsOf(items []HistoryItem) []entity.Transaction {
+	var result []entity.Transaction
+	for _, item := range items {
+		result = append(result, item.Transaction)
+	}
+	return result
+}
+
+func TestService_GetHistoryPage(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	date := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+	txsList := []entity.Transaction{
+		{Id: 1, SenderId: uuid.Nil, RecipientId: id1, Amount: 3000, TransactionDate: date},
+		{Id: 2, SenderId: id1, RecipientId: id2, Amount: 1000, TransactionDate: date.Add(time.Minute)},
+		{Id: 3, SenderId: id1, RecipientId: uuid.Nil, Amount: 2000, TransactionDate: date.Add(time.Minute)},
+		{Id: 4, SenderId: id2, RecipientId: id1, Amount: 1000, TransactionDate: date.Add(2 * time.Minute)},
+		{Id: 5, SenderId: id2, RecipientId: uuid.Nil, Amount: 500, TransactionDate: date.Add(3 * time.Minute)},
+	}
+	s := NewService(&mockTransactionRepository{items: txsList}, logger)
+
+	// pages returns ids of transactions on all pages of the history
+	pages := func(req requests.GetHistoryRequest) [][]int64 {
+		var result [][]int64
+		cursor := ""
+		for i := 0; i < len(txsList); i++ {
+			req.Cursor = &cursor
+			page, err := s.GetHistoryPage(ctx, req)
+			if !assert.NoError(t, err) {
+				return result
+			}
+			var ids []int64
+			for _, tx := range page.Transactions {
+				ids = append(ids, tx.Id)
+			}
+			result = append(result, ids)
+			if page.NextCursor == "" {
+				return result
+			}
+			cursor = page.NextCursor
+		}
+		t.Fatal("pagination did not finish")
+		return nil
+	}
+
+	// success by date (default), ties are broken by id
+	assert.Equal(t, [][]int64{{1, 2}, {3, 4}}, pages(requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 2}))
+
+	// success by date descending
+	assert.Equal(t, [][]int64{{4, 3, 2}, {1}}, pages(requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 3, OrderDirection: "DESC"}))
+
+	// success by amount, ties are broken by id
+	assert.Equal(t, [][]int64{{2}, {4}, {3}, {1}}, pages(requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 1, OrderBy: "amount"}))
+
+	// success by amount descending
+	assert.Equal(t, [][]int64{{1, 3}, {4, 2}}, pages(requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 2, OrderBy: "amount", OrderDirection: "DESC"}))
+
+	// success default page size, last page has no cursor
+	page, err := s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id2.String(), Cursor: new(string)})
+	if assert.NoError(t, err) {
+		assert.Len(t, page.Transactions, 3)
+		assert.Empty(t, page.NextCursor)
+	}
+
+	// success no transactions
+	page, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: uuid.NewString(), Cursor: new(string)})
+	if assert.NoError(t, err) {
+		assert.NotNil(t, page.Transactions)
+		assert.Empty(t, page.Transactions)
+	}
+
+	// fail cursor of a different ordering
+	page, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 1, Cursor: new(string)})
+	if assert.NoError(t, err) {
+		_, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), OrderBy: "amount", Cursor: &page.NextCursor})
+		assert.Equal(t, errInvalidCursor, err)
+	}
+
+	// fail malformed cursor
+	malformed := "not a cursor"
+	_, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Cursor: &malformed})
+	assert.Equal(t, errInvalidCursor, err)
+
+	// fail offset with cursor
+	_, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Offset: 1, Cursor: new(string)})
+	assert.Error(t, err)
+
+	// fail database error
+	_, err = s.GetHistoryPage(ctx, requests.GetHistoryRequest{OwnerId: "11111111-1111-1111-1111-111111111111", Cursor: new(string)})
+	assert.Equal(t, databaseError, err)
+}
+
+func TestService_GetHistory_Filter(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockTransactionRepository{}
+	s := NewService(repo, logger)
+	moscow := time.FixedZone("MSK", 3*60*60)
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, moscow)
+	to := time.Date(2021, 4, 1, 0, 0, 0, 0, moscow)
+	req := requests.GetHistoryRequest{
+		OwnerId:        id1.String(),
+		From:           &from,
+		To:             &to,
+		Operation:      OperationTransferOut,
+		MinAmount:      1000,
+		MaxAmount:      5000,
+		CounterpartyId: id2.String(),
+		Description:    "rent",
+	}
+	expected := HistoryFilter{
+		Currency:       entity.BaseCurrency,
+		From:           time.Date(2021, 2, 28, 21, 0, 0, 0, time.UTC),
+		To:             time.Date(2021, 3, 31, 21, 0, 0, 0, time.UTC),
+		Operation:      OperationTransferOut,
+		MinAmount:      1000,
+		MaxAmount:      5000,
+		CounterpartyId: id2,
+		Description:    "rent",
+	}
+
+	// success filter is passed to the repository with dates in UTC
+	_, err := s.GetHistory(ctx, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, repo.lastFilter)
+	}
+
+	// success filter is passed to the repository with a cursor
+	req.Cursor = new(string)
+	_, err = s.GetHistoryPage(ctx, req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, repo.lastFilter)
+	}
+
+	// success no filter, the history of the deposit in the base currency is requested
+	_, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String()})
+	if assert.NoError(t, err) {
+		assert.Equal(t, HistoryFilter{Currency: entity.BaseCurrency}, repo.lastFilter)
+	}
+
+	// success history of the deposit in another currency
+	_, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Currency: "USD"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, HistoryFilter{Currency: "USD"}, repo.lastFilter)
+	}
+
+	// fail invalid filter
+	_, err = s.GetHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), From: &to, To: &from})
+	assert.Error(t, err)
+}
+
+func TestService_ExportHistory(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	txsList := []entity.Transaction{
+		{Id: 0, SenderId: id1, RecipientId: id2, Amount: 1000},
+		{Id: 1, SenderId: uuid.Nil, RecipientId: id1, Amount: 4000},
+		{Id: 2, SenderId: id2, RecipientId: uuid.Nil, Amount: 5000},
+	}
+	repo := &mockTransactionRepository{items: txsList}
+	s := NewService(repo, logger)
+
+	var exported []entity.Transaction
+	collect := func(tx entity.Transaction) error {
+		exported = append(exported, tx)
+		return nil
+	}
+
+	// success id1's transactions with a filter
+	err := s.ExportHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Operation: OperationTopUp}, collect)
+	if assert.NoError(t, err) {
+		assert.Equal(t, txsList[:2], exported)
+		assert.Equal(t, HistoryFilter{Currency: entity.BaseCurrency, Operation: OperationTopUp}, repo.lastFilter)
+	}
+
+	// fn error stops the export
+	fnError := errors.New("client disconnected")
+	calls := 0
+	err = s.ExportHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String()}, func(tx entity.Transaction) error {
+		calls++
+		return fnError
+	})
+	assert.Equal(t, fnError, err)
+	assert.Equal(t, 1, calls)
+
+	// fail pagination
+	err = s.ExportHistory(ctx, requests.GetHistoryRequest{OwnerId: id1.String(), Limit: 10}, collect)
+	assert.Error(t, err)
+
+	// fail invalid OwnerId
+	err = s.ExportHistory(ctx, requests.GetHistoryRequest{OwnerId: "123-456-789"}, collect)
+	assert.Error(t, err)
+
+	// fail database error
+	err = s.ExportHistory(ctx, requests.GetHistoryRequest{OwnerId: "11111111-1111-1111-1111-111111111111"}, collect)
+	assert.Equal(t, databaseError, err)
+}
+
+type mockRecorder struct {
+	items []entity.Transaction
+	err   error
+}
+
+func (m *mockRecorder) Record(ctx context.Context, tx entity.Transaction) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.items = append(m.items, tx)
+	return nil
+}
+
 type mockTransactionRepository struct {
 	items          []entity.Transaction
 	lastInsertedId int64
+	lastFilter     HistoryFilter
+}
+
+func (m *mockTransactionRepository) Get(ctx context.Context, id int64) (entity.Transaction, error) {
+	for _, tx := range m.items {
+		if tx.Id == id {
+			return tx, nil
+		}
+	}
+	return entity.Transaction{}, sql.ErrNoRows
+}
+
+// Lock does not lock anything, as the mock is not used concurrently
+func (m *mockTransactionRepository) Lock(ctx context.Context, id int64) (entity.Transaction, error) {
+	return m.Get(ctx, id)
+}
+
+func (m *mockTransactionRepository) RefundedAmount(ctx context.Context, id int64) (int64, error) {
+	var refunded int64
+	for _, tx := range m.items {
+		if tx.RefundOf != nil && *tx.RefundOf == id {
+			refunded += tx.Amount
+		}
+	}
+	return refunded, nil
 }
 
 func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Transaction) error {
@@ -277,9 +797,10 @@ func (m *mockTransactionRepository) Create(ctx context.Context, tx *entity.Trans
 	return nil
 }
 
-// Offset, limit and order are ignored for simplicity
-func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
+// Filter, offset, limit and order are ignored for simplicity, the filter is only recorded
+func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, offset, limit int) ([]entity.Transaction, error) {
 	var result []entity.Transaction
+	m.lastFilter = filter
 
 	// simulate database error
 	if ownerId.String() == "11111111-1111-1111-1111-111111111111" {
@@ -295,6 +816,87 @@ func (m *mockTransactionRepository) GetForUser(ctx context.Context, ownerId uuid
 	return result, nil
 }
 
+func (m *mockTransactionRepository) GetPageForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, after *entity.Transaction, limit int) ([]entity.Transaction, error) {
+	m.lastFilter = filter
+	// simulate database error
+	if ownerId.String() == "11111111-1111-1111-1111-111111111111" {
+		return nil, databaseError
+	}
+
+	// less reports whether a goes before b in the given order
+	less := func(a, b entity.Transaction) bool {
+		if orderDirection == "DESC" {
+			a, b = b, a
+		}
+		if orderBy == "amount" && a.Amount != b.Amount {
+			return a.Amount < b.Amount
+		}
+		if orderBy == "transaction_date" && !a.TransactionDate.Equal(b.TransactionDate) {
+			return a.TransactionDate.Before(b.TransactionDate)
+		}
+		return a.Id < b.Id
+	}
+
+	var result []entity.Transaction
+	for _, tx := range m.items {
+		if (tx.SenderId == ownerId || tx.RecipientId == ownerId) && (after == nil || less(*after, tx)) {
+			result = append(result, tx)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return less(result[i], result[j]) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// Order is ignored for simplicity, the database error is simulated after all transactions are passed to fn
+func (m *mockTransactionRepository) ExportForUser(ctx context.Context, ownerId uuid.UUID, filter HistoryFilter, orderBy, orderDirection string, fn func(entity.Transaction) error) error {
+	m.lastFilter = filter
+	for _, tx := range m.items {
+		if tx.SenderId == ownerId || tx.RecipientId == ownerId {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+	}
+	// simulate database error
+	if ownerId.String() == "11111111-1111-1111-1111-111111111111" {
+		return databaseError
+	}
+	return nil
+}
+
+// Transactions are assumed to be stored in the order they were made, ids which are not requested are also returned
+func (m *mockTransactionRepository) Balance(ctx context.Context, ownerId uuid.UUID, currency string) (int64, error) {
+	var balance int64
+	for _, tx := range m.items {
+		if entity.CurrencyOrBase(tx.Currency) == entity.CurrencyOrBase(currency) {
+			balance += tx.BalanceChange(ownerId)
+		}
+	}
+	return balance, nil
+}
+
+func (m *mockTransactionRepository) BalancesAfter(ctx context.Context, ownerId uuid.UUID, currency string, ids []int64) (map[int64]int64, error) {
+	result := map[int64]int64{}
+	var balance int64
+	for _, tx := range m.items {
+		if tx.SenderId != ownerId && tx.RecipientId != ownerId || entity.CurrencyOrBase(tx.Currency) != currency {
+			continue
+		}
+		if tx.Type != entity.TransactionTypeCapture {
+			if tx.RecipientId == ownerId {
+				balance += tx.Amount
+			} else {
+				balance -= tx.Amount
+			}
+		}
+		result[tx.Id] = balance
+	}
+	return result, nil
+}
+
 func (m *mockTransactionRepository) Count(ctx context.Context) (int64, error) {
 	return int64(len(m.items)), nil
 }
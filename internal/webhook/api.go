@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger, transactionHandler routing.Handler) {
+	res := resource{service, logger}
+
+	r.Get("/webhooks", res.query)
+	r.Post("/webhooks", res.create)
+	r.Get(`/webhooks/<id:\d+>`, res.get)
+	r.Put(`/webhooks/<id:\d+>`, res.update)
+	r.Delete(`/webhooks/<id:\d+>`, transactionHandler, res.delete)
+	r.Get("/webhooks/dead-letters", res.deadLetters)
+	r.Post(`/webhooks/dead-letters/<id:\d+>/replay`, transactionHandler, res.replay)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) get(c *routing.Context) error {
+	subscription, err := r.service.Get(c.Request.Context(), id(c))
+	if err != nil {
+		return err
+	}
+	return c.Write(subscription)
+}
+
+func (r resource) query(c *routing.Context) error {
+	subscriptions, err := r.service.Query(c.Request.Context())
+	if err != nil {
+		return err
+	}
+	return c.Write(subscriptions)
+}
+
+func (r resource) create(c *routing.Context) error {
+	var input requests.WebhookRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	subscription, err := r.service.Create(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.WriteWithStatus(subscription, http.StatusCreated)
+}
+
+func (r resource) update(c *routing.Context) error {
+	var input requests.WebhookRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	subscription, err := r.service.Update(c.Request.Context(), id(c), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(subscription)
+}
+
+func (r resource) delete(c *routing.Context) error {
+	subscription, err := r.service.Delete(c.Request.Context(), id(c))
+	if err != nil {
+		return err
+	}
+	return c.Write(subscription)
+}
+
+func (r resource) deadLetters(c *routing.Context) error {
+	var input requests.DeadLettersRequest
+	if err := c.Read(&input); err != nil {
+		r.logger.With(c.Request.Context()).Info(err)
+		return errors.BadRequest("")
+	}
+
+	deadLetters, err := r.service.DeadLetters(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(deadLetters)
+}
+
+func (r resource) replay(c *routing.Context) error {
+	deadLetter, err := r.service.Replay(c.Request.Context(), id(c))
+	if err != nil {
+		return err
+	}
+	return c.Write(deadLetter)
+}
+
+// id returns the id from the path. The route guarantees that it consists of digits only.
+func id(c *routing.Context) int64 {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	return id
+}
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestAPI(t *testing.T) {
+	repo := &mockRepository{}
+	router := test.MockRouter(logger)
+	transactionHandler := func(c *routing.Context) error { return c.Next() }
+	RegisterHandlers(router.Group(""), NewService(repo, logger), logger, transactionHandler)
+
+	_ = repo.CreateDeadLetter(ctx, &entity.WebhookDeadLetter{SubscriptionId: 1, EventId: 7, OwnerId: uuid.New(), Payload: `{"id":7}`, Attempts: 6, LastError: "timeout"})
+
+	tests := []test.APITestCase{
+		{"query empty", "GET", "/webhooks", "", http.StatusOK, `[]`},
+		{"create success", "POST", "/webhooks", `{"url":"https://example.com/hook","secret":"0123456789abcdef","event_type":"top_up"}`, http.StatusCreated, `*"secret":"0123456789abcdef"*`},
+		{"create failure invalid url", "POST", "/webhooks", `{"url":"example"}`, http.StatusBadRequest, `*"url"*`},
+		{"create failure invalid event type", "POST", "/webhooks", `{"url":"https://example.com/hook","event_type":"chargeback"}`, http.StatusBadRequest, `*"event_type"*`},
+		{"create failure invalid body", "POST", "/webhooks", `"url"`, http.StatusBadRequest, ""},
+		{"get success", "GET", "/webhooks/2", "", http.StatusOK, `*"url":"https://example.com/hook"*`},
+		{"get unknown", "GET", "/webhooks/100", "", http.StatusNotFound, ""},
+		{"get invalid id", "GET", "/webhooks/abc", "", http.StatusNotFound, ""},
+		{"update success", "PUT", "/webhooks/2", `{"url":"https://example.com/hook2"}`, http.StatusOK, `*"url":"https://example.com/hook2"*`},
+		{"update failure validation", "PUT", "/webhooks/2", `{"url":""}`, http.StatusBadRequest, ""},
+		{"update unknown", "PUT", "/webhooks/100", `{"url":"https://example.com/hook2"}`, http.StatusNotFound, ""},
+		{"dead letters", "GET", "/webhooks/dead-letters", "", http.StatusOK, `*"event":{"id":7}*`},
+		{"dead letters of subscription", "GET", "/webhooks/dead-letters?subscription_id=2", "", http.StatusOK, `[]`},
+		{"dead letters failure validation", "GET", "/webhooks/dead-letters?limit=-1", "", http.StatusBadRequest, ""},
+		{"replay failure deleted subscription", "POST", "/webhooks/dead-letters/1/replay", "", http.StatusNotFound, ""},
+		{"replay unknown", "POST", "/webhooks/dead-letters/100/replay", "", http.StatusNotFound, ""},
+		{"delete success", "DELETE", "/webhooks/2", "", http.StatusOK, `*"id":2*`},
+		{"delete unknown", "DELETE", "/webhooks/2", "", http.StatusNotFound, ""},
+	}
+	for _, tc := range tests {
+		test.Endpoint(t, router, tc)
+	}
+
+	// replay success
+	subscription := entity.WebhookSubscription{Url: "https://example.com/hook"}
+	_ = repo.Create(ctx, &subscription)
+	repo.deadLetters[0].SubscriptionId = subscription.Id
+	test.Endpoint(t, router, test.APITestCase{"replay success", "POST", "/webhooks/dead-letters/1/replay", "", http.StatusOK, `*"replayed_at"*`})
+	test.Endpoint(t, router, test.APITestCase{"replay twice", "POST", "/webhooks/dead-letters/1/replay", "", http.StatusConflict, ""})
+}
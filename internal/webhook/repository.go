@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+// workerLockKey is the key of the PostgreSQL advisory lock held by the running delivery worker.
+const workerLockKey = 7002
+
+// Repository encapsulates the logic to access webhook subscriptions and their deliveries from the database.
+type Repository interface {
+	// Get returns the WebhookSubscription with the specified id.
+	Get(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	// Query returns all WebhookSubscriptions ordered by id.
+	Query(ctx context.Context) ([]entity.WebhookSubscription, error)
+	// Matching returns WebhookSubscriptions which receive events of the given type about the given owner.
+	Matching(ctx context.Context, ownerId uuid.UUID, eventType string) ([]entity.WebhookSubscription, error)
+	// Create saves a new WebhookSubscription in the storage.
+	Create(ctx context.Context, subscription *entity.WebhookSubscription) error
+	// Update saves the changes to the WebhookSubscription.
+	Update(ctx context.Context, subscription entity.WebhookSubscription) error
+	// Delete removes the WebhookSubscription with the specified id together with its pending deliveries.
+	Delete(ctx context.Context, id int64) error
+
+	// CreateDelivery saves a new WebhookDelivery in the storage. If the subscription already has a delivery of the
+	// same event, nothing is saved and the Id of the delivery stays zero.
+	CreateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error
+	// ClaimDeliveries returns up to limit WebhookDeliveries of the queues whose first delivery is due at the moment now
+	// and claims them until the given time. A queue is formed by the deliveries to the same subscription about the same
+	// owner, they are returned in order of id within every queue. Queues having deliveries claimed at the moment now
+	// are skipped. The first deliveries of all queues go before the second ones and so on, so that a long queue
+	// cannot take the whole batch.
+	ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]entity.WebhookDelivery, error)
+	// ReleaseDeliveries removes the claims from the WebhookDeliveries with the given ids, so that they can be
+	// claimed again.
+	ReleaseDeliveries(ctx context.Context, ids ...int64) error
+	// UpdateDelivery saves the changes to the WebhookDelivery.
+	UpdateDelivery(ctx context.Context, delivery entity.WebhookDelivery) error
+	// DeleteDelivery removes the WebhookDelivery with the specified id.
+	DeleteDelivery(ctx context.Context, id int64) error
+
+	// GetDeadLetter returns the WebhookDeadLetter with the specified id.
+	GetDeadLetter(ctx context.Context, id int64) (entity.WebhookDeadLetter, error)
+	// QueryDeadLetters returns WebhookDeadLetters with the given offset and limit ordered by id.
+	// If subscriptionId is not zero, only dead letters of that subscription are returned.
+	QueryDeadLetters(ctx context.Context, subscriptionId int64, offset, limit int) ([]entity.WebhookDeadLetter, error)
+	// CreateDeadLetter saves a new WebhookDeadLetter in the storage.
+	CreateDeadLetter(ctx context.Context, deadLetter *entity.WebhookDeadLetter) error
+	// UpdateDeadLetter saves the changes to the WebhookDeadLetter.
+	UpdateDeadLetter(ctx context.Context, deadLetter entity.WebhookDeadLetter) error
+
+	// TryLock tries to acquire the delivery worker lock until the end of the current DB transaction.
+	// It reports whether the lock was acquired.
+	TryLock(ctx context.Context) (bool, error)
+}
+
+// repository persists webhook subscriptions in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new webhook repository.
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Get reads the WebhookSubscription with the specified id from the database.
+func (r repository) Get(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	var subscription entity.WebhookSubscription
+	err := r.db.With(ctx).Select().Model(id, &subscription)
+	return subscription, err
+}
+
+// Query reads all WebhookSubscriptions from the database.
+func (r repository) Query(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	var subscriptions []entity.WebhookSubscription
+	err := r.db.With(ctx).Select().OrderBy("id").All(&subscriptions)
+	return subscriptions, err
+}
+
+// Matching reads the WebhookSubscriptions whose filters match the owner and the event type.
+func (r repository) Matching(ctx context.Context, ownerId uuid.UUID, eventType string) ([]entity.WebhookSubscription, error) {
+	var subscriptions []entity.WebhookSubscription
+	err := r.db.With(ctx).Select().
+		Where(dbx.Or(dbx.HashExp{"owner_id": nil}, dbx.HashExp{"owner_id": ownerId})).
+		AndWhere(dbx.Or(dbx.HashExp{"event_type": ""}, dbx.HashExp{"event_type": eventType})).
+		OrderBy("id").
+		All(&subscriptions)
+	return subscriptions, err
+}
+
+// Create saves a new WebhookSubscription record in the database.
+// WebhookSubscription is assigned an auto-incremented id from database.
+func (r repository) Create(ctx context.Context, subscription *entity.WebhookSubscription) error {
+	return r.db.With(ctx).Model(subscription).Insert()
+}
+
+// Update saves the changes to the WebhookSubscription in the database.
+func (r repository) Update(ctx context.Context, subscription entity.WebhookSubscription) error {
+	return r.db.With(ctx).Model(&subscription).Update()
+}
+
+// Delete deletes the WebhookSubscription and its WebhookDeliveries from the database.
+func (r repository) Delete(ctx context.Context, id int64) error {
+	subscription, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err = r.db.With(ctx).Delete("webhook_delivery", dbx.HashExp{"subscription_id": id}).Execute(); err != nil {
+		return err
+	}
+	return r.db.With(ctx).Model(&subscription).Delete()
+}
+
+// CreateDelivery saves a new WebhookDelivery record in the database.
+// The unique index on subscription_id and event_id makes a repeated Sink.Send of the same event a no-op.
+func (r repository) CreateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	err := r.db.With(ctx).NewQuery(`
+		INSERT INTO webhook_delivery (subscription_id, event_id, owner_id, payload, attempts, last_error,
+			next_attempt_at, created_at)
+		VALUES ({:subscription_id}, {:event_id}, {:owner_id}, {:payload}, {:attempts}, {:last_error},
+			{:next_attempt_at}, {:created_at})
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+		RETURNING id`).
+		Bind(dbx.Params{
+			"subscription_id": delivery.SubscriptionId,
+			"event_id":        delivery.EventId,
+			"owner_id":        delivery.OwnerId,
+			"payload":         delivery.Payload,
+			"attempts":        delivery.Attempts,
+			"last_error":      delivery.LastError,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"created_at":      delivery.CreatedAt,
+		}).Row(&delivery.Id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// ClaimDeliveries numbers the WebhookDeliveries within their queues, takes the first ones of the free queues and sets
+// claimed_until of the taken deliveries in the database. Queues whose first delivery waits for a retry are skipped
+// entirely, so that they do not fill the batch.
+func (r repository) ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+	err := r.db.With(ctx).NewQuery(`
+		WITH pending AS (
+			SELECT id, position FROM (
+				SELECT id,
+					ROW_NUMBER() OVER (PARTITION BY subscription_id, owner_id ORDER BY id) AS position,
+					FIRST_VALUE(next_attempt_at) OVER (PARTITION BY subscription_id, owner_id ORDER BY id) AS head_attempt_at,
+					COALESCE(BOOL_OR(claimed_until > {:now}) OVER (PARTITION BY subscription_id, owner_id), FALSE) AS busy
+				FROM webhook_delivery
+			) queued
+			WHERE head_attempt_at <= {:now} AND NOT busy
+			ORDER BY position, id
+			LIMIT {:limit}
+		), claimed AS (
+			UPDATE webhook_delivery SET claimed_until = {:until}
+			FROM pending
+			WHERE webhook_delivery.id = pending.id
+			RETURNING webhook_delivery.*, pending.position
+		)
+		SELECT * FROM claimed ORDER BY position, id`).
+		Bind(dbx.Params{"now": now, "until": until, "limit": limit}).
+		All(&deliveries)
+	return deliveries, err
+}
+
+// ReleaseDeliveries clears claimed_until of the WebhookDeliveries in the database.
+func (r repository) ReleaseDeliveries(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	_, err := r.db.With(ctx).Update("webhook_delivery", dbx.Params{"claimed_until": nil}, dbx.In("id", values...)).Execute()
+	return err
+}
+
+// UpdateDelivery saves the changes to the WebhookDelivery in the database.
+func (r repository) UpdateDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	return r.db.With(ctx).Model(&delivery).Update()
+}
+
+// DeleteDelivery deletes the WebhookDelivery from the database.
+func (r repository) DeleteDelivery(ctx context.Context, id int64) error {
+	_, err := r.db.With(ctx).Delete("webhook_delivery", dbx.HashExp{"id": id}).Execute()
+	return err
+}
+
+// GetDeadLetter reads the WebhookDeadLetter with the specified id from the database.
+func (r repository) GetDeadLetter(ctx context.Context, id int64) (entity.WebhookDeadLetter, error) {
+	var deadLetter entity.WebhookDeadLetter
+	err := r.db.With(ctx).Select().Model(id, &deadLetter)
+	return deadLetter, err
+}
+
+// QueryDeadLetters reads the WebhookDeadLetters from the database.
+func (r repository) QueryDeadLetters(ctx context.Context, subscriptionId int64, offset, limit int) ([]entity.WebhookDeadLetter, error) {
+	var deadLetters []entity.WebhookDeadLetter
+	query := r.db.With(ctx).Select().OrderBy("id").Offset(int64(offset)).Limit(int64(limit))
+	if subscriptionId != 0 {
+		query.Where(dbx.HashExp{"subscription_id": subscriptionId})
+	}
+	err := query.All(&deadLetters)
+	return deadLetters, err
+}
+
+// CreateDeadLetter saves a new WebhookDeadLetter record in the database.
+func (r repository) CreateDeadLetter(ctx context.Context, deadLetter *entity.WebhookDeadLetter) error {
+	return r.db.With(ctx).Model(deadLetter).Insert()
+}
+
+// UpdateDeadLetter saves the changes to the WebhookDeadLetter in the database.
+func (r repository) UpdateDeadLetter(ctx context.Context, deadLetter entity.WebhookDeadLetter) error {
+	return r.db.With(ctx).Model(&deadLetter).Update()
+}
+
+// TryLock acquires a transaction-level advisory lock, so that only one worker claims deliveries at a time
+// and a queue is never claimed by two workers.
+func (r repository) TryLock(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.db.With(ctx).NewQuery("SELECT pg_try_advisory_xact_lock({:key})").
+		Bind(dbx.Params{"key": workerLockKey}).
+		Row(&locked)
+	return locked, err
+}
@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/test"
+)
+
+func TestRepository(t *testing.T) {
+	db := test.DB(t)
+	test.ResetTables(t, db, "webhook_subscription", "webhook_delivery", "webhook_dead_letter")
+	repo := NewRepository(db, logger)
+
+	id1, id2 := uuid.New(), uuid.New()
+	now := time.Now().UTC()
+
+	// create subscriptions
+	all := entity.WebhookSubscription{Url: "https://example.com/all", Secret: "0123456789abcdef", CreatedAt: now, UpdatedAt: now}
+	owner := entity.WebhookSubscription{Url: "https://example.com/owner", Secret: "0123456789abcdef", OwnerId: &id1, CreatedAt: now, UpdatedAt: now}
+	topUps := entity.WebhookSubscription{Url: "https://example.com/top-ups", Secret: "0123456789abcdef", EventType: "top_up", CreatedAt: now, UpdatedAt: now}
+	for _, s := range []*entity.WebhookSubscription{&all, &owner, &topUps} {
+		if assert.NoError(t, repo.Create(ctx, s)) {
+			assert.NotZero(t, s.Id)
+		}
+	}
+
+	// get
+	stored, err := repo.Get(ctx, owner.Id)
+	if assert.NoError(t, err) && assert.NotNil(t, stored.OwnerId) {
+		assert.Equal(t, id1, *stored.OwnerId)
+		assert.Equal(t, "0123456789abcdef", stored.Secret)
+	}
+
+	// query
+	subscriptions, err := repo.Query(ctx)
+	if assert.NoError(t, err) {
+		assert.Len(t, subscriptions, 3)
+	}
+
+	// matching
+	subscriptions, err = repo.Matching(ctx, id1, "top_up")
+	if assert.NoError(t, err) {
+		assert.Len(t, subscriptions, 3)
+	}
+	subscriptions, err = repo.Matching(ctx, id2, "withdrawal")
+	if assert.NoError(t, err) && assert.Len(t, subscriptions, 1) {
+		assert.Equal(t, all.Id, subscriptions[0].Id)
+	}
+
+	// update
+	owner.Url = "https://example.com/owner2"
+	assert.NoError(t, repo.Update(ctx, owner))
+	stored, _ = repo.Get(ctx, owner.Id)
+	assert.Equal(t, "https://example.com/owner2", stored.Url)
+
+	// deliveries
+	for i := 0; i < 2; i++ {
+		delivery := entity.WebhookDelivery{SubscriptionId: owner.Id, EventId: int64(i + 1), OwnerId: id1, Payload: "{}", NextAttemptAt: now, CreatedAt: now}
+		if assert.NoError(t, repo.CreateDelivery(ctx, &delivery)) {
+			assert.NotZero(t, delivery.Id)
+		}
+	}
+	duplicate := entity.WebhookDelivery{SubscriptionId: owner.Id, EventId: 1, OwnerId: id1, Payload: "{}", NextAttemptAt: now, CreatedAt: now}
+	if assert.NoError(t, repo.CreateDelivery(ctx, &duplicate)) {
+		assert.Zero(t, duplicate.Id)
+	}
+	until := now.Add(time.Minute)
+	deliveries, err := repo.ClaimDeliveries(ctx, now, until, 10)
+	if assert.NoError(t, err) && assert.Len(t, deliveries, 2) {
+		assert.NotNil(t, deliveries[0].ClaimedUntil)
+		deliveries[0].Attempts = 1
+		deliveries[0].LastError = "timeout"
+		deliveries[0].ClaimedUntil = nil
+		assert.NoError(t, repo.UpdateDelivery(ctx, deliveries[0]))
+		assert.NoError(t, repo.DeleteDelivery(ctx, deliveries[1].Id))
+
+		deliveries, _ = repo.ClaimDeliveries(ctx, now, until, 10)
+		if assert.Len(t, deliveries, 1) {
+			assert.Equal(t, 1, deliveries[0].Attempts)
+			assert.Equal(t, "timeout", deliveries[0].LastError)
+			assert.NoError(t, repo.ReleaseDeliveries(ctx, deliveries[0].Id))
+		}
+	}
+
+	// a queue whose first delivery waits for a retry is skipped, the first deliveries of queues go first
+	waiting := entity.WebhookDelivery{SubscriptionId: all.Id, EventId: 10, OwnerId: id2, Payload: "{}", NextAttemptAt: now.Add(time.Hour), CreatedAt: now}
+	assert.NoError(t, repo.CreateDelivery(ctx, &waiting))
+	for i := 0; i < 3; i++ {
+		delivery := entity.WebhookDelivery{SubscriptionId: all.Id, EventId: int64(11 + i), OwnerId: id2, Payload: "{}", NextAttemptAt: now, CreatedAt: now}
+		assert.NoError(t, repo.CreateDelivery(ctx, &delivery))
+	}
+	for i := 0; i < 2; i++ {
+		delivery := entity.WebhookDelivery{SubscriptionId: topUps.Id, EventId: int64(20 + i), OwnerId: id2, Payload: "{}", NextAttemptAt: now, CreatedAt: now}
+		assert.NoError(t, repo.CreateDelivery(ctx, &delivery))
+	}
+	deliveries, err = repo.ClaimDeliveries(ctx, now, until, 3)
+	if assert.NoError(t, err) && assert.Len(t, deliveries, 3) {
+		assert.Equal(t, []int64{1, 20, 21}, []int64{deliveries[0].EventId, deliveries[1].EventId, deliveries[2].EventId})
+	}
+
+	// the queues claimed by another worker are skipped until the claim is released or expires
+	deliveries, err = repo.ClaimDeliveries(ctx, now, until, 10)
+	if assert.NoError(t, err) {
+		assert.Empty(t, deliveries)
+	}
+	deliveries, _ = repo.ClaimDeliveries(ctx, now.Add(2*time.Hour), now.Add(3*time.Hour), 10)
+	if assert.Len(t, deliveries, 7) {
+		ids := make([]int64, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.Id
+		}
+		assert.NoError(t, repo.ReleaseDeliveries(ctx, ids...))
+	}
+	deliveries, _ = repo.ClaimDeliveries(ctx, now, until, 10)
+	assert.Len(t, deliveries, 3)
+	assert.NoError(t, repo.Delete(ctx, all.Id))
+	assert.NoError(t, repo.Delete(ctx, topUps.Id))
+
+	// dead letters
+	deadLetter := entity.WebhookDeadLetter{SubscriptionId: owner.Id, EventId: 1, OwnerId: id1, Payload: `{"id":1}`, Attempts: 6, LastError: "timeout", CreatedAt: now}
+	if assert.NoError(t, repo.CreateDeadLetter(ctx, &deadLetter)) {
+		deadLetter.ReplayedAt = &now
+		assert.NoError(t, repo.UpdateDeadLetter(ctx, deadLetter))
+		stored, err := repo.GetDeadLetter(ctx, deadLetter.Id)
+		if assert.NoError(t, err) {
+			assert.Equal(t, `{"id":1}`, stored.Payload)
+			assert.NotNil(t, stored.ReplayedAt)
+		}
+	}
+	deadLetters, err := repo.QueryDeadLetters(ctx, owner.Id, 0, -1)
+	if assert.NoError(t, err) {
+		assert.Len(t, deadLetters, 1)
+	}
+	deadLetters, err = repo.QueryDeadLetters(ctx, all.Id, 0, -1)
+	if assert.NoError(t, err) {
+		assert.Empty(t, deadLetters)
+	}
+
+	// delete removes pending deliveries
+	assert.NoError(t, repo.Delete(ctx, owner.Id))
+	_, err = repo.Get(ctx, owner.Id)
+	assert.Equal(t, sql.ErrNoRows, err)
+	deliveries, _ = repo.ClaimDeliveries(ctx, now.Add(2*time.Hour), now.Add(3*time.Hour), 10)
+	assert.Empty(t, deliveries)
+	assert.Equal(t, sql.ErrNoRows, repo.Delete(ctx, owner.Id))
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+// Service encapsulates usecase logic for webhook subscriptions.
+type Service interface {
+	// Get returns the WebhookSubscription with the specified id.
+	Get(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	// Query returns all WebhookSubscriptions.
+	Query(ctx context.Context) ([]entity.WebhookSubscription, error)
+	// Create creates a new WebhookSubscription. The secret is generated unless it is provided in the request.
+	// The returned WebhookSubscription is the only one which contains the secret.
+	Create(ctx context.Context, req requests.WebhookRequest) (entity.WebhookSubscription, error)
+	// Update changes the WebhookSubscription with the specified id. The secret is kept unless it is provided.
+	Update(ctx context.Context, id int64, req requests.WebhookRequest) (entity.WebhookSubscription, error)
+	// Delete deletes the WebhookSubscription with the specified id.
+	Delete(ctx context.Context, id int64) (entity.WebhookSubscription, error)
+	// DeadLetters returns a list of deliveries which have failed all their attempts.
+	DeadLetters(ctx context.Context, req requests.DeadLettersRequest) ([]DeadLetter, error)
+	// Replay schedules the delivery of the dead letter with the specified id once again.
+	Replay(ctx context.Context, id int64) (DeadLetter, error)
+}
+
+// DeadLetter represents a WebhookDeadLetter together with the event which was not delivered.
+type DeadLetter struct {
+	entity.WebhookDeadLetter
+	Event json.RawMessage `json:"event"`
+}
+
+type service struct {
+	repo   Repository
+	logger log.Logger
+}
+
+// NewService creates a new webhook subscription service.
+func NewService(repo Repository, logger log.Logger) Service {
+	return service{repo, logger}
+}
+
+func (s service) Get(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	subscription, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return hideSecret(subscription), nil
+}
+
+func (s service) Query(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	subscriptions, err := s.repo.Query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entity.WebhookSubscription, len(subscriptions))
+	for i, subscription := range subscriptions {
+		result[i] = hideSecret(subscription)
+	}
+	return result, nil
+}
+
+func (s service) Create(ctx context.Context, req requests.WebhookRequest) (entity.WebhookSubscription, error) {
+	if err := req.Validate(); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return entity.WebhookSubscription{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+	subscription := entity.WebhookSubscription{
+		Url:       req.Url,
+		Secret:    secret,
+		OwnerId:   parseOwnerId(req.OwnerId),
+		EventType: req.EventType,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(ctx, &subscription); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+func (s service) Update(ctx context.Context, id int64, req requests.WebhookRequest) (entity.WebhookSubscription, error) {
+	if err := req.Validate(); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+
+	subscription, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	subscription.Url = req.Url
+	subscription.OwnerId = parseOwnerId(req.OwnerId)
+	subscription.EventType = req.EventType
+	subscription.UpdatedAt = time.Now().UTC()
+	if req.Secret != "" {
+		subscription.Secret = req.Secret
+	}
+
+	if err := s.repo.Update(ctx, subscription); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return hideSecret(subscription), nil
+}
+
+func (s service) Delete(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	subscription, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	if err = s.repo.Delete(ctx, id); err != nil {
+		return entity.WebhookSubscription{}, err
+	}
+	return hideSecret(subscription), nil
+}
+
+func (s service) DeadLetters(ctx context.Context, req requests.DeadLettersRequest) ([]DeadLetter, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// if limit not specified, set equal to -1(meaning no limit in SQL)
+	if req.Limit == 0 {
+		req.Limit = -1
+	}
+
+	deadLetters, err := s.repo.QueryDeadLetters(ctx, req.SubscriptionId, req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]DeadLetter, len(deadLetters))
+	for i, deadLetter := range deadLetters {
+		result[i] = DeadLetter{deadLetter, json.RawMessage(deadLetter.Payload)}
+	}
+	return result, nil
+}
+
+func (s service) Replay(ctx context.Context, id int64) (DeadLetter, error) {
+	deadLetter, err := s.repo.GetDeadLetter(ctx, id)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	if deadLetter.ReplayedAt != nil {
+		return DeadLetter{}, errors.Conflict("Dead letter is already replayed.")
+	}
+	if _, err = s.repo.Get(ctx, deadLetter.SubscriptionId); err != nil {
+		return DeadLetter{}, err
+	}
+
+	now := time.Now().UTC()
+	delivery := entity.WebhookDelivery{
+		SubscriptionId: deadLetter.SubscriptionId,
+		EventId:        deadLetter.EventId,
+		OwnerId:        deadLetter.OwnerId,
+		Payload:        deadLetter.Payload,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	if err = s.repo.CreateDelivery(ctx, &delivery); err != nil {
+		return DeadLetter{}, err
+	}
+
+	deadLetter.ReplayedAt = &now
+	if err = s.repo.UpdateDeadLetter(ctx, deadLetter); err != nil {
+		return DeadLetter{}, err
+	}
+	return DeadLetter{deadLetter, json.RawMessage(deadLetter.Payload)}, nil
+}
+
+// hideSecret removes the secret from the WebhookSubscription before it is returned to the client.
+func hideSecret(subscription entity.WebhookSubscription) entity.WebhookSubscription {
+	subscription.Secret = ""
+	return subscription
+}
+
+// generateSecret returns a random secret of 32 bytes encoded as hex.
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parseOwnerId returns nil for an empty owner id. The id is expected to be validated.
+func parseOwnerId(ownerId string) *uuid.UUID {
+	if ownerId == "" {
+		return nil
+	}
+	id := uuid.MustParse(ownerId)
+	return &id
+}
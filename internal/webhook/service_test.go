@@ -0,0 +1,351 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	errs "users-balance-microservice/internal/errors"
+	"users-balance-microservice/internal/requests"
+	"users-balance-microservice/pkg/log"
+)
+
+var (
+	logger, _     = log.NewForTest()
+	ctx           = context.Background()
+	databaseError = errors.New("database error")
+)
+
+func TestService_CRUD(t *testing.T) {
+	id1 := uuid.New()
+	repo := &mockRepository{}
+	s := NewService(repo, logger)
+
+	// create with generated secret
+	s1, err := s.Create(ctx, requests.WebhookRequest{Url: "https://example.com/hook"})
+	if assert.NoError(t, err) {
+		assert.NotZero(t, s1.Id)
+		assert.Len(t, s1.Secret, 64)
+		assert.Nil(t, s1.OwnerId)
+	}
+
+	// create with own secret and filters
+	s2, err := s.Create(ctx, requests.WebhookRequest{
+		Url:       "https://example.com/hook2",
+		Secret:    "0123456789abcdef",
+		OwnerId:   id1.String(),
+		EventType: "top_up",
+	})
+	if assert.NoError(t, err) && assert.NotNil(t, s2.OwnerId) {
+		assert.Equal(t, "0123456789abcdef", s2.Secret)
+		assert.Equal(t, id1, *s2.OwnerId)
+		assert.Equal(t, "top_up", s2.EventType)
+	}
+
+	// create fail validation
+	_, err = s.Create(ctx, requests.WebhookRequest{Url: "example"})
+	assert.Error(t, err)
+
+	// get hides the secret
+	s1get, err := s.Get(ctx, s1.Id)
+	if assert.NoError(t, err) {
+		assert.Empty(t, s1get.Secret)
+		assert.Equal(t, s1.Url, s1get.Url)
+	}
+	_, err = s.Get(ctx, 100)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// query hides secrets
+	subscriptions, err := s.Query(ctx)
+	if assert.NoError(t, err) && assert.Len(t, subscriptions, 2) {
+		assert.Empty(t, subscriptions[0].Secret)
+		assert.Empty(t, subscriptions[1].Secret)
+	}
+
+	// update keeps the secret unless it is provided
+	updated, err := s.Update(ctx, s2.Id, requests.WebhookRequest{Url: "https://example.com/hook3"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/hook3", updated.Url)
+		assert.Nil(t, updated.OwnerId)
+		assert.Empty(t, updated.EventType)
+		assert.Empty(t, updated.Secret)
+		stored, _ := repo.Get(ctx, s2.Id)
+		assert.Equal(t, "0123456789abcdef", stored.Secret)
+	}
+	_, err = s.Update(ctx, s2.Id, requests.WebhookRequest{Url: "https://example.com/hook3", Secret: "fedcba9876543210"})
+	if assert.NoError(t, err) {
+		stored, _ := repo.Get(ctx, s2.Id)
+		assert.Equal(t, "fedcba9876543210", stored.Secret)
+	}
+	_, err = s.Update(ctx, 100, requests.WebhookRequest{Url: "https://example.com/hook3"})
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = s.Update(ctx, s2.Id, requests.WebhookRequest{})
+	assert.Error(t, err)
+
+	// delete
+	deleted, err := s.Delete(ctx, s1.Id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, s1.Id, deleted.Id)
+		assert.Empty(t, deleted.Secret)
+		_, err = s.Get(ctx, s1.Id)
+		assert.Equal(t, sql.ErrNoRows, err)
+	}
+	_, err = s.Delete(ctx, s1.Id)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestService_DeadLetters(t *testing.T) {
+	id1 := uuid.New()
+	repo := &mockRepository{}
+	s := NewService(repo, logger)
+	subscription, _ := s.Create(ctx, requests.WebhookRequest{Url: "https://example.com/hook"})
+
+	_ = repo.CreateDeadLetter(ctx, &entity.WebhookDeadLetter{SubscriptionId: subscription.Id, EventId: 1, OwnerId: id1, Payload: `{"id":1}`, Attempts: 6, LastError: "timeout"})
+	_ = repo.CreateDeadLetter(ctx, &entity.WebhookDeadLetter{SubscriptionId: 100, EventId: 2, OwnerId: id1, Payload: `{"id":2}`, Attempts: 6, LastError: "timeout"})
+
+	// list all
+	deadLetters, err := s.DeadLetters(ctx, requests.DeadLettersRequest{})
+	if assert.NoError(t, err) && assert.Len(t, deadLetters, 2) {
+		assert.Equal(t, `{"id":1}`, string(deadLetters[0].Event))
+	}
+
+	// list of a subscription
+	deadLetters, err = s.DeadLetters(ctx, requests.DeadLettersRequest{SubscriptionId: subscription.Id})
+	if assert.NoError(t, err) {
+		assert.Len(t, deadLetters, 1)
+	}
+
+	// fail validation
+	_, err = s.DeadLetters(ctx, requests.DeadLettersRequest{Offset: -1})
+	assert.Error(t, err)
+
+	// replay schedules a new delivery
+	replayed, err := s.Replay(ctx, deadLetters[0].Id)
+	if assert.NoError(t, err) && assert.NotNil(t, replayed.ReplayedAt) && assert.Len(t, repo.deliveries, 1) {
+		assert.Equal(t, subscription.Id, repo.deliveries[0].SubscriptionId)
+		assert.EqualValues(t, 1, repo.deliveries[0].EventId)
+		assert.Equal(t, `{"id":1}`, repo.deliveries[0].Payload)
+		assert.Zero(t, repo.deliveries[0].Attempts)
+	}
+
+	// replay twice
+	_, err = s.Replay(ctx, deadLetters[0].Id)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, err.(errs.ErrorResponse).StatusCode())
+	}
+
+	// replay of a deleted subscription
+	_, err = s.Replay(ctx, repo.deadLetters[1].Id)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// replay of missing dead letter
+	_, err = s.Replay(ctx, 100)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+type mockRepository struct {
+	subscriptions []entity.WebhookSubscription
+	deliveries    []entity.WebhookDelivery
+	deadLetters   []entity.WebhookDeadLetter
+	lastId        int64
+	locked        bool
+	err           error
+}
+
+func (m *mockRepository) nextId() int64 {
+	m.lastId++
+	return m.lastId
+}
+
+func (m *mockRepository) Get(ctx context.Context, id int64) (entity.WebhookSubscription, error) {
+	for _, item := range m.subscriptions {
+		if item.Id == id {
+			return item, nil
+		}
+	}
+	return entity.WebhookSubscription{}, sql.ErrNoRows
+}
+
+func (m *mockRepository) Query(ctx context.Context) ([]entity.WebhookSubscription, error) {
+	return m.subscriptions, nil
+}
+
+func (m *mockRepository) Matching(ctx context.Context, ownerId uuid.UUID, eventType string) ([]entity.WebhookSubscription, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var result []entity.WebhookSubscription
+	for _, item := range m.subscriptions {
+		if (item.OwnerId == nil || *item.OwnerId == ownerId) && (item.EventType == "" || item.EventType == eventType) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepository) Create(ctx context.Context, subscription *entity.WebhookSubscription) error {
+	subscription.Id = m.nextId()
+	m.subscriptions = append(m.subscriptions, *subscription)
+	return nil
+}
+
+func (m *mockRepository) Update(ctx context.Context, subscription entity.WebhookSubscription) error {
+	for i, item := range m.subscriptions {
+		if item.Id == subscription.Id {
+			m.subscriptions[i] = subscription
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockRepository) Delete(ctx context.Context, id int64) error {
+	for i, item := range m.subscriptions {
+		if item.Id == id {
+			m.subscriptions = append(m.subscriptions[:i], m.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockRepository) CreateDelivery(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	for _, item := range m.deliveries {
+		if item.SubscriptionId == delivery.SubscriptionId && item.EventId == delivery.EventId {
+			return nil
+		}
+	}
+	delivery.Id = m.nextId()
+	m.deliveries = append(m.deliveries, *delivery)
+	return nil
+}
+
+// ClaimDeliveries takes the deliveries in rounds: the n-th round takes the n-th delivery of every due queue
+// without claims.
+func (m *mockRepository) ClaimDeliveries(ctx context.Context, now, until time.Time, limit int) ([]entity.WebhookDelivery, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var queues [][]entity.WebhookDelivery
+	index := map[queue]int{}
+	busy := map[queue]bool{}
+	for _, item := range m.deliveries {
+		q := queue{item.SubscriptionId, item.OwnerId}
+		i, ok := index[q]
+		if !ok {
+			i = len(queues)
+			index[q] = i
+			queues = append(queues, nil)
+		}
+		queues[i] = append(queues[i], item)
+		if item.ClaimedUntil != nil && item.ClaimedUntil.After(now) {
+			busy[q] = true
+		}
+	}
+
+	claimed := map[int64]bool{}
+	var result []entity.WebhookDelivery
+	for position := 0; len(result) < limit; position++ {
+		taken := false
+		for _, items := range queues {
+			q := queue{items[0].SubscriptionId, items[0].OwnerId}
+			if position < len(items) && !busy[q] && !items[0].NextAttemptAt.After(now) && len(result) < limit {
+				claimed[items[position].Id] = true
+				result = append(result, items[position])
+				taken = true
+			}
+		}
+		if !taken {
+			break
+		}
+	}
+
+	for i := range m.deliveries {
+		if claimed[m.deliveries[i].Id] {
+			m.deliveries[i].ClaimedUntil = &until
+		}
+	}
+	for i := range result {
+		result[i].ClaimedUntil = &until
+	}
+	return result, nil
+}
+
+func (m *mockRepository) ReleaseDeliveries(ctx context.Context, ids ...int64) error {
+	for _, id := range ids {
+		for i := range m.deliveries {
+			if m.deliveries[i].Id == id {
+				m.deliveries[i].ClaimedUntil = nil
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockRepository) UpdateDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	for i, item := range m.deliveries {
+		if item.Id == delivery.Id {
+			m.deliveries[i] = delivery
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockRepository) DeleteDelivery(ctx context.Context, id int64) error {
+	for i, item := range m.deliveries {
+		if item.Id == id {
+			m.deliveries = append(m.deliveries[:i], m.deliveries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *mockRepository) GetDeadLetter(ctx context.Context, id int64) (entity.WebhookDeadLetter, error) {
+	for _, item := range m.deadLetters {
+		if item.Id == id {
+			return item, nil
+		}
+	}
+	return entity.WebhookDeadLetter{}, sql.ErrNoRows
+}
+
+func (m *mockRepository) QueryDeadLetters(ctx context.Context, subscriptionId int64, offset, limit int) ([]entity.WebhookDeadLetter, error) {
+	var result []entity.WebhookDeadLetter
+	for _, item := range m.deadLetters {
+		if subscriptionId == 0 || item.SubscriptionId == subscriptionId {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepository) CreateDeadLetter(ctx context.Context, deadLetter *entity.WebhookDeadLetter) error {
+	deadLetter.Id = m.nextId()
+	if deadLetter.CreatedAt.IsZero() {
+		deadLetter.CreatedAt = time.Now().UTC()
+	}
+	m.deadLetters = append(m.deadLetters, *deadLetter)
+	return nil
+}
+
+func (m *mockRepository) UpdateDeadLetter(ctx context.Context, deadLetter entity.WebhookDeadLetter) error {
+	for i, item := range m.deadLetters {
+		if item.Id == deadLetter.Id {
+			m.deadLetters[i] = deadLetter
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockRepository) TryLock(ctx context.Context) (bool, error) {
+	return !m.locked, m.err
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/outbox"
+	"users-balance-microservice/pkg/log"
+)
+
+// Sink schedules deliveries of outbox events to the matching WebhookSubscriptions.
+// The deliveries themselves are made by Worker.
+type Sink struct {
+	repo   Repository
+	logger log.Logger
+}
+
+// NewSink creates a new Sink.
+func NewSink(repo Repository, logger log.Logger) Sink {
+	return Sink{repo, logger}
+}
+
+// Send saves a WebhookDelivery of the event for every matching WebhookSubscription.
+func (s Sink) Send(ctx context.Context, event outbox.Event) error {
+	subscriptions, err := s.repo.Matching(ctx, event.OwnerId, event.Type)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, subscription := range subscriptions {
+		delivery := entity.WebhookDelivery{
+			SubscriptionId: subscription.Id,
+			EventId:        event.Id,
+			OwnerId:        event.OwnerId,
+			Payload:        string(payload),
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		if err := s.repo.CreateDelivery(ctx, &delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/internal/outbox"
+)
+
+func TestSink_Send(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	all := entity.WebhookSubscription{Url: "https://example.com/all"}
+	owner := entity.WebhookSubscription{Url: "https://example.com/owner", OwnerId: &id1}
+	topUps := entity.WebhookSubscription{Url: "https://example.com/top-ups", EventType: outbox.EventTopUp}
+	for _, s := range []*entity.WebhookSubscription{&all, &owner, &topUps} {
+		_ = repo.Create(ctx, s)
+	}
+	sink := NewSink(repo, logger)
+
+	event := outbox.Event{Id: 1, Type: outbox.EventTopUp, OwnerId: id1, Data: json.RawMessage(`{"id":1}`)}
+	if assert.NoError(t, sink.Send(ctx, event)) && assert.Len(t, repo.deliveries, 3) {
+		assert.Equal(t, []int64{all.Id, owner.Id, topUps.Id}, subscriptionIds(repo.deliveries))
+
+		var payload outbox.Event
+		if assert.NoError(t, json.Unmarshal([]byte(repo.deliveries[0].Payload), &payload)) {
+			assert.Equal(t, event.Id, payload.Id)
+			assert.Equal(t, id1, payload.OwnerId)
+		}
+		assert.EqualValues(t, 1, repo.deliveries[0].EventId)
+		assert.Equal(t, id1, repo.deliveries[0].OwnerId)
+	}
+
+	// a retried event is not delivered twice
+	assert.NoError(t, sink.Send(ctx, event))
+	assert.Len(t, repo.deliveries, 3)
+
+	// other owner and other type
+	repo.deliveries = nil
+	assert.NoError(t, sink.Send(ctx, outbox.Event{Id: 2, Type: outbox.EventWithdrawal, OwnerId: id2, Data: json.RawMessage(`{}`)}))
+	assert.Equal(t, []int64{all.Id}, subscriptionIds(repo.deliveries))
+
+	// repository error
+	repo.err = databaseError
+	assert.Equal(t, databaseError, sink.Send(ctx, event))
+}
+
+func subscriptionIds(deliveries []entity.WebhookDelivery) []int64 {
+	var result []int64
+	for _, d := range deliveries {
+		result = append(result, d.SubscriptionId)
+	}
+	return result
+}
@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"users-balance-microservice/internal/entity"
+	"users-balance-microservice/pkg/dbcontext"
+	"users-balance-microservice/pkg/log"
+)
+
+const (
+	// SignatureHeader is the header with the HMAC-SHA256 signature of the delivery, see Sign.
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader is the header with the Unix time the delivery was signed at.
+	TimestampHeader = "X-Webhook-Timestamp"
+
+	// defaultBatchSize is the maximum number of deliveries processed in one round.
+	defaultBatchSize = 100
+	// defaultClaimTimeout is how long the deliveries of a round stay claimed if the worker stops before
+	// recording their results.
+	defaultClaimTimeout = time.Minute
+)
+
+// Sign returns the signature of the delivery body made at the given Unix time: hex-encoded HMAC-SHA256
+// of "<timestamp>.<body>" with the subscription secret as a key, prefixed with "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Worker delivers scheduled WebhookDeliveries.
+//
+// Every round claims a batch of deliveries in a short DB transaction, posts them to the subscribers after it is
+// committed and records the results in another DB transaction, so no DB transaction or lock is held while the
+// subscribers are waited for. The deliveries which cannot be made before the claim expires are left to the next round.
+//
+// A failed delivery is retried with exponential backoff: the n-th retry is made backoff * 2^(n-1) after the failure.
+// A delivery which has failed maxAttempts times is moved to the dead letters. Deliveries of the events of the same
+// owner to the same subscription are made in order, unless some of them were moved to the dead letters.
+type Worker struct {
+	repo          Repository
+	transactional dbcontext.TransactionFunc
+	client        *http.Client
+	logger        log.Logger
+	maxAttempts   int
+	backoff       time.Duration
+	batchSize     int
+	claimTimeout  time.Duration
+}
+
+// NewWorker creates a new Worker. The deliveries of every round are claimed and their results are recorded
+// within DB transactions started by transactional.
+func NewWorker(
+	repo Repository,
+	transactional dbcontext.TransactionFunc,
+	client *http.Client,
+	maxAttempts int,
+	backoff time.Duration,
+	logger log.Logger,
+) *Worker {
+	return &Worker{
+		repo:          repo,
+		transactional: transactional,
+		client:        client,
+		logger:        logger,
+		maxAttempts:   maxAttempts,
+		backoff:       backoff,
+		batchSize:     defaultBatchSize,
+		claimTimeout:  defaultClaimTimeout,
+	}
+}
+
+// Run makes deliveries every interval until the context is cancelled.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Deliver(ctx); err != nil {
+			w.logger.With(ctx).Errorf("failed delivering webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// queue identifies deliveries which have to be made in order.
+type queue struct {
+	subscriptionId int64
+	ownerId        uuid.UUID
+}
+
+// failure is a failed attempt of a delivery which is recorded after the round.
+type failure struct {
+	delivery entity.WebhookDelivery
+	err      error
+}
+
+// Deliver makes a single round of deliveries which are due and returns the number of successful ones.
+// If another worker is claiming deliveries at the moment, Deliver does nothing.
+func (w *Worker) Deliver(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	until := now.Add(w.claimTimeout)
+	var deliveries []entity.WebhookDelivery
+	subscriptions := map[int64]*entity.WebhookSubscription{}
+	err := w.transactional(ctx, func(ctx context.Context) error {
+		locked, err := w.repo.TryLock(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		deliveries, err = w.repo.ClaimDeliveries(ctx, now, until, w.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			if _, ok := subscriptions[delivery.SubscriptionId]; ok {
+				continue
+			}
+			s, err := w.repo.Get(ctx, delivery.SubscriptionId)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			// nil means the subscription was deleted
+			subscriptions[delivery.SubscriptionId] = nil
+			if err == nil {
+				subscriptions[delivery.SubscriptionId] = &s
+			}
+		}
+		return nil
+	})
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	// a request which is started later may not finish before the claim expires
+	deadline := until.Add(-w.client.Timeout)
+	var done, released []int64
+	var failures []failure
+	delivered := 0
+	blocked := map[queue]bool{}
+	for _, delivery := range deliveries {
+		q := queue{delivery.SubscriptionId, delivery.OwnerId}
+		if blocked[q] || delivery.NextAttemptAt.After(now) || time.Now().After(deadline) {
+			blocked[q] = true
+			released = append(released, delivery.Id)
+			continue
+		}
+
+		subscription := subscriptions[delivery.SubscriptionId]
+		if subscription == nil {
+			done = append(done, delivery.Id)
+			continue
+		}
+		if err := w.send(ctx, *subscription, delivery); err != nil {
+			blocked[q] = true
+			failures = append(failures, failure{delivery, err})
+			continue
+		}
+		done = append(done, delivery.Id)
+		delivered++
+	}
+
+	err = w.transactional(ctx, func(ctx context.Context) error {
+		for _, id := range done {
+			if err := w.repo.DeleteDelivery(ctx, id); err != nil {
+				return err
+			}
+		}
+		for _, f := range failures {
+			if err := w.fail(ctx, f.delivery, f.err, now); err != nil {
+				return err
+			}
+		}
+		return w.repo.ReleaseDeliveries(ctx, released...)
+	})
+	return delivered, err
+}
+
+// send posts the signed delivery to the subscription URL and expects a 2xx response.
+func (w *Worker) send(ctx context.Context, subscription entity.WebhookSubscription, delivery entity.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(delivery.EventId, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// fail schedules the next attempt of the delivery or moves it to the dead letters if it has no attempts left.
+func (w *Worker) fail(ctx context.Context, delivery entity.WebhookDelivery, sendErr error, now time.Time) error {
+	delivery.Attempts++
+	delivery.LastError = sendErr.Error()
+	delivery.ClaimedUntil = nil
+	w.logger.With(ctx, "delivery_id", delivery.Id, "attempts", delivery.Attempts).Infof("failed delivering webhook: %v", sendErr)
+
+	if delivery.Attempts < w.maxAttempts {
+		delivery.NextAttemptAt = now.Add(w.backoff << (delivery.Attempts - 1))
+		return w.repo.UpdateDelivery(ctx, delivery)
+	}
+
+	deadLetter := entity.WebhookDeadLetter{
+		SubscriptionId: delivery.SubscriptionId,
+		EventId:        delivery.EventId,
+		OwnerId:        delivery.OwnerId,
+		Payload:        delivery.Payload,
+		Attempts:       delivery.Attempts,
+		LastError:      delivery.LastError,
+		CreatedAt:      now,
+	}
+	if err := w.repo.CreateDeadLetter(ctx, &deadLetter); err != nil {
+		return err
+	}
+	return w.repo.DeleteDelivery(ctx, delivery.Id)
+}
@@ -0,0 +1,315 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"users-balance-microservice/internal/entity"
+)
+
+// inPlace runs the function without a real DB transaction.
+func inPlace(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
+
+// receiver is an httptest webhook receiver which verifies signatures.
+type receiver struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	secret   string
+	status   int
+	bodies   []string
+	verified []bool
+}
+
+func newReceiver(secret string) *receiver {
+	r := &receiver{secret: secret, status: http.StatusOK}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.bodies = append(r.bodies, string(body))
+		r.verified = append(r.verified, req.Header.Get(SignatureHeader) == Sign(r.secret, timestamp, body))
+		w.WriteHeader(r.status)
+	}))
+	return r
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+func TestSign(t *testing.T) {
+	signature := Sign("secret", 1636552990, []byte(`{"id":1}`))
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.Len(t, signature, 7+64)
+	assert.Equal(t, signature, Sign("secret", 1636552990, []byte(`{"id":1}`)))
+	assert.NotEqual(t, signature, Sign("other", 1636552990, []byte(`{"id":1}`)))
+	assert.NotEqual(t, signature, Sign("secret", 1636552991, []byte(`{"id":1}`)))
+	assert.NotEqual(t, signature, Sign("secret", 1636552990, []byte(`{"id":2}`)))
+}
+
+func TestWorker_Deliver(t *testing.T) {
+	rcv := newReceiver("0123456789abcdef")
+	defer rcv.server.Close()
+
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	subscription := entity.WebhookSubscription{Url: rcv.server.URL, Secret: "0123456789abcdef"}
+	_ = repo.Create(ctx, &subscription)
+	for i, owner := range []uuid.UUID{id1, id2, id1} {
+		_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{
+			SubscriptionId: subscription.Id,
+			EventId:        int64(i + 1),
+			OwnerId:        owner,
+			Payload:        `{"id":` + strconv.Itoa(i+1) + `}`,
+		})
+	}
+
+	w := NewWorker(repo, inPlace, rcv.server.Client(), 3, time.Millisecond, logger)
+
+	// signed deliveries in order
+	n, err := w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []string{`{"id":1}`, `{"id":2}`, `{"id":3}`}, rcv.received())
+		assert.Equal(t, []bool{true, true, true}, rcv.verified)
+		assert.Empty(t, repo.deliveries)
+	}
+
+	// another worker is running
+	_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{SubscriptionId: subscription.Id, EventId: 4, OwnerId: id1, Payload: `{"id":4}`})
+	repo.locked = true
+	n, err = w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+		assert.Len(t, repo.deliveries, 1)
+	}
+	repo.locked = false
+
+	// delivery to deleted subscription is dropped
+	_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{SubscriptionId: 100, EventId: 5, OwnerId: id2, Payload: `{"id":5}`})
+	n, err = w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n)
+		assert.Empty(t, repo.deliveries)
+		assert.Len(t, rcv.received(), 4)
+	}
+
+	// repository error
+	repo.err = databaseError
+	_, err = w.Deliver(ctx)
+	assert.Equal(t, databaseError, err)
+}
+
+func TestWorker_Retries(t *testing.T) {
+	rcv := newReceiver("0123456789abcdef")
+	defer rcv.server.Close()
+	rcv.setStatus(http.StatusServiceUnavailable)
+
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	subscription := entity.WebhookSubscription{Url: rcv.server.URL, Secret: "0123456789abcdef"}
+	_ = repo.Create(ctx, &subscription)
+	for i, owner := range []uuid.UUID{id1, id1, id2} {
+		_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{
+			SubscriptionId: subscription.Id,
+			EventId:        int64(i + 1),
+			OwnerId:        owner,
+			Payload:        `{"id":` + strconv.Itoa(i+1) + `}`,
+		})
+	}
+
+	backoff := time.Hour
+	w := NewWorker(repo, inPlace, rcv.server.Client(), 3, backoff, logger)
+
+	// first attempt fails: the next one is scheduled after backoff, later events of the owner wait
+	start := time.Now().UTC()
+	n, err := w.Deliver(ctx)
+	if assert.NoError(t, err) && assert.Len(t, repo.deliveries, 3) {
+		assert.Zero(t, n)
+		assert.Equal(t, []string{`{"id":1}`, `{"id":3}`}, rcv.received())
+		assert.Equal(t, 1, repo.deliveries[0].Attempts)
+		assert.Equal(t, "webhook responded with status 503", repo.deliveries[0].LastError)
+		assert.WithinDuration(t, start.Add(backoff), repo.deliveries[0].NextAttemptAt, time.Minute)
+		assert.Equal(t, 0, repo.deliveries[1].Attempts)
+	}
+
+	// deliveries scheduled in future are not attempted
+	_, err = w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Len(t, rcv.received(), 2)
+	}
+
+	// backoff doubles
+	repo.deliveries[0].NextAttemptAt = start
+	_, _ = w.Deliver(ctx)
+	assert.Equal(t, 2, repo.deliveries[0].Attempts)
+	assert.WithinDuration(t, start.Add(2*backoff), repo.deliveries[0].NextAttemptAt, time.Minute)
+
+	// exhausted delivery is moved to dead letters, and the next event of the owner is delivered
+	repo.deliveries[0].NextAttemptAt = start
+	_, _ = w.Deliver(ctx)
+	if assert.Len(t, repo.deadLetters, 1) {
+		assert.EqualValues(t, 1, repo.deadLetters[0].EventId)
+		assert.Equal(t, 3, repo.deadLetters[0].Attempts)
+		assert.Equal(t, `{"id":1}`, repo.deadLetters[0].Payload)
+	}
+
+	rcv.setStatus(http.StatusOK)
+	for i := range repo.deliveries {
+		repo.deliveries[i].NextAttemptAt = start
+	}
+	n, err = w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, n)
+		assert.Empty(t, repo.deliveries)
+	}
+
+	// unavailable receiver
+	rcv.server.Close()
+	_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{SubscriptionId: subscription.Id, EventId: 4, OwnerId: id1, Payload: `{}`})
+	n, err = w.Deliver(ctx)
+	if assert.NoError(t, err) && assert.Len(t, repo.deliveries, 1) {
+		assert.Zero(t, n)
+		assert.Equal(t, 1, repo.deliveries[0].Attempts)
+	}
+}
+
+func TestWorker_FailingSubscriber(t *testing.T) {
+	failing := newReceiver("0123456789abcdef")
+	defer failing.server.Close()
+	failing.setStatus(http.StatusServiceUnavailable)
+	healthy := newReceiver("0123456789abcdef")
+	defer healthy.server.Close()
+
+	id1 := uuid.New()
+	repo := &mockRepository{}
+	bad := entity.WebhookSubscription{Url: failing.server.URL, Secret: "0123456789abcdef"}
+	good := entity.WebhookSubscription{Url: healthy.server.URL, Secret: "0123456789abcdef"}
+	_ = repo.Create(ctx, &bad)
+	_ = repo.Create(ctx, &good)
+
+	w := NewWorker(repo, inPlace, failing.server.Client(), 3, time.Hour, logger)
+	w.batchSize = 5
+
+	// the failing subscriber has a backlog larger than a batch, which waits for a retry
+	for i := 1; i <= 2*w.batchSize; i++ {
+		_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{
+			SubscriptionId: bad.Id,
+			EventId:        int64(i),
+			OwnerId:        id1,
+			Payload:        `{"id":` + strconv.Itoa(i) + `}`,
+		})
+	}
+	n, err := w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, n)
+		assert.Len(t, failing.received(), 1)
+	}
+
+	// the events of the same owner to the healthy subscriber are still delivered
+	for i := 1; i <= 3; i++ {
+		_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{
+			SubscriptionId: good.Id,
+			EventId:        int64(i),
+			OwnerId:        id1,
+			Payload:        `{"id":` + strconv.Itoa(i) + `}`,
+		})
+	}
+	n, err = w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, n)
+		assert.Equal(t, []string{`{"id":1}`, `{"id":2}`, `{"id":3}`}, healthy.received())
+		assert.Len(t, failing.received(), 1)
+		assert.Len(t, repo.deliveries, 2*w.batchSize)
+	}
+}
+
+func TestWorker_OutsideTransaction(t *testing.T) {
+	var inTransaction, sentInTransaction bool
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sentInTransaction = sentInTransaction || inTransaction
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	id1, id2 := uuid.New(), uuid.New()
+	repo := &mockRepository{}
+	subscription := entity.WebhookSubscription{Url: server.URL, Secret: "0123456789abcdef"}
+	_ = repo.Create(ctx, &subscription)
+	for i, owner := range []uuid.UUID{id1, id2} {
+		_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{SubscriptionId: subscription.Id, EventId: int64(i + 1), OwnerId: owner, Payload: `{}`})
+	}
+
+	// the queue of id2 is claimed by another worker
+	until := time.Now().UTC().Add(time.Hour)
+	repo.deliveries[1].ClaimedUntil = &until
+
+	transactional := func(ctx context.Context, f func(ctx context.Context) error) error {
+		inTransaction = true
+		defer func() { inTransaction = false }()
+		return f(ctx)
+	}
+	w := NewWorker(repo, transactional, server.Client(), 3, time.Hour, logger)
+
+	// deliveries are claimed before and deleted after they are sent
+	n, err := w.Deliver(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, n)
+		assert.False(t, sentInTransaction)
+		if assert.Len(t, repo.deliveries, 1) {
+			assert.Equal(t, id2, repo.deliveries[0].OwnerId)
+		}
+	}
+
+	// the claim expires
+	repo.deliveries[0].ClaimedUntil = nil
+	status = http.StatusServiceUnavailable
+	n, err = w.Deliver(ctx)
+	if assert.NoError(t, err) && assert.Len(t, repo.deliveries, 1) {
+		assert.Zero(t, n)
+		assert.Equal(t, 1, repo.deliveries[0].Attempts)
+		assert.Nil(t, repo.deliveries[0].ClaimedUntil)
+	}
+}
+
+func TestWorker_ClaimTimeout(t *testing.T) {
+	rcv := newReceiver("0123456789abcdef")
+	defer rcv.server.Close()
+
+	repo := &mockRepository{}
+	subscription := entity.WebhookSubscription{Url: rcv.server.URL, Secret: "0123456789abcdef"}
+	_ = repo.Create(ctx, &subscription)
+	_ = repo.CreateDelivery(ctx, &entity.WebhookDelivery{SubscriptionId: subscription.Id, EventId: 1, OwnerId: uuid.New(), Payload: `{}`})
+
+	// a delivery which may not finish before the claim expires is left to the next round
+	client := *rcv.server.Client()
+	client.Timeout = 2 * time.Minute
+	w := NewWorker(repo, inPlace, &client, 3, time.Hour, logger)
+	n, err := w.Deliver(ctx)
+	if assert.NoError(t, err) && assert.Len(t, repo.deliveries, 1) {
+		assert.Zero(t, n)
+		assert.Empty(t, rcv.received())
+		assert.Nil(t, repo.deliveries[0].ClaimedUntil)
+	}
+}
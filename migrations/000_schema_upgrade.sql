@@ -0,0 +1,202 @@
+/* Upgrades a database created by the first postgres-init.sql, which had only the Deposit and Transaction tables, to
+   the current schema: deposits in several currencies with reserved amounts and statuses, typed transactions and
+   the tables of the later features. It must be applied before the other migrations and is applied only once:
+   psql "$APP_DSN" -f migrations/000_schema_upgrade.sql
+   Existing deposits become active RUB deposits without reserved amounts. Databases created by the current
+   postgres-init.sql are already marked as migrated. */
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS Schema_Migration(
+    name VARCHAR(64) PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE Deposit ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE Deposit ADD COLUMN IF NOT EXISTS reserved BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE Deposit ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+
+DO $$
+DECLARE
+    pkey NAME;
+BEGIN
+    /* a user has a deposit in every currency instead of a single one */
+    SELECT conname INTO pkey FROM pg_constraint
+    WHERE conrelid = 'deposit'::regclass AND contype = 'p' AND array_length(conkey, 1) = 1;
+    IF pkey IS NOT NULL THEN
+        EXECUTE format('ALTER TABLE Deposit DROP CONSTRAINT %I', pkey);
+        ALTER TABLE Deposit ADD PRIMARY KEY (owner_id, currency);
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint
+                   WHERE conrelid = 'deposit'::regclass AND conname = 'chk_reserved_within_balance') THEN
+        ALTER TABLE Deposit ADD CONSTRAINT chk_reserved_within_balance CHECK(reserved >= 0 AND reserved <= balance);
+    END IF;
+END;
+$$;
+
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS reservation_id BIGINT NULL;
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS service_id BIGINT NULL;
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS order_id BIGINT NULL;
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS exchange_rate VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS rate_id BIGINT NULL;
+ALTER TABLE Transaction ADD COLUMN IF NOT EXISTS refund_of BIGINT NULL;
+
+/* the tables and indexes added since the first postgres-init.sql, the same as there */
+CREATE TABLE IF NOT EXISTS Deposit_Status_Change(
+    id bigserial PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    previous_status VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_status_change_owner ON Deposit_Status_Change(owner_id, currency);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_service_revenue ON Transaction(transaction_date, service_id)
+    WHERE service_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transaction_sender_history ON Transaction(sender_id, transaction_date, id);
+CREATE INDEX IF NOT EXISTS idx_transaction_recipient_history ON Transaction(recipient_id, transaction_date, id);
+CREATE INDEX IF NOT EXISTS idx_transaction_sender_balance ON Transaction(sender_id, currency, id);
+CREATE INDEX IF NOT EXISTS idx_transaction_recipient_balance ON Transaction(recipient_id, currency, id);
+CREATE INDEX IF NOT EXISTS idx_transaction_refund_of ON Transaction(refund_of) WHERE refund_of IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS Rate(
+    id bigserial PRIMARY KEY,
+    source VARCHAR(32) NOT NULL,
+    fetched_at TIMESTAMP NOT NULL,
+    rates JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_fetched_at ON Rate(fetched_at);
+
+CREATE TABLE IF NOT EXISTS Balance_Snapshot(
+    owner_id UUID NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'RUB',
+    transaction_id BIGINT NOT NULL,
+    transaction_date TIMESTAMP NOT NULL,
+    balance BIGINT NOT NULL,
+
+    PRIMARY KEY (owner_id, currency, transaction_id)
+);
+
+CREATE TABLE IF NOT EXISTS Reservation(
+    id bigserial PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    amount BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    description VARCHAR(100) NULL,
+    service_id BIGINT NULL,
+    order_id BIGINT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT chk_reservation_amount_positive
+    CHECK(amount > 0)
+);
+
+CREATE TABLE IF NOT EXISTS Idempotency_Key(
+    key VARCHAR(255) PRIMARY KEY,
+    fingerprint CHAR(64) NOT NULL,
+    transaction_id BIGINT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Journal_Entry(
+    id bigserial PRIMARY KEY,
+    transaction_id BIGINT NULL,
+    description VARCHAR(100) NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Posting(
+    id bigserial PRIMARY KEY,
+    entry_id BIGINT NOT NULL,
+    account VARCHAR(64) NOT NULL,
+    amount BIGINT NOT NULL,
+
+    CONSTRAINT chk_posting_amount_not_zero
+    CHECK(amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_posting_account ON Posting(account);
+CREATE INDEX IF NOT EXISTS idx_posting_entry_id ON Posting(entry_id);
+
+/* every journal entry must be balanced by the end of the DB transaction */
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+DECLARE
+    entry BIGINT := COALESCE(NEW.entry_id, OLD.entry_id);
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM Posting WHERE entry_id = entry) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', entry;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_journal_entry_balanced ON Posting;
+CREATE CONSTRAINT TRIGGER trg_journal_entry_balanced
+    AFTER INSERT OR UPDATE OR DELETE ON Posting
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE PROCEDURE check_journal_entry_balanced();
+
+CREATE TABLE IF NOT EXISTS Outbox_Event(
+    id bigserial PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    dispatched_at TIMESTAMP NULL,
+    claimed_until TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_event_owner_pending ON Outbox_Event(owner_id, id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS Webhook_Subscription(
+    id bigserial PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    owner_id UUID NULL,
+    event_type VARCHAR(20) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Webhook_Delivery(
+    id bigserial PRIMARY KEY,
+    subscription_id BIGINT NOT NULL,
+    event_id BIGINT NOT NULL,
+    owner_id UUID NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    claimed_until TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_queue ON Webhook_Delivery(subscription_id, owner_id, id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_delivery_event ON Webhook_Delivery(subscription_id, event_id);
+
+CREATE TABLE IF NOT EXISTS Webhook_Dead_Letter(
+    id bigserial PRIMARY KEY,
+    subscription_id BIGINT NOT NULL,
+    event_id BIGINT NOT NULL,
+    owner_id UUID NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    replayed_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letter_subscription_id ON Webhook_Dead_Letter(subscription_id);
+
+INSERT INTO Schema_Migration (name, applied_at) VALUES ('000_schema_upgrade', now()) ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,48 @@
+/* Amounts of money were stored in whole units of their currency and are stored in minor units (kopecks, cents) since
+   this migration. It converts a database created by an earlier postgres-init.sql after 000_schema_upgrade and is
+   applied only once:
+   psql "$APP_DSN" -f migrations/001_amounts_in_minor_units.sql
+   Databases created by the current postgres-init.sql are already marked as migrated. */
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS Schema_Migration(
+    name VARCHAR(64) PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+);
+
+/* the number of minor units in a whole unit of the currency, the same as in pkg/money */
+CREATE FUNCTION pg_temp.minor_units(currency VARCHAR) RETURNS BIGINT AS $$
+    SELECT CASE
+        WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV',
+                          'XAF', 'XOF', 'XPF') THEN 1
+        WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1000
+        ELSE 100
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM Schema_Migration WHERE name = '001_amounts_in_minor_units') THEN
+        RAISE NOTICE 'migration 001_amounts_in_minor_units is already applied';
+        RETURN;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM Schema_Migration WHERE name = '000_schema_upgrade') THEN
+        RAISE EXCEPTION 'migration 000_schema_upgrade must be applied first';
+    END IF;
+
+    UPDATE Deposit SET balance = balance * pg_temp.minor_units(currency),
+                       reserved = reserved * pg_temp.minor_units(currency);
+    UPDATE Transaction SET amount = amount * pg_temp.minor_units(currency);
+    UPDATE Balance_Snapshot SET balance = balance * pg_temp.minor_units(currency);
+    /* reservations are made in RUB only */
+    UPDATE Reservation SET amount = amount * pg_temp.minor_units('RUB');
+    /* accounts in currencies other than RUB are suffixed with the currency code, e.g. "user:<uuid>:USD",
+       the postings of a journal entry stay balanced as they are all in the same currency */
+    UPDATE Posting SET amount = amount * pg_temp.minor_units(COALESCE(NULLIF(split_part(account, ':', 3), ''), 'RUB'));
+
+    INSERT INTO Schema_Migration (name, applied_at) VALUES ('001_amounts_in_minor_units', now());
+END;
+$$;
+
+COMMIT;
@@ -0,0 +1,12 @@
+/* Idempotency keys expire after idempotency_ttl and are deleted periodically by their creation time. The migration
+   adds the index used for that and is applied after 001_amounts_in_minor_units:
+   psql "$APP_DSN" -f migrations/002_idempotency_key_expiry.sql
+   Databases created by the current postgres-init.sql are already marked as migrated. */
+
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_key_created_at ON Idempotency_Key(created_at);
+
+INSERT INTO Schema_Migration (name, applied_at) VALUES ('002_idempotency_key_expiry', now()) ON CONFLICT DO NOTHING;
+
+COMMIT;
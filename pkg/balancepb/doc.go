@@ -0,0 +1,4 @@
+// Package balancepb contains the gRPC API of the service generated from balance.proto.
+package balancepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative balance.proto
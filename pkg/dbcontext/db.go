@@ -4,6 +4,7 @@ package dbcontext
 
 import (
 	"context"
+	"database/sql"
 
 	dbx "github.com/go-ozzo/ozzo-dbx"
 	routing "github.com/go-ozzo/ozzo-routing/v2"
@@ -51,6 +52,15 @@ func (db *DB) Transactional(ctx context.Context, f func(ctx context.Context) err
 	})
 }
 
+// RepeatableRead starts a read-only transaction with the REPEATABLE READ isolation level and calls the given function
+// with a context storing the transaction, so that all queries of the function see the same snapshot of the database.
+func (db *DB) RepeatableRead(ctx context.Context, f func(ctx context.Context) error) error {
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	return db.db.TransactionalContext(ctx, opts, func(tx *dbx.Tx) error {
+		return f(context.WithValue(ctx, txKey, tx))
+	})
+}
+
 // TransactionHandler returns a middleware that starts a transaction.
 // The transaction started is kept in the context and can be accessed via With().
 func (db *DB) TransactionHandler() routing.Handler {
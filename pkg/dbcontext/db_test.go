@@ -64,6 +64,30 @@ func TestDB_Transactional(t *testing.T) {
 	})
 }
 
+func TestDB_RepeatableRead(t *testing.T) {
+	runDBTest(t, func(db *dbx.DB) {
+		dbc := New(db)
+		err := dbc.RepeatableRead(context.Background(), func(ctx context.Context) error {
+			var count int
+			assert.NoError(t, dbc.With(ctx).Select("COUNT(*)").From("dbcontexttest").Row(&count))
+			assert.Zero(t, count)
+
+			// a row committed after the first query is not seen
+			_, err := dbc.With(context.Background()).Insert("dbcontexttest", dbx.Params{"id": "1", "name": "name1"}).Execute()
+			assert.NoError(t, err)
+			assert.NoError(t, dbc.With(ctx).Select("COUNT(*)").From("dbcontexttest").Row(&count))
+			assert.Zero(t, count)
+
+			// the transaction is read-only
+			_, err = dbc.With(ctx).Insert("dbcontexttest", dbx.Params{"id": "2", "name": "name2"}).Execute()
+			assert.Error(t, err)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, runCountQuery(t, db))
+	})
+}
+
 func TestDB_TransactionHandler(t *testing.T) {
 	runDBTest(t, func(db *dbx.DB) {
 		assert.Zero(t, runCountQuery(t, db))
@@ -0,0 +1,279 @@
+// Package money provides an exact representation of amounts of money in minor units of a currency.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Rounding is a rule of rounding an amount which does not fit into the minor units of a currency.
+type Rounding string
+
+const (
+	// HalfUp rounds to the nearest minor unit, a half is rounded away from zero. Used by default.
+	HalfUp Rounding = "half_up"
+	// HalfEven rounds to the nearest minor unit, a half is rounded to the even neighbour (banker's rounding).
+	HalfEven Rounding = "half_even"
+	// Down rounds toward zero, i.e. drops the extra digits.
+	Down Rounding = "down"
+)
+
+var (
+	// ErrInvalidAmount is returned when a decimal string cannot be parsed as an amount of a currency.
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrOverflow is returned when an amount does not fit into int64 minor units.
+	ErrOverflow = errors.New("amount overflow")
+)
+
+// scales lists the number of digits of minor units of the ISO 4217 currencies which differ from the usual 2.
+var scales = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Scale returns the number of digits of minor units of the currency, e.g. 2 for RUB and 0 for JPY.
+func Scale(currency string) int {
+	if scale, ok := scales[strings.ToUpper(currency)]; ok {
+		return scale
+	}
+	return 2
+}
+
+// Money is an amount of money in minor units of a currency, e.g. 12345 of RUB with scale 2 is 123.45 rubles.
+// The zero value is zero of an unknown currency.
+type Money struct {
+	amount   int64
+	currency string
+	scale    int
+}
+
+// New creates Money from an amount in minor units of the currency.
+func New(amount int64, currency string) Money {
+	currency = strings.ToUpper(currency)
+	return Money{amount: amount, currency: currency, scale: Scale(currency)}
+}
+
+// FromUnits creates Money from an amount in major units of the currency, e.g. whole rubles.
+// ErrOverflow is returned if the amount does not fit into int64 minor units.
+func FromUnits(units int64, currency string) (Money, error) {
+	m := New(0, currency)
+	factor := pow10(m.scale)
+	if units > math.MaxInt64/factor || units < math.MinInt64/factor {
+		return Money{}, ErrOverflow
+	}
+	m.amount = units * factor
+	return m, nil
+}
+
+// Parse parses a decimal string, e.g. "-123.45" or "1.5e2", as an amount of the currency. The string must not have
+// more significant fractional digits than the currency has minor units, so that no rounding happens implicitly.
+func Parse(s, currency string) (Money, error) {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if s, err = expand(s[:i], s[i+1:]); err != nil {
+			return Money{}, err
+		}
+	}
+
+	m := New(0, currency)
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+		if fraction == "" || strings.ContainsAny(fraction, "+-") {
+			return Money{}, ErrInvalidAmount
+		}
+	}
+	if len(fraction) > m.scale || whole == "" || whole == "-" || whole == "+" {
+		return Money{}, ErrInvalidAmount
+	}
+
+	digits := whole + fraction + strings.Repeat("0", m.scale-len(fraction))
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return Money{}, ErrOverflow
+	} else if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	m.amount = amount
+	return m, nil
+}
+
+// maxExponent limits the exponent of amounts in exponential notation, no amount with a larger one fits into int64.
+const maxExponent = 64
+
+// expand converts the mantissa and the exponent of a number in exponential notation, e.g. JSON number 1.5E2,
+// into a decimal string without trailing fractional zeros, e.g. "150".
+func expand(mantissa, exponent string) (string, error) {
+	e, err := strconv.Atoi(exponent)
+	if err != nil {
+		return "", ErrInvalidAmount
+	}
+	if e > maxExponent {
+		return "", ErrOverflow
+	} else if e < -maxExponent {
+		return "", ErrInvalidAmount
+	}
+
+	sign := ""
+	if mantissa != "" && (mantissa[0] == '-' || mantissa[0] == '+') {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	whole, fraction := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		whole, fraction = mantissa[:i], mantissa[i+1:]
+		if fraction == "" {
+			return "", ErrInvalidAmount
+		}
+	}
+	digits := whole + fraction
+	if whole == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", ErrInvalidAmount
+	}
+
+	// move the decimal point by the exponent
+	point := len(whole) + e
+	if point <= 0 {
+		digits, point = strings.Repeat("0", 1-point)+digits, 1
+	} else if point > len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	whole, fraction = strings.TrimLeft(digits[:point], "0"), strings.TrimRight(digits[point:], "0")
+	if whole == "" {
+		whole = "0"
+	}
+	if fraction == "" {
+		return sign + whole, nil
+	}
+	return sign + whole + "." + fraction, nil
+}
+
+// Amount returns the amount in minor units.
+func (m Money) Amount() int64 {
+	return m.amount
+}
+
+// Units returns the amount in whole major units, the fraction is dropped.
+func (m Money) Units() int64 {
+	return m.amount / pow10(m.scale)
+}
+
+// Currency returns the ISO 4217 code of the currency.
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// Scale returns the number of digits of minor units.
+func (m Money) Scale() int {
+	return m.scale
+}
+
+// String returns the exact decimal representation of the amount with all digits of minor units, e.g. "123.40".
+func (m Money) String() string {
+	if m.scale == 0 {
+		return strconv.FormatInt(m.amount, 10)
+	}
+
+	digits := strconv.FormatInt(m.amount, 10)
+	sign := ""
+	if m.amount < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= m.scale {
+		digits = strings.Repeat("0", m.scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-m.scale] + "." + digits[len(digits)-m.scale:]
+}
+
+// Number returns the exact amount as a JSON number without trailing zeros, e.g. 123.4.
+func (m Money) Number() json.Number {
+	s := m.String()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return json.Number(s)
+}
+
+// Rat returns the amount in major units as a rational number.
+func (m Money) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(m.amount), big.NewInt(pow10(m.scale)))
+}
+
+// Convert converts the amount to the currency at the given rate (the price of a major unit of m's currency
+// in major units of the target currency). The result is rounded to the minor units of the target currency.
+func (m Money) Convert(rate *big.Rat, currency string, rounding Rounding) (Money, error) {
+	result := New(0, currency)
+	value := new(big.Rat).Mul(m.Rat(), rate)
+	value.Mul(value, new(big.Rat).SetInt64(pow10(result.scale)))
+
+	amount, err := round(value, rounding)
+	if err != nil {
+		return Money{}, err
+	}
+	result.amount = amount
+	return result, nil
+}
+
+// Rate converts an exchange rate to a rational number by its shortest decimal representation, so that e.g. 0.013
+// stays exactly 0.013 instead of the closest binary fraction.
+func Rate(rate float32) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(float64(rate), 'g', -1, 32))
+	return r
+}
+
+// money is the JSON representation of Money.
+type money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes Money as an object with the amount as an exact decimal string, e.g.
+// {"amount":"123.45","currency":"RUB"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(money{Amount: m.String(), Currency: m.currency})
+}
+
+// UnmarshalJSON decodes Money from the representation made by MarshalJSON.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v money
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := Parse(v.Amount, v.Currency)
+	if err != nil {
+		return fmt.Errorf("%w: %q", err, v.Amount)
+	}
+	*m = parsed
+	return nil
+}
+
+// round rounds the rational number to an integer by the rule.
+func round(value *big.Rat, rounding Rounding) (int64, error) {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if remainder.Sign() != 0 && rounding != Down {
+		// compare the dropped fraction with a half: |2 * remainder| vs denominator
+		half := new(big.Int).Abs(remainder)
+		half.Lsh(half, 1)
+		cmp := half.Cmp(value.Denom())
+		if cmp > 0 || cmp == 0 && (rounding != HalfEven || quotient.Bit(0) == 1) {
+			quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return quotient.Int64(), nil
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScale(t *testing.T) {
+	assert.Equal(t, 2, Scale("RUB"))
+	assert.Equal(t, 2, Scale("usd"))
+	assert.Equal(t, 0, Scale("JPY"))
+	assert.Equal(t, 3, Scale("KWD"))
+	assert.Equal(t, "RUB", New(0, "rub").Currency())
+}
+
+func TestMoney_Units(t *testing.T) {
+	assert.Equal(t, int64(123), New(12399, "RUB").Units())
+	assert.Equal(t, int64(-123), New(-12399, "RUB").Units())
+	assert.Equal(t, int64(1000), New(1000, "JPY").Units())
+	assert.Equal(t, int64(1000), units(1000, "KWD").Units())
+}
+
+func TestFromUnits(t *testing.T) {
+	m, err := FromUnits(-1000, "KWD")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(-1000000), m.Amount())
+	}
+	m, err = FromUnits(math.MaxInt64/100, "RUB")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "92233720368547758.00", m.String())
+	}
+	m, err = FromUnits(math.MaxInt64, "JPY")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(math.MaxInt64), m.Amount())
+	}
+
+	// the amount would wrap around and change its sign
+	_, err = FromUnits(math.MaxInt64/100+1, "RUB")
+	assert.Equal(t, ErrOverflow, err)
+	_, err = FromUnits(math.MinInt64/100-1, "RUB")
+	assert.Equal(t, ErrOverflow, err)
+	_, err = FromUnits(math.MaxInt64/1000+1, "KWD")
+	assert.Equal(t, ErrOverflow, err)
+}
+
+func TestMoney_String(t *testing.T) {
+	tests := []struct {
+		money  Money
+		string string
+		number json.Number
+	}{
+		{New(12345, "RUB"), "123.45", "123.45"},
+		{New(12340, "RUB"), "123.40", "123.4"},
+		{New(-5, "RUB"), "-0.05", "-0.05"},
+		{New(0, "RUB"), "0.00", "0"},
+		{units(1000, "RUB"), "1000.00", "1000"},
+		{New(1000, "JPY"), "1000", "1000"},
+		{New(-1, "KWD"), "-0.001", "-0.001"},
+		{New(9223372036854775807, "RUB"), "92233720368547758.07", "92233720368547758.07"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.string, tc.money.String())
+		assert.Equal(t, tc.number, tc.money.Number())
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s, currency string
+		amount      int64
+		err         error
+	}{
+		{"123.45", "RUB", 12345, nil},
+		{"123.4", "RUB", 12340, nil},
+		{"123", "rub", 12300, nil},
+		{"-0.05", "RUB", -5, nil},
+		{"1000", "JPY", 1000, nil},
+		{"0.001", "KWD", 1, nil},
+		{"123.456", "RUB", 0, ErrInvalidAmount},
+		{"1.5", "JPY", 0, ErrInvalidAmount},
+		{"", "RUB", 0, ErrInvalidAmount},
+		{".5", "RUB", 0, ErrInvalidAmount},
+		{"5.", "RUB", 0, ErrInvalidAmount},
+		{"1.-5", "RUB", 0, ErrInvalidAmount},
+		{"1e2", "RUB", 10000, nil},
+		{"1.5E1", "RUB", 1500, nil},
+		{"-1.05e+1", "RUB", -1050, nil},
+		{"1.50e-1", "RUB", 15, nil},
+		{"25E-3", "KWD", 25, nil},
+		{"1e-100", "RUB", 0, ErrInvalidAmount},
+		{"1.05e-1", "RUB", 0, ErrInvalidAmount},
+		{"1.5e0", "JPY", 0, ErrInvalidAmount},
+		{"1e", "RUB", 0, ErrInvalidAmount},
+		{"e5", "RUB", 0, ErrInvalidAmount},
+		{"1.e5", "RUB", 0, ErrInvalidAmount},
+		{"1e-5e1", "RUB", 0, ErrInvalidAmount},
+		{"1e17", "RUB", 0, ErrOverflow},
+		{"1e100", "RUB", 0, ErrOverflow},
+		{"92233720368547758.08", "RUB", 0, ErrOverflow},
+	}
+	for _, tc := range tests {
+		m, err := Parse(tc.s, tc.currency)
+		assert.Equal(t, tc.err, err, tc.s)
+		if err == nil {
+			assert.Equal(t, tc.amount, m.Amount(), tc.s)
+			assert.Equal(t, Scale(tc.currency), m.Scale(), tc.s)
+		}
+	}
+}
+
+func TestMoney_Convert(t *testing.T) {
+	tests := []struct {
+		name     string
+		money    Money
+		rate     *big.Rat
+		currency string
+		rounding Rounding
+		want     string
+	}{
+		{"exact", units(1000, "RUB"), Rate(0.013), "USD", HalfUp, "13.00"},
+		{"float rate has no noise", New(12345, "RUB"), Rate(0.1), "USD", HalfUp, "12.35"},
+		{"half up", New(125, "RUB"), big.NewRat(1, 10), "USD", HalfUp, "0.13"},
+		{"half up negative", New(-125, "RUB"), big.NewRat(1, 10), "USD", HalfUp, "-0.13"},
+		{"half even down", New(125, "RUB"), big.NewRat(1, 10), "USD", HalfEven, "0.12"},
+		{"half even up", New(135, "RUB"), big.NewRat(1, 10), "USD", HalfEven, "0.14"},
+		{"half even above half", New(1251, "RUB"), big.NewRat(1, 100), "USD", HalfEven, "0.13"},
+		{"down", New(129, "RUB"), big.NewRat(1, 10), "USD", Down, "0.12"},
+		{"down negative", New(-129, "RUB"), big.NewRat(1, 10), "USD", Down, "-0.12"},
+		{"default is half up", New(125, "RUB"), big.NewRat(1, 10), "USD", "", "0.13"},
+		{"to zero scale", units(1000, "RUB"), Rate(1.5), "JPY", HalfUp, "1500"},
+		{"to three digits scale", units(1000, "RUB"), Rate(0.0041), "KWD", HalfUp, "4.100"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := tc.money.Convert(tc.rate, tc.currency, tc.rounding)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.want, m.String())
+				assert.Equal(t, tc.currency, m.Currency())
+			}
+		})
+	}
+
+	_, err := New(9223372036854775807, "RUB").Convert(big.NewRat(2, 1), "USD", HalfUp)
+	assert.Equal(t, ErrOverflow, err)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(New(-12340, "RUB"))
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"amount":"-123.40","currency":"RUB"}`, string(data))
+	}
+
+	var m Money
+	if assert.NoError(t, json.Unmarshal([]byte(`{"amount":"7.5","currency":"USD"}`), &m)) {
+		assert.Equal(t, New(750, "USD"), m)
+	}
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"7.555","currency":"USD"}`), &m), ErrInvalidAmount)
+}
+
+// units creates Money from an amount in major units which is known to fit.
+func units(units int64, currency string) Money {
+	m, err := FromUnits(units, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
@@ -1,10 +1,22 @@
 INSERT INTO transaction (sender_id, recipient_id, amount, description, transaction_date)
-VALUES (NULL, '11111111-3a7a-4d5e-8a6c-febc8c5b3f13', 2000, 'VISA TRANSFER', now()),
-       (NULL, '22222222-3a7a-4d5e-8a6c-febc8c5b3f13', 3590, 'MASTERCARD TRANSFER', now() - interval '13s'),
-       (NULL, '33333333-3a7a-4d5e-8a6c-febc8c5b3f13', 150, 'PROMO', now() - interval '25s'),
-       ('22222222-3a7a-4d5e-8a6c-febc8c5b3f13', '11111111-3a7a-4d5e-8a6c-febc8c5b3f13', 1000, 'FOR DINNER', now() - interval '33s');
+VALUES (NULL, '11111111-3a7a-4d5e-8a6c-febc8c5b3f13', 200000, 'VISA TRANSFER', now()),
+       (NULL, '22222222-3a7a-4d5e-8a6c-febc8c5b3f13', 359000, 'MASTERCARD TRANSFER', now() - interval '13s'),
+       (NULL, '33333333-3a7a-4d5e-8a6c-febc8c5b3f13', 15000, 'PROMO', now() - interval '25s'),
+       ('22222222-3a7a-4d5e-8a6c-febc8c5b3f13', '11111111-3a7a-4d5e-8a6c-febc8c5b3f13', 100000, 'FOR DINNER', now() - interval '33s');
 
 INSERT INTO deposit (owner_id, balance)
-VALUES ('11111111-3a7a-4d5e-8a6c-febc8c5b3f13', 3000),
-       ('22222222-3a7a-4d5e-8a6c-febc8c5b3f13', 2590),
-       ('33333333-3a7a-4d5e-8a6c-febc8c5b3f13', 150);
+VALUES ('11111111-3a7a-4d5e-8a6c-febc8c5b3f13', 300000),
+       ('22222222-3a7a-4d5e-8a6c-febc8c5b3f13', 259000),
+       ('33333333-3a7a-4d5e-8a6c-febc8c5b3f13', 15000);
+
+WITH entry AS (
+    INSERT INTO journal_entry (transaction_id, description, created_at)
+    SELECT id, description, transaction_date FROM transaction
+    RETURNING id, transaction_id
+)
+INSERT INTO posting (entry_id, account, amount)
+SELECT entry.id, COALESCE('user:' || t.sender_id, 'system:cash_in'), -t.amount
+FROM entry JOIN transaction t ON t.id = entry.transaction_id
+UNION ALL
+SELECT entry.id, COALESCE('user:' || t.recipient_id, 'system:cash_out'), t.amount
+FROM entry JOIN transaction t ON t.id = entry.transaction_id;